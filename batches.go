@@ -0,0 +1,215 @@
+package ocigenai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/batch"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Limits of the batches listed by GET /batches, as in the OpenAI API.
+const (
+	defaultBatchListLimit = 20
+	maxBatchListLimit     = 100
+)
+
+// batchOwnerKey is the context key of the owner of a batch, set on the requests the batch sends.
+type batchOwnerKey struct{}
+
+// batchRoute returns the resource ("files" or "batches"), ID and action of a Files or Batch API
+// request, or an empty resource for other requests. Paths end with "/files" or "/batches",
+// optionally followed by an ID and an action: "/files/{id}/content" or "/batches/{id}/cancel".
+func batchRoute(path string) (resource, id, action string) {
+	for _, name := range []string{"files", "batches"} {
+		i := strings.LastIndex(path, "/"+name)
+		if i < 0 {
+			continue
+		}
+		rest := path[i+len(name)+1:]
+		if rest == "" {
+			return name, "", ""
+		}
+		if rest[0] != '/' {
+			continue
+		}
+		parts := strings.Split(rest[1:], "/")
+		switch len(parts) {
+		case 1:
+			return name, parts[0], ""
+		case 2:
+			return name, parts[0], parts[1]
+		}
+	}
+	return "", "", ""
+}
+
+// serveBatches serves the OpenAI Files and Batch APIs. Files and batches are only visible to the
+// client key that created them.
+func (p *Proxy) serveBatches(rw http.ResponseWriter, req *http.Request, resource, id, action string) {
+	owner := p.clientKey(req)
+
+	switch {
+	case resource == "files" && id == "" && req.Method == http.MethodPost:
+		p.uploadFile(rw, req, owner)
+	case resource == "files" && id == "" && req.Method == http.MethodGet:
+		list, err := p.batches.Files(owner, req.URL.Query().Get("purpose"))
+		p.writeBatchResult(rw, list, err)
+	case resource == "files" && action == "" && req.Method == http.MethodGet:
+		file, err := p.batches.File(owner, id)
+		p.writeBatchResult(rw, file, err)
+	case resource == "files" && action == "content" && req.Method == http.MethodGet:
+		p.downloadFile(rw, owner, id)
+	case resource == "files" && action == "" && req.Method == http.MethodDelete:
+		err := p.batches.DeleteFile(owner, id)
+		p.writeBatchResult(rw, types.FileDeleted{ID: id, Object: "file", Deleted: true}, err)
+	case resource == "batches" && id == "" && req.Method == http.MethodPost:
+		var createReq types.BatchCreateRequest
+		if err := json.NewDecoder(req.Body).Decode(&createReq); err != nil {
+			writeError(rw, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse batch request")
+			return
+		}
+		created, err := p.batches.Create(owner, createReq)
+		p.writeBatchResult(rw, created, err)
+	case resource == "batches" && id == "" && req.Method == http.MethodGet:
+		limit, err := batchListLimit(req.URL.Query().Get("limit"))
+		if err != nil {
+			writeError(rw, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+			return
+		}
+		list, err := p.batches.Batches(owner, req.URL.Query().Get("after"), limit)
+		p.writeBatchResult(rw, list, err)
+	case resource == "batches" && action == "" && req.Method == http.MethodGet:
+		found, err := p.batches.Batch(owner, id)
+		p.writeBatchResult(rw, found, err)
+	case resource == "batches" && action == "cancel" && req.Method == http.MethodPost:
+		cancelled, err := p.batches.Cancel(owner, id)
+		p.writeBatchResult(rw, cancelled, err)
+	default:
+		writeError(rw, http.StatusNotFound, "invalid_request_error", "", "Invalid URL ("+req.Method+" "+req.URL.Path+")")
+	}
+}
+
+// uploadFile stores the file of a multipart upload.
+func (p *Proxy) uploadFile(rw http.ResponseWriter, req *http.Request, owner string) {
+	// The limit leaves room for the other parts of the form; the manager enforces the file size
+	req.Body = http.MaxBytesReader(rw, req.Body, p.current().config.Batches.MaxFileBytes+1<<20)
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse multipart upload")
+		return
+	}
+	defer func() { _ = req.MultipartForm.RemoveAll() }()
+
+	part, header, err := req.FormFile("file")
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "invalid_request_error", "", "Missing file")
+		return
+	}
+	defer part.Close()
+
+	file, err := p.batches.CreateFile(owner, header.Filename, req.FormValue("purpose"), part)
+	p.writeBatchResult(rw, file, err)
+}
+
+// downloadFile writes the content of a file.
+func (p *Proxy) downloadFile(rw http.ResponseWriter, owner, id string) {
+	content, file, err := p.batches.OpenFile(owner, id)
+	if err != nil {
+		p.writeBatchResult(rw, nil, err)
+		return
+	}
+	defer content.Close()
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.Header().Set("Content-Length", strconv.FormatInt(file.Bytes, 10))
+	rw.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(rw, content); err != nil {
+		p.logger.Warn("failed to write file content", "file", id, "error", err)
+	}
+}
+
+// writeBatchResult writes v, or the OpenAI error matching err.
+func (p *Proxy) writeBatchResult(rw http.ResponseWriter, v interface{}, err error) {
+	switch {
+	case err == nil:
+		writeJSON(rw, http.StatusOK, v)
+	case errors.Is(err, batch.ErrNotFound):
+		writeError(rw, http.StatusNotFound, "invalid_request_error", "", "No such object")
+	case errors.Is(err, batch.ErrTooLarge):
+		writeError(rw, http.StatusRequestEntityTooLarge, "invalid_request_error", "", "File exceeds the maximum size of "+strconv.FormatInt(p.current().config.Batches.MaxFileBytes, 10)+" bytes")
+	case errors.Is(err, batch.ErrInvalid):
+		writeError(rw, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+	default:
+		p.logger.Error("batch request failed", "error", err)
+		writeError(rw, http.StatusInternalServerError, "server_error", "", "Internal error")
+	}
+}
+
+// batchListLimit parses the limit of a batch list.
+func batchListLimit(value string) (int, error) {
+	if value == "" {
+		return defaultBatchListLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxBatchListLimit {
+		return 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxBatchListLimit))
+	}
+	return limit, nil
+}
+
+// isBatchRequest reports whether a request is sent by a batch.
+func isBatchRequest(req *http.Request) bool {
+	_, ok := req.Context().Value(batchOwnerKey{}).(string)
+	return ok
+}
+
+// executeBatchRequest sends a request of a batch through the plugin, as if its owner had sent it,
+// so that batches are translated, authenticated, rate limited and metered like other requests.
+func (p *Proxy) executeBatchRequest(ctx context.Context, owner, url string, body []byte) batch.Response {
+	rw := &bufferedResponse{header: http.Header{}}
+
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, batchOwnerKey{}, owner), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "server_error", "", err.Error())
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		p.ServeHTTP(rw, req)
+	}
+	return batch.Response{Status: rw.Status(), Header: rw.header, Body: rw.body.Bytes()}
+}
+
+// bufferedResponse is an http.ResponseWriter keeping the response of a batch request in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Status returns the status of the response.
+func (b *bufferedResponse) Status() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
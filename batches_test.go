@@ -0,0 +1,234 @@
+package ocigenai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// newBatchProxy starts the plugin with the Files and Batch APIs enabled, running one request at a
+// time so that scripted replies are consumed in line order.
+func newBatchProxy(t *testing.T) *testProxy {
+	t.Helper()
+	return newTestProxy(t, func(cfg *config.Config) {
+		cfg.Batches.Enabled = true
+		cfg.Batches.Path = t.TempDir()
+		cfg.Batches.Concurrency = 1
+	})
+}
+
+// upload uploads content as a batch input file with key.
+func (tp *testProxy) upload(t *testing.T, key, content string) (*http.Response, []byte) {
+	t.Helper()
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	if err := writer.WriteField("purpose", "batch"); err != nil {
+		t.Fatal(err)
+	}
+	part, err := writer.CreateFormFile("file", "requests.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tp.server.URL+"/v1/files", &form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+key)
+	return tp.do(t, req)
+}
+
+// get sends a GET request to the plugin with key.
+func (tp *testProxy) get(t *testing.T, key, path string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, tp.server.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	return tp.do(t, req)
+}
+
+// awaitBatch polls a batch until it has ended.
+func (tp *testProxy) awaitBatch(t *testing.T, id string) types.Batch {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, body := tp.get(t, "sk-test", "/v1/batches/"+id)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+		var batch types.Batch
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Fatal(err)
+		}
+		switch batch.Status {
+		case "completed", "failed", "expired", "cancelled":
+			return batch
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch %s did not end, status %s", id, batch.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestProxy_Batch(t *testing.T) {
+	tp := newBatchProxy(t)
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.Reply{}, ocitest.ErrorReply(http.StatusBadRequest, "InvalidParameter", "Unknown model"))
+
+	resp, body := tp.upload(t, "sk-test",
+		`{"custom_id":"first","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4","messages":[{"role":"user","content":"Hello there"}]}}`+"\n"+
+			`{"custom_id":"second","method":"POST","url":"/v1/chat/completions","body":{"model":"unknown","messages":[{"role":"user","content":"Hi"}]}}`+"\n")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var file types.File
+	if err := json.Unmarshal(body, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Purpose != "batch" || file.Filename != "requests.jsonl" || file.Bytes == 0 {
+		t.Errorf("unexpected file: %s", body)
+	}
+
+	resp, body = tp.post(t, "/v1/batches", `{"input_file_id":"`+file.ID+`","endpoint":"/v1/chat/completions","completion_window":"24h","metadata":{"job":"nightly"}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var created types.Batch
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != "validating" || created.Metadata["job"] != "nightly" {
+		t.Errorf("unexpected batch: %s", body)
+	}
+
+	batch := tp.awaitBatch(t, created.ID)
+	if batch.Status != "completed" || batch.RequestCounts.Total != 2 || batch.RequestCounts.Completed != 1 || batch.RequestCounts.Failed != 1 {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+	if batch.OutputFileID == nil || batch.ErrorFileID == nil {
+		t.Fatalf("expected output and error files, got %+v", batch)
+	}
+
+	// Batch requests are signed and sent to OCI like any other request
+	verifyAuthHeaders(t, tp.lastForwarded(t))
+	if len(tp.genai.Requests(ocitest.ChatAction)) != 2 {
+		t.Errorf("expected 2 chat requests, got %d", len(tp.genai.Requests(ocitest.ChatAction)))
+	}
+
+	resp, body = tp.get(t, "sk-test", "/v1/files/"+*batch.OutputFileID+"/content")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	results := readResults(t, body)
+	if len(results) != 1 || results[0].CustomID != "first" || results[0].Response == nil || results[0].Response.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected output file: %s", body)
+	}
+	var completion types.ChatCompletionResponse
+	if err := json.Unmarshal(results[0].Response.Body, &completion); err != nil {
+		t.Fatal(err)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "Echo: Hello there" {
+		t.Errorf("unexpected completion: %s", results[0].Response.Body)
+	}
+
+	resp, body = tp.get(t, "sk-test", "/v1/files/"+*batch.ErrorFileID+"/content")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	results = readResults(t, body)
+	if len(results) != 1 || results[0].CustomID != "second" || results[0].Response == nil || results[0].Response.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected error file: %s", body)
+	}
+
+	resp, body = tp.get(t, "sk-test", "/v1/batches?limit=1")
+	var list types.BatchList
+	if err := json.Unmarshal(body, &list); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected batch list (%d): %s", resp.StatusCode, body)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != created.ID || list.HasMore {
+		t.Errorf("unexpected batch list: %s", body)
+	}
+}
+
+func TestProxy_BatchOwnership(t *testing.T) {
+	tp := newBatchProxy(t)
+
+	resp, body := tp.upload(t, "sk-test", `{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"cohere.embed-english-v3.0","input":"Hi"}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var file types.File
+	if err := json.Unmarshal(body, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files are only visible to the key that uploaded them
+	if resp, body := tp.get(t, "sk-other", "/v1/files/"+file.ID); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for another key, got %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := tp.get(t, "sk-other", "/v1/files"); resp.StatusCode != http.StatusOK || bytes.Contains(body, []byte(file.ID)) {
+		t.Errorf("expected the file not to be listed for another key, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = tp.post(t, "/v1/batches", `{"input_file_id":"`+file.ID+`","endpoint":"/v1/responses","completion_window":"24h"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unsupported endpoint, got %d: %s", resp.StatusCode, body)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, tp.server.URL+"/v1/files/"+file.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer sk-test")
+	if resp, body := tp.do(t, req); resp.StatusCode != http.StatusOK || !bytes.Contains(body, []byte(`"deleted":true`)) {
+		t.Errorf("expected the file to be deleted, got %d: %s", resp.StatusCode, body)
+	}
+	if resp, _ := tp.get(t, "sk-test", "/v1/files/"+file.ID); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 after deletion, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxy_BatchDisabled(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	if resp, _ := tp.get(t, "sk-test", "/v1/batches"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the request to be passed through, got %d", resp.StatusCode)
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if len(tp.forwarded) != 1 {
+		t.Errorf("expected the request to reach the next handler")
+	}
+}
+
+// readResults decodes the lines of a batch output or error file.
+func readResults(t *testing.T, body []byte) []types.BatchResultLine {
+	t.Helper()
+	var results []types.BatchResultLine
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var result types.BatchResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("invalid result line %q: %v", scanner.Text(), err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,221 @@
+// Command ocigenai runs the OCI GenAI proxy plugin as a standalone reverse proxy.
+//
+// It serves exactly the routes of the Traefik middleware: OpenAI requests are translated,
+// signed and forwarded to the OCI GenAI inference endpoint, and the responses translated back.
+// Configuration is read from a YAML or JSON file and OCIGENAI_* environment variables.
+//
+// The sign subcommand signs an arbitrary request with the plugin's signing code and prints it as
+// a curl command or sends it, to diagnose signature mismatches.
+//
+// Usage:
+//
+//	ocigenai -config config.yaml [-listen :8080] [-tls-cert cert.pem -tls-key key.pem]
+//	ocigenai sign [-X POST] [-H "Name: value"] [-d body.json] [-auth api_key] [-exec] [-v] URL
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/zalbiraw/ocigenai"
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// envPrefix is the prefix of the environment variables overriding the configuration file.
+const envPrefix = "OCIGENAI"
+
+// fileConfig is the layout of the configuration file: the plugin configuration at the top
+// level, as in the Traefik dynamic configuration, plus a server section.
+type fileConfig struct {
+	Server         serverConfig `json:"server"`
+	*config.Config              // Plugin configuration
+}
+
+// serverConfig configures the HTTP server of the binary.
+type serverConfig struct {
+	// Listen is the address to listen on
+	Listen string `json:"listen,omitempty"`
+
+	// TLSCert and TLSKey are the paths of the PEM certificate and key; TLS is enabled when both are set
+	TLSCert string `json:"tlsCert,omitempty"`
+	TLSKey  string `json:"tlsKey,omitempty"`
+
+	// ShutdownTimeout bounds how long in-flight requests may take to complete on shutdown
+	ShutdownTimeout string `json:"shutdownTimeout,omitempty"`
+
+	// Name is the instance name used in logs and metrics
+	Name string `json:"name,omitempty"`
+}
+
+func main() {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "sign" {
+		err = runSign(os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+	} else {
+		err = run(os.Args[1:])
+	}
+	if err != nil {
+		log.Fatalf("ocigenai: %v", err)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("ocigenai", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path of the YAML or JSON configuration file")
+	listen := flags.String("listen", "", "address to listen on, overrides server.listen")
+	tlsCert := flags.String("tls-cert", "", "path of the TLS certificate, overrides server.tlsCert")
+	tlsKey := flags.String("tls-key", "", "path of the TLS key, overrides server.tlsKey")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fc, err := loadConfig(*configPath, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if *listen != "" {
+		fc.Server.Listen = *listen
+	}
+	if *tlsCert != "" {
+		fc.Server.TLSCert = *tlsCert
+	}
+	if *tlsKey != "" {
+		fc.Server.TLSKey = *tlsKey
+	}
+	if (fc.Server.TLSCert == "") != (fc.Server.TLSKey == "") {
+		return errors.New("both a TLS certificate and key are required to enable TLS")
+	}
+	shutdownTimeout, err := time.ParseDuration(fc.Server.ShutdownTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid server shutdownTimeout: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The plugin's own context outlives the signal context so that it is only closed once
+	// in-flight requests have drained.
+	pluginCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler, err := ocigenai.New(pluginCtx, newUpstream(), fc.Config, fc.Server.Name)
+	if err != nil {
+		return err
+	}
+
+	var draining atomic.Bool
+	server := &http.Server{
+		Addr:              fc.Server.Listen,
+		Handler:           newMux(handler, &draining),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("ocigenai: listening on %s", fc.Server.Listen)
+		if fc.Server.TLSCert != "" {
+			errs <- server.ListenAndServeTLS(fc.Server.TLSCert, fc.Server.TLSKey)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+		log.Printf("ocigenai: shutting down")
+		draining.Store(true)
+
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down: %w", err)
+		}
+	}
+
+	// Flush usage records and spans before exiting
+	if closer, ok := handler.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// loadConfig reads the configuration file, if any, and applies the environment overrides on
+// top of the defaults.
+func loadConfig(path string, lookup func(string) (string, bool)) (*fileConfig, error) {
+	fc := &fileConfig{
+		Server: serverConfig{
+			Listen:          ":8080",
+			ShutdownTimeout: "30s",
+			Name:            "ocigenai",
+		},
+		Config: ocigenai.CreateConfig(),
+	}
+
+	if path != "" {
+		if err := config.DecodeFile(path, fc); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.ApplyEnv(fc, envPrefix, lookup); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+
+	return fc, nil
+}
+
+// newMux serves the health endpoints and hands every other request to the plugin.
+// /healthz reports that the process is alive, /readyz that it accepts new requests.
+func newMux(handler http.Handler, draining *atomic.Bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(rw, "ok\n")
+	})
+	mux.HandleFunc("/readyz", func(rw http.ResponseWriter, _ *http.Request) {
+		if draining.Load() {
+			http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(rw, "ok\n")
+	})
+	mux.Handle("/", handler)
+	return mux
+}
+
+// newUpstream returns the handler the plugin forwards signed requests to. The plugin has
+// already pointed them at the OCI endpoint, so the request URL is used as is. Requests the
+// plugin passed through without routing have no upstream and are rejected.
+func newUpstream() http.Handler {
+	proxy := &httputil.ReverseProxy{
+		// Keep the URL and Host header set by the plugin, which are covered by the signature
+		Director: func(*http.Request) {},
+		// Stream server-sent events to the client as they arrive
+		FlushInterval: -1,
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Host == "" {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(rw, `{"error":{"message":"Not found","type":"invalid_request_error"}}`)
+			return
+		}
+		proxy.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	doc := "server:\n  listen: 127.0.0.1:9000\ncompartmentId: ocid1.compartment.oc1..file\nmaxTokens: 100\n"
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"OCIGENAI_MAX_TOKENS":              "200",
+		"OCIGENAI_SERVER_SHUTDOWN_TIMEOUT": "5s",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	fc, err := loadConfig(path, lookup)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if fc.Server.Listen != "127.0.0.1:9000" {
+		t.Errorf("expected listen 127.0.0.1:9000, got %s", fc.Server.Listen)
+	}
+	if fc.Server.ShutdownTimeout != "5s" {
+		t.Errorf("expected shutdownTimeout 5s, got %s", fc.Server.ShutdownTimeout)
+	}
+	if fc.CompartmentID != "ocid1.compartment.oc1..file" {
+		t.Errorf("expected compartmentId from file, got %s", fc.CompartmentID)
+	}
+	if fc.MaxTokens != 200 {
+		t.Errorf("expected maxTokens 200 from env, got %d", fc.MaxTokens)
+	}
+	if fc.Logging.Level != "info" {
+		t.Errorf("expected default logging level, got %s", fc.Logging.Level)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	var draining atomic.Bool
+	mux := newMux(newUpstream(), &draining)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		if rw.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, rw.Code)
+		}
+	}
+
+	draining.Store(true)
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while draining, got %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/unknown", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unrouted request, got %d", rw.Code)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/ocisdk"
+)
+
+// headerFlags collects repeated -H flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("header %q must have the form \"Name: value\"", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// runSign implements the sign subcommand: it signs a hand-made request with the same code as the
+// plugin, then prints it as a curl command or sends it.
+func runSign(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("ocigenai sign", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ocigenai sign [flags] URL")
+		flags.PrintDefaults()
+	}
+
+	var headers headerFlags
+	method := flags.String("X", "", "request method, defaults to POST with a body and GET otherwise")
+	flags.Var(&headers, "H", "request header as \"Name: value\", may be repeated")
+	bodyFile := flags.String("d", "", "path of the request body, - for stdin")
+	auth := flags.String("auth", ocisdk.AuthInstancePrincipal, "authentication method: instance_principal, api_key or security_token")
+	configFile := flags.String("oci-config", ocisdk.DefaultConfigFile, "OCI CLI configuration file for api_key and security_token")
+	profile := flags.String("profile", "DEFAULT", "profile of the OCI CLI configuration file")
+	execute := flags.Bool("exec", false, "send the request and print the response instead of a curl command")
+	verbose := flags.Bool("v", false, "print the body hash and signing string to stderr")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one URL")
+	}
+
+	var body []byte
+	if *bodyFile != "" {
+		var err error
+		if *bodyFile == "-" {
+			body, err = io.ReadAll(stdin)
+		} else {
+			body, err = os.ReadFile(*bodyFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+	}
+	if *method == "" {
+		*method = http.MethodGet
+		if *bodyFile != "" {
+			*method = http.MethodPost
+		}
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(*method), flags.Arg(0), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	for _, header := range headers {
+		name, value, _ := strings.Cut(header, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if *bodyFile != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	provider, err := ocisdk.Provider(*auth, *configFile, *profile, ocisdk.Key{})
+	if err != nil {
+		return fmt.Errorf("failed to create %s provider: %w", *auth, err)
+	}
+	authenticator := ocisdk.NewWithProvider(provider)
+	if err := authenticator.SignRequest(req); err != nil {
+		return err
+	}
+
+	if *verbose {
+		fmt.Fprintf(stderr, "x-content-sha256: %s\n", req.Header.Get("X-Content-Sha256"))
+		fmt.Fprintf(stderr, "signing string:\n%s\n", authenticator.SigningString(req))
+		fmt.Fprintf(stderr, "authorization: %s\n\n", req.Header.Get("Authorization"))
+	}
+
+	if !*execute {
+		_, err := io.WriteString(stdout, curlCommand(req, *bodyFile, body)+"\n")
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if *verbose {
+		fmt.Fprintf(stderr, "%s %s\n", resp.Proto, resp.Status)
+		for _, name := range sortedKeys(resp.Header) {
+			for _, value := range resp.Header[name] {
+				fmt.Fprintf(stderr, "%s: %s\n", name, value)
+			}
+		}
+		fmt.Fprintln(stderr)
+	}
+	if _, err := io.Copy(stdout, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("request failed with status %s", resp.Status)
+	}
+	return nil
+}
+
+// curlCommand renders a signed request as an equivalent curl command.
+func curlCommand(req *http.Request, bodyFile string, body []byte) string {
+	parts := []string{"curl", "-X", req.Method, shellQuote(req.URL.String())}
+	for _, name := range sortedKeys(req.Header) {
+		for _, value := range req.Header[name] {
+			parts = append(parts, "-H", shellQuote(name+": "+value))
+		}
+	}
+
+	switch {
+	case bodyFile != "" && bodyFile != "-":
+		parts = append(parts, "--data-binary", shellQuote("@"+bodyFile))
+	case len(body) > 0:
+		parts = append(parts, "--data-binary", shellQuote(string(body)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sortedKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// writeAPIKeyConfig writes an OCI CLI configuration file with an API key profile.
+func writeAPIKeyConfig(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	dir := t.TempDir()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(dir, "config")
+	config := fmt.Sprintf("[DEFAULT]\nuser=ocid1.user.oc1..user\nfingerprint=aa:bb\ntenancy=ocid1.tenancy.oc1..tenancy\nregion=us-chicago-1\nkey_file=%s\n", keyPath)
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return configPath, key
+}
+
+var signatureParams = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// verifySignature checks the Authorization header of a received request against the public key.
+func verifySignature(req *http.Request, body []byte, key *rsa.PublicKey) error {
+	params := map[string]string{}
+	for _, match := range signatureParams.FindAllStringSubmatch(req.Header.Get("Authorization"), -1) {
+		params[match[1]] = match[2]
+	}
+	if params["keyId"] != "ocid1.tenancy.oc1..tenancy/ocid1.user.oc1..user/aa:bb" {
+		return fmt.Errorf("unexpected keyId %q", params["keyId"])
+	}
+
+	sum := sha256.Sum256(body)
+	if req.Header.Get("X-Content-Sha256") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return fmt.Errorf("body hash mismatch")
+	}
+
+	var lines []string
+	for _, header := range strings.Fields(params["headers"]) {
+		switch header {
+		case "(request-target)":
+			lines = append(lines, header+": "+strings.ToLower(req.Method)+" "+req.URL.RequestURI())
+		case "host":
+			lines = append(lines, header+": "+req.Host)
+		default:
+			lines = append(lines, header+": "+req.Header.Get(header))
+		}
+	}
+	hashed := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature)
+}
+
+func TestSign_Exec(t *testing.T) {
+	configPath, key := writeAPIKeyConfig(t)
+	body := []byte(`{"compartmentId":"ocid1.compartment.oc1..example"}`)
+	bodyPath := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(bodyPath, body, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received, _ := io.ReadAll(req.Body)
+		if err := verifySignature(req, received, &key.PublicKey); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(rw, `{"ok":true}`)
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-auth", "api_key", "-oci-config", configPath, "-d", bodyPath, "-exec", "-v", server.URL + "/20231130/actions/chat"}
+	if err := runSign(args, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("failed to send signed request: %v: %s", err, stdout.String())
+	}
+
+	if stdout.String() != `{"ok":true}` {
+		t.Errorf("unexpected response: %s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "(request-target): post /20231130/actions/chat") {
+		t.Errorf("expected signing string in verbose output, got %s", stderr.String())
+	}
+}
+
+func TestSign_Curl(t *testing.T) {
+	configPath, _ := writeAPIKeyConfig(t)
+
+	var stdout bytes.Buffer
+	args := []string{"-auth", "api_key", "-oci-config", configPath, "-H", "opc-request-id: abc", "-d", "-", "https://inference.example.com/20231130/actions/chat"}
+	if err := runSign(args, strings.NewReader(`{"it's":1}`), &stdout, io.Discard); err != nil {
+		t.Fatalf("failed to sign request: %v", err)
+	}
+
+	command := stdout.String()
+	for _, expected := range []string{
+		"curl -X POST 'https://inference.example.com/20231130/actions/chat'",
+		"-H 'Opc-Request-Id: abc'",
+		"-H 'Authorization: Signature version=\"1\"",
+		"-H 'X-Content-Sha256: ",
+		`--data-binary '{"it'\''s":1}'`,
+	} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("expected %q in %s", expected, command)
+		}
+	}
+}
+
+func TestSign_UnknownAuth(t *testing.T) {
+	err := runSign([]string{"-auth", "password", "https://example.com"}, nil, io.Discard, io.Discard)
+	if err == nil {
+		t.Error("expected error for unsupported authentication method")
+	}
+}
@@ -0,0 +1,229 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/internal/ratelimit"
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// isCompletionRequest reports whether a request is a legacy text completion request, a POST to
+// a path ending with "/completions" other than the chat completions path.
+func isCompletionRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/completions") &&
+		!strings.HasSuffix(req.URL.Path, "/chat/completions")
+}
+
+// serveCompletion proxies a legacy OpenAI text completion request. Models served by the OCI
+// generateText action receive the prompt as is; other models receive it as a single-turn chat.
+// The request is rate limited, traced, metered and counted in the metrics like a chat request
+// holding the prompt, but it is not cached.
+func (p *Proxy) serveCompletion(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "text_completion", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "text_completion")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		ctx:       req.Context(),
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	completionReq, err := p.parseCompletionRequest(req)
+	if err != nil {
+		p.logger.Warn("failed to parse completion request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse completion request")
+		return
+	}
+	prompt, err := ex.settings.transformer.CompletionPrompt(completionReq)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	p.logger.Debug("completion request parsed", "model", completionReq.Model, "stream", completionReq.Stream)
+	span.SetName("text_completion " + completionReq.Model)
+
+	// The request is checked in the order of chat requests: parameter policies, input guardrails
+	// and moderation, context window and rate limits
+	transformer := p.policyTransformer(ex)
+	runtime := transform.TextRuntime(completionReq.Model)
+	var generateReq types.GenerateTextRequest
+	var oracleReq types.OracleCloudRequest
+	texts, storeTexts := []*string{&generateReq.InferenceRequest.Prompt}, func() {}
+	if runtime != "" {
+		generateReq = transformer.ToGenerateTextRequest(completionReq, prompt)
+	} else {
+		oracleReq = transformer.CompletionToOracleCloudRequest(completionReq, prompt)
+		texts, storeTexts = chatRequestTexts(&oracleReq)
+	}
+	if !p.enforcePolicies(rw, ex, transformer) {
+		return
+	}
+	if !p.guardInput(rw, ex, texts) || !p.moderateInput(rw, ex, texts) {
+		return
+	}
+	storeTexts()
+
+	// The prompt, masked, is the only text of the request
+	for _, text := range texts {
+		if *text != "" {
+			prompt = *text
+		}
+	}
+	ex.request = transform.CompletionChatRequest(completionReq, prompt)
+
+	var body []byte
+	path := chatActionPath
+	if runtime != "" {
+		ex.apiFormat = runtime
+		span.SetAttribute("gen_ai.request.model", completionReq.Model)
+		path = generateTextActionPath
+		if !p.fitPrompt(rw, ex, generateReq) {
+			return
+		}
+		body, err = json.Marshal(generateReq)
+	} else {
+		if !p.fitContext(rw, ex, &oracleReq) {
+			return
+		}
+		ex.apiFormat = oracleReq.ChatRequest.APIFormat
+		setRequestAttributes(span, oracleReq)
+		body, err = json.Marshal(oracleReq)
+	}
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return
+	}
+
+	// The estimate is taken on the request sent to OCI, whose max_tokens the policies clamped
+	tokens := ratelimit.EstimateTokens(oracleReq.ServingMode.ModelID, oracleReq.ChatRequest)
+	if runtime != "" {
+		inference := generateReq.InferenceRequest
+		tokens = models.CountTokens(generateReq.ServingMode.ModelID, inference.Prompt) + inference.MaxTokens
+	}
+	if !p.reserveTokens(rw, ex, tokens) {
+		return
+	}
+	if err := p.prepareOCIRequest(req, ex, body, path); err != nil {
+		ex.reservation.Release()
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return
+	}
+
+	recorder := newResponseRecorder(rw, p.guardStreams(ex, func() streamTranslator {
+		ex.translateSpan = ex.span.Child("response-translate", tracing.SpanKindInternal)
+		return completionStream{ex.settings.transformer.NewCompletionStream(completionReq, prompt)}
+	}))
+	p.forward(recorder, req, parent, span)
+
+	p.respondCompletion(rw, ex, recorder, completionReq, prompt)
+	p.complete(ex, recorder)
+}
+
+// parseCompletionRequest reads the request body and decodes the text completion request.
+func (p *Proxy) parseCompletionRequest(req *http.Request) (types.CompletionRequest, error) {
+	var completionReq types.CompletionRequest
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return completionReq, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return completionReq, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming completion request", "body", p.logger.Body(body))
+	}
+
+	err = json.Unmarshal(body, &completionReq)
+	return completionReq, err
+}
+
+// respondCompletion sends the OCI response recorded from the next handler to the client as a
+// text completion.
+func (p *Proxy) respondCompletion(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder, completionReq types.CompletionRequest, prompt string) {
+	if recorder.stream != nil {
+		p.closeStream(ex, recorder)
+		return
+	}
+
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+
+	var resp types.CompletionResponse
+	if transform.TextRuntime(completionReq.Model) != "" {
+		var generateTextResp types.GenerateTextResponse
+		if !p.decodeResponse(rw, ex, recorder, translateSpan, &generateTextResp) {
+			return
+		}
+		resp = ex.settings.transformer.GenerateTextToCompletion(generateTextResp, completionReq.Model)
+	} else {
+		var oracleResp types.OracleCloudResponse
+		if !p.decodeResponse(rw, ex, recorder, translateSpan, &oracleResp) {
+			return
+		}
+		resp = ex.settings.transformer.ChatToCompletion(oracleResp, completionReq.Model, prompt, completionReq.Echo)
+	}
+
+	ex.responseID = resp.ID
+	if len(resp.Choices) > 0 && resp.Choices[0].FinishReason != nil {
+		ex.finishReason = *resp.Choices[0].FinishReason
+	}
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+// completionStream streams OpenAI text completion chunks.
+type completionStream struct {
+	*transform.CompletionStream
+}
+
+func (s completionStream) translate(data []byte) ([]byte, error) {
+	chunks, err := s.Event(data)
+	if err != nil {
+		return nil, err
+	}
+	var events []byte
+	for _, chunk := range chunks {
+		if events, err = appendDataEvent(events, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (s completionStream) finish() ([]byte, error) {
+	var events []byte
+	var err error
+	for _, chunk := range s.Finish() {
+		if events, err = appendDataEvent(events, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return append(events, "data: [DONE]\n\n"...), nil
+}
+
+func (s completionStream) summary() (string, string) {
+	return s.ID(), s.FinishReason()
+}
@@ -0,0 +1,214 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// decodeCompletionChunks decodes the chunks of a streamed text completion, checking that the
+// stream ends with [DONE].
+func decodeCompletionChunks(t *testing.T, body []byte) []types.CompletionResponse {
+	t.Helper()
+	events := readEvents(t, body)
+	if len(events) == 0 || events[len(events)-1] != "[DONE]" {
+		t.Fatalf("expected the stream to end with [DONE], got %v", events)
+	}
+
+	var chunks []types.CompletionResponse
+	for _, event := range events[:len(events)-1] {
+		var chunk types.CompletionResponse
+		if err := json.Unmarshal([]byte(event), &chunk); err != nil {
+			t.Fatalf("failed to decode chunk %s: %v", event, err)
+		}
+		if chunk.Object != "text_completion" {
+			t.Errorf("unexpected chunk %s", event)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestProxy_CompletionGenerateText(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/completions", `{"model":"cohere.command","prompt":"Hello, world!","n":2,"stop":"\n"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	if forwarded.URL.Path != "/20231130/actions/generateText" {
+		t.Errorf("expected request to be routed to the generateText action, got %s", forwarded.URL.Path)
+	}
+	var generateTextReq types.GenerateTextRequest
+	if err := tp.genai.Requests(ocitest.GenerateTextAction)[0].Decode(&generateTextReq); err != nil {
+		t.Fatal(err)
+	}
+	inference := generateTextReq.InferenceRequest
+	if inference.RuntimeType != "COHERE" || inference.Prompt != "Hello, world!" || inference.NumGenerations != 2 {
+		t.Errorf("unexpected inference request %+v", inference)
+	}
+	if len(inference.StopSequences) != 1 || inference.StopSequences[0] != "\n" {
+		t.Errorf("expected the stop sequence to be forwarded, got %q", inference.StopSequences)
+	}
+
+	var completion types.CompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if completion.Object != "text_completion" || completion.Model != "cohere.command" || !strings.HasPrefix(completion.ID, "cmpl-") {
+		t.Errorf("unexpected completion: %s", body)
+	}
+	if len(completion.Choices) != 2 {
+		t.Fatalf("expected 2 choices, got %s", body)
+	}
+	for i, choice := range completion.Choices {
+		if choice.Index != i || choice.Text != "Echo: Hello, world!" || choice.FinishReason == nil || *choice.FinishReason != "stop" {
+			t.Errorf("unexpected choice %d: %+v", i, choice)
+		}
+	}
+}
+
+func TestProxy_CompletionChat(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/completions", `{"model":"meta.llama-3.1-70b-instruct","prompt":["Hello"],"echo":true,"temperature":0}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	requests := tp.genai.Requests(ocitest.ChatAction)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 chat request, got %d", len(requests))
+	}
+	var oracleReq types.OracleCloudRequest
+	if err := requests[0].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	if oracleReq.ChatRequest.APIFormat != "GENERIC" || len(oracleReq.ChatRequest.Messages) != 1 || oracleReq.ChatRequest.Temperature != 0 {
+		t.Errorf("expected a single-turn GENERIC chat request with the explicit temperature, got %+v", oracleReq.ChatRequest)
+	}
+
+	var completion types.CompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(completion.Choices) != 1 || completion.Choices[0].Text != "HelloEcho: Hello" {
+		t.Errorf("expected the echoed prompt and the completion, got %s", body)
+	}
+	if completion.Usage == nil || completion.Usage.TotalTokens == 0 {
+		t.Errorf("expected the chat usage to be reported, got %s", body)
+	}
+}
+
+func TestProxy_CompletionChatStream(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/completions", `{"model":"gpt-4","prompt":"Hello","echo":true,"stream":true,"stream_options":{"include_usage":true}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	// Echoed prompt, one chunk per word, finish reason and usage
+	chunks := decodeCompletionChunks(t, body)
+	if len(chunks) != 5 {
+		t.Fatalf("expected 5 chunks, got %s", body)
+	}
+	var text strings.Builder
+	for _, chunk := range chunks[:3] {
+		text.WriteString(chunk.Choices[0].Text)
+	}
+	if text.String() != "HelloEcho: Hello" {
+		t.Errorf("expected streamed text %q, got %q", "HelloEcho: Hello", text.String())
+	}
+	if reason := chunks[3].Choices[0].FinishReason; reason == nil || *reason != "stop" {
+		t.Errorf("expected finish reason stop, got %+v", chunks[3])
+	}
+	if len(chunks[4].Choices) != 0 || chunks[4].Usage == nil {
+		t.Errorf("expected a usage chunk, got %+v", chunks[4])
+	}
+}
+
+func TestProxy_CompletionGenerateTextStream(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/completions", `{"model":"cohere.command-light","prompt":"Hi","max_tokens":1,"stream":true}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	chunks := decodeCompletionChunks(t, body)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %s", body)
+	}
+	if chunks[0].Choices[0].Text != "Echo: " || chunks[0].Choices[0].FinishReason != nil {
+		t.Errorf("unexpected text chunk %+v", chunks[0])
+	}
+	if reason := chunks[1].Choices[0].FinishReason; reason == nil || *reason != "length" {
+		t.Errorf("expected finish reason length, got %+v", chunks[1])
+	}
+}
+
+func TestProxy_CompletionInvalidRequests(t *testing.T) {
+	tests := map[string]string{
+		"token prompt":         `{"model":"gpt-4","prompt":[1,2,3]}`,
+		"several prompts":      `{"model":"gpt-4","prompt":["a","b"]}`,
+		"n without generation": `{"model":"gpt-4","prompt":"a","n":2}`,
+		"invalid json":         `{"model":`,
+	}
+
+	tp := newTestProxy(t, nil)
+	for name, request := range tests {
+		resp, body := tp.post(t, "/v1/completions", request)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), `"invalid_request_error"`) {
+			t.Errorf("%s: expected an invalid request error, got %d: %s", name, resp.StatusCode, body)
+		}
+	}
+	if len(tp.forwarded) != 0 {
+		t.Error("expected no request to be forwarded")
+	}
+}
+
+func TestProxy_CompletionChecksOrder(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Policies = []config.ParameterPolicy{{Name: "no-penalty", Forbid: []string{"presence_penalty"}}}
+		cfg.Guardrails.Rules = []config.GuardrailRule{
+			{Name: "blocklist", Type: "keywords", Keywords: []string{"forbidden"}},
+			{Name: "pii", Type: "pii", Action: "mask"},
+		}
+	})
+
+	// Parameter policies are enforced before the guardrails, as for chat
+	resp, body := tp.post(t, "/v1/completions", `{"model":"cohere.command","prompt":"The forbidden plan","presence_penalty":1}`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "unsupported_parameter") {
+		t.Errorf("expected the policy to reject the request first, got %d: %s", resp.StatusCode, body)
+	}
+
+	// The masked prompt is sent, to generateText and to chat
+	resp, body = tp.post(t, "/v1/completions", `{"model":"cohere.command","prompt":"Email jane@example.com"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var generateTextReq types.GenerateTextRequest
+	if err := tp.genai.Requests(ocitest.GenerateTextAction)[0].Decode(&generateTextReq); err != nil {
+		t.Fatal(err)
+	}
+	if prompt := generateTextReq.InferenceRequest.Prompt; prompt != "Email [EMAIL]" {
+		t.Errorf("expected the masked prompt, got %q", prompt)
+	}
+
+	resp, body = tp.post(t, "/v1/completions", `{"model":"meta.llama-3.3-70b-instruct","prompt":"Email jane@example.com"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if message := tp.lastOCIRequest(t).ChatRequest.Messages[0].Content[0].Text; message != "Email [EMAIL]" {
+		t.Errorf("expected the masked prompt, got %q", message)
+	}
+}
@@ -0,0 +1,83 @@
+package ocigenai
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// fitContext checks the estimated size of an OCI chat request against the capabilities of its
+// model, when the checks are enabled and the model is known. When truncation is enabled, the
+// oldest turns of conversations are dropped until the request fits. Requests that do
+// not fit are rejected and false is returned.
+func (p *Proxy) fitContext(rw http.ResponseWriter, ex *exchange, oracleReq *types.OracleCloudRequest) bool {
+	if ex.settings.models == nil {
+		return true
+	}
+	model := oracleReq.ServingMode.ModelID
+	capabilities, ok := ex.settings.models.Lookup(model)
+	if !ok {
+		return true
+	}
+
+	chat := &oracleReq.ChatRequest
+	prompt := models.PromptTokens(model, *chat)
+	truncated := 0
+	for ex.settings.config.ContextWindow.Truncate && prompt+chat.MaxTokens > capabilities.ContextWindow {
+		dropped := transform.DropOldestTurn(chat)
+		if dropped == 0 {
+			break
+		}
+		prompt = models.PromptTokens(model, *chat)
+		truncated += dropped
+	}
+	if truncated > 0 {
+		p.logger.Info("conversation truncated to fit the context window", "model", model, "messages", truncated, "key", ex.clientKey)
+		ex.span.SetAttribute("context.truncated_messages", truncated)
+	}
+
+	return p.checkContext(rw, ex, capabilities, prompt, chat.MaxTokens)
+}
+
+// fitPrompt checks the estimated size of the prompt of an OCI generateText request against the
+// capabilities of its model, like fitContext. Prompts are never truncated.
+func (p *Proxy) fitPrompt(rw http.ResponseWriter, ex *exchange, generateReq types.GenerateTextRequest) bool {
+	if ex.settings.models == nil {
+		return true
+	}
+	model := generateReq.ServingMode.ModelID
+	capabilities, ok := ex.settings.models.Lookup(model)
+	if !ok {
+		return true
+	}
+	return p.checkContext(rw, ex, capabilities, models.CountTokens(model, generateReq.InferenceRequest.Prompt), generateReq.InferenceRequest.MaxTokens)
+}
+
+// checkContext rejects requests whose max_tokens exceeds the output limit of the model, or whose
+// estimated prompt tokens and max_tokens exceed its context window, like OpenAI does, and
+// returns false.
+func (p *Proxy) checkContext(rw http.ResponseWriter, ex *exchange, capabilities models.Capabilities, prompt, maxTokens int) bool {
+	ex.span.SetAttribute("context.prompt_tokens", prompt)
+
+	if maxTokens > capabilities.MaxOutputTokens {
+		p.logger.Debug("max_tokens exceeds the output limit", "max_tokens", maxTokens, "limit", capabilities.MaxOutputTokens)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", fmt.Sprintf(
+			"max_tokens is too large: %d. This model supports at most %d completion tokens, whereas you provided %d.",
+			maxTokens, capabilities.MaxOutputTokens, maxTokens))
+		return false
+	}
+
+	if prompt+maxTokens > capabilities.ContextWindow {
+		p.logger.Debug("request exceeds the context window", "prompt_tokens", prompt, "max_tokens", maxTokens, "window", capabilities.ContextWindow)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded", fmt.Sprintf(
+			"This model's maximum context length is %d tokens. However, you requested %d tokens (%d in the messages, %d in the completion). "+
+				"Please reduce the length of the messages or completion.",
+			capabilities.ContextWindow, prompt+maxTokens, prompt, maxTokens))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,102 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// testContextWindow checks the requests to a model with a 200 token window.
+func testContextWindow(cfg *config.Config) {
+	cfg.ContextWindow.Enabled = true
+	cfg.ContextWindow.Models = []config.ModelCapability{{Model: "meta.tiny", ContextWindow: 200, MaxOutputTokens: 100}}
+}
+
+// chatBody returns a chat completion request to meta.tiny holding messages.
+func chatBody(t *testing.T, maxTokens int, messages ...types.ChatCompletionMessage) string {
+	t.Helper()
+	body, err := json.Marshal(types.ChatCompletionRequest{Model: "meta.tiny", MaxTokens: maxTokens, Messages: messages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestProxy_ContextWindow(t *testing.T) {
+	tp := newTestProxy(t, testContextWindow)
+	long := strings.Repeat("word ", 300)
+
+	resp, body := tp.post(t, "/v1/chat/completions", chatBody(t, 50, types.ChatCompletionMessage{Role: "user", Content: long}))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", resp.StatusCode, body)
+	}
+	var apiErr types.ErrorResponse
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if apiErr.Error.Code != "context_length_exceeded" || !strings.Contains(apiErr.Error.Message, "maximum context length is 200 tokens") {
+		t.Errorf("expected a context_length_exceeded error, got %s", body)
+	}
+
+	resp, body = tp.post(t, "/v1/chat/completions", chatBody(t, 150, types.ChatCompletionMessage{Role: "user", Content: "Hello"}))
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "max_tokens is too large") {
+		t.Errorf("expected max_tokens to be rejected, got %d: %s", resp.StatusCode, body)
+	}
+	if requests := tp.genai.Requests(ocitest.ChatAction); len(requests) != 0 {
+		t.Errorf("expected no request to be sent to OCI, got %d", len(requests))
+	}
+
+	// Requests that fit and requests to unknown models are sent
+	if resp, body := tp.post(t, "/v1/chat/completions", chatBody(t, 50, types.ChatCompletionMessage{Role: "user", Content: "Hello"})); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"`+long+`"}]}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_ContextWindowTruncate(t *testing.T) {
+	tp := newTestProxy(t, testContextWindow, func(cfg *config.Config) { cfg.ContextWindow.Truncate = true })
+
+	resp, body := tp.post(t, "/v1/chat/completions", chatBody(t, 50,
+		types.ChatCompletionMessage{Role: "system", Content: "Be brief."},
+		types.ChatCompletionMessage{Role: "user", Content: strings.Repeat("word ", 150)},
+		types.ChatCompletionMessage{Role: "assistant", Content: "Noted."},
+		types.ChatCompletionMessage{Role: "user", Content: "Hello"},
+	))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	messages := tp.lastOCIRequest(t).ChatRequest.Messages
+	if len(messages) != 2 || messages[0].Role != "SYSTEM" || messages[1].Content[0].Text != "Hello" {
+		t.Errorf("expected the oldest turn to be dropped, got %+v", messages)
+	}
+
+	// A last message exceeding the window on its own is still rejected
+	resp, body = tp.post(t, "/v1/chat/completions", chatBody(t, 50, types.ChatCompletionMessage{Role: "user", Content: strings.Repeat("word ", 300)}))
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "context_length_exceeded") {
+		t.Errorf("expected a context_length_exceeded error, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_ContextWindowMasked(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.ContextWindow.Enabled = true
+		cfg.ContextWindow.Models = []config.ModelCapability{{Model: "meta.tiny", ContextWindow: 200, MaxOutputTokens: 100}}
+		cfg.Guardrails.Rules = []config.GuardrailRule{{Name: "dump", Type: "regex", Pattern: `(word )+`, Action: "mask", Mask: "[DUMP]"}}
+	})
+
+	// The request is checked as it is sent to OCI, once masked
+	resp, body := tp.post(t, "/v1/chat/completions", chatBody(t, 50, types.ChatCompletionMessage{Role: "user", Content: strings.Repeat("word ", 300)}))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the masked request to fit, got %d: %s", resp.StatusCode, body)
+	}
+	if message := tp.lastOCIRequest(t).ChatRequest.Messages[0].Content[0].Text; message != "[DUMP]" {
+		t.Errorf("expected the masked message, got %q", message)
+	}
+}
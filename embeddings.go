@@ -0,0 +1,131 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// isEmbeddingsRequest reports whether a request is an OpenAI embeddings request, a POST to a path
+// ending with "/embeddings".
+func isEmbeddingsRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/embeddings")
+}
+
+// serveEmbeddings proxies an OpenAI embeddings request of a batch to the OCI embedText action.
+// There is no public embeddings endpoint: other embeddings requests are passed through. Embeddings
+// requests are rate limited, traced, metered and counted in the metrics under their model, but
+// they are not cached.
+func (p *Proxy) serveEmbeddings(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "embeddings", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "embeddings")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	embeddingReq, err := p.parseEmbeddingRequest(req)
+	if err != nil {
+		p.logger.Warn("failed to parse embeddings request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse embeddings request")
+		return
+	}
+	p.logger.Debug("embeddings request parsed", "model", embeddingReq.Model, "inputs", len(embeddingReq.Input))
+	ex.request.Model = embeddingReq.Model
+	ex.request.User = embeddingReq.User
+	span.SetName("embeddings " + embeddingReq.Model)
+	span.SetAttribute("gen_ai.request.model", embeddingReq.Model)
+
+	embedTextReq, err := ex.target.transformer.EmbeddingsToEmbedTextRequest(embeddingReq)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	recorder, embedTextResp, ok := p.embedText(rw, req, ex, parent, embedTextReq)
+	if ok {
+		writeJSON(rw, http.StatusOK, transform.ToEmbeddingResponse(embedTextResp, embeddingReq))
+	}
+	if recorder != nil {
+		p.complete(ex, recorder)
+	}
+}
+
+// parseEmbeddingRequest reads the request body and decodes the embeddings request.
+func (p *Proxy) parseEmbeddingRequest(req *http.Request) (types.EmbeddingRequest, error) {
+	var embeddingReq types.EmbeddingRequest
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return embeddingReq, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return embeddingReq, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming embeddings request", "body", p.logger.Body(body))
+	}
+
+	err = json.Unmarshal(body, &embeddingReq)
+	return embeddingReq, err
+}
+
+// embedText reserves the estimated tokens of an embedText request against the rate limits, sends
+// it to OCI and decodes the response. Errors are reported to the client, and false returned; the
+// recorder of the upstream response is nil when the request could not be sent.
+func (p *Proxy) embedText(rw http.ResponseWriter, req *http.Request, ex *exchange, parent tracing.SpanContext, embedTextReq types.EmbedTextRequest) (*responseRecorder, types.EmbedTextResponse, bool) {
+	var embedTextResp types.EmbedTextResponse
+
+	tokens := 0
+	for _, input := range embedTextReq.Inputs {
+		tokens += models.CountTokens(ex.request.Model, input)
+	}
+	if !p.reserveTokens(rw, ex, tokens) {
+		return nil, embedTextResp, false
+	}
+
+	body, err := json.Marshal(embedTextReq)
+	if err == nil {
+		err = p.prepareOCIRequest(req, ex, body, embedTextActionPath)
+	}
+	if err != nil {
+		ex.reservation.Release()
+		ex.span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return nil, embedTextResp, false
+	}
+
+	// Embedding responses are never streamed, so the recorder buffers the whole response
+	recorder := newResponseRecorder(rw, nil)
+	p.forward(recorder, req, parent, ex.span)
+
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+	if !p.decodeResponse(rw, ex, recorder, translateSpan, &embedTextResp) {
+		return recorder, embedTextResp, false
+	}
+	ex.responseID = embedTextResp.ID
+	return recorder, embedTextResp, true
+}
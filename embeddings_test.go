@@ -0,0 +1,137 @@
+package ocigenai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/internal/usage"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Embeddings are only served to the requests of batches, so the tests send them through
+// executeBatchRequest.
+func TestProxy_Embeddings(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp := tp.proxy.executeBatchRequest(context.Background(), "sk-test", "/v1/embeddings", []byte(`{"model":"cohere.embed-english-v3.0","input":["Hello","World"]}`))
+	body := resp.Body
+	if resp.Status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Status, body)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	if forwarded.URL.Path != "/20231130/actions/embedText" {
+		t.Errorf("expected request to be routed to the embedText action, got %s", forwarded.URL.Path)
+	}
+	var embedReq types.EmbedTextRequest
+	if err := tp.genai.Requests(ocitest.EmbedTextAction)[0].Decode(&embedReq); err != nil {
+		t.Fatal(err)
+	}
+	if embedReq.CompartmentID != testCompartment || embedReq.ServingMode.ModelID != "cohere.embed-english-v3.0" || len(embedReq.Inputs) != 2 {
+		t.Errorf("unexpected embedText request: %+v", embedReq)
+	}
+
+	var embeddingResp struct {
+		Object string `json:"object"`
+		Model  string `json:"model"`
+		Data   []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage types.EmbeddingUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &embeddingResp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if embeddingResp.Object != "list" || embeddingResp.Model != "cohere.embed-english-v3.0" || len(embeddingResp.Data) != 2 {
+		t.Fatalf("unexpected response: %s", body)
+	}
+	if embeddingResp.Data[1].Index != 1 || len(embeddingResp.Data[1].Embedding) != ocitest.EmbeddingDimensions {
+		t.Errorf("expected an embedding per input, got %s", body)
+	}
+	if embeddingResp.Usage.PromptTokens != 2 || embeddingResp.Usage.TotalTokens != 2 {
+		t.Errorf("expected the usage of the inputs, got %+v", embeddingResp.Usage)
+	}
+}
+
+func TestProxy_EmbeddingsBase64(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp := tp.proxy.executeBatchRequest(context.Background(), "sk-test", "/v1/embeddings", []byte(`{"model":"cohere.embed-english-v3.0","input":"Hello","encoding_format":"base64"}`))
+	body := resp.Body
+	if resp.Status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Status, body)
+	}
+	var embeddingResp struct {
+		Data []struct {
+			Embedding string `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &embeddingResp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(embeddingResp.Data) != 1 {
+		t.Fatalf("expected 1 embedding, got %s", body)
+	}
+	raw, err := base64.StdEncoding.DecodeString(embeddingResp.Data[0].Embedding)
+	if err != nil || len(raw) != 4*ocitest.EmbeddingDimensions {
+		t.Errorf("expected %d base64 float32s, got %s", ocitest.EmbeddingDimensions, body)
+	}
+}
+
+func TestProxy_EmbeddingsInvalidRequest(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp := tp.proxy.executeBatchRequest(context.Background(), "sk-test", "/v1/embeddings", []byte(`{"model":"cohere.embed-english-v3.0"}`))
+	body := resp.Body
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d: %s", resp.Status, body)
+	}
+	if len(tp.genai.Requests(ocitest.EmbedTextAction)) != 0 {
+		t.Error("expected the invalid request not to be forwarded")
+	}
+}
+
+func TestProxy_EmbeddingsRateLimit(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.RateLimit.RequestsPerMinute = 1
+		cfg.Usage.Enabled = true
+	})
+
+	request := []byte(`{"model":"cohere.embed-english-v3.0","input":"Hello"}`)
+	if resp := tp.proxy.executeBatchRequest(context.Background(), "sk-test", "/v1/embeddings", request); resp.Status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	if resp := tp.proxy.executeBatchRequest(context.Background(), "sk-test", "/v1/embeddings", request); resp.Status != http.StatusTooManyRequests {
+		t.Errorf("expected the second request to be rate limited, got %d: %s", resp.Status, resp.Body)
+	}
+	if requests := tp.genai.Requests(ocitest.EmbedTextAction); len(requests) != 1 {
+		t.Errorf("expected only the first request to be sent to OCI, got %d", len(requests))
+	}
+
+	totals := tp.proxy.ledger.Totals(usage.Filter{Model: "cohere.embed-english-v3.0"})
+	if len(totals) != 1 || totals[0].Requests != 1 || totals[0].TotalTokens == 0 {
+		t.Errorf("expected the embeddings request to be metered, got %+v", totals)
+	}
+}
+
+func TestProxy_EmbeddingsNotPublic(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, _ := tp.post(t, "/v1/embeddings", `{"model":"cohere.embed-english-v3.0","input":"Hello"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the next handler's status 404, got %d", resp.StatusCode)
+	}
+	if forwarded := tp.lastForwarded(t); forwarded.URL.Path != "/v1/embeddings" {
+		t.Errorf("expected the request to be passed through unchanged, got %s", forwarded.URL)
+	}
+	if n := len(tp.genai.Requests(ocitest.EmbedTextAction)); n != 0 {
+		t.Errorf("expected no request to OCI, got %d", n)
+	}
+}
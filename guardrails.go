@@ -0,0 +1,372 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zalbiraw/ocigenai/internal/guardrail"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// contentFilter is the OpenAI error code and finish reason of text blocked by a guardrail. It is
+// also understood as an OCI finish reason, so blocked responses are translated to every dialect.
+const contentFilter = "content_filter"
+
+// guardInput applies the input guardrails to the texts of an OCI request, masking them in place.
+// Blocked requests are answered with a content_filter error and false is returned.
+func (p *Proxy) guardInput(rw http.ResponseWriter, ex *exchange, texts []*string) bool {
+	if ex.settings.guardrails == nil {
+		return true
+	}
+
+	for _, text := range texts {
+		result := ex.settings.guardrails.Apply(guardrail.StageInput, *text)
+		*text = result.Text
+		p.reportMatches(ex, guardrail.StageInput, result)
+		if result.Blocked != nil {
+			p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", contentFilter,
+				"The request was blocked by the guardrail rule "+result.Blocked.Rule+".")
+			return false
+		}
+	}
+	return true
+}
+
+// reportMatches logs the rules that matched a text, without the text, and records blocks on the span.
+func (p *Proxy) reportMatches(ex *exchange, stage string, result guardrail.Result) {
+	for _, match := range result.Matches {
+		p.logger.Info("guardrail matched", "stage", stage, "rule", match.Rule, "action", match.Action, "count", match.Count, "key", ex.clientKey)
+	}
+	if result.Blocked != nil {
+		ex.span.SetAttribute("guardrail.blocked", result.Blocked.Rule)
+	}
+}
+
+// chatRequestTexts returns the texts of an OCI chat request: the text parts and tool call
+// arguments of GENERIC messages, or the COHERE preamble, chat history and message, and the RAG
+// documents. The texts held in JSON values, the documents and COHERE tool calls, are copies:
+// store writes them back to the request once they are masked.
+func chatRequestTexts(oracleReq *types.OracleCloudRequest) (texts []*string, store func()) {
+	chat := &oracleReq.ChatRequest
+	texts = []*string{&chat.PreambleOverride, &chat.Message}
+	for i := range chat.Messages {
+		msg := &chat.Messages[i]
+		for k := range msg.Content {
+			if msg.Content[k].Text != "" {
+				texts = append(texts, &msg.Content[k].Text)
+			}
+		}
+		for k := range msg.ToolCalls {
+			texts = append(texts, &msg.ToolCalls[k].Arguments)
+		}
+	}
+
+	var stores []func()
+	addJSON := func(raw *json.RawMessage) {
+		fields, storeFields := jsonTexts(raw)
+		texts = append(texts, fields...)
+		stores = append(stores, storeFields)
+	}
+	for i := range chat.ChatHistory {
+		msg := &chat.ChatHistory[i]
+		texts = append(texts, &msg.Message)
+		for k := range msg.ToolCalls {
+			addJSON(&msg.ToolCalls[k].Parameters)
+		}
+		for k := range msg.ToolResults {
+			addJSON(&msg.ToolResults[k].Call.Parameters)
+			for n := range msg.ToolResults[k].Outputs {
+				addJSON(&msg.ToolResults[k].Outputs[n])
+			}
+		}
+	}
+	for i := range chat.Documents {
+		addJSON(&chat.Documents[i])
+	}
+
+	return texts, func() {
+		for _, store := range stores {
+			store()
+		}
+	}
+}
+
+// jsonTexts returns copies of the string fields of raw, a JSON object, and the function writing
+// them back to raw when they changed. Values other than objects are returned whole.
+func jsonTexts(raw *json.RawMessage) ([]*string, func()) {
+	var object map[string]interface{}
+	if err := json.Unmarshal(*raw, &object); err != nil || object == nil {
+		text := string(*raw)
+		return []*string{&text}, func() {
+			if json.Valid([]byte(text)) {
+				*raw = json.RawMessage(text)
+			}
+		}
+	}
+
+	fields := make(map[string]*string)
+	var texts []*string
+	for name, value := range object {
+		if text, ok := value.(string); ok {
+			fields[name] = &text
+			texts = append(texts, &text)
+		}
+	}
+	return texts, func() {
+		changed := false
+		for name, text := range fields {
+			if object[name] != *text {
+				object[name], changed = *text, true
+			}
+		}
+		if changed {
+			if encoded, err := json.Marshal(object); err == nil {
+				*raw = encoded
+			}
+		}
+	}
+}
+
+// guardOutput applies the output guardrails to a decoded OCI chat or generateText response.
+func (p *Proxy) guardOutput(ex *exchange, v interface{}) {
+	if ex.settings.guardrails == nil || !ex.settings.guardrails.Enabled(guardrail.StageOutput) {
+		return
+	}
+
+	for _, generated := range generatedTexts(v) {
+		result := ex.settings.guardrails.Apply(guardrail.StageOutput, *generated.text)
+		p.reportMatches(ex, guardrail.StageOutput, result)
+		if result.Blocked != nil {
+			generated.withhold()
+			continue
+		}
+		*generated.text = result.Text
+	}
+}
+
+// generatedText is a text generated in an OCI response, and the function withholding its choice:
+// the choice loses its text and tool calls and finishes with content_filter.
+type generatedText struct {
+	text     *string
+	withhold func()
+}
+
+// generatedTexts returns the non-empty texts generated in a decoded OCI chat or generateText response.
+func generatedTexts(v interface{}) []generatedText {
+	var texts []generatedText
+	switch resp := v.(type) {
+	case *types.OracleCloudResponse:
+		chat := &resp.ChatResponse
+		if chat.Text != "" {
+			texts = append(texts, generatedText{&chat.Text, func() {
+				chat.Text = ""
+				chat.FinishReason = contentFilter
+			}})
+		}
+		for i := range chat.Choices {
+			choice := &chat.Choices[i]
+			withhold := func() {
+				choice.Message.Content = nil
+				choice.Message.ToolCalls = nil
+				choice.FinishReason = contentFilter
+			}
+			for k := range choice.Message.Content {
+				if choice.Message.Content[k].Text != "" {
+					texts = append(texts, generatedText{&choice.Message.Content[k].Text, withhold})
+				}
+			}
+		}
+	case *types.GenerateTextResponse:
+		for i := range resp.InferenceResponse.GeneratedTexts {
+			generated := &resp.InferenceResponse.GeneratedTexts[i]
+			if generated.Text == "" {
+				continue
+			}
+			texts = append(texts, generatedText{&generated.Text, func() {
+				generated.Text = ""
+				generated.FinishReason = contentFilter
+			}})
+		}
+		for i := range resp.InferenceResponse.Choices {
+			choice := &resp.InferenceResponse.Choices[i]
+			if choice.Text == "" {
+				continue
+			}
+			texts = append(texts, generatedText{&choice.Text, func() {
+				choice.Text = ""
+				choice.FinishReason = contentFilter
+			}})
+		}
+	}
+	return texts
+}
+
+// guardStreams wraps the stream translators created by newStream so that the output guardrails
+// are applied to the OCI events before they are translated.
+func (p *Proxy) guardStreams(ex *exchange, newStream func() streamTranslator) func() streamTranslator {
+	if ex.settings.guardrails == nil || !ex.settings.guardrails.Enabled(guardrail.StageOutput) {
+		return newStream
+	}
+	return func() streamTranslator {
+		return &guardedStream{streamTranslator: newStream(), proxy: p, ex: ex, guard: ex.settings.guardrails.NewStream()}
+	}
+}
+
+// guardedStream applies the output guardrails to the text of the OCI events of a stream, COHERE,
+// GENERIC or generateText, before they are translated. Text is released once the guardrails have
+// seen enough of what follows it, and the rest is flushed before the finish reason. A blocked
+// stream is closed with a content_filter finish reason and the following events are dropped.
+type guardedStream struct {
+	streamTranslator
+	proxy   *Proxy
+	ex      *exchange
+	guard   *guardrail.Stream
+	generic bool
+	flushed bool
+	blocked bool
+}
+
+func (s *guardedStream) translate(data []byte) ([]byte, error) {
+	if s.blocked {
+		return nil, nil
+	}
+	if s.flushed {
+		// Only usage follows the finish reason
+		return s.streamTranslator.translate(data)
+	}
+
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	if _, ok := event["finishReason"]; ok {
+		// The final COHERE event repeats the whole text, which has already been released
+		delete(event, "text")
+		events, err := s.flush()
+		if err != nil || s.blocked {
+			return events, err
+		}
+		final, err := s.translateEvent(event)
+		return append(events, final...), err
+	}
+
+	if raw, ok := event["message"]; ok {
+		s.generic = true
+		var msg types.GenericMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, err
+		}
+		var text string
+		var content []types.GenericContent
+		for _, part := range msg.Content {
+			if part.Type == "TEXT" {
+				text += part.Text
+				continue
+			}
+			content = append(content, part)
+		}
+		if text != "" {
+			result := s.write(text)
+			if result.Blocked != nil {
+				return s.block()
+			}
+			if result.Text != "" {
+				content = append([]types.GenericContent{{Type: "TEXT", Text: result.Text}}, content...)
+			}
+		}
+		msg.Content = content
+		encoded, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		event["message"] = encoded
+		return s.translateEvent(event)
+	}
+
+	if raw, ok := event["text"]; ok {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, err
+		}
+		result := s.write(text)
+		if result.Blocked != nil {
+			return s.block()
+		}
+		encoded, err := json.Marshal(result.Text)
+		if err != nil {
+			return nil, err
+		}
+		event["text"] = encoded
+	}
+	return s.translateEvent(event)
+}
+
+func (s *guardedStream) finish() ([]byte, error) {
+	var events []byte
+	if !s.flushed && !s.blocked {
+		var err error
+		if events, err = s.flush(); err != nil {
+			return events, err
+		}
+	}
+	closing, err := s.streamTranslator.finish()
+	return append(events, closing...), err
+}
+
+// contentType returns the content type of the wrapped translator's client stream.
+func (s *guardedStream) contentType() string {
+	if typed, ok := s.streamTranslator.(contentTyper); ok {
+		return typed.contentType()
+	}
+	return "text/event-stream"
+}
+
+// write passes generated text to the guardrails and reports the matches.
+func (s *guardedStream) write(text string) guardrail.Result {
+	result := s.guard.Write(text)
+	s.proxy.reportMatches(s.ex, guardrail.StageOutput, result)
+	return result
+}
+
+// flush releases the text held back by the guardrails as a text event.
+func (s *guardedStream) flush() ([]byte, error) {
+	s.flushed = true
+	result := s.guard.Flush()
+	s.proxy.reportMatches(s.ex, guardrail.StageOutput, result)
+	if result.Blocked != nil {
+		return s.block()
+	}
+	if result.Text == "" {
+		return nil, nil
+	}
+	return s.translateEvent(s.textEvent(result.Text))
+}
+
+// block closes the client stream with the content_filter finish reason.
+func (s *guardedStream) block() ([]byte, error) {
+	s.blocked = true
+	reason, err := json.Marshal(contentFilter)
+	if err != nil {
+		return nil, err
+	}
+	return s.translateEvent(map[string]json.RawMessage{"finishReason": reason})
+}
+
+// textEvent returns an OCI event carrying text, in the format of the stream.
+func (s *guardedStream) textEvent(text string) map[string]json.RawMessage {
+	if s.generic {
+		msg, _ := json.Marshal(types.GenericMessage{Role: "ASSISTANT", Content: []types.GenericContent{{Type: "TEXT", Text: text}}})
+		return map[string]json.RawMessage{"message": msg}
+	}
+	encoded, _ := json.Marshal(text)
+	return map[string]json.RawMessage{"text": encoded}
+}
+
+func (s *guardedStream) translateEvent(event map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return s.streamTranslator.translate(data)
+}
@@ -0,0 +1,210 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// testGuardrails sets a blocklist and PII masking on both stages.
+func testGuardrails(cfg *config.Config) {
+	cfg.Guardrails.Rules = []config.GuardrailRule{
+		{Name: "blocklist", Type: "keywords", Keywords: []string{"forbidden"}},
+		{Name: "pii", Type: "pii", Action: "mask"},
+	}
+}
+
+// streamedContent returns the content and the last finish reason of a streamed chat completion.
+func streamedContent(t *testing.T, body []byte) (string, string) {
+	t.Helper()
+	var content strings.Builder
+	var reason string
+	for _, event := range readEvents(t, body) {
+		if event == "[DONE]" {
+			continue
+		}
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(event), &chunk); err != nil {
+			t.Fatalf("failed to decode chunk %s: %v", event, err)
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			if choice.FinishReason != nil {
+				reason = *choice.FinishReason
+			}
+		}
+	}
+	return content.String(), reason
+}
+
+func TestProxy_GuardrailsBlockInput(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"Tell me the Forbidden secret"}]}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", resp.StatusCode, body)
+	}
+	var apiErr types.ErrorResponse
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if apiErr.Error.Code != "content_filter" || !strings.Contains(apiErr.Error.Message, "blocklist") {
+		t.Errorf("expected a content_filter error naming the rule, got %s", body)
+	}
+	if strings.Contains(string(body), "secret") {
+		t.Errorf("expected the prompt not to be echoed, got %s", body)
+	}
+	if requests := tp.genai.Requests(ocitest.ChatAction); len(requests) != 0 {
+		t.Errorf("expected no request to be sent to OCI, got %d", len(requests))
+	}
+}
+
+func TestProxy_GuardrailsMaskInput(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"Email jane@example.com"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	var oracleReq types.OracleCloudRequest
+	if err := tp.genai.Requests(ocitest.ChatAction)[0].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	if oracleReq.ChatRequest.Message != "Email [EMAIL]" {
+		t.Errorf("expected the email to be masked before OCI, got %q", oracleReq.ChatRequest.Message)
+	}
+}
+
+func TestProxy_GuardrailsDocuments(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"cohere.command-r-plus","messages":[{"role":"user","content":"Summarize"}],
+		"documents":[{"title":"notes","text":"The forbidden plan"}]}`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "content_filter") {
+		t.Fatalf("expected a blocked keyword in a document to be rejected, got %d: %s", resp.StatusCode, body)
+	}
+	if requests := tp.genai.Requests(ocitest.ChatAction); len(requests) != 0 {
+		t.Errorf("expected no request to be sent to OCI, got %d", len(requests))
+	}
+
+	resp, body = tp.post(t, "/v1/chat/completions", `{"model":"cohere.command-r-plus","messages":[
+		{"role":"user","content":"Write to jane@example.com"},
+		{"role":"assistant","content":"Done."},
+		{"role":"user","content":"Summarize"}],
+		"documents":["Contact bob@example.com"]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	chatReq := tp.lastOCIRequest(t).ChatRequest
+	if len(chatReq.ChatHistory) != 2 || chatReq.ChatHistory[0].Message != "Write to [EMAIL]" {
+		t.Errorf("expected the email to be masked in the chat history, got %+v", chatReq.ChatHistory)
+	}
+	if len(chatReq.Documents) != 1 || string(chatReq.Documents[0]) != `{"text":"Contact [EMAIL]"}` {
+		t.Errorf("expected the email to be masked in the document, got %s", chatReq.Documents)
+	}
+}
+
+func TestProxy_GuardrailsToolArguments(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"meta.llama-3.3-70b-instruct","messages":[
+		{"role":"user","content":"Look it up"},
+		{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"search","arguments":"{\"q\":\"forbidden\"}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"nothing"}]}`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "content_filter") {
+		t.Fatalf("expected a blocked keyword in tool call arguments to be rejected, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_GuardrailsOutput(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.TextReply("Call 555-123-4567 for help"))
+	resp, body := tp.post(t, "/v1/chat/completions", testRequest)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var completion types.ChatCompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if content := completion.Choices[0].Message.Content; content != "Call [PHONE] for help" {
+		t.Errorf("expected the phone number to be masked, got %v", content)
+	}
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.TextReply("Here is the forbidden answer"))
+	resp, body = tp.post(t, "/v1/chat/completions", testRequest)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	completion = types.ChatCompletionResponse{}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if completion.Choices[0].FinishReason != "content_filter" || strings.Contains(string(body), "answer") {
+		t.Errorf("expected the answer to be withheld with finish reason content_filter, got %s", body)
+	}
+}
+
+func TestProxy_GuardrailsStream(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.TextReply("Write to jane@example.com for the details."))
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"Hello"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	content, reason := streamedContent(t, body)
+	if content != "Write to [EMAIL] for the details." || reason != "stop" {
+		t.Errorf("expected masked content and finish reason stop, got %q and %q", content, reason)
+	}
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.TextReply("Fine so far, then a forbidden word and more text."))
+	resp, body = tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"Hello"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	content, reason = streamedContent(t, body)
+	if reason != "content_filter" || strings.Contains(content, "forbidden") || strings.Contains(content, "more text") {
+		t.Errorf("expected the stream to stop with content_filter, got %q and %q", content, reason)
+	}
+	if events := readEvents(t, body); events[len(events)-1] != "[DONE]" {
+		t.Errorf("expected the stream to end with [DONE], got %v", events)
+	}
+	// GENERIC events carry their text in messages
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.TextReply("Mail jane@example.com today."))
+	resp, body = tp.post(t, "/v1/chat/completions", `{"model":"meta.llama-3.1-70b-instruct","stream":true,"messages":[{"role":"user","content":"Hello"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if content, reason = streamedContent(t, body); content != "Mail [EMAIL] today." || reason != "stop" {
+		t.Errorf("expected masked content and finish reason stop, got %q and %q", content, reason)
+	}
+}
+
+func TestProxy_GuardrailsMessages(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.TextReply("A forbidden reply"))
+	resp, body := tp.post(t, "/v1/messages", `{
+		"model": "meta.llama-3.1-70b-instruct",
+		"max_tokens": 64,
+		"messages": [{"role": "user", "content": "Hello"}]
+	}`, anthropicHeader...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var message types.MessagesResponse
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if message.StopReason == nil || *message.StopReason != "refusal" || strings.Contains(string(body), "reply") {
+		t.Errorf("expected a refusal without the reply, got %s", body)
+	}
+}
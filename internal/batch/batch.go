@@ -0,0 +1,368 @@
+// Package batch implements the OpenAI Files and Batch APIs for the OCI GenAI proxy plugin.
+//
+// Clients upload JSONL files of requests and create batches that execute them asynchronously,
+// with bounded concurrency, through an Executor. Uploaded files, results and the state of every
+// batch are kept in a directory: a batch interrupted by a restart is resumed where it stopped,
+// and replicas sharing the directory coordinate through lock files so that every batch is run
+// by a single instance at a time.
+package batch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/logging"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Errors returned by the manager; errors wrapping ErrInvalid describe what is wrong with the request.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInvalid  = errors.New("invalid request")
+	ErrTooLarge = errors.New("file too large")
+)
+
+// Endpoints are the endpoints batches can send requests to.
+var Endpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"}
+
+// completionWindow is the only completion window supported, as in the OpenAI API.
+const completionWindow = "24h"
+
+// Batch statuses.
+const (
+	StatusValidating = "validating"
+	StatusFailed     = "failed"
+	StatusInProgress = "in_progress"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusExpired    = "expired"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+// Manager stores files and batches and runs the batches.
+type Manager struct {
+	dir          string
+	concurrency  int
+	maxFileBytes int64
+	maxRequests  int
+	execute      Executor
+	logger       *logging.Logger
+	now          func() time.Time
+
+	poll      time.Duration // Interval between scans for batches to run
+	heartbeat time.Duration // Interval between lock refreshes, cancellation checks and state saves
+	backoff   time.Duration // Maximum wait before retrying a rate limited request
+
+	slots chan struct{} // Bounds the requests in flight across batches
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	running map[string]func() // Cancels the batches run by this instance
+}
+
+// job is the persisted state of a batch.
+type job struct {
+	types.Batch
+
+	// Owner is the client key of the creator; batches and files are only visible to their owner
+	Owner string `json:"owner"`
+
+	// OutputFile and ErrorFile are the IDs of the result files, allocated when the batch starts
+	OutputFile string `json:"outputFile,omitempty"`
+	ErrorFile  string `json:"errorFile,omitempty"`
+}
+
+// New creates a manager keeping its files and batches in the configured directory, which is
+// created if needed. Batches only run once Run is called; their failures are logged to logger.
+func New(cfg config.Batches, execute Executor, logger *logging.Logger) (*Manager, error) {
+	m := &Manager{
+		dir:          cfg.Path,
+		concurrency:  cfg.Concurrency,
+		maxFileBytes: cfg.MaxFileBytes,
+		maxRequests:  cfg.MaxRequests,
+		execute:      execute,
+		logger:       logger,
+		now:          time.Now,
+		poll:         15 * time.Second,
+		heartbeat:    5 * time.Second,
+		backoff:      time.Minute,
+		wake:         make(chan struct{}, 1),
+		running:      make(map[string]func()),
+	}
+	if m.concurrency <= 0 {
+		m.concurrency = 1
+	}
+	m.slots = make(chan struct{}, m.concurrency)
+
+	for _, sub := range []string{"files", "batches"} {
+		if err := os.MkdirAll(filepath.Join(m.dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create batch directory: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Create creates a batch executing the requests of an uploaded file. The file is validated once
+// the batch starts; invalid files fail the batch.
+func (m *Manager) Create(owner string, req types.BatchCreateRequest) (types.Batch, error) {
+	if !supportedEndpoint(req.Endpoint) {
+		return types.Batch{}, fmt.Errorf("%w: unsupported endpoint %q", ErrInvalid, req.Endpoint)
+	}
+	if req.CompletionWindow != completionWindow {
+		return types.Batch{}, fmt.Errorf("%w: completion_window must be %q", ErrInvalid, completionWindow)
+	}
+	file, err := m.File(owner, req.InputFileID)
+	if errors.Is(err, ErrNotFound) {
+		return types.Batch{}, fmt.Errorf("%w: input file %q not found", ErrInvalid, req.InputFileID)
+	}
+	if err != nil {
+		return types.Batch{}, err
+	}
+	if file.Purpose != PurposeBatch {
+		return types.Batch{}, fmt.Errorf("%w: input file %q does not have purpose \"batch\"", ErrInvalid, req.InputFileID)
+	}
+
+	now := m.now().Unix()
+	expires := now + int64(24*time.Hour/time.Second)
+	j := &job{
+		Batch: types.Batch{
+			ID:               newID("batch_"),
+			Object:           "batch",
+			Endpoint:         req.Endpoint,
+			InputFileID:      req.InputFileID,
+			CompletionWindow: req.CompletionWindow,
+			Status:           StatusValidating,
+			CreatedAt:        now,
+			ExpiresAt:        &expires,
+			Metadata:         req.Metadata,
+		},
+		Owner: owner,
+	}
+	if err := m.save(j); err != nil {
+		return types.Batch{}, err
+	}
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return j.Batch, nil
+}
+
+// Batch returns a batch of owner.
+func (m *Manager) Batch(owner, id string) (types.Batch, error) {
+	j, err := m.load(id)
+	if err != nil {
+		return types.Batch{}, err
+	}
+	if j.Owner != owner {
+		return types.Batch{}, ErrNotFound
+	}
+	return m.view(j), nil
+}
+
+// Batches returns the batches of owner, most recent first: at most limit batches created before
+// the batch after, or the most recent ones when after is empty.
+func (m *Manager) Batches(owner, after string, limit int) (types.BatchList, error) {
+	list := types.BatchList{Object: "list", Data: []types.Batch{}}
+
+	jobs, err := m.jobs()
+	if err != nil {
+		return list, err
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if jobs[i].CreatedAt != jobs[k].CreatedAt {
+			return jobs[i].CreatedAt > jobs[k].CreatedAt
+		}
+		return jobs[i].ID > jobs[k].ID
+	})
+
+	skipping := after != ""
+	for _, j := range jobs {
+		if j.Owner != owner {
+			continue
+		}
+		if skipping {
+			skipping = j.ID != after
+			continue
+		}
+		if len(list.Data) == limit {
+			list.HasMore = true
+			break
+		}
+		list.Data = append(list.Data, m.view(j))
+	}
+	if len(list.Data) > 0 {
+		list.FirstID = list.Data[0].ID
+		list.LastID = list.Data[len(list.Data)-1].ID
+	}
+	return list, nil
+}
+
+// Cancel cancels a batch of owner. Requests already sent complete; the batch is cancelled once
+// they have, with the results received so far.
+func (m *Manager) Cancel(owner, id string) (types.Batch, error) {
+	j, err := m.load(id)
+	if err != nil {
+		return types.Batch{}, err
+	}
+	if j.Owner != owner {
+		return types.Batch{}, ErrNotFound
+	}
+	if terminal(j.Status) {
+		return types.Batch{}, fmt.Errorf("%w: batch %s is %s and cannot be cancelled", ErrInvalid, id, j.Status)
+	}
+
+	// The marker reaches the instance running the batch, wherever it runs
+	marker, err := os.OpenFile(m.batchPath(id, ".cancel"), os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return types.Batch{}, err
+	}
+	if err := marker.Close(); err != nil {
+		return types.Batch{}, err
+	}
+
+	m.cancelLocal(id)
+	return m.view(j), nil
+}
+
+// view returns the batch of a job as reported to clients: batches being cancelled are
+// "cancelling" until the instance running them has finished.
+func (m *Manager) view(j *job) types.Batch {
+	batch := j.Batch
+	if terminal(batch.Status) {
+		return batch
+	}
+	if info, err := os.Stat(m.batchPath(j.ID, ".cancel")); err == nil {
+		cancelling := info.ModTime().Unix()
+		batch.Status = StatusCancelling
+		batch.CancellingAt = &cancelling
+	}
+	return batch
+}
+
+// load reads the state of a batch.
+func (m *Manager) load(id string) (*job, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(m.batchPath(id, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := &job{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("invalid state of batch %s: %w", id, err)
+	}
+	return j, nil
+}
+
+// save writes the state of a batch.
+func (m *Manager) save(j *job) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return writeFile(m.batchPath(j.ID, ".json"), data)
+}
+
+// jobs reads the state of all batches. Unreadable states are skipped.
+func (m *Manager) jobs() ([]*job, error) {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "batches", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*job, 0, len(paths))
+	for _, path := range paths {
+		j, err := m.load(trimExt(filepath.Base(path)))
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+func (m *Manager) batchPath(id, ext string) string {
+	return filepath.Join(m.dir, "batches", id+ext)
+}
+
+// terminal reports whether a batch with status has ended.
+func terminal(status string) bool {
+	switch status {
+	case StatusFailed, StatusCompleted, StatusExpired, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func supportedEndpoint(endpoint string) bool {
+	for _, supported := range Endpoints {
+		if endpoint == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// newID generates an OpenAI style ID with prefix.
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// validID reports whether id is safe to use as a file name. IDs are client input, so anything but
+// letters, digits, "-" and "_" is rejected.
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// trimExt returns name without its extension.
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+// writeFile writes data to path under a temporary name first, so that readers never see a
+// partial file.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,373 @@
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/logging"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+const owner = "0123456789abcdef"
+
+// echo answers every request with its body, and fails requests whose model is "bad".
+func echo(_ context.Context, _, _ string, body []byte) Response {
+	if strings.Contains(string(body), `"bad"`) {
+		return Response{Status: http.StatusBadRequest, Header: http.Header{}, Body: []byte(`{"error":{"message":"Unknown model."}}`)}
+	}
+	return Response{Status: http.StatusOK, Header: http.Header{"Opc-Request-Id": {"REQ"}}, Body: body}
+}
+
+// newTestManager creates a manager in a temporary directory with short intervals.
+func newTestManager(t *testing.T, execute Executor) *Manager {
+	t.Helper()
+	m, err := New(config.Batches{Path: t.TempDir(), Concurrency: 2, MaxFileBytes: 1 << 20, MaxRequests: 10}, execute, logging.New(config.Logging{}, "test", io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.poll = 10 * time.Millisecond
+	m.heartbeat = 10 * time.Millisecond
+	m.backoff = 10 * time.Millisecond
+	return m
+}
+
+// start runs the manager until the end of the test.
+func start(t *testing.T, m *Manager) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// createBatch uploads lines as a batch input and creates a chat completions batch.
+func createBatch(t *testing.T, m *Manager, lines ...string) types.Batch {
+	t.Helper()
+	file, err := m.CreateFile(owner, "input.jsonl", PurposeBatch, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := m.Create(owner, types.BatchCreateRequest{InputFileID: file.ID, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+// chatLine returns a line of a chat completions batch input.
+func chatLine(customID, model string) string {
+	return `{"custom_id":"` + customID + `","method":"POST","url":"/v1/chat/completions","body":{"model":"` + model + `","messages":[{"role":"user","content":"Hi"}]}}`
+}
+
+// waitFor waits until a batch has ended.
+func waitFor(t *testing.T, m *Manager, id string) types.Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		batch, err := m.Batch(owner, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if terminal(batch.Status) {
+			return batch
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not end", id)
+	return types.Batch{}
+}
+
+// readResults reads a result file of a batch.
+func readResults(t *testing.T, m *Manager, id *string) []types.BatchResultLine {
+	t.Helper()
+	if id == nil {
+		return nil
+	}
+	content, file, err := m.OpenFile(owner, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = content.Close() }()
+	if file.Purpose != PurposeBatchOutput {
+		t.Errorf("expected a batch output file, got %+v", file)
+	}
+
+	var results []types.BatchResultLine
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		var result types.BatchResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestManager_Batch(t *testing.T) {
+	m := newTestManager(t, echo)
+	start(t, m)
+
+	created := createBatch(t, m, chatLine("a", "m"), chatLine("b", "bad"), chatLine("c", "m"))
+	if created.Status != StatusValidating || created.ExpiresAt == nil || *created.ExpiresAt-created.CreatedAt != 86400 {
+		t.Errorf("unexpected batch: %+v", created)
+	}
+
+	batch := waitFor(t, m, created.ID)
+	if batch.Status != StatusCompleted || batch.CompletedAt == nil || batch.InProgressAt == nil {
+		t.Fatalf("expected the batch to complete, got %+v", batch)
+	}
+	if batch.RequestCounts != (types.BatchRequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Errorf("unexpected request counts: %+v", batch.RequestCounts)
+	}
+
+	outputs := readResults(t, m, batch.OutputFileID)
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 results, got %+v", outputs)
+	}
+	for _, result := range outputs {
+		if result.Response == nil || result.Response.StatusCode != 200 || result.Response.RequestID != "REQ" || !strings.HasPrefix(result.ID, "batch_req_") {
+			t.Errorf("unexpected result: %+v", result)
+		}
+		if !strings.Contains(string(result.Response.Body), `"messages"`) {
+			t.Errorf("expected the response body, got %s", result.Response.Body)
+		}
+	}
+	errs := readResults(t, m, batch.ErrorFileID)
+	if len(errs) != 1 || errs[0].CustomID != "b" || errs[0].Response.StatusCode != 400 {
+		t.Errorf("expected the failed request in the error file, got %+v", errs)
+	}
+
+	// Batches and files are only visible to their owner
+	if _, err := m.Batch("someone-else", batch.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the batch to be hidden from other clients, got %v", err)
+	}
+	list, err := m.Batches(owner, "", 10)
+	if err != nil || len(list.Data) != 1 || list.FirstID != batch.ID {
+		t.Errorf("expected the batch to be listed, got %+v, %v", list, err)
+	}
+	files, err := m.Files(owner, PurposeBatchOutput)
+	if err != nil || len(files.Data) != 2 {
+		t.Errorf("expected the result files to be listed, got %+v, %v", files, err)
+	}
+}
+
+func TestManager_Validation(t *testing.T) {
+	m := newTestManager(t, echo)
+	start(t, m)
+
+	created := createBatch(t, m,
+		chatLine("a", "m"),
+		chatLine("a", "m"),
+		`{"custom_id":"b","method":"POST","url":"/v1/embeddings","body":{}}`,
+		`{"custom_id":"c","method":"POST","url":"/v1/chat/completions","body":{"stream":true}}`,
+		`not json`,
+	)
+	batch := waitFor(t, m, created.ID)
+	if batch.Status != StatusFailed || batch.Errors == nil || batch.FailedAt == nil {
+		t.Fatalf("expected the batch to fail, got %+v", batch)
+	}
+
+	var codes []string
+	for _, problem := range batch.Errors.Data {
+		codes = append(codes, problem.Code+"@"+strconv.Itoa(*problem.Line))
+	}
+	expected := "duplicate_custom_id@2 invalid_url@3 invalid_request@4 invalid_json_line@5"
+	if strings.Join(codes, " ") != expected {
+		t.Errorf("expected errors %q, got %q", expected, strings.Join(codes, " "))
+	}
+
+	// The input of an ended batch can be deleted
+	if err := m.DeleteFile(owner, batch.InputFileID); err != nil {
+		t.Errorf("expected the input of a failed batch to be deletable, got %v", err)
+	}
+}
+
+func TestManager_Create(t *testing.T) {
+	m := newTestManager(t, echo)
+
+	file, err := m.CreateFile(owner, "input.jsonl", PurposeBatch, strings.NewReader(chatLine("a", "m")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]types.BatchCreateRequest{
+		"unknown endpoint":  {InputFileID: file.ID, Endpoint: "/v1/images/generations", CompletionWindow: "24h"},
+		"completion window": {InputFileID: file.ID, Endpoint: "/v1/chat/completions", CompletionWindow: "1h"},
+		"unknown file":      {InputFileID: "file-unknown", Endpoint: "/v1/chat/completions", CompletionWindow: "24h"},
+	}
+	for name, req := range tests {
+		if _, err := m.Create(owner, req); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected an invalid request error, got %v", name, err)
+		}
+	}
+
+	if _, err := m.CreateFile(owner, "input.jsonl", "fine-tune", strings.NewReader("{}")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected an error for an unsupported purpose, got %v", err)
+	}
+	if _, err := m.CreateFile(owner, "input.jsonl", PurposeBatch, strings.NewReader(strings.Repeat("x", 2<<20))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected an error for a file too large, got %v", err)
+	}
+
+	batch, err := m.Create(owner, types.BatchCreateRequest{InputFileID: file.ID, Endpoint: "/v1/chat/completions", CompletionWindow: "24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteFile(owner, file.ID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected the input of a pending batch not to be deletable, got %v", err)
+	}
+	if _, err := m.Cancel("someone-else", batch.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the batch to be hidden from other clients, got %v", err)
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	release := make(chan struct{})
+	var calls sync.WaitGroup
+	calls.Add(2)
+	m := newTestManager(t, func(ctx context.Context, owner, url string, body []byte) Response {
+		calls.Done()
+		<-release
+		return echo(ctx, owner, url, body)
+	})
+	start(t, m)
+
+	created := createBatch(t, m, chatLine("a", "m"), chatLine("b", "m"), chatLine("c", "m"), chatLine("d", "m"))
+	calls.Wait()
+
+	cancelling, err := m.Cancel(owner, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelling.Status != StatusCancelling || cancelling.CancellingAt == nil {
+		t.Errorf("expected the batch to be cancelling, got %+v", cancelling)
+	}
+	close(release)
+
+	batch := waitFor(t, m, created.ID)
+	if batch.Status != StatusCancelled || batch.CancelledAt == nil || batch.CancellingAt == nil {
+		t.Fatalf("expected the batch to be cancelled, got %+v", batch)
+	}
+	if batch.RequestCounts.Completed != 2 || len(readResults(t, m, batch.OutputFileID)) != 2 {
+		t.Errorf("expected the requests in flight to complete, got %+v", batch.RequestCounts)
+	}
+	if _, err := m.Cancel(owner, created.ID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected a cancelled batch not to be cancellable, got %v", err)
+	}
+}
+
+func TestManager_Resume(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	m := newTestManager(t, func(ctx context.Context, owner, url string, body []byte) Response {
+		mu.Lock()
+		sent = append(sent, string(body))
+		mu.Unlock()
+		return echo(ctx, owner, url, body)
+	})
+
+	// A previous run sent the first request and crashed while writing the result of the second
+	created := createBatch(t, m, chatLine("a", "m1"), chatLine("b", "m2"), chatLine("c", "m3"))
+	j, err := m.load(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Status = StatusInProgress
+	j.RequestCounts.Total = 3
+	j.OutputFile, j.ErrorFile = newID("file-"), newID("file-")
+	if err := m.save(j); err != nil {
+		t.Fatal(err)
+	}
+	previous := `{"id":"batch_req_1","custom_id":"a","response":{"status_code":200,"request_id":"R","body":{}},"error":null}` + "\n" + `{"id":"batch_req_2","cust`
+	if err := os.WriteFile(m.filePath(j.OutputFile, ".jsonl"), []byte(previous), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lock of the crashed instance is stale
+	if err := os.WriteFile(m.batchPath(j.ID, ".lock"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(m.batchPath(j.ID, ".lock"), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	start(t, m)
+	batch := waitFor(t, m, created.ID)
+	if batch.Status != StatusCompleted || batch.RequestCounts.Completed != 3 {
+		t.Fatalf("expected the batch to complete, got %+v", batch)
+	}
+	if len(sent) != 2 || strings.Contains(strings.Join(sent, ""), "m1") {
+		t.Errorf("expected only the requests without result to be sent, got %q", sent)
+	}
+	if results := readResults(t, m, batch.OutputFileID); len(results) != 3 {
+		t.Errorf("expected the partial line to be dropped, got %+v", results)
+	}
+}
+
+func TestManager_Locked(t *testing.T) {
+	m := newTestManager(t, echo)
+	created := createBatch(t, m, chatLine("a", "m"))
+
+	// Another instance runs the batch and keeps its lock fresh
+	if err := os.WriteFile(m.batchPath(created.ID, ".lock"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	start(t, m)
+	time.Sleep(15 * time.Millisecond)
+	if batch, _ := m.Batch(owner, created.ID); batch.Status != StatusValidating {
+		t.Errorf("expected the batch of another instance not to run, got %+v", batch)
+	}
+}
+
+func TestManager_RateLimited(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	m := newTestManager(t, func(ctx context.Context, owner, url string, body []byte) Response {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			return Response{Status: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}, Body: []byte(`{}`)}
+		}
+		return echo(ctx, owner, url, body)
+	})
+	start(t, m)
+
+	batch := waitFor(t, m, createBatch(t, m, chatLine("a", "m")).ID)
+	if batch.RequestCounts.Completed != 1 || attempts != 3 {
+		t.Errorf("expected the request to be retried until it succeeds, got %+v after %d attempts", batch.RequestCounts, attempts)
+	}
+}
+
+func TestManager_Expired(t *testing.T) {
+	m := newTestManager(t, echo)
+	created := createBatch(t, m, chatLine("a", "m"), chatLine("b", "m"))
+	m.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	start(t, m)
+
+	batch := waitFor(t, m, created.ID)
+	if batch.Status != StatusExpired || batch.ExpiredAt == nil || batch.OutputFileID != nil {
+		t.Fatalf("expected the batch to expire, got %+v", batch)
+	}
+	errs := readResults(t, m, batch.ErrorFileID)
+	if len(errs) != 2 || errs[0].Error == nil || errs[0].Error.Code != "batch_expired" || errs[0].Response != nil {
+		t.Errorf("expected the requests to expire, got %+v", errs)
+	}
+}
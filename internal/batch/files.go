@@ -0,0 +1,174 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Purposes of the files: uploaded batch inputs, and batch results.
+const (
+	PurposeBatch       = "batch"
+	PurposeBatchOutput = "batch_output"
+)
+
+// fileRecord is the persisted metadata of a file.
+type fileRecord struct {
+	types.File
+
+	// Owner is the client key of the uploader; files are only visible to their owner
+	Owner string `json:"owner"`
+}
+
+// CreateFile stores an uploaded file read from r. Only batch inputs can be uploaded.
+func (m *Manager) CreateFile(owner, filename, purpose string, r io.Reader) (types.File, error) {
+	if purpose != PurposeBatch {
+		return types.File{}, fmt.Errorf("%w: unsupported purpose %q", ErrInvalid, purpose)
+	}
+
+	id := newID("file-")
+	tmp, err := os.CreateTemp(filepath.Join(m.dir, "files"), ".tmp-*")
+	if err != nil {
+		return types.File{}, err
+	}
+	written, err := io.Copy(tmp, io.LimitReader(r, m.maxFileBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > m.maxFileBytes {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.filePath(id, ".jsonl"))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return types.File{}, err
+	}
+
+	file := types.File{
+		ID:        id,
+		Object:    "file",
+		Bytes:     written,
+		CreatedAt: m.now().Unix(),
+		Filename:  filename,
+		Purpose:   purpose,
+		Status:    "processed",
+	}
+	if err := m.saveFile(owner, file); err != nil {
+		_ = os.Remove(m.filePath(id, ".jsonl"))
+		return types.File{}, err
+	}
+	return file, nil
+}
+
+// File returns a file of owner.
+func (m *Manager) File(owner, id string) (types.File, error) {
+	record, err := m.loadFile(id)
+	if err != nil {
+		return types.File{}, err
+	}
+	if record.Owner != owner {
+		return types.File{}, ErrNotFound
+	}
+	return record.File, nil
+}
+
+// Files returns the files of owner with purpose, or all of them when purpose is empty, most
+// recent first.
+func (m *Manager) Files(owner, purpose string) (types.FileList, error) {
+	list := types.FileList{Object: "list", Data: []types.File{}}
+
+	paths, err := filepath.Glob(filepath.Join(m.dir, "files", "*.json"))
+	if err != nil {
+		return list, err
+	}
+	for _, path := range paths {
+		record, err := m.loadFile(trimExt(filepath.Base(path)))
+		if err != nil || record.Owner != owner || (purpose != "" && record.Purpose != purpose) {
+			continue
+		}
+		list.Data = append(list.Data, record.File)
+	}
+	sort.Slice(list.Data, func(i, k int) bool {
+		if list.Data[i].CreatedAt != list.Data[k].CreatedAt {
+			return list.Data[i].CreatedAt > list.Data[k].CreatedAt
+		}
+		return list.Data[i].ID > list.Data[k].ID
+	})
+	return list, nil
+}
+
+// OpenFile opens the content of a file of owner.
+func (m *Manager) OpenFile(owner, id string) (*os.File, types.File, error) {
+	file, err := m.File(owner, id)
+	if err != nil {
+		return nil, file, err
+	}
+	content, err := os.Open(m.filePath(id, ".jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, file, ErrNotFound
+	}
+	return content, file, err
+}
+
+// DeleteFile deletes a file of owner. The input of a batch that has not ended cannot be deleted.
+func (m *Manager) DeleteFile(owner, id string) error {
+	if _, err := m.File(owner, id); err != nil {
+		return err
+	}
+	jobs, err := m.jobs()
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		if j.InputFileID == id && !terminal(j.Status) {
+			return fmt.Errorf("%w: file %s is the input of batch %s, which has not ended", ErrInvalid, id, j.ID)
+		}
+	}
+	if err := os.Remove(m.filePath(id, ".json")); err != nil {
+		return err
+	}
+	if err := os.Remove(m.filePath(id, ".jsonl")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// loadFile reads the metadata of a file.
+func (m *Manager) loadFile(id string) (*fileRecord, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(m.filePath(id, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &fileRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("invalid metadata of file %s: %w", id, err)
+	}
+	return record, nil
+}
+
+// saveFile writes the metadata of a file, which makes it visible.
+func (m *Manager) saveFile(owner string, file types.File) error {
+	data, err := json.Marshal(fileRecord{File: file, Owner: owner})
+	if err != nil {
+		return err
+	}
+	return writeFile(m.filePath(file.ID, ".json"), data)
+}
+
+func (m *Manager) filePath(id, ext string) string {
+	return filepath.Join(m.dir, "files", id+ext)
+}
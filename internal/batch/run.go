@@ -0,0 +1,605 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/logging"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Executor sends a request of a batch to url on behalf of the owner of the batch. ctx is done when
+// the manager stops.
+type Executor func(ctx context.Context, owner, url string, body []byte) Response
+
+// Response is the response to a request of a batch.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// maxErrors bounds the validation errors reported for a batch.
+const maxErrors = 100
+
+// Run runs batches until ctx is done: new batches, batches left unfinished by a previous run, and
+// batches of other instances whose lock has gone stale. Once ctx is done, Run waits for the
+// requests in flight; their batches are resumed by the next run.
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(m.poll)
+	defer ticker.Stop()
+
+	for {
+		m.startAll(ctx, &wg)
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+	}
+}
+
+// startAll starts the unfinished batches that no instance is running, oldest first.
+func (m *Manager) startAll(ctx context.Context, wg *sync.WaitGroup) {
+	jobs, err := m.jobs()
+	if err != nil {
+		m.logger.Error("failed to list batches", "error", err)
+		return
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].CreatedAt < jobs[k].CreatedAt })
+
+	for _, j := range jobs {
+		if terminal(j.Status) || ctx.Err() != nil {
+			continue
+		}
+		m.mu.Lock()
+		_, running := m.running[j.ID]
+		m.mu.Unlock()
+		if running || !m.lock(j.ID) {
+			continue
+		}
+
+		cancelled := make(chan struct{})
+		var once sync.Once
+		m.mu.Lock()
+		m.running[j.ID] = func() { once.Do(func() { close(cancelled) }) }
+		m.mu.Unlock()
+
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			defer m.unlock(j.ID)
+			m.run(ctx, j, cancelled)
+		}(j)
+	}
+}
+
+// run runs a batch locked by this instance: it validates its input, sends the requests that have
+// no result yet and finalizes it.
+func (m *Manager) run(ctx context.Context, j *job, cancelled chan struct{}) {
+	if m.cancelRequested(j.ID) {
+		m.cancelLocal(j.ID)
+	}
+	if j.Status == StatusValidating {
+		if !m.start(j) {
+			return
+		}
+	}
+
+	status, err := m.process(ctx, j, cancelled)
+	if err == nil && status != "" {
+		err = m.finalize(j, status)
+	}
+	if err != nil {
+		m.logger.Error("failed to run batch", "batch", j.ID, "error", err)
+	}
+}
+
+// start validates the input of a batch and moves it to in_progress, or fails it. It returns false
+// when the batch must not be processed.
+func (m *Manager) start(j *job) bool {
+	total, problems, err := m.validate(j)
+	if err != nil {
+		m.logger.Error("failed to validate batch", "batch", j.ID, "error", err)
+		return false
+	}
+
+	now := m.now().Unix()
+	if len(problems) > 0 {
+		j.Status = StatusFailed
+		j.FailedAt = &now
+		j.Errors = &types.BatchErrors{Object: "list", Data: problems}
+	} else {
+		j.Status = StatusInProgress
+		j.InProgressAt = &now
+		j.RequestCounts.Total = total
+		j.OutputFile = newID("file-")
+		j.ErrorFile = newID("file-")
+	}
+	if err := m.save(j); err != nil {
+		m.logger.Error("failed to save batch", "batch", j.ID, "error", err)
+		return false
+	}
+	return j.Status == StatusInProgress
+}
+
+// validate checks every line of the input of a batch and returns the number of requests, or the
+// problems found.
+func (m *Manager) validate(j *job) (int, []types.BatchError, error) {
+	input, err := os.Open(m.filePath(j.InputFileID, ".jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, []types.BatchError{{Code: "file_not_found", Message: "The input file " + j.InputFileID + " no longer exists."}}, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = input.Close() }()
+
+	var problems []types.BatchError
+	report := func(line int, code, message string) {
+		if len(problems) < maxErrors {
+			problems = append(problems, types.BatchError{Code: code, Message: message, Line: &line})
+		}
+	}
+
+	total := 0
+	seen := make(map[string]bool)
+	err = eachLine(input, func(line int, data []byte) {
+		total++
+		var req types.BatchRequestLine
+		if err := json.Unmarshal(data, &req); err != nil {
+			report(line, "invalid_json_line", "This line is not parseable as valid JSON.")
+			return
+		}
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		switch {
+		case req.CustomID == "":
+			report(line, "missing_required_parameter", "custom_id is required.")
+		case seen[req.CustomID]:
+			report(line, "duplicate_custom_id", "The custom_id "+req.CustomID+" is not unique within the batch.")
+		case req.Method != http.MethodPost:
+			report(line, "invalid_method", "The method must be POST.")
+		case req.URL != j.Endpoint:
+			report(line, "invalid_url", "The url must match the endpoint of the batch, "+j.Endpoint+".")
+		case json.Unmarshal(req.Body, &body) != nil:
+			report(line, "invalid_request", "The body must be a JSON object.")
+		case body.Stream:
+			report(line, "invalid_request", "Streaming is not supported in batches.")
+		}
+		seen[req.CustomID] = true
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	switch {
+	case total == 0:
+		problems = append(problems, types.BatchError{Code: "empty_file", Message: "The input file has no requests."})
+	case m.maxRequests > 0 && total > m.maxRequests:
+		problems = append(problems, types.BatchError{Code: "too_many_requests",
+			Message: "The batch has " + strconv.Itoa(total) + " requests, more than the limit of " + strconv.Itoa(m.maxRequests) + "."})
+	}
+	return total, problems, nil
+}
+
+// process sends the requests of a batch that have no result yet. It returns the final status of
+// the batch, or "" when ctx was done first.
+func (m *Manager) process(ctx context.Context, j *job, cancelled chan struct{}) (string, error) {
+	results, done, err := m.openResults(j)
+	if err != nil {
+		return "", err
+	}
+	defer results.close()
+
+	input, err := os.Open(m.filePath(j.InputFileID, ".jsonl"))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = input.Close() }()
+
+	// The heartbeat keeps the lock fresh, notices cancellations made through other instances and
+	// saves the progress
+	stopHeartbeat := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(m.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopHeartbeat:
+				return
+			case <-ticker.C:
+				m.touch(j.ID)
+				if m.cancelRequested(j.ID) {
+					m.cancelLocal(j.ID)
+				}
+				results.save(m, j)
+			}
+		}
+	}()
+
+	var inFlight sync.WaitGroup
+	stop := ""
+	err = eachLine(input, func(_ int, data []byte) {
+		var req types.BatchRequestLine
+		if json.Unmarshal(data, &req) != nil || done[req.CustomID] {
+			return
+		}
+		if stop == "" {
+			stop = m.stopReason(ctx, j, cancelled)
+		}
+		if stop == StatusExpired {
+			results.write(j, req.CustomID, nil, &types.BatchError{
+				Code:    "batch_expired",
+				Message: "This request could not be executed before the completion window expired.",
+			})
+			return
+		}
+		if stop != "" {
+			return
+		}
+
+		select {
+		case m.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		case <-cancelled:
+			return
+		}
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			defer func() { <-m.slots }()
+			if resp, ok := m.send(ctx, j, req.Body); ok {
+				results.write(j, req.CustomID, &resp, nil)
+			}
+		}()
+	})
+	inFlight.Wait()
+	close(stopHeartbeat)
+	<-heartbeatDone
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		results.save(m, j)
+		return "", nil
+	case stop == StatusExpired:
+		return StatusExpired, nil
+	case stop == StatusCancelled || m.cancelRequested(j.ID):
+		return StatusCancelled, nil
+	}
+	return StatusCompleted, nil
+}
+
+// stopReason returns why no more requests of a batch may be sent, or "".
+func (m *Manager) stopReason(ctx context.Context, j *job, cancelled chan struct{}) string {
+	select {
+	case <-ctx.Done():
+		return "shutdown"
+	case <-cancelled:
+		return StatusCancelled
+	default:
+	}
+	if j.ExpiresAt != nil && m.now().Unix() >= *j.ExpiresAt {
+		return StatusExpired
+	}
+	return ""
+}
+
+// send sends a request of a batch, retrying while it is rate limited. It returns false when ctx
+// was done before a response was received; the request is then sent again when the batch resumes.
+func (m *Manager) send(ctx context.Context, j *job, body []byte) (Response, bool) {
+	for {
+		resp := m.execute(ctx, j.Owner, j.Endpoint, body)
+		if ctx.Err() != nil {
+			return resp, false
+		}
+		if resp.Status != http.StatusTooManyRequests && resp.Status != http.StatusServiceUnavailable {
+			return resp, true
+		}
+		if j.ExpiresAt != nil && m.now().Unix() >= *j.ExpiresAt {
+			return resp, true
+		}
+
+		timer := time.NewTimer(m.retryDelay(resp.Header))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, false
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying a rate limited request: the Retry-After of
+// the response, bounded by the maximum backoff, or one second.
+func (m *Manager) retryDelay(header http.Header) time.Duration {
+	delay := time.Second
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	if delay > m.backoff {
+		delay = m.backoff
+	}
+	return delay
+}
+
+// finalize publishes the result files of a batch and moves it to its final status.
+func (m *Manager) finalize(j *job, status string) error {
+	now := m.now().Unix()
+	j.Status = StatusFinalizing
+	j.FinalizingAt = &now
+	if err := m.save(j); err != nil {
+		return err
+	}
+
+	var err error
+	if j.OutputFileID, err = m.publish(j, j.OutputFile, "_output.jsonl"); err != nil {
+		return err
+	}
+	if j.ErrorFileID, err = m.publish(j, j.ErrorFile, "_error.jsonl"); err != nil {
+		return err
+	}
+
+	now = m.now().Unix()
+	switch status {
+	case StatusCompleted:
+		j.CompletedAt = &now
+	case StatusExpired:
+		j.ExpiredAt = &now
+	case StatusCancelled:
+		j.CancellingAt = m.view(j).CancellingAt
+		j.CancelledAt = &now
+	}
+	j.Status = status
+	if err := m.save(j); err != nil {
+		return err
+	}
+	_ = os.Remove(m.batchPath(j.ID, ".cancel"))
+	return nil
+}
+
+// publish makes a result file of a batch visible to its owner and returns its ID. Empty result
+// files are removed instead, and nil returned.
+func (m *Manager) publish(j *job, id, suffix string) (*string, error) {
+	if id == "" {
+		return nil, nil
+	}
+	path := m.filePath(id, ".jsonl")
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, os.Remove(path)
+	}
+
+	file := types.File{
+		ID:        id,
+		Object:    "file",
+		Bytes:     info.Size(),
+		CreatedAt: m.now().Unix(),
+		Filename:  j.ID + suffix,
+		Purpose:   PurposeBatchOutput,
+		Status:    "processed",
+	}
+	if err := m.saveFile(j.Owner, file); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// results appends the results of a batch to its output and error files and counts them.
+type results struct {
+	mu      sync.Mutex
+	output  *os.File
+	errors  *os.File
+	changed bool
+	logger  *logging.Logger
+}
+
+// openResults opens the result files of a batch for appending and returns the custom IDs of the
+// requests that already have a result. A line left incomplete by a crash is dropped, and its
+// request sent again.
+func (m *Manager) openResults(j *job) (*results, map[string]bool, error) {
+	done := make(map[string]bool)
+	r := &results{logger: m.logger}
+
+	var counts [2]int
+	for i, id := range []string{j.OutputFile, j.ErrorFile} {
+		file, err := os.OpenFile(m.filePath(id, ".jsonl"), os.O_CREATE|os.O_RDWR, 0o600)
+		if err != nil {
+			r.close()
+			return nil, nil, err
+		}
+		if i == 0 {
+			r.output = file
+		} else {
+			r.errors = file
+		}
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			r.close()
+			return nil, nil, err
+		}
+		complete := bytes.LastIndexByte(data, '\n') + 1
+		if complete < len(data) {
+			if err := file.Truncate(int64(complete)); err != nil {
+				r.close()
+				return nil, nil, err
+			}
+		}
+		if _, err := file.Seek(int64(complete), io.SeekStart); err != nil {
+			r.close()
+			return nil, nil, err
+		}
+
+		_ = eachLine(bytes.NewReader(data[:complete]), func(_ int, line []byte) {
+			var result types.BatchResultLine
+			if json.Unmarshal(line, &result) == nil && !done[result.CustomID] {
+				done[result.CustomID] = true
+				counts[i]++
+			}
+		})
+	}
+
+	j.RequestCounts.Completed, j.RequestCounts.Failed = counts[0], counts[1]
+	return r, done, nil
+}
+
+// write appends the result of a request: successful responses to the output file, and failed
+// responses and requests that could not be sent to the error file.
+func (r *results) write(j *job, customID string, resp *Response, failure *types.BatchError) {
+	result := types.BatchResultLine{ID: newID("batch_req_"), CustomID: customID, Error: failure}
+	if resp != nil {
+		body := json.RawMessage(resp.Body)
+		if !json.Valid(body) {
+			encoded, _ := json.Marshal(string(resp.Body))
+			body = encoded
+		}
+		requestID := resp.Header.Get("opc-request-id")
+		if requestID == "" {
+			requestID = result.ID
+		}
+		result.Response = &types.BatchResponse{StatusCode: resp.Status, RequestID: requestID, Body: body}
+	}
+	line, err := json.Marshal(result)
+	if err != nil {
+		r.logger.Error("failed to encode batch result", "batch", j.ID, "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	succeeded := resp != nil && resp.Status >= 200 && resp.Status < 300
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file := r.errors
+	if succeeded {
+		file = r.output
+	}
+	if _, err := file.Write(line); err != nil {
+		r.logger.Error("failed to write batch result", "batch", j.ID, "error", err)
+		return
+	}
+	if succeeded {
+		j.RequestCounts.Completed++
+	} else {
+		j.RequestCounts.Failed++
+	}
+	r.changed = true
+}
+
+// save saves the progress of a batch, if it changed since the last save.
+func (r *results) save(m *Manager, j *job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.changed {
+		return
+	}
+	if err := m.save(j); err != nil {
+		m.logger.Error("failed to save batch", "batch", j.ID, "error", err)
+		return
+	}
+	r.changed = false
+}
+
+func (r *results) close() {
+	for _, file := range []*os.File{r.output, r.errors} {
+		if file != nil {
+			_ = file.Close()
+		}
+	}
+}
+
+// eachLine calls fn with every non-blank line of r and its 1-based line number.
+func eachLine(r io.Reader, fn func(line int, data []byte)) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			fn(line, trimmed)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lock takes the lock of a batch, replacing the lock of an instance that stopped refreshing it.
+// It returns false when another instance holds the lock.
+func (m *Manager) lock(id string) bool {
+	path := m.batchPath(id, ".lock")
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			return file.Close() == nil
+		}
+		info, err := os.Stat(path)
+		if err == nil && time.Since(info.ModTime()) < 3*m.heartbeat {
+			return false
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false
+		}
+		_ = os.Remove(path)
+	}
+	return false
+}
+
+// touch refreshes the lock of a batch.
+func (m *Manager) touch(id string) {
+	now := time.Now()
+	if err := os.Chtimes(m.batchPath(id, ".lock"), now, now); err != nil {
+		m.logger.Error("failed to refresh batch lock", "batch", id, "error", err)
+	}
+}
+
+// unlock releases the lock of a batch run by this instance.
+func (m *Manager) unlock(id string) {
+	m.mu.Lock()
+	delete(m.running, id)
+	m.mu.Unlock()
+	_ = os.Remove(m.batchPath(id, ".lock"))
+}
+
+// cancelRequested reports whether a batch has been cancelled, through any instance.
+func (m *Manager) cancelRequested(id string) bool {
+	_, err := os.Stat(m.batchPath(id, ".cancel"))
+	return err == nil
+}
+
+// cancelLocal stops a batch run by this instance from sending more requests.
+func (m *Manager) cancelLocal(id string) {
+	m.mu.Lock()
+	cancel := m.running[id]
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
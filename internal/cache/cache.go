@@ -0,0 +1,143 @@
+// Package cache implements the exact-match response cache of the OCI GenAI proxy plugin.
+//
+// Responses are keyed on a canonical hash of the transformed Oracle Cloud request, which
+// includes the compartment, so identical prompts sent with identical parameters are only
+// paid for once. Storage is pluggable through the Backend interface; an in-memory LRU with
+// entry, size and TTL limits is provided.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Backend stores cached responses.
+type Backend interface {
+	// Get returns the value stored for key, if present and not expired.
+	Get(key string) ([]byte, bool)
+
+	// Set stores value for key for at most ttl.
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// Key returns the canonical cache key of a request.
+//
+// The key covers the compartment, the model and every chat parameter. Streaming options
+// are cleared first, so that streaming and non-streaming clients share entries.
+// The request is serialized with encoding/json, whose output is deterministic: struct fields are
+// written in declaration order and map keys are sorted.
+func Key(req types.OracleCloudRequest) (string, error) {
+	req.ChatRequest.IsStream = false
+	req.ChatRequest.StreamOptions = types.StreamOptions{}
+
+	canonical, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IsDeterministic reports whether the request samples greedily, so that replaying a previous
+// response is indistinguishable from calling the model again.
+func IsDeterministic(req types.OracleCloudRequest) bool {
+	return req.ChatRequest.Temperature == 0
+}
+
+// LRU is an in-memory Backend evicting the least recently used entries once the number of
+// entries or their total size exceeds its limits.
+type LRU struct {
+	maxEntries int
+	maxBytes   int
+	now        func() time.Time
+
+	mu    sync.Mutex
+	order *list.List // most recently used at the front
+	items map[string]*list.Element
+	size  int
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU creates an LRU holding at most maxEntries entries and maxBytes bytes of values.
+// A limit of 0 disables it.
+func NewLRU(maxEntries, maxBytes int) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		now:        time.Now,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored for key and marks it as recently used.
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set stores value for key. Values larger than the size limit are not stored.
+func (c *LRU) Set(key string, value []byte, ttl time.Duration) {
+	if c.maxBytes > 0 && len(value) > c.maxBytes {
+		return
+	}
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	c.size += len(value)
+
+	for (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	entry := elem.Value.(*lruEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	c.size -= len(entry.value)
+}
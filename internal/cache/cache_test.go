@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func testRequest() types.OracleCloudRequest {
+	return types.OracleCloudRequest{
+		CompartmentID: "ocid1.compartment.oc1..test",
+		ServingMode:   types.ServingMode{ModelID: "cohere.command-r-plus", ServingType: "ON_DEMAND"},
+		ChatRequest: types.ChatRequest{
+			MaxTokens: 600,
+			Message:   "Hello",
+			APIFormat: "COHERE",
+		},
+	}
+}
+
+func TestKey(t *testing.T) {
+	base, err := Key(testRequest())
+	if err != nil {
+		t.Fatalf("failed to compute key: %v", err)
+	}
+
+	streaming := testRequest()
+	streaming.ChatRequest.IsStream = true
+	streaming.ChatRequest.StreamOptions.IsIncludeUsage = true
+	if key, _ := Key(streaming); key != base {
+		t.Error("expected streaming and non-streaming requests to share a key")
+	}
+
+	otherCompartment := testRequest()
+	otherCompartment.CompartmentID = "ocid1.compartment.oc1..other"
+	if key, _ := Key(otherCompartment); key == base {
+		t.Error("expected compartment to be part of the key")
+	}
+
+	otherPrompt := testRequest()
+	otherPrompt.ChatRequest.Message = "Hello!"
+	if key, _ := Key(otherPrompt); key == base {
+		t.Error("expected prompt to be part of the key")
+	}
+}
+
+func TestIsDeterministic(t *testing.T) {
+	req := testRequest()
+	if !IsDeterministic(req) {
+		t.Error("expected temperature 0 to be deterministic")
+	}
+
+	req.ChatRequest.Temperature = 0.7
+	if IsDeterministic(req) {
+		t.Error("expected temperature 0.7 not to be deterministic")
+	}
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2, 0)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Get("a")
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || string(v) != "1" {
+		t.Error("expected recently used entry to be kept")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestLRU_SizeLimit(t *testing.T) {
+	c := NewLRU(0, 10)
+	c.Set("a", []byte("12345"), 0)
+	c.Set("b", []byte("12345"), 0)
+	c.Set("c", []byte("123"), 0)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected oldest entry to be evicted to respect the size limit")
+	}
+
+	c.Set("huge", make([]byte, 11), 0)
+	if _, ok := c.Get("huge"); ok {
+		t.Error("expected oversized value not to be stored")
+	}
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Now()
+	c := NewLRU(10, 0)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", []byte("1"), time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected entry before expiry")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected entry to expire")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected expired entry to be removed, got %d entries", c.Len())
+	}
+}
+
+func TestReplay(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog, héhé ünïcödé text"
+	resp := types.OracleCloudResponse{ChatResponse: types.ChatResponse{
+		APIFormat:    "COHERE",
+		Text:         text,
+		FinishReason: "COMPLETE",
+		Usage:        &types.Usage{PromptTokens: 3, CompletionTokens: 12, TotalTokens: 15},
+	}}
+
+	var events []replayEvent
+	scanner := bufio.NewScanner(bytes.NewReader(Replay(resp)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			t.Fatalf("unexpected line %q", line)
+		}
+		var event replayEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) < 3 {
+		t.Fatalf("expected the text to be split into several events, got %d", len(events))
+	}
+
+	var rebuilt strings.Builder
+	for _, event := range events[:len(events)-1] {
+		rebuilt.WriteString(event.Text)
+	}
+	if rebuilt.String() != text {
+		t.Errorf("expected chunks to rebuild the text, got %q", rebuilt.String())
+	}
+
+	last := events[len(events)-1]
+	if last.FinishReason != "COMPLETE" || last.Usage == nil || last.Usage.TotalTokens != 15 || last.Text != "" {
+		t.Errorf("unexpected final event %+v", last)
+	}
+}
+
+func TestReplay_Generic(t *testing.T) {
+	resp := types.OracleCloudResponse{ChatResponse: types.ChatResponse{
+		APIFormat:    "GENERIC",
+		FinishReason: "tool_calls",
+		Choices: []types.GenericChoice{{
+			Message: types.GenericMessage{
+				Role:      "ASSISTANT",
+				Content:   []types.GenericContent{{Type: "TEXT", Text: "Let me check."}},
+				ToolCalls: []types.GenericToolCall{{ID: "call_1", Type: "FUNCTION", Name: "f", Arguments: "{}"}},
+			},
+			FinishReason: "tool_calls",
+		}},
+	}}
+
+	expected := `data: {"apiFormat":"GENERIC","message":{"role":"ASSISTANT","content":[{"type":"TEXT","text":"Let me check."}]}}` + "\n\n" +
+		`data: {"apiFormat":"GENERIC","message":{"role":"ASSISTANT","toolCalls":[{"id":"call_1","type":"FUNCTION","name":"f","arguments":"{}"}]}}` + "\n\n" +
+		`data: {"apiFormat":"GENERIC","finishReason":"tool_calls"}` + "\n\n"
+	if replayed := string(Replay(resp)); replayed != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, replayed)
+	}
+}
+
+func TestReplay_Grounding(t *testing.T) {
+	searchRequired := true
+	resp := types.OracleCloudResponse{ChatResponse: types.ChatResponse{
+		APIFormat:        "COHERE",
+		Text:             "In Antarctica.",
+		FinishReason:     "COMPLETE",
+		Citations:        []types.Citation{{Start: 3, End: 13, Text: "Antarctica", DocumentIDs: []string{"doc_0"}}},
+		Documents:        []json.RawMessage{json.RawMessage(`{"id":"doc_0","text":"Emperor penguins live in Antarctica."}`)},
+		SearchQueries:    []types.SearchQuery{{Text: "emperor penguin habitat"}},
+		IsSearchRequired: &searchRequired,
+	}}
+
+	expected := `data: {"apiFormat":"COHERE","text":"In Antarctica."}` + "\n\n" +
+		`data: {"apiFormat":"COHERE","citations":[{"start":3,"end":13,"text":"Antarctica","documentIds":["doc_0"]}],` +
+		`"documents":[{"id":"doc_0","text":"Emperor penguins live in Antarctica."}],` +
+		`"searchQueries":[{"text":"emperor penguin habitat"}],"isSearchRequired":true}` + "\n\n" +
+		`data: {"apiFormat":"COHERE","finishReason":"COMPLETE"}` + "\n\n"
+	if replayed := string(Replay(resp)); replayed != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, replayed)
+	}
+}
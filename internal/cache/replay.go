@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// replayChunkSize is the approximate number of bytes of text per replayed event.
+const replayChunkSize = 16
+
+// replayEvent is a single event of a replayed OCI chat stream.
+type replayEvent struct {
+	APIFormat        string                `json:"apiFormat,omitempty"`
+	Text             string                `json:"text,omitempty"`
+	Message          *types.GenericMessage `json:"message,omitempty"`
+	Citations        []types.Citation      `json:"citations,omitempty"`
+	Documents        []json.RawMessage     `json:"documents,omitempty"`
+	SearchQueries    []types.SearchQuery   `json:"searchQueries,omitempty"`
+	IsSearchRequired *bool                 `json:"isSearchRequired,omitempty"`
+	FinishReason     string                `json:"finishReason,omitempty"`
+	Usage            *types.Usage          `json:"usage,omitempty"`
+}
+
+// Replay re-chunks a cached response into the server-sent events OCI sends for a streamed chat,
+// so that cached responses can be served to streaming clients. Responses in the GENERIC format
+// are replayed from their first choice, with the tool calls in a single event; the grounding
+// information of COHERE responses is replayed in a single event after the text.
+func Replay(resp types.OracleCloudResponse) []byte {
+	var buf bytes.Buffer
+	write := func(event replayEvent) {
+		data, _ := json.Marshal(event)
+		buf.WriteString("data: ")
+		buf.Write(data)
+		buf.WriteString("\n\n")
+	}
+
+	apiFormat := resp.ChatResponse.APIFormat
+	finishReason := resp.ChatResponse.FinishReason
+	if len(resp.ChatResponse.Choices) > 0 {
+		choice := resp.ChatResponse.Choices[0]
+		for _, content := range choice.Message.Content {
+			if content.Type != "TEXT" {
+				continue
+			}
+			for _, piece := range split(content.Text, replayChunkSize) {
+				write(replayEvent{APIFormat: apiFormat, Message: &types.GenericMessage{
+					Role:    "ASSISTANT",
+					Content: []types.GenericContent{{Type: "TEXT", Text: piece}},
+				}})
+			}
+		}
+		if len(choice.Message.ToolCalls) > 0 {
+			write(replayEvent{APIFormat: apiFormat, Message: &types.GenericMessage{
+				Role:      "ASSISTANT",
+				ToolCalls: choice.Message.ToolCalls,
+			}})
+		}
+		finishReason = choice.FinishReason
+	} else {
+		for _, piece := range split(resp.ChatResponse.Text, replayChunkSize) {
+			write(replayEvent{APIFormat: apiFormat, Text: piece})
+		}
+		grounding := replayEvent{
+			APIFormat:        apiFormat,
+			Citations:        resp.ChatResponse.Citations,
+			Documents:        resp.ChatResponse.Documents,
+			SearchQueries:    resp.ChatResponse.SearchQueries,
+			IsSearchRequired: resp.ChatResponse.IsSearchRequired,
+		}
+		if grounding.Citations != nil || grounding.Documents != nil || grounding.SearchQueries != nil || grounding.IsSearchRequired != nil {
+			write(grounding)
+		}
+	}
+	write(replayEvent{
+		APIFormat:    apiFormat,
+		FinishReason: finishReason,
+		Usage:        resp.ChatResponse.Usage,
+	})
+
+	return buf.Bytes()
+}
+
+// split cuts text into pieces of roughly size bytes, preferring to cut after whitespace
+// and never cutting inside a UTF-8 sequence.
+func split(text string, size int) []string {
+	var pieces []string
+	for len(text) > 0 {
+		if len(text) <= size+size/2 {
+			pieces = append(pieces, text)
+			break
+		}
+
+		cut := -1
+		for i, r := range text {
+			if i >= size*2 {
+				break
+			}
+			if i >= size && (r == ' ' || r == '\n') {
+				cut = i + 1
+				break
+			}
+		}
+		if cut < 0 {
+			cut = size
+			for cut < len(text) && !utf8.RuneStart(text[cut]) {
+				cut++
+			}
+		}
+
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	return pieces
+}
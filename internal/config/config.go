@@ -4,6 +4,11 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 )
 
 // Config represents the plugin configuration with all available options.
@@ -14,6 +19,11 @@ type Config struct {
 	// This is required and must be provided in the plugin configuration.
 	CompartmentID string `json:"compartmentId,omitempty"`
 
+	// Endpoint is the OCI GenAI inference endpoint requests are routed to,
+	// e.g. https://inference.generativeai.us-chicago-1.oci.oraclecloud.com.
+	// Default: the inference endpoint of the instance's region
+	Endpoint string `json:"endpoint,omitempty"`
+
 	// MaxTokens is the default maximum number of tokens to generate.
 	// This can be overridden by individual requests.
 	MaxTokens int `json:"maxTokens,omitempty"`
@@ -37,6 +47,495 @@ type Config struct {
 	// TopK limits the number of highest probability tokens to consider.
 	// 0 means no limit. Default: 0
 	TopK int `json:"topK,omitempty"`
+
+	// APIFormat is the OCI chat API format: "COHERE" or "GENERIC".
+	// Default: chosen per request, GENERIC for non-Cohere model families and for
+	// requests with tools or images, COHERE otherwise
+	APIFormat string `json:"apiFormat,omitempty"`
+
+	// RateLimit configures the token-aware rate limiter.
+	// It is disabled unless at least one budget is set.
+	RateLimit RateLimit `json:"rateLimit,omitempty"`
+
+	// Usage configures usage metering and cost accounting.
+	Usage Usage `json:"usage,omitempty"`
+
+	// Metrics configures the Prometheus metrics endpoint.
+	Metrics Metrics `json:"metrics,omitempty"`
+
+	// Tracing configures OpenTelemetry span export.
+	Tracing Tracing `json:"tracing,omitempty"`
+
+	// Logging configures the plugin log output.
+	Logging Logging `json:"logging,omitempty"`
+
+	// Cache configures the exact-match response cache.
+	Cache Cache `json:"cache,omitempty"`
+
+	// Responses configures the store of the OpenAI Responses API.
+	Responses Responses `json:"responses,omitempty"`
+
+	// Ollama configures the Ollama compatible endpoints.
+	Ollama Ollama `json:"ollama,omitempty"`
+
+	// Batches configures the OpenAI Files and Batch APIs.
+	Batches Batches `json:"batches,omitempty"`
+
+	// Targets configures the selection of the compartment and identity of each request.
+	// Default: every request goes to CompartmentID, signed by the instance principal
+	Targets Targets `json:"targets,omitempty"`
+
+	// Guardrails configures the rules applied to prompts and generated text.
+	Guardrails Guardrails `json:"guardrails,omitempty"`
+
+	// Moderation configures the checks of the OCI applyGuardrails action.
+	Moderation Moderation `json:"moderation,omitempty"`
+
+	// Policies are the parameter policies of chat and text completion requests, applied in order.
+	Policies []ParameterPolicy `json:"policies,omitempty"`
+
+	// ContextWindow configures the context window checks made before requests are sent to OCI.
+	ContextWindow ContextWindow `json:"contextWindow,omitempty"`
+
+	// Reload configures the reloading of the configuration from a watched file.
+	// Default: disabled
+	Reload Reload `json:"reload,omitempty"`
+}
+
+// Reload configures a YAML or JSON file of plugin configuration overriding the inline one, read
+// when the plugin starts and again whenever it changes. Changes are validated and applied to the
+// requests that follow; invalid files are rejected and the running configuration is kept. The
+// usage, metrics, tracing, logging, cache, responses and batches sections only change on restart.
+type Reload struct {
+	// File is the configuration file; files with a .json extension are read as JSON, all others
+	// as YAML. It may not set the reload section.
+	File string `json:"file,omitempty"`
+
+	// Interval is how often the file is checked for changes, e.g. "5s". Default: 5s
+	Interval string `json:"interval,omitempty"`
+}
+
+// ContextWindow configures the checks of the estimated size of chat and text completion requests
+// against the context window and the output limit of their model. Requests to models missing
+// from the registry are not checked.
+type ContextWindow struct {
+	// Enabled turns the checks on.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Truncate drops the oldest messages of conversations exceeding the context window until
+	// they fit, instead of rejecting them. System messages and the last message are kept.
+	// Default: false
+	Truncate bool `json:"truncate,omitempty"`
+
+	// Models add models to the built-in registry or override their capabilities.
+	Models []ModelCapability `json:"models,omitempty"`
+}
+
+// ModelCapability is the context window and output limit of a model.
+type ModelCapability struct {
+	// Model is the model ID; a trailing * matches a prefix, e.g. meta.llama-3.1-*.
+	Model string `json:"model"`
+
+	// ContextWindow is the number of tokens of the prompt and the completion together.
+	ContextWindow int `json:"contextWindow,omitempty"`
+
+	// MaxOutputTokens is the number of tokens the model generates at most.
+	// Default: the context window
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+}
+
+// ParameterPolicy sets the defaults, bounds and forbidden parameters of the chat and text
+// completion requests of some models and client keys. Parameters are named as in OpenAI
+// requests: max_tokens, temperature, top_p, top_k, frequency_penalty and presence_penalty.
+type ParameterPolicy struct {
+	// Name identifies the policy in errors and logs.
+	Name string `json:"name,omitempty"`
+
+	// Models are the model IDs the policy applies to; a trailing * matches a prefix, e.g. cohere.*.
+	// Default: every model
+	Models []string `json:"models,omitempty"`
+
+	// Keys are the client keys the policy applies to, as sent in the rateLimit keyHeader, or
+	// file:// or env: references. Default: every key
+	Keys []string `json:"keys,omitempty" secret:"true"`
+
+	// Defaults replace the configured defaults of the parameters the client does not set.
+	Defaults map[string]float64 `json:"defaults,omitempty"`
+
+	// Min and Max bound the parameters, whether set by the client or defaulted; equal bounds
+	// fix a parameter.
+	Min map[string]float64 `json:"min,omitempty"`
+	Max map[string]float64 `json:"max,omitempty"`
+
+	// Forbid are the parameters the client may not set; requests setting them are rejected.
+	Forbid []string `json:"forbid,omitempty"`
+}
+
+// Moderation configures content moderation, prompt injection and PII detection by the OCI
+// applyGuardrails action, for the OpenAI moderations endpoint and for proxied requests.
+type Moderation struct {
+	// Enabled serves /v1/moderations.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Input checks the prompts of chat and text completion requests before they are sent to the
+	// model. Requires Enabled. Default: false
+	Input bool `json:"input,omitempty"`
+
+	// Output checks the text generated for non-streamed responses. Requires Enabled. Default: false
+	Output bool `json:"output,omitempty"`
+
+	// Categories are the content moderation categories scored: OVERALL and BLOCKLIST.
+	// Default: [OVERALL, BLOCKLIST]
+	Categories []string `json:"categories,omitempty"`
+
+	// PIITypes are the types of personal data detected, such as EMAIL or TELEPHONE_NUMBER.
+	// Default: none, personal data is not detected
+	PIITypes []string `json:"piiTypes,omitempty"`
+
+	// ContentThreshold is the content moderation score from which text is flagged; 0 disables
+	// content moderation. Default: 0.5
+	ContentThreshold float64 `json:"contentThreshold,omitempty"`
+
+	// PromptInjectionThreshold is the prompt injection score from which prompts are flagged; 0
+	// disables prompt injection detection. Generated text is not checked for prompt injection.
+	// Default: 0.5
+	PromptInjectionThreshold float64 `json:"promptInjectionThreshold,omitempty"`
+
+	// PIIThreshold is the score from which detected personal data flags text. Default: 0.5
+	PIIThreshold float64 `json:"piiThreshold,omitempty"`
+
+	// FailOpen lets requests and responses through when the checks fail. Default: false,
+	// they are rejected
+	FailOpen bool `json:"failOpen,omitempty"`
+
+	// MaxInputs is the maximum number of inputs of a moderations request, each checked by its own
+	// applyGuardrails call; 0 disables the limit. Default: 32
+	MaxInputs int `json:"maxInputs,omitempty"`
+}
+
+// Guardrails configures the rules applied to the prompts of chat and text completion requests
+// before they reach the model, and to the text it generates, streams included.
+type Guardrails struct {
+	// Rules are applied in order; the masks applied by a rule are seen by the next ones.
+	Rules []GuardrailRule `json:"rules,omitempty"`
+
+	// Holdback is the number of bytes of streamed text held back so that matches spanning
+	// chunks are found. Default: 64
+	Holdback int `json:"holdback,omitempty"`
+}
+
+// GuardrailRule is a rule matching keywords, a regular expression or personal data.
+type GuardrailRule struct {
+	// Name identifies the rule in errors and logs.
+	Name string `json:"name,omitempty"`
+
+	// Type is keywords, regex or pii.
+	Type string `json:"type,omitempty"`
+
+	// Keywords are matched as whole words, ignoring case, by keywords rules.
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Pattern is the Go regular expression of regex rules.
+	Pattern string `json:"pattern,omitempty"`
+
+	// Detectors are the personal data detected by pii rules: email, phone, card and ssn.
+	// Default: all of them
+	Detectors []string `json:"detectors,omitempty"`
+
+	// Action is block, mask or log. Default: block
+	Action string `json:"action,omitempty"`
+
+	// Mask replaces the matches of mask rules.
+	// Default: [REDACTED], or [EMAIL], [PHONE], [CARD] and [SSN] for pii rules
+	Mask string `json:"mask,omitempty"`
+
+	// Stage is the text the rule applies to: input, output or both. Default: both
+	Stage string `json:"stage,omitempty"`
+}
+
+// Targets configures per-request selection of the OCI compartment requests are sent to and of the
+// identity signing them. A compartment is selected by a trusted header or by the policy of the
+// client key, and must always be listed in Compartments; other requests go to CompartmentID.
+type Targets struct {
+	// Header is a request header naming the compartment of a request, by name or ID. It must be
+	// set by a trusted gateway, as clients could otherwise pick any allowed compartment.
+	// Default: disabled
+	Header string `json:"header,omitempty"`
+
+	// Compartments is the allowlist of the compartments requests may select.
+	Compartments []Compartment `json:"compartments,omitempty"`
+
+	// Identities are the identities compartments can be signed for, in addition to the
+	// instance principal.
+	Identities []Identity `json:"identities,omitempty"`
+
+	// Identity is the name of the identity signing requests to CompartmentID and to the
+	// compartments naming none. Default: the instance principal
+	Identity string `json:"identity,omitempty"`
+
+	// Keys restricts client keys to some compartments; the first is used when the request selects none.
+	Keys []KeyPolicy `json:"keys,omitempty"`
+}
+
+// Compartment is a compartment requests may select.
+type Compartment struct {
+	// Name identifies the compartment in the header and key policies.
+	Name string `json:"name,omitempty"`
+
+	// ID is the OCID of the compartment.
+	ID string `json:"id,omitempty"`
+
+	// Identity is the name of the identity signing requests to the compartment.
+	// Default: the identity of CompartmentID
+	Identity string `json:"identity,omitempty"`
+}
+
+// Identity is an OCI identity signing requests, with its own credentials and cached tokens.
+type Identity struct {
+	// Name identifies the identity in compartments.
+	Name string `json:"name,omitempty"`
+
+	// Auth is the authentication method: instance_principal, api_key or security_token.
+	// Default: instance_principal
+	Auth string `json:"auth,omitempty"`
+
+	// ConfigFile is the OCI CLI configuration file of API keys and security tokens.
+	// Default: ~/.oci/config
+	ConfigFile string `json:"configFile,omitempty"`
+
+	// Profile is the profile of the configuration file. Default: DEFAULT
+	Profile string `json:"profile,omitempty"`
+
+	// PrivateKey is the PEM private key of API keys and security tokens, usually a file:// or env:
+	// reference. Default: the key_file of the profile
+	PrivateKey string `json:"privateKey,omitempty" secret:"true"`
+
+	// Passphrase is the passphrase of the private key, usually a file:// or env: reference.
+	// Default: the pass_phrase of the profile
+	Passphrase string `json:"passphrase,omitempty" secret:"true"`
+
+	// Endpoint is the OCI GenAI inference endpoint of the identity.
+	// Default: Endpoint, or the inference endpoint of the identity's region
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// KeyPolicy restricts a client key to some compartments.
+type KeyPolicy struct {
+	// Key is the client key, as sent in the rateLimit keyHeader, or a file:// or env: reference.
+	Key string `json:"key,omitempty" secret:"true"`
+
+	// Compartments are the names of the compartments the key may use; the first is its default.
+	Compartments []string `json:"compartments,omitempty"`
+}
+
+// Cache configures the exact-match response cache. Only deterministic requests
+// (temperature 0) are cached, unless the client opts in with OptInHeader.
+type Cache struct {
+	// Enabled turns the cache on.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// MaxEntries is the maximum number of cached responses. Default: 1000
+	MaxEntries int `json:"maxEntries,omitempty"`
+
+	// MaxBytes is the maximum total size of cached responses. Default: 67108864 (64 MiB)
+	MaxBytes int `json:"maxBytes,omitempty"`
+
+	// TTL is how long a response is cached, e.g. "1h". Default: 1h
+	TTL string `json:"ttl,omitempty"`
+
+	// OptInHeader is the request header clients set to "true" to cache non-deterministic requests.
+	// Default: X-OCIGenAI-Cache
+	OptInHeader string `json:"optInHeader,omitempty"`
+}
+
+// Responses configures the store keeping the conversations of stored Responses API responses,
+// which later requests continue through previous_response_id.
+type Responses struct {
+	// Store is where stored responses are kept: memory or file. Default: memory
+	Store string `json:"store,omitempty"`
+
+	// Path is the directory of the file store, one file per response.
+	Path string `json:"path,omitempty"`
+
+	// TTL is how long a stored response can be continued, e.g. "24h". Default: 24h
+	TTL string `json:"ttl,omitempty"`
+
+	// MaxEntries is the maximum number of responses kept by the memory store. Default: 10000
+	MaxEntries int `json:"maxEntries,omitempty"`
+}
+
+// Ollama configures the Ollama compatible endpoints. Chat, generate and embed requests are
+// served for any model; only the model list needs configuring.
+type Ollama struct {
+	// Models are the OCI model IDs listed by /api/tags, for clients that pick a model from the list.
+	Models []string `json:"models,omitempty"`
+}
+
+// Batches configures the OpenAI Files and Batch APIs. Batches run in the background with bounded
+// concurrency; uploaded files, results and the state of every batch are kept in a directory so
+// that batches survive restarts.
+type Batches struct {
+	// Enabled serves /v1/files and /v1/batches.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Path is the directory of the files and batches. Required when enabled.
+	Path string `json:"path,omitempty"`
+
+	// Concurrency is the number of batch requests sent at once. Default: 4
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// MaxFileBytes is the maximum size of an uploaded file. Default: 209715200 (200 MiB)
+	MaxFileBytes int64 `json:"maxFileBytes,omitempty"`
+
+	// MaxRequests is the maximum number of requests in a batch. Default: 50000
+	MaxRequests int `json:"maxRequests,omitempty"`
+}
+
+// Logging configures the structured log output of the plugin.
+// Credentials are always redacted from log entries.
+type Logging struct {
+	// Level is the minimum level logged: debug, info, warn or error. Default: info
+	Level string `json:"level,omitempty"`
+
+	// Format is either text (logfmt style) or json. Default: text
+	Format string `json:"format,omitempty"`
+
+	// Body controls debug logging of request bodies: off, truncated or full. Default: off
+	Body string `json:"body,omitempty"`
+
+	// BodyLimit is the number of bytes kept when bodies are truncated. Default: 1024
+	BodyLimit int `json:"bodyLimit,omitempty"`
+
+	// RedactPII also redacts email addresses, phone, card and social security numbers.
+	RedactPII bool `json:"redactPII,omitempty"`
+}
+
+// Tracing configures the export of OpenTelemetry spans over OTLP/HTTP JSON.
+type Tracing struct {
+	// Enabled turns span creation and export on.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Endpoint is the OTLP/HTTP traces endpoint, e.g. http://collector:4318/v1/traces.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ServiceName is reported as the service.name resource attribute. Default: ocigenai
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Headers are added to every export request, e.g. collector authentication. Their values may
+	// be file:// or env: references.
+	Headers map[string]string `json:"headers,omitempty" secret:"true"`
+
+	// BatchSize is the maximum number of spans per export. Default: 512
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// FlushInterval is how often queued spans are exported, e.g. "5s". Default: 5s
+	FlushInterval string `json:"flushInterval,omitempty"`
+}
+
+// Metrics configures the Prometheus metrics endpoint served by the plugin.
+type Metrics struct {
+	// Enabled turns metrics collection on.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Path is the path on which metrics are served. Default: /metrics
+	Path string `json:"path,omitempty"`
+}
+
+// RateLimit holds the per-minute request and token budgets enforced by the plugin.
+// Budgets apply to each combination of client key and model independently, up to MaxKeys
+// combinations at once.
+type RateLimit struct {
+	// RequestsPerMinute is the number of requests allowed per minute. 0 disables the request budget.
+	RequestsPerMinute int `json:"requestsPerMinute,omitempty"`
+
+	// TokensPerMinute is the number of tokens (prompt + completion) allowed per minute.
+	// 0 disables the token budget.
+	TokensPerMinute int `json:"tokensPerMinute,omitempty"`
+
+	// KeyHeader is the request header identifying the client key. Default: Authorization
+	KeyHeader string `json:"keyHeader,omitempty"`
+
+	// Models overrides the budgets for specific models.
+	Models []ModelRateLimit `json:"models,omitempty"`
+
+	// MaxKeys is the maximum number of budgets tracked at once. Past it, the least recently used
+	// budget is evicted, and starts full again if its combination returns. 0 disables the limit.
+	// Default: 10000
+	MaxKeys int `json:"maxKeys,omitempty"`
+}
+
+// ModelRateLimit overrides the default budgets for a single model.
+type ModelRateLimit struct {
+	// Model is the model ID the override applies to.
+	Model string `json:"model"`
+
+	// RequestsPerMinute is the number of requests allowed per minute for this model.
+	RequestsPerMinute int `json:"requestsPerMinute,omitempty"`
+
+	// TokensPerMinute is the number of tokens allowed per minute for this model.
+	TokensPerMinute int `json:"tokensPerMinute,omitempty"`
+}
+
+// Usage configures the usage ledger that records every completed request.
+type Usage struct {
+	// Enabled turns usage metering on.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// AdminPath is the path on which the plugin serves usage totals. Default: /ocigenai/usage
+	AdminPath string `json:"adminPath,omitempty"`
+
+	// AdminToken is the bearer token required to read the usage totals, preferably a secret
+	// reference. Default: the totals are not served
+	AdminToken string `json:"adminToken,omitempty" secret:"true"`
+
+	// Sinks lists the destinations usage records are written to.
+	Sinks []UsageSink `json:"sinks,omitempty"`
+
+	// Prices is the per-model price table used to estimate cost.
+	Prices []ModelPrice `json:"prices,omitempty"`
+}
+
+// UsageSink describes a destination for usage records.
+type UsageSink struct {
+	// Type is one of "file", "stdout" or "webhook".
+	Type string `json:"type"`
+
+	// Path is the JSONL file records are appended to (file sinks).
+	Path string `json:"path,omitempty"`
+
+	// URL is the endpoint batches of records are posted to (webhook sinks).
+	URL string `json:"url,omitempty"`
+
+	// BatchSize is the number of records sent per webhook call. Default: 100
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// FlushInterval is the maximum time records are held before being sent, e.g. "10s". Default: 10s
+	FlushInterval string `json:"flushInterval,omitempty"`
+}
+
+// ModelPrice is the price of a model per one million tokens.
+type ModelPrice struct {
+	// Model is the model ID the price applies to.
+	Model string `json:"model"`
+
+	// PromptPerMillion is the price of one million prompt tokens.
+	PromptPerMillion float64 `json:"promptPerMillion,omitempty"`
+
+	// CompletionPerMillion is the price of one million completion tokens.
+	CompletionPerMillion float64 `json:"completionPerMillion,omitempty"`
+}
+
+// Enabled reports whether any request or token budget is configured.
+func (r RateLimit) Enabled() bool {
+	if r.RequestsPerMinute > 0 || r.TokensPerMinute > 0 {
+		return true
+	}
+	for _, m := range r.Models {
+		if m.RequestsPerMinute > 0 || m.TokensPerMinute > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // New creates a new configuration with sensible defaults.
@@ -49,16 +548,73 @@ func New() *Config {
 		FrequencyPenalty: 0.0,  // No repetition penalty by default
 		PresencePenalty:  0.0,  // No presence penalty by default
 		TopK:             0,    // No token limit by default
+		RateLimit: RateLimit{
+			KeyHeader: "Authorization", // Standard OpenAI bearer key
+			MaxKeys:   10000,
+		},
+		Usage: Usage{
+			AdminPath: "/ocigenai/usage",
+		},
+		Metrics: Metrics{
+			Path: "/metrics",
+		},
+		Tracing: Tracing{
+			ServiceName: "ocigenai",
+		},
+		Cache: Cache{
+			MaxEntries:  1000,
+			MaxBytes:    64 << 20,
+			TTL:         "1h",
+			OptInHeader: "X-OCIGenAI-Cache",
+		},
+		Responses: Responses{
+			Store:      "memory",
+			TTL:        "24h",
+			MaxEntries: 10000,
+		},
+		Guardrails: Guardrails{
+			Holdback: 64,
+		},
+		Moderation: Moderation{
+			Categories:               []string{"OVERALL", "BLOCKLIST"},
+			ContentThreshold:         0.5,
+			PromptInjectionThreshold: 0.5,
+			PIIThreshold:             0.5,
+			MaxInputs:                32,
+		},
+		Batches: Batches{
+			Concurrency:  4,
+			MaxFileBytes: 200 << 20,
+			MaxRequests:  50000,
+		},
+		Logging: Logging{
+			Level:     "info",
+			Format:    "text",
+			Body:      "off",
+			BodyLimit: 1024,
+		},
+		Reload: Reload{
+			Interval: "5s",
+		},
 	}
 }
 
 // Validate checks if the configuration is valid and returns an error if not.
-// Currently, it only validates that the required CompartmentID is provided.
+// It requires CompartmentID, checks the model parameters against their ranges and validates each
+// section, such as the rate limits, targets, policies, guardrails and reload settings. Errors of a
+// section are prefixed with its name.
 func (c *Config) Validate() error {
 	if c.CompartmentID == "" {
 		return fmt.Errorf("compartmentId is required and cannot be empty")
 	}
 
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("endpoint must be an http(s) URL, got %q", c.Endpoint)
+		}
+	}
+
 	// Additional validation could be added here for parameter ranges
 	if c.Temperature < 0.0 || c.Temperature > 2.0 {
 		return fmt.Errorf("temperature must be between 0.0 and 2.0, got %f", c.Temperature)
@@ -84,5 +640,516 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("topK must be non-negative, got %d", c.TopK)
 	}
 
+	if c.APIFormat != "" && c.APIFormat != "COHERE" && c.APIFormat != "GENERIC" {
+		return fmt.Errorf("apiFormat must be COHERE or GENERIC, got %q", c.APIFormat)
+	}
+
+	if err := c.RateLimit.validate(); err != nil {
+		return fmt.Errorf("rateLimit: %w", err)
+	}
+
+	if err := c.Usage.validate(); err != nil {
+		return fmt.Errorf("usage: %w", err)
+	}
+
+	if c.Metrics.Enabled && c.Metrics.Path == "" {
+		return fmt.Errorf("metrics: path cannot be empty")
+	}
+
+	if err := c.Tracing.validate(); err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+
+	if err := c.Logging.validate(); err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
+
+	if err := c.Cache.validate(); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+
+	if err := c.Responses.validate(); err != nil {
+		return fmt.Errorf("responses: %w", err)
+	}
+
+	if err := c.Ollama.validate(); err != nil {
+		return fmt.Errorf("ollama: %w", err)
+	}
+
+	if err := c.Batches.validate(); err != nil {
+		return fmt.Errorf("batches: %w", err)
+	}
+
+	if err := c.Targets.validate(); err != nil {
+		return fmt.Errorf("targets: %w", err)
+	}
+
+	if err := c.Guardrails.validate(); err != nil {
+		return fmt.Errorf("guardrails: %w", err)
+	}
+
+	if err := c.Moderation.validate(); err != nil {
+		return fmt.Errorf("moderation: %w", err)
+	}
+
+	for i, policy := range c.Policies {
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("policies[%d]: %w", i, err)
+		}
+	}
+
+	if err := c.ContextWindow.validate(); err != nil {
+		return fmt.Errorf("contextWindow: %w", err)
+	}
+
+	if err := c.Reload.validate(); err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+
+	return nil
+}
+
+func (r RateLimit) validate() error {
+	if r.RequestsPerMinute < 0 {
+		return fmt.Errorf("requestsPerMinute must be non-negative, got %d", r.RequestsPerMinute)
+	}
+
+	if r.TokensPerMinute < 0 {
+		return fmt.Errorf("tokensPerMinute must be non-negative, got %d", r.TokensPerMinute)
+	}
+
+	if r.MaxKeys < 0 {
+		return fmt.Errorf("maxKeys must be non-negative, got %d", r.MaxKeys)
+	}
+
+	for _, m := range r.Models {
+		if m.Model == "" {
+			return fmt.Errorf("model is required for model overrides")
+		}
+		if m.RequestsPerMinute < 0 || m.TokensPerMinute < 0 {
+			return fmt.Errorf("budgets for model %s must be non-negative", m.Model)
+		}
+	}
+
+	return nil
+}
+
+func (u Usage) validate() error {
+	if !u.Enabled {
+		return nil
+	}
+
+	if u.AdminPath == "" {
+		return fmt.Errorf("adminPath cannot be empty")
+	}
+
+	for _, sink := range u.Sinks {
+		switch sink.Type {
+		case "file":
+			if sink.Path == "" {
+				return fmt.Errorf("path is required for file sinks")
+			}
+		case "stdout":
+		case "webhook":
+			if sink.URL == "" {
+				return fmt.Errorf("url is required for webhook sinks")
+			}
+		default:
+			return fmt.Errorf("unknown sink type %q", sink.Type)
+		}
+
+		if sink.BatchSize < 0 {
+			return fmt.Errorf("batchSize must be non-negative, got %d", sink.BatchSize)
+		}
+
+		if sink.FlushInterval != "" {
+			if _, err := time.ParseDuration(sink.FlushInterval); err != nil {
+				return fmt.Errorf("invalid flushInterval %q: %w", sink.FlushInterval, err)
+			}
+		}
+	}
+
+	for _, price := range u.Prices {
+		if price.Model == "" {
+			return fmt.Errorf("model is required for prices")
+		}
+		if price.PromptPerMillion < 0 || price.CompletionPerMillion < 0 {
+			return fmt.Errorf("prices for model %s must be non-negative", price.Model)
+		}
+	}
+
+	return nil
+}
+
+func (t Tracing) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+
+	if t.Endpoint == "" {
+		return fmt.Errorf("endpoint is required")
+	}
+
+	if t.BatchSize < 0 {
+		return fmt.Errorf("batchSize must be non-negative, got %d", t.BatchSize)
+	}
+
+	if t.FlushInterval != "" {
+		if _, err := time.ParseDuration(t.FlushInterval); err != nil {
+			return fmt.Errorf("invalid flushInterval %q: %w", t.FlushInterval, err)
+		}
+	}
+
+	return nil
+}
+
+func (l Logging) validate() error {
+	switch l.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("unknown level %q", l.Level)
+	}
+
+	switch l.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("unknown format %q", l.Format)
+	}
+
+	switch l.Body {
+	case "", "off", "truncated", "full":
+	default:
+		return fmt.Errorf("unknown body mode %q", l.Body)
+	}
+
+	if l.BodyLimit < 0 {
+		return fmt.Errorf("bodyLimit must be non-negative, got %d", l.BodyLimit)
+	}
+
+	return nil
+}
+
+func (c Cache) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("maxEntries must be non-negative, got %d", c.MaxEntries)
+	}
+
+	if c.MaxBytes < 0 {
+		return fmt.Errorf("maxBytes must be non-negative, got %d", c.MaxBytes)
+	}
+
+	if c.TTL != "" {
+		if _, err := time.ParseDuration(c.TTL); err != nil {
+			return fmt.Errorf("invalid ttl %q: %w", c.TTL, err)
+		}
+	}
+
+	return nil
+}
+
+func (r Responses) validate() error {
+	switch r.Store {
+	case "", "memory":
+	case "file":
+		if r.Path == "" {
+			return fmt.Errorf("path is required for the file store")
+		}
+	default:
+		return fmt.Errorf("unknown store %q", r.Store)
+	}
+
+	if r.MaxEntries < 0 {
+		return fmt.Errorf("maxEntries must be non-negative, got %d", r.MaxEntries)
+	}
+
+	if r.TTL != "" {
+		if _, err := time.ParseDuration(r.TTL); err != nil {
+			return fmt.Errorf("invalid ttl %q: %w", r.TTL, err)
+		}
+	}
+
+	return nil
+}
+
+func (o Ollama) validate() error {
+	for i, model := range o.Models {
+		if model == "" {
+			return fmt.Errorf("models[%d] must not be empty", i)
+		}
+	}
+
+	return nil
+}
+
+func (b Batches) validate() error {
+	if b.Enabled && b.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	if b.Concurrency < 0 {
+		return fmt.Errorf("concurrency must be non-negative, got %d", b.Concurrency)
+	}
+
+	if b.MaxFileBytes < 0 {
+		return fmt.Errorf("maxFileBytes must be non-negative, got %d", b.MaxFileBytes)
+	}
+
+	if b.MaxRequests < 0 {
+		return fmt.Errorf("maxRequests must be non-negative, got %d", b.MaxRequests)
+	}
+
+	return nil
+}
+
+func (t Targets) validate() error {
+	if t.Header != "" && len(t.Compartments) == 0 {
+		return fmt.Errorf("compartments are required with a header")
+	}
+
+	identities := make(map[string]bool, len(t.Identities))
+	for i, identity := range t.Identities {
+		if identity.Name == "" {
+			return fmt.Errorf("identities[%d]: name is required", i)
+		}
+		if identities[identity.Name] {
+			return fmt.Errorf("duplicate identity %q", identity.Name)
+		}
+		identities[identity.Name] = true
+
+		switch identity.Auth {
+		case "", "instance_principal", "api_key", "security_token":
+		default:
+			return fmt.Errorf("identity %s: unknown auth %q", identity.Name, identity.Auth)
+		}
+		if (identity.PrivateKey != "" || identity.Passphrase != "") && (identity.Auth == "" || identity.Auth == "instance_principal") {
+			return fmt.Errorf("identity %s: privateKey and passphrase require api_key or security_token auth", identity.Name)
+		}
+		if identity.Endpoint != "" {
+			u, err := url.Parse(identity.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("identity %s: endpoint must be an http(s) URL, got %q", identity.Name, identity.Endpoint)
+			}
+		}
+	}
+
+	if t.Identity != "" && !identities[t.Identity] {
+		return fmt.Errorf("unknown identity %q", t.Identity)
+	}
+
+	compartments := make(map[string]bool, len(t.Compartments))
+	for i, compartment := range t.Compartments {
+		if compartment.Name == "" || compartment.ID == "" {
+			return fmt.Errorf("compartments[%d]: name and id are required", i)
+		}
+		if compartments[compartment.Name] {
+			return fmt.Errorf("duplicate compartment %q", compartment.Name)
+		}
+		compartments[compartment.Name] = true
+
+		if compartment.Identity != "" && !identities[compartment.Identity] {
+			return fmt.Errorf("compartment %s: unknown identity %q", compartment.Name, compartment.Identity)
+		}
+	}
+
+	for i, policy := range t.Keys {
+		if policy.Key == "" {
+			return fmt.Errorf("keys[%d]: key is required", i)
+		}
+		if len(policy.Compartments) == 0 {
+			return fmt.Errorf("keys[%d]: compartments are required", i)
+		}
+		for _, name := range policy.Compartments {
+			if !compartments[name] {
+				return fmt.Errorf("keys[%d]: unknown compartment %q", i, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g Guardrails) validate() error {
+	if g.Holdback < 0 {
+		return fmt.Errorf("holdback must be non-negative, got %d", g.Holdback)
+	}
+
+	names := make(map[string]bool, len(g.Rules))
+	for i, r := range g.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rules[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Type {
+		case "keywords":
+			if len(r.Keywords) == 0 {
+				return fmt.Errorf("rule %s: keywords are required", r.Name)
+			}
+			for _, keyword := range r.Keywords {
+				if keyword == "" {
+					return fmt.Errorf("rule %s: keywords must not be empty", r.Name)
+				}
+			}
+		case "regex":
+			if r.Pattern == "" {
+				return fmt.Errorf("rule %s: pattern is required", r.Name)
+			}
+			if _, err := regexp.Compile(r.Pattern); err != nil {
+				return fmt.Errorf("rule %s: invalid pattern: %w", r.Name, err)
+			}
+		case "pii":
+			for _, detector := range r.Detectors {
+				switch detector {
+				case "email", "phone", "card", "ssn":
+				default:
+					return fmt.Errorf("rule %s: unknown detector %q", r.Name, detector)
+				}
+			}
+		default:
+			return fmt.Errorf("rule %s: unknown type %q", r.Name, r.Type)
+		}
+
+		switch r.Action {
+		case "", "block", "mask", "log":
+		default:
+			return fmt.Errorf("rule %s: unknown action %q", r.Name, r.Action)
+		}
+
+		switch r.Stage {
+		case "", "input", "output", "both":
+		default:
+			return fmt.Errorf("rule %s: unknown stage %q", r.Name, r.Stage)
+		}
+	}
+
+	return nil
+}
+
+func (m Moderation) validate() error {
+	if (m.Input || m.Output) && !m.Enabled {
+		return fmt.Errorf("input and output checks require enabled")
+	}
+
+	for _, category := range m.Categories {
+		if category != "OVERALL" && category != "BLOCKLIST" {
+			return fmt.Errorf("unknown category %q", category)
+		}
+	}
+
+	for _, piiType := range m.PIITypes {
+		if piiType == "" {
+			return fmt.Errorf("piiTypes must not be empty")
+		}
+	}
+
+	if m.MaxInputs < 0 {
+		return fmt.Errorf("maxInputs must be non-negative, got %d", m.MaxInputs)
+	}
+
+	thresholds := []struct {
+		name  string
+		value float64
+	}{
+		{"contentThreshold", m.ContentThreshold},
+		{"promptInjectionThreshold", m.PromptInjectionThreshold},
+		{"piiThreshold", m.PIIThreshold},
+	}
+	for _, threshold := range thresholds {
+		if threshold.value < 0 || threshold.value > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %f", threshold.name, threshold.value)
+		}
+	}
+
+	if m.Enabled && (m.ContentThreshold == 0 || len(m.Categories) == 0) && m.PromptInjectionThreshold == 0 && len(m.PIITypes) == 0 {
+		return fmt.Errorf("at least one check must be configured")
+	}
+
+	return nil
+}
+
+// policyParameters are the parameters policies apply to, with whether they are whole numbers.
+var policyParameters = map[string]bool{
+	"max_tokens":        true,
+	"temperature":       false,
+	"top_p":             false,
+	"top_k":             true,
+	"frequency_penalty": false,
+	"presence_penalty":  false,
+}
+
+func (p ParameterPolicy) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	for _, model := range p.Models {
+		if model == "" || strings.Contains(strings.TrimSuffix(model, "*"), "*") {
+			return fmt.Errorf("policy %s: invalid model %q", p.Name, model)
+		}
+	}
+
+	for _, values := range []map[string]float64{p.Defaults, p.Min, p.Max} {
+		for name, value := range values {
+			whole, ok := policyParameters[name]
+			if !ok {
+				return fmt.Errorf("policy %s: unknown parameter %q", p.Name, name)
+			}
+			if whole && value != math.Trunc(value) {
+				return fmt.Errorf("policy %s: %s must be a whole number, got %v", p.Name, name, value)
+			}
+		}
+	}
+
+	for name, min := range p.Min {
+		if max, ok := p.Max[name]; ok && min > max {
+			return fmt.Errorf("policy %s: min %s is greater than its max", p.Name, name)
+		}
+	}
+
+	for _, name := range p.Forbid {
+		if _, ok := policyParameters[name]; !ok {
+			return fmt.Errorf("policy %s: unknown parameter %q", p.Name, name)
+		}
+	}
+
+	return nil
+}
+
+func (w ContextWindow) validate() error {
+	for i, m := range w.Models {
+		if m.Model == "" || strings.Contains(strings.TrimSuffix(m.Model, "*"), "*") {
+			return fmt.Errorf("models[%d]: invalid model %q", i, m.Model)
+		}
+		if m.ContextWindow < 1 {
+			return fmt.Errorf("model %s: contextWindow must be greater than 0, got %d", m.Model, m.ContextWindow)
+		}
+		if m.MaxOutputTokens < 0 || m.MaxOutputTokens > m.ContextWindow {
+			return fmt.Errorf("model %s: maxOutputTokens must be between 0 and the context window, got %d", m.Model, m.MaxOutputTokens)
+		}
+	}
+
+	return nil
+}
+
+func (r Reload) validate() error {
+	if r.File == "" {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(r.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval %q: %w", r.Interval, err)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", r.Interval)
+	}
+
 	return nil
 }
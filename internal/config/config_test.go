@@ -115,3 +115,444 @@ func TestValidate_InvalidTopK(t *testing.T) {
 		t.Error("expected error for invalid topK")
 	}
 }
+
+func TestValidate_APIFormat(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+
+	for _, format := range []string{"", "COHERE", "GENERIC"} {
+		cfg.APIFormat = format
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected apiFormat %q to be valid, got %v", format, err)
+		}
+	}
+
+	cfg.APIFormat = "cohere"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid apiFormat")
+	}
+}
+
+func TestValidate_InvalidRateLimit(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.RateLimit.TokensPerMinute = -1
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("expected error for invalid rateLimit")
+	}
+}
+
+func TestValidate_InvalidRateLimitMaxKeys(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.RateLimit.MaxKeys = -1
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative rateLimit.maxKeys")
+	}
+}
+
+func TestValidate_RateLimitModelRequired(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.RateLimit.Models = []ModelRateLimit{{RequestsPerMinute: 10}}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Error("expected error for rate limit override without model")
+	}
+}
+
+func TestRateLimitEnabled(t *testing.T) {
+	cfg := New()
+	if cfg.RateLimit.Enabled() {
+		t.Error("expected rate limiting to be disabled by default")
+	}
+
+	cfg.RateLimit.Models = []ModelRateLimit{{Model: "cohere.command-r-plus", TokensPerMinute: 1000}}
+	if !cfg.RateLimit.Enabled() {
+		t.Error("expected model override to enable rate limiting")
+	}
+}
+
+func TestValidate_UsageSinks(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.Usage.Enabled = true
+	cfg.Usage.Sinks = []UsageSink{{Type: "webhook"}}
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for webhook sink without url")
+	}
+
+	cfg.Usage.Sinks = []UsageSink{{Type: "kafka"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for unknown sink type")
+	}
+
+	cfg.Usage.Sinks = []UsageSink{{Type: "webhook", URL: "http://collector", FlushInterval: "soon"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid flushInterval")
+	}
+
+	cfg.Usage.Sinks = []UsageSink{{Type: "file", Path: "/var/log/usage.jsonl"}, {Type: "stdout"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid usage config, got: %v", err)
+	}
+}
+
+func TestValidate_MetricsPath(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Path = ""
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for empty metrics path")
+	}
+}
+
+func TestValidate_Tracing(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.Tracing.Enabled = true
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for missing tracing endpoint")
+	}
+
+	cfg.Tracing.Endpoint = "http://collector:4318/v1/traces"
+	cfg.Tracing.FlushInterval = "soon"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid flush interval")
+	}
+
+	cfg.Tracing.FlushInterval = "2s"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid tracing config, got %v", err)
+	}
+}
+
+func TestValidate_Logging(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Logging)
+		wantErr bool
+	}{
+		{"defaults", func(*Logging) {}, false},
+		{"json debug full", func(l *Logging) { l.Level, l.Format, l.Body = "debug", "json", "full" }, false},
+		{"unknown level", func(l *Logging) { l.Level = "trace" }, true},
+		{"unknown format", func(l *Logging) { l.Format = "xml" }, true},
+		{"unknown body mode", func(l *Logging) { l.Body = "some" }, true},
+		{"negative body limit", func(l *Logging) { l.BodyLimit = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := New()
+			cfg.CompartmentID = "test-compartment-id"
+			tt.modify(&cfg.Logging)
+
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Cache(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.Cache.Enabled = true
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected default cache config to be valid, got %v", err)
+	}
+
+	cfg.Cache.TTL = "forever"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid ttl")
+	}
+
+	cfg.Cache.TTL = "10m"
+	cfg.Cache.MaxEntries = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative maxEntries")
+	}
+}
+
+func TestValidate_Responses(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected default responses config to be valid, got %v", err)
+	}
+
+	cfg.Responses.Store = "file"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for file store without path")
+	}
+
+	cfg.Responses.Path = "/var/lib/ocigenai/responses"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected file store with path to be valid, got %v", err)
+	}
+
+	cfg.Responses.Store = "redis"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for unknown store")
+	}
+
+	cfg.Responses.Store = "memory"
+	cfg.Responses.TTL = "forever"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid ttl")
+	}
+}
+
+func TestValidate_Ollama(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+
+	cfg.Ollama.Models = []string{"meta.llama-3.3-70b-instruct"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected model list to be valid, got %v", err)
+	}
+
+	cfg.Ollama.Models = append(cfg.Ollama.Models, "")
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for empty model")
+	}
+}
+
+func TestValidate_Batches(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+
+	cfg.Batches.Enabled = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for batches without path")
+	}
+
+	cfg.Batches.Path = "/var/lib/ocigenai/batches"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected batches with path to be valid, got %v", err)
+	}
+
+	cfg.Batches.Concurrency = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative concurrency")
+	}
+}
+
+func TestValidate_Targets(t *testing.T) {
+	valid := func() *Config {
+		cfg := New()
+		cfg.CompartmentID = "test-compartment-id"
+		cfg.Targets = Targets{
+			Header:       "X-OCI-Compartment",
+			Identities:   []Identity{{Name: "team-b", Auth: "api_key", Profile: "TEAM_B"}},
+			Compartments: []Compartment{{Name: "team-a", ID: "ocid1.compartment.oc1..a"}, {Name: "team-b", ID: "ocid1.compartment.oc1..b", Identity: "team-b"}},
+			Keys:         []KeyPolicy{{Key: "sk-team-a", Compartments: []string{"team-a"}}},
+		}
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected targets to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Targets)
+	}{
+		{"header without compartments", func(tg *Targets) { tg.Compartments = nil; tg.Keys = nil }},
+		{"compartment without id", func(tg *Targets) { tg.Compartments[0].ID = "" }},
+		{"duplicate compartment", func(tg *Targets) { tg.Compartments[1].Name = "team-a" }},
+		{"unknown identity", func(tg *Targets) { tg.Compartments[1].Identity = "team-c" }},
+		{"unknown default identity", func(tg *Targets) { tg.Identity = "team-c" }},
+		{"unknown auth", func(tg *Targets) { tg.Identities[0].Auth = "password" }},
+		{"private key of the instance principal", func(tg *Targets) {
+			tg.Identities[0].Auth = "instance_principal"
+			tg.Identities[0].PrivateKey = "env:TEAM_B_KEY"
+		}},
+		{"key without compartments", func(tg *Targets) { tg.Keys[0].Compartments = nil }},
+		{"key with unknown compartment", func(tg *Targets) { tg.Keys[0].Compartments = []string{"team-c"} }},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.modify(&cfg.Targets)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidate_Guardrails(t *testing.T) {
+	valid := func() *Config {
+		cfg := New()
+		cfg.CompartmentID = "test-compartment-id"
+		cfg.Guardrails.Rules = []GuardrailRule{
+			{Name: "blocklist", Type: "keywords", Keywords: []string{"secret"}},
+			{Name: "ids", Type: "regex", Pattern: `EMP-\d+`, Action: "mask", Stage: "output"},
+			{Name: "pii", Type: "pii", Detectors: []string{"email"}, Action: "log"},
+		}
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected guardrails to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Guardrails)
+	}{
+		{"negative holdback", func(g *Guardrails) { g.Holdback = -1 }},
+		{"rule without name", func(g *Guardrails) { g.Rules[0].Name = "" }},
+		{"duplicate rule", func(g *Guardrails) { g.Rules[1].Name = "blocklist" }},
+		{"empty keyword", func(g *Guardrails) { g.Rules[0].Keywords = []string{""} }},
+		{"invalid pattern", func(g *Guardrails) { g.Rules[1].Pattern = "(" }},
+		{"unknown detector", func(g *Guardrails) { g.Rules[2].Detectors = []string{"passport"} }},
+		{"unknown type", func(g *Guardrails) { g.Rules[0].Type = "model" }},
+		{"unknown action", func(g *Guardrails) { g.Rules[0].Action = "warn" }},
+		{"unknown stage", func(g *Guardrails) { g.Rules[0].Stage = "tools" }},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.modify(&cfg.Guardrails)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidate_Moderation(t *testing.T) {
+	valid := func() *Config {
+		cfg := New()
+		cfg.CompartmentID = "test-compartment-id"
+		cfg.Moderation.Enabled = true
+		cfg.Moderation.Input = true
+		cfg.Moderation.PIITypes = []string{"EMAIL"}
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected moderation to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Moderation)
+	}{
+		{"checks without enabled", func(m *Moderation) { m.Enabled = false }},
+		{"unknown category", func(m *Moderation) { m.Categories = []string{"HATE"} }},
+		{"empty PII type", func(m *Moderation) { m.PIITypes = []string{""} }},
+		{"threshold above 1", func(m *Moderation) { m.ContentThreshold = 1.5 }},
+		{"negative threshold", func(m *Moderation) { m.PIIThreshold = -0.1 }},
+		{"negative max inputs", func(m *Moderation) { m.MaxInputs = -1 }},
+		{"no check", func(m *Moderation) { m.ContentThreshold, m.PromptInjectionThreshold, m.PIITypes = 0, 0, nil }},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.modify(&cfg.Moderation)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidate_Policies(t *testing.T) {
+	valid := func() *Config {
+		cfg := New()
+		cfg.CompartmentID = "test-compartment-id"
+		cfg.Policies = []ParameterPolicy{{
+			Name:     "interns",
+			Models:   []string{"cohere.*"},
+			Defaults: map[string]float64{"temperature": 0},
+			Max:      map[string]float64{"max_tokens": 2048},
+			Forbid:   []string{"top_k"},
+		}}
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("expected policies to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*ParameterPolicy)
+	}{
+		{"no name", func(p *ParameterPolicy) { p.Name = "" }},
+		{"inner wildcard", func(p *ParameterPolicy) { p.Models = []string{"cohere.*-plus"} }},
+		{"unknown parameter", func(p *ParameterPolicy) { p.Min = map[string]float64{"seed": 1} }},
+		{"fractional max_tokens", func(p *ParameterPolicy) { p.Max["max_tokens"] = 10.5 }},
+		{"min above max", func(p *ParameterPolicy) { p.Min = map[string]float64{"max_tokens": 4096} }},
+		{"unknown forbidden parameter", func(p *ParameterPolicy) { p.Forbid = []string{"seed"} }},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.modify(&cfg.Policies[0])
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidate_ContextWindow(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.ContextWindow.Enabled = true
+	cfg.ContextWindow.Models = []ModelCapability{{Model: "custom.*", ContextWindow: 8000, MaxOutputTokens: 2000}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected context window config to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		model ModelCapability
+	}{
+		{"no model", ModelCapability{ContextWindow: 8000}},
+		{"inner wildcard", ModelCapability{Model: "custom.*-v2", ContextWindow: 8000}},
+		{"no context window", ModelCapability{Model: "custom.small"}},
+		{"output above the window", ModelCapability{Model: "custom.small", ContextWindow: 8000, MaxOutputTokens: 9000}},
+	}
+	for _, tt := range tests {
+		cfg.ContextWindow.Models = []ModelCapability{tt.model}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestValidate_Reload(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.Reload.File = "/etc/ocigenai/config.yaml"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected reload config to be valid, got %v", err)
+	}
+
+	for _, interval := range []string{"", "soon", "0s", "-5s"} {
+		cfg.Reload.Interval = interval
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected an error for interval %q", interval)
+		}
+	}
+}
+
+func TestValidate_Endpoint(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "test-compartment-id"
+
+	cfg.Endpoint = "https://inference.generativeai.us-chicago-1.oci.oraclecloud.com"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid endpoint, got %v", err)
+	}
+
+	cfg.Endpoint = "inference.generativeai.us-chicago-1.oci.oraclecloud.com"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for endpoint without scheme")
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DecodeFile decodes a YAML or JSON file into v using its JSON struct tags.
+// Files with a .json extension are decoded as JSON, all others as YAML.
+// Fields absent from the file keep their current value.
+func DecodeFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := Decode(data, filepath.Ext(path) == ".json", v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// Decode decodes a YAML or JSON document into v using its JSON struct tags.
+func Decode(data []byte, isJSON bool, v interface{}) error {
+	data, err := toJSON(data, isJSON)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Overlay decodes a YAML or JSON document of plugin configuration over cfg. Each top level option
+// the document sets replaces the one of cfg as a whole, with the defaults of New for the fields it
+// leaves out, so lists and maps are never merged with their previous content. It returns the
+// names of the options set.
+func Overlay(cfg *Config, data []byte, isJSON bool) ([]string, error) {
+	fresh := New()
+	if err := Decode(data, isJSON, fresh); err != nil {
+		return nil, err
+	}
+
+	data, err := toJSON(data, isJSON)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var set []string
+	dst, src := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(fresh).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		name := strings.Split(dst.Type().Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := doc[name]; ok {
+			dst.Field(i).Set(src.Field(i))
+			set = append(set, name)
+		}
+	}
+	return set, nil
+}
+
+// toJSON converts a YAML document to JSON, and returns JSON documents as they are.
+func toJSON(data []byte, isJSON bool) ([]byte, error) {
+	if isJSON {
+		return data, nil
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// ApplyEnv overrides the scalar fields of the struct pointed to by v from environment variables.
+// Variable names are the prefix followed by the upper snake case JSON names of the field path,
+// e.g. OCIGENAI_COMPARTMENT_ID or OCIGENAI_LOGGING_LEVEL. Slices and maps are not supported.
+func ApplyEnv(v interface{}, prefix string, lookup func(string) (string, bool)) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ApplyEnv expects a pointer to a struct")
+	}
+	return applyEnv(value.Elem(), prefix, lookup)
+}
+
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		target := v.Field(i)
+
+		// Embedded structs share the prefix of their parent, as their fields are promoted in JSON
+		if field.Anonymous {
+			if target.Kind() == reflect.Ptr && !target.IsNil() {
+				target = target.Elem()
+			}
+			if target.Kind() == reflect.Struct {
+				if err := applyEnv(target, prefix, lookup); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + "_" + envName(name)
+
+		if target.Kind() == reflect.Struct {
+			if err := applyEnv(target, key, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setScalar(target, raw); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setScalar(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
+
+// envName converts a camelCase JSON name to UPPER_SNAKE_CASE, e.g. compartmentId to COMPARTMENT_ID.
+func envName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			// Keep acronyms together: redactPII becomes REDACT_PII
+			if !unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
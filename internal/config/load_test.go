@@ -0,0 +1,202 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `---
+# Plugin configuration
+compartmentId: "ocid1.compartment.oc1..example" # quoted
+maxTokens: 800
+temperature: 0.5
+name: it's plain
+empty:
+rateLimit:
+  tokensPerMinute: 1000
+  models:
+    - model: cohere.command-r-plus
+      tokensPerMinute: 100
+    - model: 'meta.llama'
+  keys:
+  - a
+  - b
+flow: [1, "two", 'three, four']
+`
+
+	got, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatalf("failed to parse yaml: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"compartmentId": "ocid1.compartment.oc1..example",
+		"maxTokens":     int64(800),
+		"temperature":   0.5,
+		"name":          "it's plain",
+		"empty":         nil,
+		"rateLimit": map[string]interface{}{
+			"tokensPerMinute": int64(1000),
+			"models": []interface{}{
+				map[string]interface{}{"model": "cohere.command-r-plus", "tokensPerMinute": int64(100)},
+				map[string]interface{}{"model": "meta.llama"},
+			},
+			"keys": []interface{}{"a", "b"},
+		},
+		"flow": []interface{}{int64(1), "two", "three, four"},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected document:\n%#v", got)
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	tests := map[string]struct {
+		doc      string
+		expected string
+	}{
+		"bad indentation":       {doc: "a:\n    b: 1\n  c: 2\n", expected: "bad indentation"},
+		"duplicate key":         {doc: "a: 1\na: 2\n", expected: "duplicate key"},
+		"not a mapping":         {doc: "just text\n", expected: "expected key: value"},
+		"unterminated sequence": {doc: "a: [1, 2\n", expected: "unterminated flow sequence"},
+		"anchor":                {doc: "a: &base\n  b: 1\n", expected: "anchors and aliases are not supported"},
+		"alias":                 {doc: "a: *base\n", expected: "anchors and aliases are not supported"},
+		"alias item":            {doc: "a:\n  - *base\n", expected: "anchors and aliases are not supported"},
+		"tag":                   {doc: "a: !!str 1\n", expected: "tags are not supported"},
+		"flow mapping":          {doc: "a: {b: 1}\n", expected: "flow mappings are not supported"},
+		"flow mapping item":     {doc: "a: [1, {b: 1}]\n", expected: "flow mappings are not supported"},
+		"literal scalar":        {doc: "a: |\n  one\n  two\n", expected: "block scalars are not supported"},
+		"folded scalar":         {doc: "a: >-\n  one\n", expected: "block scalars are not supported"},
+		"plain continuation":    {doc: "a: one\n  two\nb: 1\n", expected: "line 2: multi-line scalars are not supported"},
+		"item continuation":     {doc: "a:\n  - one\n    two\n", expected: "line 3: multi-line scalars are not supported"},
+		"quoted continuation":   {doc: "a: \"one\n  two\"\n", expected: "line 1: unterminated quoted string"},
+		"multiple documents":    {doc: "a: 1\n---\nb: 2\n", expected: "multiple documents are not supported"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseYAML([]byte(test.doc))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestDecodeFile(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(yamlPath, []byte("compartmentId: yaml\nlogging:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"compartmentId":"json","maxTokens":100}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New()
+	if err := DecodeFile(yamlPath, cfg); err != nil {
+		t.Fatalf("failed to decode yaml: %v", err)
+	}
+	if cfg.CompartmentID != "yaml" || cfg.Logging.Level != "debug" || cfg.MaxTokens != 600 {
+		t.Errorf("unexpected config from yaml: %+v", cfg)
+	}
+
+	cfg = New()
+	if err := DecodeFile(jsonPath, cfg); err != nil {
+		t.Fatalf("failed to decode json: %v", err)
+	}
+	if cfg.CompartmentID != "json" || cfg.MaxTokens != 100 {
+		t.Errorf("unexpected config from json: %+v", cfg)
+	}
+
+	if err := os.WriteFile(yamlPath, []byte("compartment: typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeFile(yamlPath, New()); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	cfg := New()
+	cfg.CompartmentID = "inline"
+	cfg.RateLimit.RequestsPerMinute = 10
+	cfg.Tracing.Headers = map[string]string{"a": "1", "b": "2"}
+	cfg.Policies = []ParameterPolicy{{Name: "a", Forbid: []string{"temperature"}, Max: map[string]float64{"max_tokens": 10}}}
+
+	doc := "maxTokens: 100\nrateLimit:\n  tokensPerMinute: 500\ntracing:\n  headers:\n    b: \"3\"\npolicies:\n  - name: b\n"
+	set, err := Overlay(cfg, []byte(doc), false)
+	if err != nil {
+		t.Fatalf("failed to overlay: %v", err)
+	}
+
+	if expected := []string{"maxTokens", "rateLimit", "tracing", "policies"}; !reflect.DeepEqual(set, expected) {
+		t.Errorf("expected options %v to be set, got %v", expected, set)
+	}
+	if cfg.CompartmentID != "inline" || cfg.MaxTokens != 100 {
+		t.Errorf("expected options left out to be kept, got %q and %d", cfg.CompartmentID, cfg.MaxTokens)
+	}
+	if cfg.RateLimit.RequestsPerMinute != 0 || cfg.RateLimit.TokensPerMinute != 500 || cfg.RateLimit.MaxKeys != New().RateLimit.MaxKeys {
+		t.Errorf("expected the rate limits to be replaced, got %+v", cfg.RateLimit)
+	}
+	if !reflect.DeepEqual(cfg.Tracing.Headers, map[string]string{"b": "3"}) {
+		t.Errorf("expected the headers to be replaced, got %v", cfg.Tracing.Headers)
+	}
+	if !reflect.DeepEqual(cfg.Policies, []ParameterPolicy{{Name: "b"}}) {
+		t.Errorf("expected the policies to be replaced, got %+v", cfg.Policies)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"OCIGENAI_COMPARTMENT_ID":     "from-env",
+		"OCIGENAI_TEMPERATURE":        "0.2",
+		"OCIGENAI_MAX_TOKENS":         "42",
+		"OCIGENAI_LOGGING_LEVEL":      "warn",
+		"OCIGENAI_LOGGING_REDACT_PII": "true",
+		"OCIGENAI_METRICS_ENABLED":    "true",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	cfg := New()
+	if err := ApplyEnv(cfg, "OCIGENAI", lookup); err != nil {
+		t.Fatalf("failed to apply env: %v", err)
+	}
+
+	if cfg.CompartmentID != "from-env" || cfg.Temperature != 0.2 || cfg.MaxTokens != 42 {
+		t.Errorf("unexpected top-level fields: %+v", cfg)
+	}
+	if cfg.Logging.Level != "warn" || !cfg.Logging.RedactPII || !cfg.Metrics.Enabled {
+		t.Errorf("unexpected nested fields: %+v %+v", cfg.Logging, cfg.Metrics)
+	}
+
+	env["OCIGENAI_MAX_TOKENS"] = "many"
+	if err := ApplyEnv(New(), "OCIGENAI", lookup); err == nil {
+		t.Error("expected error for invalid integer")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"compartmentId":     "COMPARTMENT_ID",
+		"topK":              "TOP_K",
+		"redactPII":         "REDACT_PII",
+		"requestsPerMinute": "REQUESTS_PER_MINUTE",
+		"ttl":               "TTL",
+	}
+	for name, expected := range tests {
+		if got := envName(name); got != expected {
+			t.Errorf("envName(%q): expected %s, got %s", name, expected, got)
+		}
+	}
+}
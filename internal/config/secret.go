@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Prefixes of the references to secrets kept out of the configuration.
+const (
+	FileReference = "file://" // file:///run/secrets/key.pem is the content of the file
+	EnvReference  = "env:"    // env:OCI_KEY_PASSPHRASE is the value of the environment variable
+)
+
+// ResolveSecrets replaces the references to secrets in the fields tagged `secret:"true"` of the
+// struct pointed to by v, and of its nested structs, with the secrets; a tagged field is a string,
+// or a slice or map of strings. References in other fields are kept as they are. Files are read
+// whole, without their trailing line break; environment variables are looked up with lookup and
+// must be set. The resolved secrets are returned so that they can be redacted; errors name the
+// references only.
+func ResolveSecrets(v interface{}, lookup func(string) (string, bool)) ([]string, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ResolveSecrets expects a pointer to a struct")
+	}
+	r := &resolver{lookup: lookup}
+	if err := r.resolve(value.Elem(), "", false); err != nil {
+		return nil, err
+	}
+	return r.secrets, nil
+}
+
+// SecretFiles returns the paths of the files referenced by the secret fields of the struct
+// pointed to by v, without reading them, so that they can be watched for rotation.
+func SecretFiles(v interface{}) []string {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	r := &resolver{filesOnly: true}
+	_ = r.resolve(value.Elem(), "", false)
+	return r.files
+}
+
+// resolver resolves the references of a configuration and collects their secrets, or only
+// collects the files they reference.
+type resolver struct {
+	lookup    func(string) (string, bool)
+	secrets   []string
+	filesOnly bool
+	files     []string
+}
+
+// resolve resolves the references of v, a secret field or a part of one when secret is true.
+func (r *resolver) resolve(v reflect.Value, path string, secret bool) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return r.resolve(v.Elem(), path, secret)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" || field.Anonymous {
+				name = field.Name
+			}
+			if err := r.resolve(v.Field(i), joinPath(path, name), field.Tag.Get("secret") == "true"); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := r.resolve(v.Index(i), fmt.Sprintf("%s[%d]", path, i), secret); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !secret || v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			secret, ok, err := r.secret(v.MapIndex(key).String())
+			if err != nil {
+				return fmt.Errorf("%s.%v: %w", path, key, err)
+			}
+			if ok {
+				v.SetMapIndex(key, reflect.ValueOf(secret).Convert(v.Type().Elem()))
+			}
+		}
+	case reflect.String:
+		if !secret {
+			return nil
+		}
+		value, ok, err := r.secret(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if ok {
+			v.SetString(value)
+		}
+	}
+	return nil
+}
+
+// secret returns the secret value refers to, and false when value is not a reference.
+func (r *resolver) secret(value string) (string, bool, error) {
+	var secret string
+	switch {
+	case strings.HasPrefix(value, FileReference):
+		path := strings.TrimPrefix(value, FileReference)
+		if r.filesOnly {
+			r.files = append(r.files, path)
+			return "", false, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read secret file %s: %w", path, err)
+		}
+		secret = strings.TrimRight(string(data), "\r\n")
+	case r.filesOnly:
+		return "", false, nil
+	case strings.HasPrefix(value, EnvReference):
+		name := strings.TrimPrefix(value, EnvReference)
+		env, ok := r.lookup(name)
+		if !ok {
+			return "", false, fmt.Errorf("environment variable %s is not set", name)
+		}
+		secret = env
+	default:
+		return "", false, nil
+	}
+
+	if secret != "" {
+		r.secrets = append(r.secrets, secret)
+	}
+	return secret, true, nil
+}
+
+// joinPath appends the JSON name of a field to the path of its parent.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyPath, []byte("sk-from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"TEAM_B_PASSPHRASE": "hunter2", "COLLECTOR_TOKEN": "Bearer abc", "ADMIN_TOKEN": "admin-token", "EVAL_KEY": "sk-eval"}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	cfg := New()
+	cfg.CompartmentID = "ocid1.compartment.oc1..fake"
+	cfg.Targets.Identities = []Identity{{Name: "team-b", Auth: "api_key", Passphrase: "env:TEAM_B_PASSPHRASE"}}
+	cfg.Targets.Keys = []KeyPolicy{{Key: "file://" + keyPath, Compartments: []string{"team-b"}}}
+	cfg.Tracing.Headers = map[string]string{"Authorization": "env:COLLECTOR_TOKEN"}
+	cfg.Usage.AdminToken = "env:ADMIN_TOKEN"
+	cfg.Policies = []ParameterPolicy{{Name: "eval", Keys: []string{"env:EVAL_KEY"}}}
+	cfg.Responses.Path = "env:TEAM_B_PASSPHRASE"
+
+	secrets, err := ResolveSecrets(cfg, lookup)
+	if err != nil {
+		t.Fatalf("failed to resolve secrets: %v", err)
+	}
+	if cfg.Targets.Identities[0].Passphrase != "hunter2" || cfg.Targets.Keys[0].Key != "sk-from-file" || cfg.Tracing.Headers["Authorization"] != "Bearer abc" {
+		t.Errorf("unexpected resolved config: %+v %+v", cfg.Targets, cfg.Tracing.Headers)
+	}
+	if cfg.Usage.AdminToken != "admin-token" || cfg.Policies[0].Keys[0] != "sk-eval" {
+		t.Errorf("unexpected resolved config: %q %+v", cfg.Usage.AdminToken, cfg.Policies[0].Keys)
+	}
+	if cfg.CompartmentID != "ocid1.compartment.oc1..fake" {
+		t.Errorf("expected plain values to be kept, got %q", cfg.CompartmentID)
+	}
+	if cfg.Responses.Path != "env:TEAM_B_PASSPHRASE" {
+		t.Errorf("expected references outside of secret fields to be kept, got %q", cfg.Responses.Path)
+	}
+	if len(secrets) != 5 {
+		t.Errorf("expected the 5 secrets to be returned, got %d", len(secrets))
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	cfg := New()
+	cfg.Targets.Identities = []Identity{{Name: "team-b", Auth: "api_key", PrivateKey: "file:///run/secrets/team-b.pem", Passphrase: "env:TEAM_B_PASSPHRASE"}}
+	cfg.Tracing.Headers = map[string]string{"Authorization": "file:///run/secrets/collector"}
+	cfg.Responses.Path = "file:///var/lib/responses"
+
+	files := SecretFiles(cfg)
+	if len(files) != 2 || !containsFile(files, "/run/secrets/team-b.pem") || !containsFile(files, "/run/secrets/collector") {
+		t.Errorf("expected the files of the secret fields, got %v", files)
+	}
+}
+
+func containsFile(files []string, path string) bool {
+	for _, file := range files {
+		if file == path {
+			return true
+		}
+	}
+	return false
+}
+
+func TestResolveSecrets_Errors(t *testing.T) {
+	lookup := func(string) (string, bool) { return "", false }
+
+	cfg := New()
+	cfg.Targets.Keys = []KeyPolicy{{Key: "env:MISSING_KEY"}}
+	_, err := ResolveSecrets(cfg, lookup)
+	if err == nil || !strings.Contains(err.Error(), "targets.keys[0].key") || !strings.Contains(err.Error(), "MISSING_KEY") {
+		t.Errorf("expected an error naming the field and the variable, got %v", err)
+	}
+
+	cfg = New()
+	cfg.Targets.Keys = []KeyPolicy{{Key: "file://" + filepath.Join(t.TempDir(), "missing")}}
+	if _, err := ResolveSecrets(cfg, lookup); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,363 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML parses the subset of YAML used by configuration files into maps, slices and
+// scalars, so the result can be re-encoded as JSON and decoded with the struct tags.
+//
+// Supported: block mappings and sequences (including sequences of mappings), single-line
+// plain, single and double quoted scalars, single-line flow sequences of scalars, comments and
+// a leading document marker. Anchors, aliases, tags, flow mappings, block and multi-line
+// scalars and multiple documents are rejected with an error rather than misread.
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		p.lines = append(p.lines, yamlLine{number: i + 1, raw: raw})
+	}
+	p.prepare()
+	for _, line := range p.lines {
+		if line.text == "---" || line.text == "..." {
+			return nil, p.errorf(line, "multiple documents are not supported")
+		}
+	}
+
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return map[string]interface{}{}, nil
+	}
+
+	value, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "unexpected content")
+	}
+	return value, nil
+}
+
+type yamlLine struct {
+	number int
+	raw    string
+	indent int
+	text   string // content without indentation and comments; empty for blank lines
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(line yamlLine, format string, args ...interface{}) error {
+	return fmt.Errorf("yaml line %d: %s", line.number, fmt.Sprintf(format, args...))
+}
+
+// prepare computes the indentation and comment-free text of every line.
+func (p *yamlParser) prepare() {
+	for i := range p.lines {
+		line := &p.lines[i]
+		trimmed := strings.TrimLeft(line.raw, " ")
+		line.indent = len(line.raw) - len(trimmed)
+		line.text = strings.TrimSpace(stripComment(trimmed))
+		if i == 0 && line.text == "---" {
+			line.text = ""
+		}
+	}
+}
+
+// stripComment removes a trailing comment that is not inside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the mapping or sequence starting at the current line.
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			break
+		}
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "bad indentation")
+		}
+		if !isSequenceItem(line.text) {
+			break
+		}
+
+		rest := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if rest == "" {
+			p.pos++
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+
+		if _, _, ok := splitKey(rest); ok {
+			// An inline mapping starts on the item line: reparse it at the column of its first key
+			offset := strings.Index(line.raw, rest)
+			p.lines[p.pos].indent = offset
+			p.lines[p.pos].text = rest
+			value, err := p.parseMapping(offset)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+
+		value, err := parseScalar(rest)
+		if err != nil {
+			return nil, p.errorf(line, "%v", err)
+		}
+		items = append(items, value)
+		p.pos++
+		if err := p.checkContinuation(indent); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			break
+		}
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "bad indentation")
+		}
+		if isSequenceItem(line.text) {
+			break
+		}
+
+		key, value, ok := splitKey(line.text)
+		if !ok {
+			return nil, p.errorf(line, "expected key: value")
+		}
+		if _, exists := m[key]; exists {
+			return nil, p.errorf(line, "duplicate key %q", key)
+		}
+		p.pos++
+
+		switch {
+		case value == "":
+			// A nested block, or a sequence at the same indentation as its key
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+				nested, err := p.parseSequence(indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = nested
+				continue
+			}
+			nested, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nested
+		default:
+			scalar, err := parseScalar(value)
+			if err != nil {
+				return nil, p.errorf(line, "%v", err)
+			}
+			m[key] = scalar
+			if err := p.checkContinuation(indent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// parseNested parses the block indented deeper than parent, or returns nil if there is none.
+func (p *yamlParser) parseNested(parent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return nil, nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+// checkContinuation rejects a scalar continued on more indented lines after the line at indent.
+func (p *yamlParser) checkContinuation(indent int) error {
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.errorf(p.lines[p.pos], "multi-line scalars are not supported")
+	}
+	return nil
+}
+
+// splitKey splits "key: value" outside of quotes.
+func splitKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if unquoted, err := unquote(key); err == nil {
+				key = unquoted
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, fmt.Errorf("not quoted")
+}
+
+// parseScalar converts a scalar or single-line flow sequence to a value.
+func parseScalar(s string) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	switch s[0] {
+	case '"', '\'':
+		if len(s) < 2 || s[len(s)-1] != s[0] {
+			return nil, fmt.Errorf("unterminated quoted string %s, multi-line scalars are not supported", s)
+		}
+		value, err := unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", s)
+		}
+		return value, nil
+	case '&', '*':
+		return nil, fmt.Errorf("anchors and aliases are not supported")
+	case '!':
+		return nil, fmt.Errorf("tags are not supported")
+	case '|', '>':
+		return nil, fmt.Errorf("block scalars are not supported")
+	case '[':
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		items := []interface{}{}
+		for _, part := range splitFlow(s[1 : len(s)-1]) {
+			value, err := parseScalar(part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case '{':
+		return nil, fmt.Errorf("flow mappings are not supported")
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
+
+// splitFlow splits the content of a flow collection on commas outside quotes and brackets.
+func splitFlow(s string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
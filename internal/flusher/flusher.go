@@ -0,0 +1,71 @@
+// Package flusher runs the background flushes of the exporters that send their queued records in
+// batches: whenever a batch fills up, at a fixed interval, and a last time when they are closed.
+package flusher
+
+import (
+	"sync"
+	"time"
+)
+
+// Flusher calls a flush function from a background goroutine.
+type Flusher struct {
+	flush   func() error
+	onError func(error)
+	wakes   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	close   sync.Once
+}
+
+// Start calls flush every interval and whenever the flusher is woken, until it is closed. The
+// errors of the background flushes are passed to onError, when set.
+func Start(interval time.Duration, flush func() error, onError func(error)) *Flusher {
+	f := &Flusher{
+		flush:   flush,
+		onError: onError,
+		wakes:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go f.run(interval)
+	return f
+}
+
+// Wake requests a flush, once a batch is full. It does not wait for the flush.
+func (f *Flusher) Wake() {
+	select {
+	case f.wakes <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the background flushes and returns the error of a last flush. Later calls do
+// nothing.
+func (f *Flusher) Close() error {
+	var err error
+	f.close.Do(func() {
+		close(f.done)
+		<-f.stopped
+		err = f.flush()
+	})
+	return err
+}
+
+func (f *Flusher) run(interval time.Duration) {
+	defer close(f.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+		case <-f.wakes:
+		}
+		if err := f.flush(); err != nil && f.onError != nil {
+			f.onError(err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package flusher
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlusher_Wake(t *testing.T) {
+	var flushes int32
+	failed := make(chan error, 1)
+	f := Start(time.Hour, func() error {
+		atomic.AddInt32(&flushes, 1)
+		return errors.New("unreachable")
+	}, func(err error) { failed <- err })
+
+	f.Wake()
+	select {
+	case err := <-failed:
+		if err.Error() != "unreachable" {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a woken flusher to flush")
+	}
+
+	if err := f.Close(); err == nil {
+		t.Error("expected the error of the last flush")
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("expected a second close to do nothing, got %v", err)
+	}
+	if n := atomic.LoadInt32(&flushes); n != 2 {
+		t.Errorf("expected 2 flushes, got %d", n)
+	}
+}
+
+func TestFlusher_Interval(t *testing.T) {
+	flushed := make(chan struct{}, 1)
+	f := Start(time.Millisecond, func() error {
+		select {
+		case flushed <- struct{}{}:
+		default:
+		}
+		return nil
+	}, nil)
+	defer f.Close()
+
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a flush at the interval")
+	}
+}
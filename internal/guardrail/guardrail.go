@@ -0,0 +1,242 @@
+// Package guardrail applies content rules to the prompts sent to the model and to the text it
+// generates for the OCI GenAI proxy plugin.
+//
+// Rules match keywords, regular expressions or built-in PII detectors, and either block the
+// text, mask the matches or only report them. Generated text can be checked as a whole or, for
+// streams, incrementally through a Stream.
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// Stages of the text a rule applies to.
+const (
+	StageInput  = "input"  // Prompts sent to the model
+	StageOutput = "output" // Text generated by the model
+	StageBoth   = "both"
+)
+
+// Rule actions.
+const (
+	ActionBlock = "block" // Reject the prompt, or stop the generated text
+	ActionMask  = "mask"  // Replace the matches
+	ActionLog   = "log"   // Only report the matches
+)
+
+// defaultMask replaces the matches of keyword and regex rules.
+const defaultMask = "[REDACTED]"
+
+// Match reports the matches of a rule in a text.
+type Match struct {
+	Rule   string
+	Action string
+	Count  int
+}
+
+// Result is the outcome of applying the rules to a text.
+type Result struct {
+	// Text is the text with the masks applied
+	Text string
+
+	// Matches are the rules that matched, in order
+	Matches []Match
+
+	// Blocked is the blocking rule that matched, if any; later rules are not applied
+	Blocked *Match
+}
+
+// Pipeline applies rules in order; the masks applied by a rule are seen by the next ones.
+type Pipeline struct {
+	rules    []rule
+	holdback int
+}
+
+// rule is a compiled rule.
+type rule struct {
+	name     string
+	action   string
+	input    bool
+	output   bool
+	patterns []pattern
+}
+
+// pattern is a regular expression and the mask replacing its matches.
+type pattern struct {
+	re    *regexp.Regexp
+	mask  string
+	valid func(match string) bool // Rejects false positives; nil accepts every match
+}
+
+// detectors are the built-in PII detectors, by name, in the order they are applied.
+var detectors = map[string]pattern{
+	"email": {re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), mask: "[EMAIL]"},
+	"card":  {re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), mask: "[CARD]", valid: luhn},
+	"ssn":   {re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), mask: "[SSN]"},
+	"phone": {re: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`), mask: "[PHONE]"},
+}
+
+// detectorOrder applies card numbers before phone numbers, which match parts of them.
+var detectorOrder = []string{"email", "card", "ssn", "phone"}
+
+// New compiles the configured rules. The configuration must have been validated.
+func New(cfg config.Guardrails) (*Pipeline, error) {
+	p := &Pipeline{holdback: cfg.Holdback}
+
+	for _, c := range cfg.Rules {
+		r := rule{
+			name:   c.Name,
+			action: c.Action,
+			input:  c.Stage != StageOutput,
+			output: c.Stage != StageInput,
+		}
+		if r.action == "" {
+			r.action = ActionBlock
+		}
+		mask := c.Mask
+		if mask == "" {
+			mask = defaultMask
+		}
+
+		switch c.Type {
+		case "keywords":
+			words := make([]string, 0, len(c.Keywords))
+			for _, keyword := range c.Keywords {
+				words = append(words, keywordPattern(keyword))
+			}
+			r.patterns = []pattern{{re: regexp.MustCompile(`(?i)` + strings.Join(words, "|")), mask: mask}}
+		case "regex":
+			re, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", c.Name, err)
+			}
+			r.patterns = []pattern{{re: re, mask: mask}}
+		case "pii":
+			for _, name := range detectorOrder {
+				if len(c.Detectors) > 0 && !contains(c.Detectors, name) {
+					continue
+				}
+				detector := detectors[name]
+				if c.Mask != "" {
+					detector.mask = c.Mask
+				}
+				r.patterns = append(r.patterns, detector)
+			}
+		default:
+			return nil, fmt.Errorf("rule %s: unknown type %q", c.Name, c.Type)
+		}
+
+		p.rules = append(p.rules, r)
+	}
+
+	return p, nil
+}
+
+// keywordPattern matches keyword as a whole word: word characters at its edges must not be
+// followed or preceded by other word characters.
+func keywordPattern(keyword string) string {
+	quoted := regexp.QuoteMeta(keyword)
+	first, _ := utf8.DecodeRuneInString(keyword)
+	last, _ := utf8.DecodeLastRuneInString(keyword)
+	if isWordRune(first) {
+		quoted = `\b` + quoted
+	}
+	if isWordRune(last) {
+		quoted += `\b`
+	}
+	return quoted
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Apply applies the rules of stage to text.
+func (p *Pipeline) Apply(stage, text string) Result {
+	result := Result{Text: text}
+	for i := range p.rules {
+		r := &p.rules[i]
+		if !r.applies(stage) {
+			continue
+		}
+
+		count := 0
+		for _, pat := range r.patterns {
+			result.Text = pat.re.ReplaceAllStringFunc(result.Text, func(match string) string {
+				if pat.valid != nil && !pat.valid(match) {
+					return match
+				}
+				count++
+				if r.action == ActionMask {
+					return pat.mask
+				}
+				return match
+			})
+		}
+		if count == 0 {
+			continue
+		}
+
+		match := Match{Rule: r.name, Action: r.action, Count: count}
+		result.Matches = append(result.Matches, match)
+		if r.action == ActionBlock {
+			result.Blocked = &match
+			return result
+		}
+	}
+	return result
+}
+
+// applies reports whether the rule applies to stage.
+func (r *rule) applies(stage string) bool {
+	if stage == StageInput {
+		return r.input
+	}
+	return r.output
+}
+
+// Enabled reports whether any rule applies to stage.
+func (p *Pipeline) Enabled(stage string) bool {
+	for i := range p.rules {
+		if p.rules[i].applies(stage) {
+			return true
+		}
+	}
+	return false
+}
+
+// luhn reports whether the digits of a candidate card number pass the Luhn checksum.
+func luhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package guardrail
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+func newPipeline(t *testing.T, holdback int, rules ...config.GuardrailRule) *Pipeline {
+	t.Helper()
+	p, err := New(config.Guardrails{Rules: rules, Holdback: holdback})
+	if err != nil {
+		t.Fatalf("failed to create pipeline: %v", err)
+	}
+	return p
+}
+
+func TestApply_Keywords(t *testing.T) {
+	p := newPipeline(t, 64, config.GuardrailRule{Name: "secrets", Type: "keywords", Keywords: []string{"project x", "c++"}, Action: ActionMask})
+
+	tests := []struct {
+		text     string
+		expected string
+		count    int
+	}{
+		{"Tell me about Project X.", "Tell me about [REDACTED].", 1},
+		{"Tell me about project xylophone.", "Tell me about project xylophone.", 0},
+		{"I write C++ and c++.", "I write [REDACTED] and [REDACTED].", 2},
+	}
+	for _, tt := range tests {
+		result := p.Apply(StageInput, tt.text)
+		if result.Text != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.text, tt.expected, result.Text)
+		}
+		if tt.count == 0 && len(result.Matches) != 0 {
+			t.Errorf("%q: expected no match, got %+v", tt.text, result.Matches)
+		}
+		if tt.count > 0 && (len(result.Matches) != 1 || result.Matches[0].Count != tt.count) {
+			t.Errorf("%q: expected %d matches, got %+v", tt.text, tt.count, result.Matches)
+		}
+	}
+}
+
+func TestApply_PII(t *testing.T) {
+	p := newPipeline(t, 64, config.GuardrailRule{Name: "pii", Type: "pii", Action: ActionMask})
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"Mail jane.doe@example.com today", "Mail [EMAIL] today"},
+		{"Card 4111 1111 1111 1111 on file", "Card [CARD] on file"},
+		{"Order 4111 1111 1111 1112 shipped", "Order 4111 1111 1111 1112 shipped"},
+		{"SSN 123-45-6789", "SSN [SSN]"},
+		{"Call (555) 123-4567 or +1 555.123.4567", "Call [PHONE] or [PHONE]"},
+	}
+	for _, tt := range tests {
+		if result := p.Apply(StageOutput, tt.text); result.Text != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.text, tt.expected, result.Text)
+		}
+	}
+}
+
+func TestApply_StagesAndOrder(t *testing.T) {
+	p := newPipeline(t, 64,
+		config.GuardrailRule{Name: "audit", Type: "regex", Pattern: `(?i)password`, Action: ActionLog},
+		config.GuardrailRule{Name: "deny", Type: "keywords", Keywords: []string{"password"}, Stage: StageInput},
+		config.GuardrailRule{Name: "never", Type: "keywords", Keywords: []string{"password"}},
+	)
+
+	result := p.Apply(StageInput, "my password is hunter2")
+	if result.Blocked == nil || result.Blocked.Rule != "deny" {
+		t.Fatalf("expected the deny rule to block, got %+v", result.Blocked)
+	}
+	if result.Text != "my password is hunter2" {
+		t.Errorf("expected logged text to be unchanged, got %q", result.Text)
+	}
+	if len(result.Matches) != 2 || result.Matches[0].Rule != "audit" {
+		t.Errorf("expected audit and deny matches, got %+v", result.Matches)
+	}
+
+	result = p.Apply(StageOutput, "my password is hunter2")
+	if result.Blocked == nil || result.Blocked.Rule != "never" {
+		t.Errorf("expected the output rule to block, got %+v", result.Blocked)
+	}
+	if !p.Enabled(StageInput) || !p.Enabled(StageOutput) {
+		t.Error("expected both stages to be enabled")
+	}
+}
+
+func TestStream_HoldsBackMatches(t *testing.T) {
+	p := newPipeline(t, 24, config.GuardrailRule{Name: "pii", Type: "pii", Detectors: []string{"email"}, Action: ActionMask})
+	s := p.NewStream()
+
+	var released strings.Builder
+	for _, chunk := range []string{"Write to ", "jane", ".doe@exa", "mple.com", " for the details."} {
+		released.WriteString(s.Write(chunk).Text)
+	}
+	released.WriteString(s.Flush().Text)
+
+	if expected := "Write to [EMAIL] for the details."; released.String() != expected {
+		t.Errorf("expected %q, got %q", expected, released.String())
+	}
+}
+
+func TestStream_Block(t *testing.T) {
+	p := newPipeline(t, 16, config.GuardrailRule{Name: "deny", Type: "keywords", Keywords: []string{"forbidden"}})
+	s := p.NewStream()
+
+	var released strings.Builder
+	var blocked *Match
+	for _, chunk := range []string{"This is fine. ", "More text then forb", "idden stuff", " and the rest."} {
+		result := s.Write(chunk)
+		released.WriteString(result.Text)
+		if result.Blocked != nil {
+			blocked = result.Blocked
+		}
+	}
+	if result := s.Flush(); result.Blocked != nil {
+		blocked = result.Blocked
+	}
+
+	if blocked == nil || blocked.Rule != "deny" {
+		t.Fatalf("expected the deny rule to block, got %+v", blocked)
+	}
+	if strings.Contains(released.String(), "forb") {
+		t.Errorf("expected the match not to be released, got %q", released.String())
+	}
+	if result := s.Write("more"); result.Text != "" {
+		t.Errorf("expected nothing to be released once blocked, got %q", result.Text)
+	}
+}
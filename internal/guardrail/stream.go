@@ -0,0 +1,80 @@
+package guardrail
+
+import "unicode/utf8"
+
+// Stream applies the output rules to text generated incrementally. The end of the text is held
+// back, so that matches spanning increments are found before any of their text is released:
+// matches longer than the holdback may be released unmasked.
+type Stream struct {
+	pipeline *Pipeline
+	pending  string
+	blocked  bool
+}
+
+// NewStream creates a stream applying the output rules to a single generated text.
+func (p *Pipeline) NewStream() *Stream {
+	return &Stream{pipeline: p}
+}
+
+// Write adds generated text. The returned text can be released; once a result is blocked,
+// nothing more must be released.
+func (s *Stream) Write(text string) Result {
+	if s.blocked {
+		return Result{}
+	}
+	s.pending += text
+
+	cut := len(s.pending) - s.pipeline.holdback
+	if cut <= 0 {
+		return Result{}
+	}
+	cut = s.cutBefore(cut)
+	ready := s.pending[:cut]
+	s.pending = s.pending[cut:]
+	return s.apply(ready)
+}
+
+// Flush releases the text held back at the end of the generated text.
+func (s *Stream) Flush() Result {
+	if s.blocked || s.pending == "" {
+		return Result{}
+	}
+	ready := s.pending
+	s.pending = ""
+	return s.apply(ready)
+}
+
+func (s *Stream) apply(text string) Result {
+	result := s.pipeline.Apply(StageOutput, text)
+	if result.Blocked != nil {
+		s.blocked = true
+		result.Text = ""
+	}
+	return result
+}
+
+// cutBefore moves cut back to a rune boundary outside any match of an output rule, so that
+// matches are always released whole.
+func (s *Stream) cutBefore(cut int) int {
+	for moved := true; moved && cut > 0; {
+		moved = false
+		for cut < len(s.pending) && !utf8.RuneStart(s.pending[cut]) {
+			cut--
+		}
+		for i := range s.pipeline.rules {
+			r := &s.pipeline.rules[i]
+			if !r.output {
+				continue
+			}
+			for _, pat := range r.patterns {
+				for _, loc := range pat.re.FindAllStringIndex(s.pending, -1) {
+					if loc[0] < cut && cut < loc[1] {
+						cut = loc[0]
+						moved = true
+					}
+				}
+			}
+		}
+	}
+	return cut
+}
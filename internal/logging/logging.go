@@ -0,0 +1,281 @@
+// Package logging provides the leveled, structured logger used by the plugin.
+//
+// Every entry is tagged with the plugin instance name and written either as logfmt style
+// text or as JSON. Credentials are always redacted from logged values; personal data can
+// optionally be redacted as well.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+// Log levels, in increasing order of severity.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the lowercase name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLevel converts a level name to a Level.
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// Body logging modes.
+const (
+	BodyOff       = "off"
+	BodyTruncated = "truncated"
+	BodyFull      = "full"
+)
+
+// Logger writes structured log entries for one plugin instance.
+type Logger struct {
+	name      string
+	level     Level
+	json      bool
+	body      string
+	bodyLimit int
+	redactor  *Redactor
+	now       func() time.Time
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// New creates a logger for the named plugin instance from the logging configuration.
+// The configuration is expected to have been validated.
+func New(cfg config.Logging, name string, out io.Writer) *Logger {
+	level, _ := ParseLevel(cfg.Level)
+
+	body := cfg.Body
+	if body == "" {
+		body = BodyOff
+	}
+
+	return &Logger{
+		name:      name,
+		level:     level,
+		json:      cfg.Format == "json",
+		body:      body,
+		bodyLimit: cfg.BodyLimit,
+		redactor:  NewRedactor(cfg.RedactPII),
+		now:       time.Now,
+		out:       out,
+	}
+}
+
+// Enabled reports whether entries at the given level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug logs a message with alternating key and value fields at debug level.
+func (l *Logger) Debug(msg string, fields ...interface{}) {
+	l.log(LevelDebug, msg, fields)
+}
+
+// Info logs a message with alternating key and value fields at info level.
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.log(LevelInfo, msg, fields)
+}
+
+// Warn logs a message with alternating key and value fields at warn level.
+func (l *Logger) Warn(msg string, fields ...interface{}) {
+	l.log(LevelWarn, msg, fields)
+}
+
+// Error logs a message with alternating key and value fields at error level.
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.log(LevelError, msg, fields)
+}
+
+// AddSecrets redacts secrets wherever they appear in the entries that follow.
+func (l *Logger) AddSecrets(secrets ...string) {
+	l.redactor.AddSecrets(secrets...)
+}
+
+// SetSecrets replaces the secrets redacted from the entries that follow.
+func (l *Logger) SetSecrets(secrets ...string) {
+	l.redactor.SetSecrets(secrets...)
+}
+
+// LogsBodies reports whether request and response bodies should be logged.
+func (l *Logger) LogsBodies() bool {
+	return l.body != BodyOff && l.Enabled(LevelDebug)
+}
+
+// Body prepares a request or response body for logging according to the body mode.
+// It returns an empty string when bodies are not logged.
+func (l *Logger) Body(body []byte) string {
+	switch l.body {
+	case BodyFull:
+		return string(body)
+	case BodyTruncated:
+		if l.bodyLimit > 0 && len(body) > l.bodyLimit {
+			return string(body[:l.bodyLimit]) + "...(truncated, " + strconv.Itoa(len(body)) + " bytes)"
+		}
+		return string(body)
+	}
+	return ""
+}
+
+// Headers returns the headers with credentials replaced, ready to be logged.
+func (l *Logger) Headers(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for name, values := range header {
+		if isSensitiveHeader(name) {
+			out[name] = redacted
+			continue
+		}
+		out[name] = l.redactor.Redact(strings.Join(values, ", "))
+	}
+	return out
+}
+
+func (l *Logger) log(level Level, msg string, fields []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if l.json {
+		l.writeJSON(&buf, level, msg, fields)
+	} else {
+		l.writeText(&buf, level, msg, fields)
+	}
+	buf.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+// value converts a field value to what is logged, redacting strings and errors.
+func (l *Logger) value(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return l.redactor.Redact(value)
+	case error:
+		return l.redactor.Redact(value.Error())
+	case fmt.Stringer:
+		return l.redactor.Redact(value.String())
+	}
+	return v
+}
+
+func (l *Logger) writeJSON(buf *bytes.Buffer, level Level, msg string, fields []interface{}) {
+	buf.WriteByte('{')
+	writeJSONField(buf, "time", l.now().UTC().Format(time.RFC3339Nano), true)
+	writeJSONField(buf, "level", level.String(), false)
+	writeJSONField(buf, "name", l.name, false)
+	writeJSONField(buf, "msg", l.redactor.Redact(msg), false)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := field(fields, i)
+		writeJSONField(buf, key, l.value(value), false)
+	}
+	buf.WriteByte('}')
+}
+
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}, first bool) {
+	if !first {
+		buf.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+func (l *Logger) writeText(buf *bytes.Buffer, level Level, msg string, fields []interface{}) {
+	buf.WriteString("time=")
+	buf.WriteString(l.now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(" level=")
+	buf.WriteString(level.String())
+	buf.WriteString(" name=")
+	buf.WriteString(quote(l.name))
+	buf.WriteString(" msg=")
+	buf.WriteString(quote(l.redactor.Redact(msg)))
+	for i := 0; i < len(fields); i += 2 {
+		key, value := field(fields, i)
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(textValue(l.value(value)))
+	}
+}
+
+// field returns the key and value at position i of an alternating key/value list.
+func field(fields []interface{}, i int) (string, interface{}) {
+	key, ok := fields[i].(string)
+	if !ok {
+		key = fmt.Sprint(fields[i])
+	}
+	if i+1 >= len(fields) {
+		return key, "(missing)"
+	}
+	return key, fields[i+1]
+}
+
+func textValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return quote(value)
+	case int, int64, float64, bool:
+		return fmt.Sprint(value)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return quote(fmt.Sprint(v))
+	}
+	return quote(string(b))
+}
+
+// quote quotes a text value when it would otherwise be ambiguous.
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\r\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
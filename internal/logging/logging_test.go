@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+func newTestLogger(cfg config.Logging) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(cfg, "my-plugin", &buf)
+	l.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return l, &buf
+}
+
+func TestLogger_Text(t *testing.T) {
+	l, buf := newTestLogger(config.Logging{Level: "info"})
+
+	l.Debug("hidden")
+	l.Info("request received", "path", "/v1/chat/completions", "messages", 2, "note", "two words")
+
+	expected := `time=2024-01-02T03:04:05Z level=info name=my-plugin msg="request received" path=/v1/chat/completions messages=2 note="two words"` + "\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	l, buf := newTestLogger(config.Logging{Level: "debug", Format: "json"})
+
+	l.Warn("upstream failed", "status", 500, "error", errors.New("boom"), "odd")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected JSON output, got %s: %v", buf.String(), err)
+	}
+	if entry["level"] != "warn" || entry["name"] != "my-plugin" || entry["msg"] != "upstream failed" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	if entry["status"] != float64(500) || entry["error"] != "boom" || entry["odd"] != "(missing)" {
+		t.Errorf("unexpected fields %v", entry)
+	}
+	if !strings.HasPrefix(buf.String(), `{"time":"2024-01-02T03:04:05Z","level":"warn"`) {
+		t.Errorf("expected fields in order, got %s", buf.String())
+	}
+}
+
+func TestLogger_Headers(t *testing.T) {
+	l, _ := newTestLogger(config.Logging{})
+
+	header := http.Header{}
+	header.Set("Authorization", `Signature version="1",keyId="ST$eyJhbGciOi.abc.def",signature="c2ln"`)
+	header.Set("X-Content-Sha256", "abc=")
+	header.Set("X-Api-Key", "sk-123")
+
+	logged := l.Headers(header)
+	if logged["Authorization"] != redacted || logged["X-Api-Key"] != redacted {
+		t.Errorf("expected credentials to be redacted, got %v", logged)
+	}
+	if logged["X-Content-Sha256"] != "abc=" {
+		t.Errorf("expected other headers to be kept, got %v", logged)
+	}
+}
+
+func TestLogger_Body(t *testing.T) {
+	body := []byte(`{"message":"hello world"}`)
+
+	l, _ := newTestLogger(config.Logging{Level: "debug", Body: "off"})
+	if l.LogsBodies() || l.Body(body) != "" {
+		t.Error("expected bodies not to be logged when off")
+	}
+
+	l, _ = newTestLogger(config.Logging{Level: "debug", Body: "truncated", BodyLimit: 10})
+	if got := l.Body(body); got != `{"message"...(truncated, 25 bytes)` {
+		t.Errorf("unexpected truncated body %q", got)
+	}
+
+	l, _ = newTestLogger(config.Logging{Level: "info", Body: "full"})
+	if l.LogsBodies() {
+		t.Error("expected bodies to be logged at debug level only")
+	}
+	if got := l.Body(body); got != string(body) {
+		t.Errorf("unexpected full body %q", got)
+	}
+}
+
+func TestRedactor_Secrets(t *testing.T) {
+	r := NewRedactor(false)
+
+	tests := map[string]string{
+		`Authorization: Bearer sk-abc.123`:                        `Authorization: Bearer [REDACTED]`,
+		`keyId="ST$eyJraWQ.payload.sig",signature="c2lnbmF0dXJl"`: `keyId="[REDACTED]",signature="[REDACTED]"`,
+		`token ST$abc.def-ghi issued`:                             `token ST$[REDACTED] issued`,
+		`{"token":"abc123","model":"m"}`:                          `{"token":"[REDACTED]","model":"m"}`,
+		`contact me at jane@example.com`:                          `contact me at jane@example.com`,
+		`{"maxTokens":600,"totalTokens":5}`:                       `{"maxTokens":600,"totalTokens":5}`,
+	}
+
+	for input, expected := range tests {
+		if got := r.Redact(input); got != expected {
+			t.Errorf("Redact(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+}
+
+func TestRedactor_KnownSecrets(t *testing.T) {
+	r := NewRedactor(false)
+	r.AddSecrets("hunter2-passphrase", "hunter2", "abc")
+
+	got := r.Redact("passphrase hunter2-passphrase, short hunter2, abc unchanged")
+	expected := "passphrase [REDACTED], short [REDACTED], abc unchanged"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	r.SetSecrets("rotated-passphrase")
+	got = r.Redact("old hunter2, new rotated-passphrase")
+	expected = "old hunter2, new [REDACTED]"
+	if got != expected {
+		t.Errorf("expected the rotated secrets to replace the known ones, got %q", got)
+	}
+}
+
+func TestRedactor_PII(t *testing.T) {
+	r := NewRedactor(true)
+
+	got := r.Redact("mail jane@example.com, call +1 555-123-4567, card 4111 1111 1111 1111, ssn 123-45-6789")
+	expected := "mail [EMAIL], call [PHONE], card [CARD], ssn [SSN]"
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestLogger_RedactsFields(t *testing.T) {
+	l, buf := newTestLogger(config.Logging{Level: "info", RedactPII: true})
+
+	l.Info("forwarding", "detail", "Bearer secret-token for jane@example.com")
+
+	if strings.Contains(buf.String(), "secret-token") || strings.Contains(buf.String(), "jane@example.com") {
+		t.Errorf("expected field to be redacted, got %s", buf.String())
+	}
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are never logged, whatever their content.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"Api-Key":             true,
+	"Opc-Obo-Token":       true,
+}
+
+func isSensitiveHeader(name string) bool {
+	return sensitiveHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// rule replaces the matches of a pattern.
+type rule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// secretRules redact credentials that can appear in free text, such as the OCI
+// signature header, the security token used as keyId, bearer tokens and JWTs.
+var secretRules = []rule{
+	{regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "$1 " + redacted},
+	{regexp.MustCompile(`(keyId|signature)="[^"]*"`), `$1="` + redacted + `"`},
+	{regexp.MustCompile(`ST\$[A-Za-z0-9._-]+`), "ST$" + redacted},
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), redacted},
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), redacted},
+	{regexp.MustCompile(`(?i)("?(?:api_?key|token|secret|password)"?\s*[:=]\s*"?)[^"\s,}]+`), "$1" + redacted},
+}
+
+// piiRules redact common personal data patterns when PII redaction is enabled.
+var piiRules = []rule{
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[SSN]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`), "[CARD]"},
+	{regexp.MustCompile(`\+?\d{1,2}[ .-]?\(?\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`), "[PHONE]"},
+}
+
+// minSecretLength is the length from which known secrets are redacted wherever they appear;
+// shorter values would mask unrelated text.
+const minSecretLength = 4
+
+// Redactor removes credentials, and optionally personal data, from text.
+type Redactor struct {
+	rules []rule
+
+	mu      sync.RWMutex
+	secrets []string // Known secrets, longest first
+}
+
+// NewRedactor creates a redactor. Credentials are always redacted; pii adds personal data patterns.
+func NewRedactor(pii bool) *Redactor {
+	rules := secretRules
+	if pii {
+		rules = append(append([]rule{}, secretRules...), piiRules...)
+	}
+	return &Redactor{rules: rules}
+}
+
+// AddSecrets makes the redactor replace every occurrence of secrets, such as the values resolved
+// from secret references of the configuration. Secrets shorter than four characters are ignored.
+func (r *Redactor) AddSecrets(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addSecrets(secrets)
+}
+
+// SetSecrets replaces the known secrets of the redactor with secrets, such as the values resolved
+// from a reloaded configuration, so that rotated secrets do not accumulate.
+func (r *Redactor) SetSecrets(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = nil
+	r.addSecrets(secrets)
+}
+
+// addSecrets adds secrets to the known secrets. Calls are serialized by mu.
+func (r *Redactor) addSecrets(secrets []string) {
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength || containsString(r.secrets, secret) {
+			continue
+		}
+		r.secrets = append(r.secrets, secret)
+	}
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// Redact returns s with every known secret and sensitive match replaced.
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	r.mu.RUnlock()
+
+	for _, rule := range r.rules {
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
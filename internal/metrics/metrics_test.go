@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Render(&buf); err != nil {
+		t.Fatalf("failed to render metrics: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests served.", "model", "status")
+
+	c.Inc("cohere.command-r-plus", "200")
+	c.Add(2, "cohere.command-r-plus", "200")
+	c.Inc("cohere.command-r-plus", "429")
+	c.Add(-1, "cohere.command-r-plus", "200")
+
+	if v := c.Value("cohere.command-r-plus", "200"); v != 3 {
+		t.Errorf("expected counter value 3, got %f", v)
+	}
+
+	expected := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{model="cohere.command-r-plus",status="200"} 3
+requests_total{model="cohere.command-r-plus",status="429"} 1
+`
+	if got := render(t, r); got != expected {
+		t.Errorf("unexpected exposition:\n%s", got)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("in_flight", "Requests in flight.")
+
+	g.Add(2)
+	g.Add(-1)
+	if v := g.Value(); v != 1 {
+		t.Errorf("expected gauge value 1, got %f", v)
+	}
+
+	g.Set(5)
+	if !strings.Contains(render(t, r), "in_flight 5\n") {
+		t.Errorf("expected gauge to be rendered without labels, got:\n%s", render(t, r))
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+
+	h.Observe(0.05, "/v1/chat/completions")
+	h.Observe(0.5, "/v1/chat/completions")
+	h.Observe(5, "/v1/chat/completions")
+
+	if c := h.Count("/v1/chat/completions"); c != 3 {
+		t.Errorf("expected 3 observations, got %d", c)
+	}
+
+	expected := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/v1/chat/completions",le="0.1"} 1
+latency_seconds_bucket{route="/v1/chat/completions",le="1"} 2
+latency_seconds_bucket{route="/v1/chat/completions",le="+Inf"} 3
+latency_seconds_sum{route="/v1/chat/completions"} 5.55
+latency_seconds_count{route="/v1/chat/completions"} 3
+`
+	if got := render(t, r); got != expected {
+		t.Errorf("unexpected exposition:\n%s", got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("errors_total", "Errors.", "message")
+	c.Inc("a \"quoted\" \\ value\nwith newline")
+
+	expected := `errors_total{message="a \"quoted\" \\ value\nwith newline"} 1`
+	if !strings.Contains(render(t, r), expected) {
+		t.Errorf("expected escaped label, got:\n%s", render(t, r))
+	}
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "model")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for missing label values")
+		}
+	}()
+	c.Inc()
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("requests_total", "Requests.").Inc()
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rw.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if !strings.Contains(rw.Body.String(), "requests_total 1") {
+		t.Errorf("unexpected body:\n%s", rw.Body.String())
+	}
+}
@@ -0,0 +1,100 @@
+// Package models provides the capabilities of the OCI GenAI chat models, and the local token
+// estimates requests are checked against before they are sent to OCI.
+//
+// The registry holds the context window and the output limit of the known on-demand models,
+// extended or overridden by the configuration. Token counts are estimated per model family,
+// without the model's tokenizer, so they are approximations.
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// Capabilities are the limits of a model, in tokens.
+type Capabilities struct {
+	// ContextWindow is the number of tokens of the prompt and the completion together
+	ContextWindow int
+
+	// MaxOutputTokens is the number of tokens the model generates at most
+	MaxOutputTokens int
+}
+
+// builtin are the capabilities of the OCI GenAI on-demand models.
+var builtin = map[string]Capabilities{
+	"cohere.command":                              {ContextWindow: 4096, MaxOutputTokens: 4096},
+	"cohere.command-light":                        {ContextWindow: 4096, MaxOutputTokens: 4096},
+	"cohere.command-r-16k":                        {ContextWindow: 16000, MaxOutputTokens: 4000},
+	"cohere.command-r-plus":                       {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"cohere.command-r-08-2024":                    {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"cohere.command-r-plus-08-2024":               {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"cohere.command-a-03-2025":                    {ContextWindow: 256000, MaxOutputTokens: 8000},
+	"meta.llama-3-70b-instruct":                   {ContextWindow: 8000, MaxOutputTokens: 4000},
+	"meta.llama-3.1-70b-instruct":                 {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"meta.llama-3.1-405b-instruct":                {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"meta.llama-3.2-11b-vision-instruct":          {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"meta.llama-3.2-90b-vision-instruct":          {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"meta.llama-3.3-70b-instruct":                 {ContextWindow: 128000, MaxOutputTokens: 4000},
+	"meta.llama-4-scout-17b-16e-instruct":         {ContextWindow: 192000, MaxOutputTokens: 4000},
+	"meta.llama-4-maverick-17b-128e-instruct-fp8": {ContextWindow: 512000, MaxOutputTokens: 4000},
+	"google.gemini-2.5-pro":                       {ContextWindow: 1048576, MaxOutputTokens: 65536},
+	"google.gemini-2.5-flash":                     {ContextWindow: 1048576, MaxOutputTokens: 65536},
+	"google.gemini-2.5-flash-lite":                {ContextWindow: 1048576, MaxOutputTokens: 65536},
+	"xai.grok-3":                                  {ContextWindow: 131072, MaxOutputTokens: 16000},
+	"xai.grok-3-mini":                             {ContextWindow: 131072, MaxOutputTokens: 16000},
+	"xai.grok-4":                                  {ContextWindow: 256000, MaxOutputTokens: 16000},
+	"openai.gpt-oss-20b":                          {ContextWindow: 128000, MaxOutputTokens: 16000},
+	"openai.gpt-oss-120b":                         {ContextWindow: 128000, MaxOutputTokens: 16000},
+}
+
+// prefixEntry is the capabilities of the models of a prefix.
+type prefixEntry struct {
+	prefix       string
+	capabilities Capabilities
+}
+
+// Registry holds the capabilities of models. It is read-only once created.
+type Registry struct {
+	models   map[string]Capabilities
+	prefixes []prefixEntry // Longest prefix first
+}
+
+// NewRegistry creates a registry of the built-in models, extended or overridden by the
+// configured models.
+func NewRegistry(overrides []config.ModelCapability) *Registry {
+	r := &Registry{models: make(map[string]Capabilities, len(builtin)+len(overrides))}
+	for model, capabilities := range builtin {
+		r.models[model] = capabilities
+	}
+
+	for _, m := range overrides {
+		capabilities := Capabilities{ContextWindow: m.ContextWindow, MaxOutputTokens: m.MaxOutputTokens}
+		if capabilities.MaxOutputTokens == 0 {
+			capabilities.MaxOutputTokens = capabilities.ContextWindow
+		}
+		if prefix := strings.TrimSuffix(m.Model, "*"); prefix != m.Model {
+			r.prefixes = append(r.prefixes, prefixEntry{prefix: prefix, capabilities: capabilities})
+			continue
+		}
+		r.models[m.Model] = capabilities
+	}
+	sort.SliceStable(r.prefixes, func(i, j int) bool { return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix) })
+
+	return r
+}
+
+// Lookup returns the capabilities of a model: those of its ID, or of the longest configured prefix
+// matching it. It reports false for unknown models.
+func (r *Registry) Lookup(model string) (Capabilities, bool) {
+	if capabilities, ok := r.models[model]; ok {
+		return capabilities, true
+	}
+	for _, entry := range r.prefixes {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.capabilities, true
+		}
+	}
+	return Capabilities{}, false
+}
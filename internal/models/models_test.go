@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	r := NewRegistry([]config.ModelCapability{
+		{Model: "cohere.command-r-plus", ContextWindow: 64000, MaxOutputTokens: 2000},
+		{Model: "custom.*", ContextWindow: 8000},
+		{Model: "custom.large-*", ContextWindow: 32000, MaxOutputTokens: 4000},
+	})
+
+	tests := []struct {
+		model    string
+		expected Capabilities
+		ok       bool
+	}{
+		{"meta.llama-3.3-70b-instruct", Capabilities{ContextWindow: 128000, MaxOutputTokens: 4000}, true},
+		{"cohere.command-r-plus", Capabilities{ContextWindow: 64000, MaxOutputTokens: 2000}, true},
+		{"custom.small", Capabilities{ContextWindow: 8000, MaxOutputTokens: 8000}, true},
+		{"custom.large-v2", Capabilities{ContextWindow: 32000, MaxOutputTokens: 4000}, true},
+		{"gpt-4", Capabilities{}, false},
+	}
+	for _, tt := range tests {
+		capabilities, ok := r.Lookup(tt.model)
+		if ok != tt.ok || capabilities != tt.expected {
+			t.Errorf("%s: expected %+v (%v), got %+v (%v)", tt.model, tt.expected, tt.ok, capabilities, ok)
+		}
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		model    string
+		text     string
+		expected int
+	}{
+		{"meta.llama-3.3-70b-instruct", "", 0},
+		{"meta.llama-3.3-70b-instruct", "Hello, world!", 4},
+		{"meta.llama-3.3-70b-instruct", "internationalization", 5},
+		{"cohere.command-r-plus", "internationalization", 4},
+		{"unknown", "internationalization", 6},
+		{"meta.llama-3.3-70b-instruct", "你好世界", 4},
+	}
+	for _, tt := range tests {
+		if got := CountTokens(tt.model, tt.text); got != tt.expected {
+			t.Errorf("%s %q: expected %d tokens, got %d", tt.model, tt.text, tt.expected, got)
+		}
+	}
+
+	// Estimates grow with the text
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100)
+	if got := CountTokens("meta.llama-3.3-70b-instruct", text); got < 1000 || got > 1500 {
+		t.Errorf("expected about 1000 to 1500 tokens, got %d", got)
+	}
+}
+
+func TestPromptTokens(t *testing.T) {
+	model := "meta.llama-3.3-70b-instruct"
+	req := types.ChatRequest{Messages: []types.GenericMessage{
+		{Role: "SYSTEM", Content: []types.GenericContent{{Type: "TEXT", Text: "Be brief."}}},
+		{Role: "USER", Content: []types.GenericContent{{Type: "TEXT", Text: "Hello, world!"}}},
+	}}
+
+	expected := MessageTokens(model, req.Messages[0]) + MessageTokens(model, req.Messages[1])
+	if got := PromptTokens(model, req); got != expected || got != 5+3+5+4 {
+		t.Errorf("expected %d prompt tokens, got %d", expected, got)
+	}
+
+	// The COHERE format carries the prompt in the message
+	if got := PromptTokens("cohere.command-r-plus", types.ChatRequest{Message: "Hello, world!"}); got != 4+4 {
+		t.Errorf("expected 8 prompt tokens, got %d", got)
+	}
+}
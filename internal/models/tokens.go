@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// family is the tokenizer profile of a model family.
+type family struct {
+	charsPerToken   float64 // Characters of a word per token
+	messageOverhead int     // Tokens of the role and delimiters of each message
+}
+
+// families are the tokenizer profiles of the model families, by the prefix of their model IDs.
+var families = map[string]family{
+	"cohere": {charsPerToken: 4.5, messageOverhead: 4},
+	"meta":   {charsPerToken: 4, messageOverhead: 5},
+	"google": {charsPerToken: 4, messageOverhead: 3},
+	"xai":    {charsPerToken: 4, messageOverhead: 4},
+	"openai": {charsPerToken: 4, messageOverhead: 4},
+}
+
+// defaultFamily is the profile of the other families, erring on the side of more tokens.
+var defaultFamily = family{charsPerToken: 3.5, messageOverhead: 4}
+
+// familyOf returns the tokenizer profile of a model.
+func familyOf(model string) family {
+	if i := strings.IndexByte(model, '.'); i > 0 {
+		if f, ok := families[model[:i]]; ok {
+			return f
+		}
+	}
+	return defaultFamily
+}
+
+// CountTokens estimates the number of tokens of text for a model. Words count for one token per
+// few characters, depending on the family, rounded and at least one; punctuation, symbols and
+// CJK characters count for one token each.
+func CountTokens(model, text string) int {
+	f := familyOf(model)
+	tokens := 0
+	word := 0 // Characters of the current word
+	endWord := func() {
+		if word > 0 {
+			tokens += 1 + int(float64(word)/f.charsPerToken-0.5)
+			word = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			endWord()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			endWord()
+			tokens++
+		}
+	}
+	endWord()
+	return tokens
+}
+
+// PromptTokens estimates the number of prompt tokens of an OCI chat request: its messages, the
+// definitions of its tools and its documents. Images are not counted.
+func PromptTokens(model string, req types.ChatRequest) int {
+	f := familyOf(model)
+	tokens := 0
+	if req.PreambleOverride != "" {
+		tokens += f.messageOverhead + CountTokens(model, req.PreambleOverride)
+	}
+	for _, msg := range req.ChatHistory {
+		tokens += cohereMessageTokens(model, msg)
+	}
+	if req.Message != "" {
+		tokens += f.messageOverhead + CountTokens(model, req.Message)
+	}
+	for _, msg := range req.Messages {
+		tokens += MessageTokens(model, msg)
+	}
+	if len(req.Tools) > 0 {
+		definitions, _ := json.Marshal(req.Tools)
+		tokens += CountTokens(model, string(definitions))
+	}
+	for _, document := range req.Documents {
+		tokens += f.messageOverhead + CountTokens(model, string(document))
+	}
+	return tokens
+}
+
+// MessageTokens estimates the number of tokens of a message of an OCI chat request in the
+// GENERIC format, its tool calls included.
+func MessageTokens(model string, msg types.GenericMessage) int {
+	tokens := familyOf(model).messageOverhead
+	for _, content := range msg.Content {
+		tokens += CountTokens(model, content.Text)
+	}
+	for _, call := range msg.ToolCalls {
+		tokens += CountTokens(model, call.Name) + CountTokens(model, call.Arguments)
+	}
+	return tokens
+}
+
+// cohereMessageTokens estimates the number of tokens of a message of the chat history of an OCI
+// chat request in the COHERE format, its tool calls and results included.
+func cohereMessageTokens(model string, msg types.CohereMessage) int {
+	tokens := familyOf(model).messageOverhead + CountTokens(model, msg.Message)
+	for _, call := range msg.ToolCalls {
+		tokens += CountTokens(model, call.Name) + CountTokens(model, string(call.Parameters))
+	}
+	for _, result := range msg.ToolResults {
+		for _, output := range result.Outputs {
+			tokens += CountTokens(model, string(output))
+		}
+	}
+	return tokens
+}
@@ -6,31 +6,87 @@ package ocisdk
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
+// Authentication methods accepted by Provider.
+const (
+	AuthInstancePrincipal = "instance_principal"
+	AuthAPIKey            = "api_key"
+	AuthSecurityToken     = "security_token"
+)
+
+// DefaultConfigFile is the default path of the OCI CLI configuration file.
+const DefaultConfigFile = "~/.oci/config"
+
 // Authenticator handles OCI Instance Principal authentication and request signing.
 type Authenticator struct {
-	signer HTTPRequestSigner
+	provider ConfigurationProvider
+	signer   HTTPRequestSigner
 }
 
-// New creates a new authenticator with default settings.
-func New() *Authenticator {
-	auth := &Authenticator{}
-
+// New creates an authenticator signing requests as the instance principal. It fails outside of an
+// OCI instance, where the instance metadata service cannot be reached.
+func New() (*Authenticator, error) {
 	provider, err := InstancePrincipalConfigurationProvider()
 	if err != nil {
-		panic(fmt.Errorf("error getting provider: %v", err))
+		return nil, fmt.Errorf("error getting provider: %w", err)
 	}
 
+	return NewWithProvider(provider), nil
+}
+
+// NewWithProvider creates an authenticator signing requests with the keys of provider.
+func NewWithProvider(provider ConfigurationProvider) *Authenticator {
 	// Create the OCI request signer using the key provider
-	auth.signer = DefaultRequestSigner(provider)
+	return &Authenticator{
+		provider: provider,
+		signer:   DefaultRequestSigner(provider),
+	}
+}
+
+// Key is the key material of API keys and security tokens given by the caller, instead of the
+// files named by the OCI CLI configuration file.
+type Key struct {
+	PrivateKey string // PEM private key. Default: the key_file of the profile
+	Passphrase string // Passphrase of the private key. Default: the pass_phrase of the profile
+}
+
+// Provider creates the configuration provider of an authentication method. Instance principals
+// use the instance metadata service; API keys and security tokens are read from the given
+// profile of an OCI CLI configuration file, with the private key and passphrase of key when set.
+func Provider(method, configFile, profile string, key Key) (ConfigurationProvider, error) {
+	if configFile == "" {
+		configFile = DefaultConfigFile
+	}
+	if profile == "" {
+		profile = "DEFAULT"
+	}
+	fileProvider := &fileConfigurationProvider{
+		ConfigPath:         configFile,
+		PrivateKeyPassword: key.Passphrase,
+		PrivateKey:         []byte(key.PrivateKey),
+		Profile:            profile,
+	}
 
-	return auth
+	switch method {
+	case "", AuthInstancePrincipal:
+		return InstancePrincipalConfigurationProvider()
+	case AuthAPIKey:
+		return fileProvider, nil
+	case AuthSecurityToken:
+		return &sessionTokenConfigurationProvider{fileProvider}, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication method %q", method)
+	}
 }
 
 // SignRequest adds OCI authentication headers to the given HTTP request.
 // It uses cached credentials when available or fetches fresh ones if needed.
 func (a *Authenticator) SignRequest(req *http.Request) error {
+	// The date is part of the signature and must be within five minutes of the server time
+	req.Header.Set(requestHeaderDate, time.Now().UTC().Format(http.TimeFormat))
+
 	// Use the OCI request signer to sign the request
 	if err := a.signer.Sign(req); err != nil {
 		return fmt.Errorf("failed to sign request: %w", err)
@@ -38,3 +94,30 @@ func (a *Authenticator) SignRequest(req *http.Request) error {
 
 	return nil
 }
+
+// Region returns the OCI region the authenticator's credentials belong to.
+// An empty string is returned if the region cannot be determined.
+func (a *Authenticator) Region() string {
+	region, err := a.provider.Region()
+	if err != nil {
+		return ""
+	}
+
+	return region
+}
+
+// InferenceEndpoint returns the OCI GenAI inference endpoint of a region.
+func InferenceEndpoint(region string) string {
+	r := StringToRegion(region)
+	return fmt.Sprintf("https://inference.generativeai.%s.oci.%s", r, r.SecondLevelDomain())
+}
+
+// SigningString returns the string whose signature is in the Authorization header of a request
+// signed by the authenticator. It is used to diagnose signature mismatches.
+func (a *Authenticator) SigningString(req *http.Request) string {
+	signer, ok := a.signer.(ociRequestSigner)
+	if !ok {
+		return ""
+	}
+	return signer.getSigningString(req)
+}
@@ -0,0 +1,97 @@
+package ocisdk
+
+import (
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+)
+
+func TestURLBasedX509CertificateRetriever_Refresh(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	base := env.IMDS.URL()
+
+	retriever := newURLBasedX509CertificateRetriever(&http.Client{}, base+ocitest.LeafCertificatePath, base+ocitest.LeafCertificateKeyPath, "")
+	if err := retriever.Refresh(); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+
+	if got := extractTenancyIDFromCertificate(retriever.Certificate()); got != testTenancy {
+		t.Errorf("expected tenancy %s, got %s", testTenancy, got)
+	}
+	if retriever.PrivateKey() == nil || len(retriever.PrivateKeyPemRaw()) == 0 {
+		t.Fatal("expected the private key to be retrieved")
+	}
+	leafKey := env.IMDS.Leaf().PublicKey.(*rsa.PublicKey)
+	if retriever.PrivateKey().PublicKey.N.Cmp(leafKey.N) != 0 {
+		t.Error("expected the private key to match the certificate")
+	}
+}
+
+func TestURLBasedX509CertificateRetriever_WithoutKey(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+
+	retriever := newURLBasedX509CertificateRetriever(&http.Client{}, env.IMDS.URL()+ocitest.IntermediateCertificatePath, "", "")
+	if err := retriever.Refresh(); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+
+	if retriever.Certificate() == nil || !retriever.Certificate().IsCA {
+		t.Error("expected the intermediate certificate")
+	}
+	if retriever.PrivateKey() != nil {
+		t.Error("expected no private key")
+	}
+	if env.IMDS.Requests(ocitest.LeafCertificateKeyPath) != 0 {
+		t.Error("expected no key request")
+	}
+}
+
+func TestURLBasedX509CertificateRetriever_FailureIsAtomic(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	base := env.IMDS.URL()
+
+	retriever := newURLBasedX509CertificateRetriever(&http.Client{}, base+ocitest.LeafCertificatePath, base+ocitest.LeafCertificateKeyPath, "")
+	if err := retriever.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	previous := retriever.Certificate()
+
+	// A new certificate is served but its key cannot be fetched
+	if err := env.IMDS.Rotate(testTenancy); err != nil {
+		t.Fatal(err)
+	}
+	env.IMDS.Fail(ocitest.LeafCertificateKeyPath, http.StatusInternalServerError)
+
+	err := retriever.Refresh()
+	if err == nil || !strings.Contains(err.Error(), "failed to renew private key") {
+		t.Fatalf("expected private key error, got %v", err)
+	}
+	if !retriever.Certificate().Equal(previous) {
+		t.Error("expected the previous certificate to be kept")
+	}
+
+	env.IMDS.Fail(ocitest.LeafCertificateKeyPath, 0)
+	if err := retriever.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if retriever.Certificate().Equal(previous) {
+		t.Error("expected the rotated certificate after recovery")
+	}
+}
+
+func TestURLBasedX509CertificateRetriever_InvalidPEM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("not a certificate"))
+	}))
+	defer server.Close()
+
+	retriever := newURLBasedX509CertificateRetriever(&http.Client{}, server.URL, "", "")
+	err := retriever.Refresh()
+	if err == nil || !strings.Contains(err.Error(), "not valid pem data") {
+		t.Errorf("expected invalid PEM error, got %v", err)
+	}
+}
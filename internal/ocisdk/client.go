@@ -619,7 +619,7 @@ func (client BaseClient) RefreshableTokenWrappedCallWithDetails(ctx context.Cont
 		if response != nil && response.StatusCode != 401 {
 			return response, err
 		}
-		time.Sleep(1 * time.Second)
+		sleep(1 * time.Second)
 	}
 	return
 }
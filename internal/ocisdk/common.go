@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -217,16 +218,16 @@ func StringToRegion(stringRegion string) (r Region) {
 	return
 }
 
-// // canStringBeRegion test if the string can be a region, if it can, returns the string as is, otherwise it
-// // returns an error
-// var blankRegex = regexp.MustCompile(`\s`)
+// canStringBeRegion test if the string can be a region, if it can, returns the string as is, otherwise it
+// returns an error
+var blankRegex = regexp.MustCompile(`\s`)
 
-// func canStringBeRegion(stringRegion string) (region string, err error) {
-// 	if blankRegex.MatchString(stringRegion) || stringRegion == "" {
-// 		return "", fmt.Errorf("region can not be empty or have spaces")
-// 	}
-// 	return stringRegion, nil
-// }
+func canStringBeRegion(stringRegion string) (region string, err error) {
+	if blankRegex.MatchString(stringRegion) || stringRegion == "" {
+		return "", fmt.Errorf("region can not be empty or have spaces")
+	}
+	return stringRegion, nil
+}
 
 // check region info from original map
 func checkAndAddRegionMetadata(region string) Region {
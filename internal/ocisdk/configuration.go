@@ -5,7 +5,13 @@ package ocisdk
 
 import (
 	"crypto/rsa"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 )
 
 // import (
@@ -24,8 +30,8 @@ import (
 type AuthenticationType string
 
 const (
-	// 	// UserPrincipal is default auth type
-	// 	UserPrincipal AuthenticationType = "user_principal"
+	// UserPrincipal is default auth type
+	UserPrincipal AuthenticationType = "user_principal"
 	// InstancePrincipal is used for instance principal auth type
 	InstancePrincipal AuthenticationType = "instance_principal"
 
@@ -275,31 +281,34 @@ type ConfigurationProvider interface {
 // 		fmt.Errorf("unsupported, keep the interface")
 // }
 
-// // fileConfigurationProvider. reads configuration information from a file
-// type fileConfigurationProvider struct {
-// 	//The path to the configuration file
-// 	ConfigPath string
+// fileConfigurationProvider. reads configuration information from a file
+type fileConfigurationProvider struct {
+	//The path to the configuration file
+	ConfigPath string
 
-// 	//The password for the private key
-// 	PrivateKeyPassword string
+	//The password for the private key
+	PrivateKeyPassword string
 
-// 	//The profile for the configuration
-// 	Profile string
+	//The PEM private key, used instead of the key_file of the profile when set
+	PrivateKey []byte
 
-// 	//ConfigFileInfo
-// 	FileInfo *configFileInfo
+	//The profile for the configuration
+	Profile string
 
-// 	//Mutex to protect the config file
-// 	configMux sync.Mutex
-// }
+	//ConfigFileInfo
+	FileInfo *configFileInfo
 
-// type fileConfigurationProviderError struct {
-// 	err error
-// }
+	//Mutex to protect the config file
+	configMux sync.Mutex
+}
 
-// func (fpe fileConfigurationProviderError) Error() string {
-// 	return fmt.Sprintf("%s\nFor more info about config file and how to get required information, see https://docs.oracle.com/en-us/iaas/Content/API/Concepts/sdkconfig.htm", fpe.err)
-// }
+type fileConfigurationProviderError struct {
+	err error
+}
+
+func (fpe fileConfigurationProviderError) Error() string {
+	return fmt.Sprintf("%s\nFor more info about config file and how to get required information, see https://docs.oracle.com/en-us/iaas/Content/API/Concepts/sdkconfig.htm", fpe.err)
+}
 
 // // ConfigurationProviderFromFile creates a configuration provider from a configuration file
 // // by reading the "DEFAULT" profile
@@ -308,7 +317,7 @@ type ConfigurationProvider interface {
 // 		return nil, fmt.Errorf("config file path can not be empty")
 // 	}
 
-// 	return fileConfigurationProvider{
+// 	return &fileConfigurationProvider{
 // 		ConfigPath:         configFilePath,
 // 		PrivateKeyPassword: privateKeyPassword,
 // 		Profile:            "DEFAULT",
@@ -322,319 +331,301 @@ type ConfigurationProvider interface {
 // 		return nil, fileConfigurationProviderError{err: fmt.Errorf("config file path can not be empty")}
 // 	}
 
-// 	return fileConfigurationProvider{
+// 	return &fileConfigurationProvider{
 // 		ConfigPath:         configFilePath,
 // 		PrivateKeyPassword: privateKeyPassword,
 // 		Profile:            profile,
 // 		configMux:          sync.Mutex{}}, nil
 // }
 
-// type configFileInfo struct {
-// 	UserOcid, Fingerprint, KeyFilePath, TenancyOcid, Region, Passphrase, SecurityTokenFilePath, DelegationTokenFilePath,
-// 	AuthenticationType string
-// 	PresentConfiguration rune
-// }
-
-// const (
-// 	hasTenancy = 1 << iota
-// 	hasUser
-// 	hasFingerprint
-// 	hasRegion
-// 	hasKeyFile
-// 	hasPassphrase
-// 	hasSecurityTokenFile
-// 	hasDelegationTokenFile
-// 	hasAuthenticationType
-// 	none
-// )
-
-// var profileRegex = regexp.MustCompile(`^\[(.*)\]`)
-
-// func parseConfigFile(data []byte, profile string) (info *configFileInfo, err error) {
-
-// 	if len(data) == 0 {
-// 		return nil, fileConfigurationProviderError{err: fmt.Errorf("configuration file content is empty")}
-// 	}
-
-// 	content := string(data)
-// 	splitContent := strings.Split(content, "\n")
-
-// 	//Look for profile
-// 	for i, line := range splitContent {
-// 		if match := profileRegex.FindStringSubmatch(line); len(match) > 1 && match[1] == profile {
-// 			start := i + 1
-// 			return parseConfigAtLine(start, splitContent)
-// 		}
-// 	}
+type configFileInfo struct {
+	UserOcid, Fingerprint, KeyFilePath, TenancyOcid, Region, Passphrase, SecurityTokenFilePath, DelegationTokenFilePath,
+	AuthenticationType string
+	PresentConfiguration rune
+}
 
-// 	return nil, fileConfigurationProviderError{err: fmt.Errorf("configuration file did not contain profile: %s", profile)}
-// }
+const (
+	hasTenancy = 1 << iota
+	hasUser
+	hasFingerprint
+	hasRegion
+	hasKeyFile
+	hasPassphrase
+	hasSecurityTokenFile
+	hasDelegationTokenFile
+	hasAuthenticationType
+	none
+)
 
-// func parseConfigAtLine(start int, content []string) (info *configFileInfo, err error) {
-// 	var configurationPresent rune
-// 	info = &configFileInfo{}
-// 	for i := start; i < len(content); i++ {
-// 		line := content[i]
-// 		if profileRegex.MatchString(line) {
-// 			break
-// 		}
+var profileRegex = regexp.MustCompile(`^\[(.*)\]`)
 
-// 		if !strings.Contains(line, "=") {
-// 			continue
-// 		}
+func parseConfigFile(data []byte, profile string) (info *configFileInfo, err error) {
 
-// 		splits := strings.Split(line, "=")
-// 		switch key, value := strings.TrimSpace(splits[0]), strings.TrimSpace(splits[1]); strings.ToLower(key) {
-// 		case "passphrase", "pass_phrase":
-// 			configurationPresent = configurationPresent | hasPassphrase
-// 			info.Passphrase = value
-// 		case "user":
-// 			configurationPresent = configurationPresent | hasUser
-// 			info.UserOcid = value
-// 		case "fingerprint":
-// 			configurationPresent = configurationPresent | hasFingerprint
-// 			info.Fingerprint = value
-// 		case "key_file":
-// 			configurationPresent = configurationPresent | hasKeyFile
-// 			info.KeyFilePath = value
-// 		case "tenancy":
-// 			configurationPresent = configurationPresent | hasTenancy
-// 			info.TenancyOcid = value
-// 		case "region":
-// 			configurationPresent = configurationPresent | hasRegion
-// 			info.Region = value
-// 		case "security_token_file":
-// 			configurationPresent = configurationPresent | hasSecurityTokenFile
-// 			info.SecurityTokenFilePath = value
-// 		case "delegation_token_file":
-// 			configurationPresent = configurationPresent | hasDelegationTokenFile
-// 			info.DelegationTokenFilePath = value
-// 		case "authentication_type":
-// 			configurationPresent = configurationPresent | hasAuthenticationType
-// 			info.AuthenticationType = value
-// 		}
-// 	}
-// 	info.PresentConfiguration = configurationPresent
-// 	return
+	if len(data) == 0 {
+		return nil, fileConfigurationProviderError{err: fmt.Errorf("configuration file content is empty")}
+	}
 
-// }
+	content := string(data)
+	splitContent := strings.Split(content, "\n")
 
-// // cleans and expands the path if it contains a tilde , returns the expanded path or the input path as is if not expansion
-// // was performed
-// func expandPath(filename string) (expandedPath string) {
-// 	cleanedPath := filepath.Clean(filename)
-// 	expandedPath = cleanedPath
-// 	if strings.HasPrefix(cleanedPath, "~") {
-// 		rest := cleanedPath[2:]
-// 		expandedPath = filepath.Join(getHomeFolder(), rest)
-// 	}
-// 	return
-// }
+	//Look for profile
+	for i, line := range splitContent {
+		if match := profileRegex.FindStringSubmatch(line); len(match) > 1 && match[1] == profile {
+			start := i + 1
+			return parseConfigAtLine(start, splitContent)
+		}
+	}
 
-// func openConfigFile(configFilePath string) (data []byte, err error) {
-// 	expandedPath := expandPath(configFilePath)
-// 	data, err = readFileFromCache(expandedPath)
-// 	if err != nil {
-// 		err = fmt.Errorf("can not read config file: %s due to: %s", configFilePath, err.Error())
-// 	}
+	return nil, fileConfigurationProviderError{err: fmt.Errorf("configuration file did not contain profile: %s", profile)}
+}
 
-// 	return
-// }
+func parseConfigAtLine(start int, content []string) (info *configFileInfo, err error) {
+	var configurationPresent rune
+	info = &configFileInfo{}
+	for i := start; i < len(content); i++ {
+		line := content[i]
+		if profileRegex.MatchString(line) {
+			break
+		}
+
+		if !strings.Contains(line, "=") {
+			continue
+		}
+
+		splits := strings.Split(line, "=")
+		switch key, value := strings.TrimSpace(splits[0]), strings.TrimSpace(splits[1]); strings.ToLower(key) {
+		case "passphrase", "pass_phrase":
+			configurationPresent = configurationPresent | hasPassphrase
+			info.Passphrase = value
+		case "user":
+			configurationPresent = configurationPresent | hasUser
+			info.UserOcid = value
+		case "fingerprint":
+			configurationPresent = configurationPresent | hasFingerprint
+			info.Fingerprint = value
+		case "key_file":
+			configurationPresent = configurationPresent | hasKeyFile
+			info.KeyFilePath = value
+		case "tenancy":
+			configurationPresent = configurationPresent | hasTenancy
+			info.TenancyOcid = value
+		case "region":
+			configurationPresent = configurationPresent | hasRegion
+			info.Region = value
+		case "security_token_file":
+			configurationPresent = configurationPresent | hasSecurityTokenFile
+			info.SecurityTokenFilePath = value
+		case "delegation_token_file":
+			configurationPresent = configurationPresent | hasDelegationTokenFile
+			info.DelegationTokenFilePath = value
+		case "authentication_type":
+			configurationPresent = configurationPresent | hasAuthenticationType
+			info.AuthenticationType = value
+		}
+	}
+	info.PresentConfiguration = configurationPresent
+	return
 
-// func (p fileConfigurationProvider) String() string {
-// 	return fmt.Sprintf("Configuration provided by file: %s", p.ConfigPath)
-// }
+}
 
-// func (p fileConfigurationProvider) readAndParseConfigFile() (info *configFileInfo, err error) {
-// 	p.configMux.Lock()
-// 	defer p.configMux.Unlock()
-// 	if p.FileInfo != nil {
-// 		return p.FileInfo, nil
-// 	}
+// cleans and expands the path if it contains a tilde , returns the expanded path or the input path as is if not expansion
+// was performed
+func expandPath(filename string) (expandedPath string) {
+	cleanedPath := filepath.Clean(filename)
+	expandedPath = cleanedPath
+	if strings.HasPrefix(cleanedPath, "~") {
+		rest := cleanedPath[2:]
+		expandedPath = filepath.Join(getHomeFolder(), rest)
+	}
+	return
+}
 
-// 	if p.ConfigPath == "" {
-// 		return nil, fileConfigurationProviderError{err: fmt.Errorf("configuration path can not be empty")}
-// 	}
+func openConfigFile(configFilePath string) (data []byte, err error) {
+	expandedPath := expandPath(configFilePath)
+	data, err = os.ReadFile(expandedPath)
+	if err != nil {
+		err = fmt.Errorf("can not read config file: %s due to: %s", configFilePath, err.Error())
+	}
 
-// 	data, err := openConfigFile(p.ConfigPath)
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("error while parsing config file: %s. Due to: %s", p.ConfigPath, err.Error())}
-// 		return
-// 	}
+	return
+}
 
-// 	p.FileInfo, err = parseConfigFile(data, p.Profile)
-// 	return p.FileInfo, err
-// }
+func (p *fileConfigurationProvider) String() string {
+	return fmt.Sprintf("Configuration provided by file: %s", p.ConfigPath)
+}
 
-// func presentOrError(value string, expectedConf, presentConf rune, confMissing string) (string, error) {
-// 	if presentConf&expectedConf == expectedConf {
-// 		return value, nil
-// 	}
-// 	return "", fileConfigurationProviderError{err: errors.New(confMissing + " configuration is missing from file")}
-// }
+func (p *fileConfigurationProvider) readAndParseConfigFile() (info *configFileInfo, err error) {
+	p.configMux.Lock()
+	defer p.configMux.Unlock()
+	if p.FileInfo != nil {
+		return p.FileInfo, nil
+	}
 
-// func (p fileConfigurationProvider) TenancyOCID() (value string, err error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
-// 		return
-// 	}
+	if p.ConfigPath == "" {
+		return nil, fileConfigurationProviderError{err: fmt.Errorf("configuration path can not be empty")}
+	}
 
-// 	value, err = presentOrError(info.TenancyOcid, hasTenancy, info.PresentConfiguration, "tenancy")
-// 	if err == nil && value == "" {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("tenancy OCID can not be empty when reading from config file")}
-// 	}
-// 	return
-// }
+	data, err := openConfigFile(p.ConfigPath)
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("error while parsing config file: %s. Due to: %s", p.ConfigPath, err.Error())}
+		return
+	}
 
-// func (p fileConfigurationProvider) UserOCID() (value string, err error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
-// 		return
-// 	}
+	p.FileInfo, err = parseConfigFile(data, p.Profile)
+	return p.FileInfo, err
+}
 
-// 	if value, err = presentOrError(info.UserOcid, hasUser, info.PresentConfiguration, "user"); err != nil {
-// 		// need to check if securityTokenPath is provided, if security token is provided, userOCID can be "".
-// 		if _, stErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration,
-// 			"securityTokenPath"); stErr == nil {
-// 			err = nil
-// 		}
-// 	}
-// 	return
-// }
+func presentOrError(value string, expectedConf, presentConf rune, confMissing string) (string, error) {
+	if presentConf&expectedConf == expectedConf {
+		return value, nil
+	}
+	return "", fileConfigurationProviderError{err: errors.New(confMissing + " configuration is missing from file")}
+}
 
-// func (p fileConfigurationProvider) KeyFingerprint() (value string, err error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
-// 		return
-// 	}
-// 	value, err = presentOrError(info.Fingerprint, hasFingerprint, info.PresentConfiguration, "fingerprint")
-// 	if err == nil && value == "" {
-// 		return "", fmt.Errorf("fingerprint can not be empty when reading from config file")
-// 	}
-// 	return
-// }
+func (p *fileConfigurationProvider) TenancyOCID() (value string, err error) {
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
+		return
+	}
 
-// func (p fileConfigurationProvider) KeyID() (keyID string, err error) {
-// 	tenancy, err := p.TenancyOCID()
-// 	if err != nil {
-// 		return
-// 	}
+	value, err = presentOrError(info.TenancyOcid, hasTenancy, info.PresentConfiguration, "tenancy")
+	if err == nil && value == "" {
+		err = fileConfigurationProviderError{err: fmt.Errorf("tenancy OCID can not be empty when reading from config file")}
+	}
+	return
+}
 
-// 	fingerprint, err := p.KeyFingerprint()
-// 	if err != nil {
-// 		return
-// 	}
+func (p *fileConfigurationProvider) UserOCID() (value string, err error) {
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
+		return
+	}
 
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
-// 		return
-// 	}
-// 	if info.PresentConfiguration&hasUser == hasUser {
-// 		if info.UserOcid == "" {
-// 			err = fileConfigurationProviderError{err: fmt.Errorf("user cannot be empty in the config file")}
-// 			return
-// 		}
-// 		return fmt.Sprintf("%s/%s/%s", tenancy, info.UserOcid, fingerprint), nil
-// 	}
-// 	filePath, pathErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration, "securityTokenFilePath")
-// 	if pathErr == nil {
-// 		rawString, err := getTokenContent(filePath)
-// 		if err != nil {
-// 			return "", fileConfigurationProviderError{err: err}
-// 		}
-// 		return "ST$" + rawString, nil
-// 	}
-// 	err = fileConfigurationProviderError{err: fmt.Errorf("can not read SecurityTokenFilePath from configuration file due to: %s", pathErr.Error())}
-// 	return
-// }
+	if value, err = presentOrError(info.UserOcid, hasUser, info.PresentConfiguration, "user"); err != nil {
+		// need to check if securityTokenPath is provided, if security token is provided, userOCID can be "".
+		if _, stErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration,
+			"securityTokenPath"); stErr == nil {
+			err = nil
+		}
+	}
+	return
+}
 
-// func (p fileConfigurationProvider) PrivateRSAKey() (key *rsa.PrivateKey, err error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
-// 		return
-// 	}
+func (p *fileConfigurationProvider) KeyFingerprint() (value string, err error) {
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
+		return
+	}
+	value, err = presentOrError(info.Fingerprint, hasFingerprint, info.PresentConfiguration, "fingerprint")
+	if err == nil && value == "" {
+		return "", fmt.Errorf("fingerprint can not be empty when reading from config file")
+	}
+	return
+}
 
-// 	filePath, err := presentOrError(info.KeyFilePath, hasKeyFile, info.PresentConfiguration, "key file path")
-// 	if err != nil {
-// 		return
-// 	}
+func (p *fileConfigurationProvider) KeyID() (keyID string, err error) {
+	tenancy, err := p.TenancyOCID()
+	if err != nil {
+		return
+	}
 
-// 	expandedPath := expandPath(filePath)
-// 	pemFileContent, err := readFileFromCache(expandedPath)
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read PrivateKey  from configuration file due to: %s", err.Error())}
-// 		return
-// 	}
+	fingerprint, err := p.KeyFingerprint()
+	if err != nil {
+		return
+	}
 
-// 	password := p.PrivateKeyPassword
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
+		return
+	}
+	if info.PresentConfiguration&hasUser == hasUser {
+		if info.UserOcid == "" {
+			err = fileConfigurationProviderError{err: fmt.Errorf("user cannot be empty in the config file")}
+			return
+		}
+		return fmt.Sprintf("%s/%s/%s", tenancy, info.UserOcid, fingerprint), nil
+	}
+	filePath, pathErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration, "securityTokenFilePath")
+	if pathErr == nil {
+		rawString, err := getTokenContent(filePath)
+		if err != nil {
+			return "", fileConfigurationProviderError{err: err}
+		}
+		return "ST$" + rawString, nil
+	}
+	err = fileConfigurationProviderError{err: fmt.Errorf("can not read SecurityTokenFilePath from configuration file due to: %s", pathErr.Error())}
+	return
+}
 
-// 	if password == "" && ((info.PresentConfiguration & hasPassphrase) == hasPassphrase) {
-// 		password = info.Passphrase
-// 	}
+func (p *fileConfigurationProvider) PrivateRSAKey() (key *rsa.PrivateKey, err error) {
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())}
+		return
+	}
 
-// 	key, err = PrivateKeyFromBytes(pemFileContent, &password)
-// 	return
-// }
+	pemFileContent := p.PrivateKey
+	if len(pemFileContent) == 0 {
+		filePath, pathErr := presentOrError(info.KeyFilePath, hasKeyFile, info.PresentConfiguration, "key file path")
+		if pathErr != nil {
+			return nil, pathErr
+		}
+
+		expandedPath := expandPath(filePath)
+		if pemFileContent, err = os.ReadFile(expandedPath); err != nil {
+			err = fileConfigurationProviderError{err: fmt.Errorf("can not read PrivateKey  from configuration file due to: %s", err.Error())}
+			return
+		}
+	}
 
-// func (p fileConfigurationProvider) Region() (value string, err error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read region configuration due to: %s", err.Error())}
-// 		return
-// 	}
+	password := p.PrivateKeyPassword
 
-// 	value, err = presentOrError(info.Region, hasRegion, info.PresentConfiguration, "region")
-// 	if err != nil {
-// 		val, error := getRegionFromEnvVar()
-// 		if error != nil {
-// 			err = fileConfigurationProviderError{err: fmt.Errorf("region configuration is missing from file, nor for OCI_REGION env var")}
-// 			return
-// 		}
-// 		value = val
-// 	}
+	if password == "" && ((info.PresentConfiguration & hasPassphrase) == hasPassphrase) {
+		password = info.Passphrase
+	}
 
-// 	return canStringBeRegion(value)
-// }
+	key, err = PrivateKeyFromBytes(pemFileContent, &password)
+	return
+}
 
-// func (p fileConfigurationProvider) AuthType() (AuthConfig, error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fmt.Errorf("can not read tenancy configuration due to: %s", err.Error())
-// 		return AuthConfig{UnknownAuthenticationType, true, nil}, err
-// 	}
-// 	val, _ := presentOrError(info.AuthenticationType, hasAuthenticationType, info.PresentConfiguration, "authentication_type")
+func (p *fileConfigurationProvider) Region() (value string, err error) {
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read region configuration due to: %s", err.Error())}
+		return
+	}
 
-// 	if val == "instance_principal" {
-// 		if filePath, err := presentOrError(info.DelegationTokenFilePath, hasDelegationTokenFile, info.PresentConfiguration, "delegationTokenFilePath"); err == nil {
-// 			if delegationToken, err := getTokenContent(filePath); err == nil && delegationToken != "" {
-// 				Debugf("delegation token content is %s, and error is %s ", delegationToken, err)
-// 				return AuthConfig{InstancePrincipalDelegationToken, true, &delegationToken}, nil
-// 			}
-// 			return AuthConfig{UnknownAuthenticationType, true, nil}, err
+	value, err = presentOrError(info.Region, hasRegion, info.PresentConfiguration, "region")
+	if err != nil {
+		val, error := getRegionFromEnvVar()
+		if error != nil {
+			err = fileConfigurationProviderError{err: fmt.Errorf("region configuration is missing from file, nor for OCI_REGION env var")}
+			return
+		}
+		value = val
+	}
 
-// 		}
-// 		// normal instance principle
-// 		return AuthConfig{InstancePrincipal, true, nil}, nil
-// 	}
+	return canStringBeRegion(value)
+}
 
-// 	// by default, if no "authentication_type" is provided, just treated as user principle type, and will not return error
-// 	return AuthConfig{UserPrincipal, true, nil}, nil
-// }
+func (p *fileConfigurationProvider) AuthType() (AuthConfig, error) {
+	// Only API keys and security tokens are read from configuration files
+	return AuthConfig{UserPrincipal, true, nil}, nil
+}
 
-// func getTokenContent(filePath string) (string, error) {
-// 	expandedPath := expandPath(filePath)
-// 	tokenFileContent, err := readFile(expandedPath)
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read token content from configuration file due to: %s", err.Error())}
-// 		return "", err
-// 	}
-// 	return string(tokenFileContent), nil
-// }
+func getTokenContent(filePath string) (string, error) {
+	expandedPath := expandPath(filePath)
+	tokenFileContent, err := os.ReadFile(expandedPath)
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read token content from configuration file due to: %s", err.Error())}
+		return "", err
+	}
+	return string(tokenFileContent), nil
+}
 
 // // A configuration provider that look for information in  multiple configuration providers
 // type composingConfigurationProvider struct {
@@ -730,61 +721,61 @@ type ConfigurationProvider interface {
 // 	return AuthConfig{UnknownAuthenticationType, false, nil}, fmt.Errorf("did not find a proper configuration for auth type")
 // }
 
-// func getRegionFromEnvVar() (string, error) {
-// 	regionEnvVar := "OCI_REGION"
-// 	if region, existed := os.LookupEnv(regionEnvVar); existed {
-// 		return region, nil
-// 	}
-// 	return "", fmt.Errorf("did not find OCI_REGION env var")
-// }
+func getRegionFromEnvVar() (string, error) {
+	regionEnvVar := "OCI_REGION"
+	if region, existed := os.LookupEnv(regionEnvVar); existed {
+		return region, nil
+	}
+	return "", fmt.Errorf("did not find OCI_REGION env var")
+}
 
-// type sessionTokenConfigurationProvider struct {
-// 	fileConfigurationProvider
-// }
+type sessionTokenConfigurationProvider struct {
+	*fileConfigurationProvider
+}
 
-// func (p sessionTokenConfigurationProvider) UserOCID() (value string, err error) {
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read the configuration due to: %s", err.Error())}
-// 		return
-// 	}
-// 	// In case of session token-based authentication, userOCID will not be present
-// 	// need to check if session token path is provided in the configuration
-// 	if _, stErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration,
-// 		"securityTokenPath"); stErr == nil {
-// 		err = nil
-// 	}
-// 	return
-// }
+func (p *sessionTokenConfigurationProvider) UserOCID() (value string, err error) {
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read the configuration due to: %s", err.Error())}
+		return
+	}
+	// In case of session token-based authentication, userOCID will not be present
+	// need to check if session token path is provided in the configuration
+	if _, stErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration,
+		"securityTokenPath"); stErr == nil {
+		err = nil
+	}
+	return
+}
 
-// func (p sessionTokenConfigurationProvider) KeyID() (keyID string, err error) {
-// 	_, err = p.TenancyOCID()
-// 	if err != nil {
-// 		return
-// 	}
+func (p *sessionTokenConfigurationProvider) KeyID() (keyID string, err error) {
+	_, err = p.TenancyOCID()
+	if err != nil {
+		return
+	}
 
-// 	_, err = p.KeyFingerprint()
-// 	if err != nil {
-// 		return
-// 	}
+	_, err = p.KeyFingerprint()
+	if err != nil {
+		return
+	}
 
-// 	info, err := p.readAndParseConfigFile()
-// 	if err != nil {
-// 		err = fileConfigurationProviderError{err: fmt.Errorf("can not read SessionTokenFilePath configuration due to: %s", err.Error())}
-// 		return
-// 	}
+	info, err := p.readAndParseConfigFile()
+	if err != nil {
+		err = fileConfigurationProviderError{err: fmt.Errorf("can not read SessionTokenFilePath configuration due to: %s", err.Error())}
+		return
+	}
 
-// 	filePath, pathErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration, "securityTokenFilePath")
-// 	if pathErr == nil {
-// 		rawString, err := getTokenContent(filePath)
-// 		if err != nil {
-// 			return "", fileConfigurationProviderError{err: err}
-// 		}
-// 		return "ST$" + rawString, nil
-// 	}
-// 	err = fileConfigurationProviderError{err: fmt.Errorf("can not read SessionTokenFilePath from configuration file due to: %s", pathErr.Error())}
-// 	return
-// }
+	filePath, pathErr := presentOrError(info.SecurityTokenFilePath, hasSecurityTokenFile, info.PresentConfiguration, "securityTokenFilePath")
+	if pathErr == nil {
+		rawString, err := getTokenContent(filePath)
+		if err != nil {
+			return "", fileConfigurationProviderError{err: err}
+		}
+		return "ST$" + rawString, nil
+	}
+	err = fileConfigurationProviderError{err: fmt.Errorf("can not read SessionTokenFilePath from configuration file due to: %s", pathErr.Error())}
+	return
+}
 
 // // ConfigurationProviderForSessionToken creates a session token configuration provider from a configuration file
 // // by reading the "DEFAULT" profile
@@ -793,8 +784,8 @@ type ConfigurationProvider interface {
 // 		return nil, fileConfigurationProviderError{err: fmt.Errorf("config file path can not be empty")}
 // 	}
 
-// 	return sessionTokenConfigurationProvider{
-// 		fileConfigurationProvider{
+// 	return &sessionTokenConfigurationProvider{
+// 		&fileConfigurationProvider{
 // 			ConfigPath:         configFilePath,
 // 			PrivateKeyPassword: privateKeyPassword,
 // 			Profile:            "DEFAULT",
@@ -808,17 +799,17 @@ type ConfigurationProvider interface {
 // 		return nil, fileConfigurationProviderError{err: fmt.Errorf("config file path can not be empty")}
 // 	}
 
-// 	return sessionTokenConfigurationProvider{
-// 		fileConfigurationProvider{
+// 	return &sessionTokenConfigurationProvider{
+// 		&fileConfigurationProvider{
 // 			ConfigPath:         configFilePath,
 // 			PrivateKeyPassword: privateKeyPassword,
 // 			Profile:            profile,
 // 			configMux:          sync.Mutex{}}}, nil
 // }
 
-// func (p sessionTokenConfigurationProvider) Refreshable() bool {
-// 	return true
-// }
+func (p *sessionTokenConfigurationProvider) Refreshable() bool {
+	return true
+}
 
 // RefreshableConfigurationProvider the interface to identity if the config provider is refreshable
 type RefreshableConfigurationProvider interface {
@@ -0,0 +1,50 @@
+package ocisdk
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeKeyFile(t *testing.T, path string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestProvider_ReadsRotatedKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key.pem")
+	configPath := filepath.Join(dir, "config")
+	config := "[DEFAULT]\nuser=ocid1.user.oc1..example\nfingerprint=aa:bb\ntenancy=" + testTenancy + "\nregion=" + testRegion + "\nkey_file=" + keyPath + "\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		// Every provider reads the current key file: a rotated key is not served from a cache
+		expected := writeKeyFile(t, keyPath)
+		provider, err := Provider(AuthAPIKey, configPath, "", Key{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := provider.PrivateRSAKey()
+		if err != nil {
+			t.Fatalf("failed to read the private key: %v", err)
+		}
+		if key.N.Cmp(expected.N) != 0 {
+			t.Errorf("key %d: expected the key of the file", i)
+		}
+	}
+}
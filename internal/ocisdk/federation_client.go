@@ -186,6 +186,10 @@ func newX509FederationClient(region Region, tenancyID string, leafCertificateRet
 // 	return client, nil
 // }
 
+// TokenRefreshObserver, when set, is called after every attempt to renew the federation
+// security token with the time the attempt took and its error, if any.
+var TokenRefreshObserver func(duration time.Duration, err error)
+
 var (
 	genericHeaders = []string{"date", "(request-target)"} // "host" is not needed for the federation endpoint.  Don't ask me why.
 	bodyHeaders    = []string{"content-length", "content-type", "x-content-sha256"}
@@ -248,7 +252,12 @@ func (c *x509FederationClient) SecurityToken() (token string, err error) {
 
 func (c *x509FederationClient) renewSecurityTokenIfNotValid() (err error) {
 	if c.securityToken == nil || !c.securityToken.Valid() {
-		if err = c.renewSecurityToken(); err != nil {
+		start := time.Now()
+		err = c.renewSecurityToken()
+		if TokenRefreshObserver != nil {
+			TokenRefreshObserver(time.Since(start), err)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to renew security token: %s", err.Error())
 		}
 	}
@@ -304,7 +313,7 @@ func (c *x509FederationClient) getSecurityToken() (securityToken, error) {
 			return nil, fmt.Errorf("error %s returned by auth service: %s", httpResponse.Status, err.Error())
 		}
 		nextDuration := time.Duration(1000.0*(math.Pow(2.0, float64(retry)))) * time.Millisecond
-		time.Sleep(nextDuration)
+		sleep(nextDuration)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to call: %s", err.Error())
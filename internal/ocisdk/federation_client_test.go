@@ -0,0 +1,191 @@
+package ocisdk
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+)
+
+func newTestProvider(t *testing.T) ConfigurationProvider {
+	t.Helper()
+	provider, err := InstancePrincipalConfigurationProvider()
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return provider
+}
+
+func TestX509FederationClient_CachesToken(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	provider := newTestProvider(t)
+
+	first, err := provider.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("expected the security token to be reused")
+	}
+	if issued := env.Federation.Issued(); issued != 1 {
+		t.Errorf("expected 1 security token, got %d", issued)
+	}
+}
+
+func TestX509FederationClient_RenewsExpiringToken(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	// Tokens expiring within the refresh buffer are renewed on every use
+	env.Federation.SetTokenTTL(time.Minute)
+	provider := newTestProvider(t)
+
+	first, err := provider.PrivateRSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider.PrivateRSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if issued := env.Federation.Issued(); issued != 2 {
+		t.Errorf("expected 2 security tokens, got %d", issued)
+	}
+	if first.N.Cmp(second.N) == 0 {
+		t.Error("expected a new session key with every renewal")
+	}
+
+	env.Federation.SetTokenTTL(time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := provider.KeyID(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if issued := env.Federation.Issued(); issued != 3 {
+		t.Errorf("expected the long-lived token to be cached, got %d tokens", issued)
+	}
+}
+
+func TestX509FederationClient_CertificateRotation(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	env.Federation.SetTokenTTL(time.Minute)
+	provider := newTestProvider(t)
+
+	if _, err := provider.KeyID(); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.IMDS.Rotate(testTenancy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.KeyID(); err != nil {
+		t.Fatalf("expected renewal with the rotated certificate, got %v", err)
+	}
+
+	if rejected := env.Federation.Rejected(); rejected != 0 {
+		t.Errorf("expected no rejected federation request, got %d", rejected)
+	}
+	// One fetch when the provider is created, one per renewal
+	if fetches := env.IMDS.Requests(ocitest.LeafCertificatePath); fetches != 3 {
+		t.Errorf("expected 3 leaf certificate fetches, got %d", fetches)
+	}
+}
+
+func TestX509FederationClient_TenancyChange(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	env.Federation.SetTokenTTL(time.Minute)
+	provider := newTestProvider(t)
+
+	if _, err := provider.KeyID(); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.IMDS.Rotate("ocid1.tenancy.oc1..other"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := provider.KeyID()
+	if err == nil || !strings.Contains(err.Error(), "unexpected update of tenancy OCID") {
+		t.Errorf("expected tenancy change error, got %v", err)
+	}
+}
+
+func TestX509FederationClient_AuthServiceUnavailable(t *testing.T) {
+	noSleep(t)
+	env := ocitest.Start(t, testRegion, testTenancy)
+	env.Federation.Fail(http.StatusServiceUnavailable)
+	provider := newTestProvider(t)
+
+	var observed []error
+	TokenRefreshObserver = func(_ time.Duration, err error) { observed = append(observed, err) }
+	t.Cleanup(func() { TokenRefreshObserver = nil })
+
+	if _, err := provider.KeyID(); err == nil {
+		t.Fatal("expected error when the auth service is unavailable")
+	}
+	if requests := env.Federation.Requests(); requests != 3 {
+		t.Errorf("expected 3 attempts, got %d", requests)
+	}
+	if len(observed) != 1 || observed[0] == nil {
+		t.Errorf("expected one failed refresh to be observed, got %v", observed)
+	}
+
+	// The client recovers once the service is back
+	env.Federation.Fail(0)
+	if _, err := provider.KeyID(); err != nil {
+		t.Errorf("expected recovery, got %v", err)
+	}
+	if len(observed) != 2 || observed[1] != nil {
+		t.Errorf("expected a successful refresh to be observed, got %v", observed)
+	}
+}
+
+func TestX509FederationClient_ClientErrorNotRetried(t *testing.T) {
+	noSleep(t)
+	env := ocitest.Start(t, testRegion, testTenancy)
+	env.Federation.Fail(http.StatusBadRequest)
+	provider := newTestProvider(t)
+
+	if _, err := provider.KeyID(); err == nil {
+		t.Fatal("expected error")
+	}
+	if requests := env.Federation.Requests(); requests != 1 {
+		t.Errorf("expected a single attempt, got %d", requests)
+	}
+}
+
+// tamperingDispatcher changes the date of requests after they are signed.
+type tamperingDispatcher struct {
+	next HTTPRequestDispatcher
+}
+
+func (d tamperingDispatcher) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" && !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer") {
+		req.Header.Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	}
+	return d.next.Do(req)
+}
+
+func TestX509FederationClient_InvalidSignatureRejected(t *testing.T) {
+	noSleep(t)
+	env := ocitest.Start(t, testRegion, testTenancy)
+
+	provider, err := newInstancePrincipalConfigurationProvider("", func(next HTTPRequestDispatcher) (HTTPRequestDispatcher, error) {
+		return tamperingDispatcher{next: next}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	_, err = provider.KeyID()
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected 401 error, got %v", err)
+	}
+	if env.Federation.Rejected() != 1 || env.Federation.Issued() != 0 {
+		t.Errorf("expected the request to be rejected, got %d rejected and %d issued", env.Federation.Rejected(), env.Federation.Issued())
+	}
+}
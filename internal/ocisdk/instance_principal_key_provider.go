@@ -30,6 +30,9 @@ var (
 	regionURL, leafCertificateURL, leafCertificateKeyURL, intermediateCertificateURL string
 )
 
+// sleep waits between retries of calls to the metadata and auth services. Tests replace it.
+var sleep = time.Sleep
+
 // instancePrincipalKeyProvider implements KeyProvider to provide a key ID and its corresponding private key
 // for an instance principal by getting a security token via x509FederationClient.
 //
@@ -112,7 +115,7 @@ func getRegionForFederationClient(dispatcher HTTPRequestDispatcher, url string)
 		if nextDuration > 30*time.Second {
 			nextDuration = 30*time.Second + time.Duration(rand.Float64())*time.Second
 		}
-		time.Sleep(nextDuration)
+		sleep(nextDuration)
 	}
 	return
 }
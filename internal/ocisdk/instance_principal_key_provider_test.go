@@ -0,0 +1,147 @@
+package ocisdk
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+)
+
+const (
+	testRegion  = "us-chicago-1"
+	testTenancy = "ocid1.tenancy.oc1..fake"
+)
+
+// noSleep disables the back-off between retries for the duration of the test.
+func noSleep(t *testing.T) {
+	t.Helper()
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = time.Sleep })
+}
+
+func TestInstancePrincipalConfigurationProvider(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+
+	provider, err := InstancePrincipalConfigurationProvider()
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	region, err := provider.Region()
+	if err != nil || region != testRegion {
+		t.Errorf("expected region %s, got %s (%v)", testRegion, region, err)
+	}
+	tenancy, err := provider.TenancyOCID()
+	if err != nil || tenancy != testTenancy {
+		t.Errorf("expected tenancy %s, got %s (%v)", testTenancy, tenancy, err)
+	}
+
+	keyID, err := provider.KeyID()
+	if err != nil {
+		t.Fatalf("failed to get key ID: %v", err)
+	}
+	if !strings.HasPrefix(keyID, "ST$") {
+		t.Errorf("expected security token key ID, got %s", keyID)
+	}
+
+	key, err := provider.PrivateRSAKey()
+	if err != nil {
+		t.Fatalf("failed to get private key: %v", err)
+	}
+	if key.PublicKey.N.Cmp(env.Federation.SessionKey().N) != 0 {
+		t.Error("expected the private key to match the federated session key")
+	}
+
+	if issued := env.Federation.Issued(); issued != 1 {
+		t.Errorf("expected 1 security token, got %d", issued)
+	}
+}
+
+func TestInstancePrincipal_SignedRequestVerifies(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+
+	provider, err := InstancePrincipalConfigurationProvider()
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	body := []byte(`{"compartmentId":"ocid1.compartment.oc1..fake"}`)
+	req, err := http.NewRequest(http.MethodPost, "https://inference.generativeai.us-chicago-1.oci.oraclecloud.com/20231130/actions/chat", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := NewWithProvider(provider).SignRequest(req); err != nil {
+		t.Fatalf("failed to sign request: %v", err)
+	}
+
+	// Simulate the request as received by the server
+	req.Host = req.URL.Host
+	received, _ := io.ReadAll(req.Body)
+	if err := env.Federation.VerifyRequest(req, received); err != nil {
+		t.Errorf("expected signature to verify, got %v", err)
+	}
+
+	req.Header.Set("Date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if err := env.Federation.VerifyRequest(req, received); err == nil {
+		t.Error("expected tampered request to fail verification")
+	}
+}
+
+func TestInstancePrincipal_RegionUnavailable(t *testing.T) {
+	noSleep(t)
+	env := ocitest.Start(t, testRegion, testTenancy)
+	env.IMDS.Fail(ocitest.RegionPath, http.StatusInternalServerError)
+
+	if _, err := InstancePrincipalConfigurationProvider(); err == nil {
+		t.Fatal("expected error when the region is unavailable")
+	} else if !strings.Contains(err.Error(), "failed to get the region name") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if requests := env.IMDS.Requests(ocitest.RegionPath); requests != 8 {
+		t.Errorf("expected 8 attempts, got %d", requests)
+	}
+}
+
+func TestInstancePrincipal_LeafCertificateUnavailable(t *testing.T) {
+	env := ocitest.Start(t, testRegion, testTenancy)
+	env.IMDS.Fail(ocitest.LeafCertificatePath, http.StatusNotFound)
+
+	_, err := InstancePrincipalConfigurationProvider()
+	if err == nil || !strings.Contains(err.Error(), "failed to refresh the leaf certificate") {
+		t.Errorf("expected leaf certificate error, got %v", err)
+	}
+	if env.Federation.Requests() != 0 {
+		t.Error("expected no federation request without a certificate")
+	}
+}
+
+func TestInstancePrincipal_MetadataBaseURL(t *testing.T) {
+	t.Setenv(metadataBaseURLEnvVar, "")
+	if got := getMetadataBaseURL(); got != defaultMetadataBaseURL {
+		t.Errorf("expected default base URL, got %s", got)
+	}
+
+	t.Setenv(metadataBaseURLEnvVar, "http://127.0.0.1:1/opc/v2")
+	if got := getMetadataBaseURL(); got != "http://127.0.0.1:1/opc/v2" {
+		t.Errorf("expected overridden base URL, got %s", got)
+	}
+}
+
+func TestNew_OffInstance(t *testing.T) {
+	noSleep(t)
+	imds := httptest.NewServer(http.NotFoundHandler())
+	imds.Close()
+	t.Setenv(metadataBaseURLEnvVar, imds.URL)
+
+	if authenticator, err := New(); err == nil {
+		t.Errorf("expected an error without the instance metadata service, got %v", authenticator)
+	}
+}
@@ -0,0 +1,373 @@
+package ocitest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FederationPath is the path of the X.509 federation endpoint.
+const FederationPath = "/v1/x509"
+
+// DefaultTokenTTL is the lifetime of the security tokens issued by default.
+const DefaultTokenTTL = time.Hour
+
+// Federation is a fake X.509 federation endpoint of the OCI auth service. It verifies that
+// federation requests are signed with the key of a leaf certificate issued by its IMDS, and
+// exchanges the session public key of the request for a security token.
+type Federation struct {
+	server *httptest.Server
+	imds   *IMDS
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	ttl      time.Duration
+	failure  int
+	requests int
+	issued   int
+	rejected int
+	last     *rsa.PublicKey
+}
+
+// federationRequest is the body of a request to the federation endpoint.
+type federationRequest struct {
+	Certificate              string   `json:"certificate"`
+	PublicKey                string   `json:"publicKey"`
+	IntermediateCertificates []string `json:"intermediateCertificates"`
+	FingerprintAlgorithm     string   `json:"fingerprintAlgorithm"`
+}
+
+// NewFederation starts a federation endpoint trusting the certificates issued by imds.
+func NewFederation(imds *IMDS) (*Federation, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Federation{imds: imds, key: key, ttl: DefaultTokenTTL}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f, nil
+}
+
+// URL returns the base URL of the endpoint, the value of OCI_SDK_AUTH_CLIENT_REGION_URL.
+func (f *Federation) URL() string {
+	return f.server.URL
+}
+
+// Close stops the server.
+func (f *Federation) Close() {
+	f.server.Close()
+}
+
+// SetTokenTTL sets the lifetime of the security tokens issued from now on. The SDK renews
+// tokens expiring within five minutes, so a shorter lifetime forces a renewal on every use.
+func (f *Federation) SetTokenTTL(ttl time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ttl = ttl
+}
+
+// Fail makes the endpoint answer every request with status. A status of 0 restores normal operation.
+func (f *Federation) Fail(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failure = status
+}
+
+// Requests returns the number of federation requests received.
+func (f *Federation) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// Issued returns the number of security tokens issued.
+func (f *Federation) Issued() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.issued
+}
+
+// Rejected returns the number of requests rejected because of an invalid signature or certificate.
+func (f *Federation) Rejected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rejected
+}
+
+// SessionKey returns the session public key of the last security token issued.
+func (f *Federation) SessionKey() *rsa.PublicKey {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+// VerifyRequest checks that a request to an OCI service is signed with a valid, unexpired
+// security token issued by the endpoint and the session key it was issued for.
+func (f *Federation) VerifyRequest(req *http.Request, body []byte) error {
+	signature, err := ParseSignature(req.Header.Get("Authorization"))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(signature.KeyID, "ST$") {
+		return errors.New("keyId is not a security token")
+	}
+
+	claims, err := f.verifyToken(strings.TrimPrefix(signature.KeyID, "ST$"))
+	if err != nil {
+		return err
+	}
+	if !signature.covers("host") {
+		return errors.New("signature does not cover host")
+	}
+
+	sessionKey, err := parseJWK(claims.JWK)
+	if err != nil {
+		return err
+	}
+	return signature.Verify(req, body, sessionKey)
+}
+
+func (f *Federation) serveHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != FederationPath || req.Method != http.MethodPost {
+		writeServiceError(rw, http.StatusNotFound, "NotFound", "not found")
+		return
+	}
+
+	f.mu.Lock()
+	f.requests++
+	failure, ttl := f.failure, f.ttl
+	f.mu.Unlock()
+	if failure != 0 {
+		writeServiceError(rw, failure, "InjectedFailure", fmt.Sprintf("injected failure %d", failure))
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	var fedReq federationRequest
+	if err := json.Unmarshal(body, &fedReq); err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "invalid federation request")
+		return
+	}
+
+	sessionKey, tenancyID, err := f.verifyFederationRequest(req, body, fedReq)
+	if err != nil {
+		f.mu.Lock()
+		f.rejected++
+		f.mu.Unlock()
+		writeServiceError(rw, http.StatusUnauthorized, "NotAuthenticated", err.Error())
+		return
+	}
+
+	token, err := f.issueToken(tenancyID, sessionKey, ttl)
+	if err != nil {
+		writeServiceError(rw, http.StatusInternalServerError, "InternalServerError", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	f.issued++
+	f.last = sessionKey
+	f.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]string{"token": token})
+}
+
+// verifyFederationRequest checks the certificates and signature of a federation request and
+// returns the session key to issue a token for and the tenancy of the instance.
+func (f *Federation) verifyFederationRequest(req *http.Request, body []byte, fedReq federationRequest) (*rsa.PublicKey, string, error) {
+	leaf, err := parseDERBase64Certificate(fedReq.Certificate)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid certificate: %w", err)
+	}
+	if err := f.imds.verify(leaf); err != nil {
+		return nil, "", fmt.Errorf("certificate not issued by the metadata service: %w", err)
+	}
+	if len(fedReq.IntermediateCertificates) == 0 {
+		return nil, "", errors.New("missing intermediate certificates")
+	}
+
+	tenancyID := tenancyFromCertificate(leaf)
+	if tenancyID == "" {
+		return nil, "", errors.New("certificate has no tenancy")
+	}
+
+	signature, err := ParseSignature(req.Header.Get("Authorization"))
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(leaf.Raw)
+	expectedKeyID := tenancyID + "/fed-x509-sha256/" + strings.ReplaceAll(fmt.Sprintf("% x", sum), " ", ":")
+	if signature.KeyID != expectedKeyID {
+		return nil, "", fmt.Errorf("keyId %q does not match the certificate", signature.KeyID)
+	}
+
+	leafKey, ok := leaf.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, "", errors.New("certificate key is not an RSA key")
+	}
+	if err := signature.Verify(req, body, leafKey); err != nil {
+		return nil, "", err
+	}
+
+	der, err := base64.StdEncoding.DecodeString(fedReq.PublicKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %w", err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid public key: %w", err)
+	}
+	sessionKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, "", errors.New("session key is not an RSA key")
+	}
+
+	return sessionKey, tenancyID, nil
+}
+
+// tokenClaims are the claims of the issued security tokens.
+type tokenClaims struct {
+	Subject  string `json:"sub"`
+	Issuer   string `json:"iss"`
+	Tenant   string `json:"opc-tenant"`
+	CertType string `json:"opc-certtype"`
+	IssuedAt int64  `json:"iat"`
+	Expiry   int64  `json:"exp"`
+	JWK      string `json:"jwk"`
+}
+
+// jwk is the JSON web key of the session key embedded in a token.
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+var jwtEncoding = base64.RawURLEncoding
+
+func (f *Federation) issueToken(tenancyID string, sessionKey *rsa.PublicKey, ttl time.Duration) (string, error) {
+	key, err := json.Marshal(jwk{
+		KeyType: "RSA",
+		KeyID:   "ocid1.instance.oc1..fake",
+		N:       jwtEncoding.EncodeToString(sessionKey.N.Bytes()),
+		E:       jwtEncoding.EncodeToString(big.NewInt(int64(sessionKey.E)).Bytes()),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims, err := json.Marshal(tokenClaims{
+		Subject:  "ocid1.instance.oc1..fake",
+		Issuer:   "authService.oracle.com",
+		Tenant:   tenancyID,
+		CertType: "instance",
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(ttl).Unix(),
+		JWK:      string(key),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	header := jwtEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT","kid":"asw"}`))
+	signed := header + "." + jwtEncoding.EncodeToString(claims)
+	hashed := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + jwtEncoding.EncodeToString(signature), nil
+}
+
+// verifyToken checks the signature and expiry of a security token issued by the endpoint.
+func (f *Federation) verifyToken(token string) (tokenClaims, error) {
+	var claims tokenClaims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed security token")
+	}
+	signature, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errors.New("malformed security token signature")
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&f.key.PublicKey, crypto.SHA256, hashed[:], signature); err != nil {
+		return claims, errors.New("security token not issued by the federation endpoint")
+	}
+
+	payload, err := jwtEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, errors.New("malformed security token claims")
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, errors.New("malformed security token claims")
+	}
+	if time.Now().Unix() >= claims.Expiry {
+		return claims, errors.New("security token expired")
+	}
+	return claims, nil
+}
+
+func parseJWK(raw string) (*rsa.PublicKey, error) {
+	var key jwk
+	if err := json.Unmarshal([]byte(raw), &key); err != nil {
+		return nil, errors.New("malformed session key")
+	}
+	n, err := jwtEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, errors.New("malformed session key modulus")
+	}
+	e, err := jwtEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, errors.New("malformed session key exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func parseDERBase64Certificate(s string) (*x509.Certificate, error) {
+	der, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func tenancyFromCertificate(cert *x509.Certificate) string {
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if strings.HasPrefix(ou, "opc-tenant:") {
+			return strings.TrimPrefix(ou, "opc-tenant:")
+		}
+	}
+	return ""
+}
+
+// writeServiceError writes an error in the format of OCI services.
+func writeServiceError(rw http.ResponseWriter, status int, code, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"code": code, "message": message})
+}
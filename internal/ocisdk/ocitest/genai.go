@@ -0,0 +1,857 @@
+package ocitest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Actions of the OCI GenAI inference API served by the fake.
+const (
+	ChatAction            = "chat"
+	GenerateTextAction    = "generateText"
+	EmbedTextAction       = "embedText"
+	RerankTextAction      = "rerankText"
+	ApplyGuardrailsAction = "applyGuardrails"
+)
+
+// ActionsPath is the path prefix of the OCI GenAI inference actions.
+const ActionsPath = "/20231130/actions/"
+
+// EmbeddingDimensions is the length of the embeddings returned by embedText.
+const EmbeddingDimensions = 16
+
+// maxEmbedInputs is the maximum number of inputs of a single embedText request.
+const maxEmbedInputs = 96
+
+// Verifier checks the signature of a request received by a fake service. body is the request body.
+// Federation.VerifyRequest verifies requests signed by an instance principal.
+type Verifier func(req *http.Request, body []byte) error
+
+// Reply is a scripted answer of the GenAI fake to a single request. The zero Reply is a
+// successful response generated from the request, as when nothing is scripted.
+type Reply struct {
+	// Status is the status of an error reply; replies with a status of 400 or more are sent as OCI errors
+	Status int
+
+	// Code and Message form the OCI error document of error replies
+	Code    string
+	Message string
+
+	// Text is the text generated by chat and generateText replies; the default echoes the last
+	// user message or the prompt
+	Text string
+
+	// FinishReason is reported by chat and generateText replies; the default depends on the API
+	// format or runtime and on max tokens
+	FinishReason string
+
+	// Usage is reported by chat and embedText replies; the default counts words
+	Usage *Usage
+
+	// Embeddings are returned by embedText replies instead of the computed ones
+	Embeddings [][]float64
+
+	// Scores are the relevance scores of the documents of rerankText replies, by document index
+	Scores []float64
+
+	// Moderation are the scores of applyGuardrails replies, by content moderation category or
+	// PROMPT_INJECTION, instead of the computed ones
+	Moderation map[string]float64
+
+	// Body is sent verbatim as a successful JSON response, overriding every other field
+	Body string
+}
+
+// TextReply returns a chat reply generating text.
+func TextReply(text string) Reply {
+	return Reply{Text: text}
+}
+
+// ErrorReply returns a reply failing with an OCI error.
+func ErrorReply(status int, code, message string) Reply {
+	return Reply{Status: status, Code: code, Message: message}
+}
+
+// Usage is the token usage reported by OCI GenAI.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// GenAIRequest is a request received by the GenAI fake whose signature was verified.
+type GenAIRequest struct {
+	Action string
+	Header http.Header
+	Body   []byte
+}
+
+// Decode decodes the JSON body of the request into v.
+func (r GenAIRequest) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// GenAI is a fake OCI Generative AI inference endpoint. It verifies the signature of every
+// request, validates it like the service does, and answers chat (COHERE and GENERIC formats,
+// streamed or not), generateText (COHERE and LLAMA runtimes, streamed or not), embedText,
+// rerankText and applyGuardrails requests with scripted or generated responses.
+type GenAI struct {
+	server *httptest.Server
+	verify Verifier
+
+	mu        sync.Mutex
+	scripts   map[string][]Reply
+	throttled int
+	requests  []GenAIRequest
+	rejected  int
+}
+
+// NewGenAI starts a GenAI endpoint accepting the requests verify accepts.
+func NewGenAI(verify Verifier) *GenAI {
+	g := &GenAI{verify: verify, scripts: make(map[string][]Reply)}
+	g.server = httptest.NewServer(http.HandlerFunc(g.serveHTTP))
+	return g
+}
+
+// StartGenAI starts a GenAI endpoint and stops it when the test ends.
+func StartGenAI(t testing.TB, verify Verifier) *GenAI {
+	t.Helper()
+	g := NewGenAI(verify)
+	t.Cleanup(g.Close)
+	return g
+}
+
+// URL returns the base URL of the endpoint, the value of the plugin endpoint setting.
+func (g *GenAI) URL() string {
+	return g.server.URL
+}
+
+// Close stops the server.
+func (g *GenAI) Close() {
+	g.server.Close()
+}
+
+// Enqueue scripts the replies to the next requests of action, in order. Once the script is
+// exhausted, responses are generated from the requests again.
+func (g *GenAI) Enqueue(action string, replies ...Reply) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.scripts[action] = append(g.scripts[action], replies...)
+}
+
+// Throttle makes the endpoint answer the next n verified requests, of any action, with 429 Too Many Requests.
+func (g *GenAI) Throttle(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.throttled = n
+}
+
+// Requests returns the verified requests received for action.
+func (g *GenAI) Requests(action string) []GenAIRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var requests []GenAIRequest
+	for _, r := range g.requests {
+		if r.Action == action {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+// Rejected returns the number of requests rejected because of an invalid signature.
+func (g *GenAI) Rejected() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.rejected
+}
+
+// next records a verified request and returns the reply scripted for it, if any, and the
+// sequence number of the request.
+func (g *GenAI) next(action string, req *http.Request, body []byte) (Reply, bool, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.requests = append(g.requests, GenAIRequest{Action: action, Header: req.Header.Clone(), Body: body})
+
+	if g.throttled > 0 {
+		g.throttled--
+		return ErrorReply(http.StatusTooManyRequests, "TooManyRequests", "Too many requests for the tenancy."), true, len(g.requests)
+	}
+	script := g.scripts[action]
+	if len(script) == 0 {
+		return Reply{}, false, len(g.requests)
+	}
+	g.scripts[action] = script[1:]
+	return script[0], true, len(g.requests)
+}
+
+func (g *GenAI) serveHTTP(rw http.ResponseWriter, req *http.Request) {
+	action := strings.TrimPrefix(req.URL.Path, ActionsPath)
+	if !strings.HasPrefix(req.URL.Path, ActionsPath) || req.Method != http.MethodPost {
+		writeServiceError(rw, http.StatusNotFound, "NotAuthorizedOrNotFound", "Authorization failed or requested resource not found.")
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	if err := g.verify(req, body); err != nil {
+		g.mu.Lock()
+		g.rejected++
+		g.mu.Unlock()
+		writeServiceError(rw, http.StatusUnauthorized, "NotAuthenticated", "The required information to complete authentication was not provided or was incorrect: "+err.Error())
+		return
+	}
+
+	switch action {
+	case ChatAction, GenerateTextAction, EmbedTextAction, RerankTextAction, ApplyGuardrailsAction:
+	default:
+		writeServiceError(rw, http.StatusNotFound, "NotAuthorizedOrNotFound", "Authorization failed or requested resource not found.")
+		return
+	}
+
+	reply, scripted, sequence := g.next(action, req, body)
+	requestID := req.Header.Get("opc-request-id")
+	if requestID == "" {
+		requestID = fmt.Sprintf("FAKE%08d", sequence)
+	}
+	rw.Header().Set("opc-request-id", requestID)
+
+	switch {
+	case reply.Status >= http.StatusBadRequest:
+		writeServiceError(rw, reply.Status, reply.Code, reply.Message)
+		return
+	case scripted && reply.Body != "":
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(rw, reply.Body)
+		return
+	}
+
+	if !strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "Content-Type must be application/json.")
+		return
+	}
+
+	switch action {
+	case ChatAction:
+		g.serveChat(rw, body, reply)
+	case GenerateTextAction:
+		g.serveGenerateText(rw, body, reply)
+	case EmbedTextAction:
+		g.serveEmbedText(rw, body, reply)
+	case RerankTextAction:
+		g.serveRerankText(rw, body, reply)
+	case ApplyGuardrailsAction:
+		g.serveApplyGuardrails(rw, body, reply)
+	}
+}
+
+// servingMode selects the model of a GenAI request.
+type servingMode struct {
+	ServingType string `json:"servingType"`
+	ModelID     string `json:"modelId"`
+	EndpointID  string `json:"endpointId"`
+}
+
+// validateTarget checks the fields common to every GenAI request.
+func validateTarget(compartmentID string, mode servingMode) error {
+	switch {
+	case compartmentID == "":
+		return fmt.Errorf("compartmentId must not be empty")
+	case mode.ServingType == "ON_DEMAND" && mode.ModelID == "":
+		return fmt.Errorf("servingMode.modelId must not be empty")
+	case mode.ServingType == "DEDICATED" && mode.EndpointID == "":
+		return fmt.Errorf("servingMode.endpointId must not be empty")
+	case mode.ServingType != "ON_DEMAND" && mode.ServingType != "DEDICATED":
+		return fmt.Errorf("servingMode.servingType %q is not supported", mode.ServingType)
+	}
+	return nil
+}
+
+// modelID is the model reported in responses.
+func (m servingMode) modelID() string {
+	if m.ModelID != "" {
+		return m.ModelID
+	}
+	return m.EndpointID
+}
+
+// genericContent is a content part of a GENERIC chat message.
+type genericContent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// genericMessage is a message of a GENERIC chat request or response.
+type genericMessage struct {
+	Role    string           `json:"role"`
+	Content []genericContent `json:"content"`
+}
+
+// text returns the concatenated text parts of the message.
+func (m genericMessage) text() string {
+	var parts []string
+	for _, c := range m.Content {
+		if c.Type == "TEXT" {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// chatRequest is the body of a chat request.
+type chatRequest struct {
+	CompartmentID string      `json:"compartmentId"`
+	ServingMode   servingMode `json:"servingMode"`
+	ChatRequest   struct {
+		APIFormat     string           `json:"apiFormat"`
+		Message       string           `json:"message"`
+		Messages      []genericMessage `json:"messages"`
+		MaxTokens     int              `json:"maxTokens"`
+		IsStream      bool             `json:"isStream"`
+		StreamOptions *struct {
+			IsIncludeUsage bool `json:"isIncludeUsage"`
+		} `json:"streamOptions"`
+	} `json:"chatRequest"`
+}
+
+// prompt returns the text of the prompt and the last user message of the request.
+func (r chatRequest) prompt() (prompt, last string) {
+	if r.ChatRequest.APIFormat == "COHERE" {
+		return r.ChatRequest.Message, r.ChatRequest.Message
+	}
+
+	var parts []string
+	for _, m := range r.ChatRequest.Messages {
+		parts = append(parts, m.text())
+		if m.Role == "USER" {
+			last = m.text()
+		}
+	}
+	return strings.Join(parts, " "), last
+}
+
+func (g *GenAI) serveChat(rw http.ResponseWriter, body []byte, reply Reply) {
+	var req chatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "Invalid request body: "+err.Error())
+		return
+	}
+
+	err := validateTarget(req.CompartmentID, req.ServingMode)
+	if err == nil {
+		switch req.ChatRequest.APIFormat {
+		case "COHERE":
+			if req.ChatRequest.Message == "" {
+				err = fmt.Errorf("chatRequest.message must not be empty")
+			}
+		case "GENERIC":
+			if len(req.ChatRequest.Messages) == 0 {
+				err = fmt.Errorf("chatRequest.messages must not be empty")
+			}
+		default:
+			err = fmt.Errorf("chatRequest.apiFormat %q is not supported", req.ChatRequest.APIFormat)
+		}
+	}
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	prompt, last := req.prompt()
+	text := reply.Text
+	if text == "" {
+		text = "Echo: " + last
+	}
+
+	// Generation stops at the token limit; tokens are words
+	finishReason := reply.FinishReason
+	words := strings.SplitAfter(text, " ")
+	if limit := req.ChatRequest.MaxTokens; limit > 0 && len(words) > limit {
+		words = words[:limit]
+		text = strings.Join(words, "")
+		if finishReason == "" {
+			finishReason = "MAX_TOKENS"
+		}
+	}
+	if finishReason == "" {
+		finishReason = "COMPLETE"
+	}
+	generic := req.ChatRequest.APIFormat == "GENERIC"
+	if generic {
+		finishReason = genericFinishReason(finishReason)
+	}
+
+	usage := reply.Usage
+	if usage == nil {
+		usage = &Usage{PromptTokens: countTokens(prompt), CompletionTokens: countTokens(text)}
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	if !req.ChatRequest.IsStream {
+		chat := map[string]interface{}{"apiFormat": req.ChatRequest.APIFormat, "usage": usage}
+		if generic {
+			chat["choices"] = []interface{}{map[string]interface{}{
+				"index":        0,
+				"message":      genericMessage{Role: "ASSISTANT", Content: []genericContent{{Type: "TEXT", Text: text}}},
+				"finishReason": finishReason,
+			}}
+		} else {
+			chat["text"] = text
+			chat["finishReason"] = finishReason
+		}
+		writeJSON(rw, map[string]interface{}{
+			"modelId":      req.ServingMode.modelID(),
+			"modelVersion": "1.0",
+			"chatResponse": chat,
+		})
+		return
+	}
+
+	includeUsage := req.ChatRequest.StreamOptions != nil && req.ChatRequest.StreamOptions.IsIncludeUsage
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.WriteHeader(http.StatusOK)
+
+	for _, word := range words {
+		if generic {
+			writeEvent(rw, map[string]interface{}{
+				"index":   0,
+				"message": genericMessage{Role: "ASSISTANT", Content: []genericContent{{Type: "TEXT", Text: word}}},
+			})
+		} else {
+			writeEvent(rw, map[string]interface{}{"apiFormat": "COHERE", "text": word})
+		}
+	}
+
+	// COHERE repeats the complete text with the finish reason, GENERIC reports usage separately
+	if generic {
+		writeEvent(rw, map[string]interface{}{"index": 0, "finishReason": finishReason})
+		if includeUsage {
+			writeEvent(rw, map[string]interface{}{"usage": usage})
+		}
+		return
+	}
+	final := map[string]interface{}{"apiFormat": "COHERE", "text": text, "finishReason": finishReason}
+	if includeUsage {
+		final["usage"] = usage
+	}
+	writeEvent(rw, final)
+}
+
+// genericFinishReason converts a Cohere finish reason to the vocabulary of the GENERIC format.
+func genericFinishReason(reason string) string {
+	switch reason {
+	case "COMPLETE", "STOP_SEQUENCE":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "ERROR_TOXIC":
+		return "content_filter"
+	}
+	return reason
+}
+
+// generateTextRequest is the body of a generateText request.
+type generateTextRequest struct {
+	CompartmentID    string      `json:"compartmentId"`
+	ServingMode      servingMode `json:"servingMode"`
+	InferenceRequest struct {
+		RuntimeType    string `json:"runtimeType"`
+		Prompt         string `json:"prompt"`
+		MaxTokens      int    `json:"maxTokens"`
+		NumGenerations int    `json:"numGenerations"`
+		IsEcho         bool   `json:"isEcho"`
+		IsStream       bool   `json:"isStream"`
+	} `json:"inferenceRequest"`
+}
+
+func (g *GenAI) serveGenerateText(rw http.ResponseWriter, body []byte, reply Reply) {
+	var req generateTextRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "Invalid request body: "+err.Error())
+		return
+	}
+
+	inference := req.InferenceRequest
+	err := validateTarget(req.CompartmentID, req.ServingMode)
+	switch {
+	case err != nil:
+	case inference.RuntimeType != "COHERE" && inference.RuntimeType != "LLAMA":
+		err = fmt.Errorf("inferenceRequest.runtimeType %q is not supported", inference.RuntimeType)
+	case inference.Prompt == "":
+		err = fmt.Errorf("inferenceRequest.prompt must not be empty")
+	case inference.NumGenerations < 0 || inference.NumGenerations > 5:
+		err = fmt.Errorf("inferenceRequest.numGenerations must be between 1 and 5")
+	}
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	text := reply.Text
+	if text == "" {
+		text = "Echo: " + inference.Prompt
+	}
+
+	// Generation stops at the token limit; tokens are words
+	finishReason := reply.FinishReason
+	words := strings.SplitAfter(text, " ")
+	if limit := inference.MaxTokens; limit > 0 && len(words) > limit {
+		words = words[:limit]
+		text = strings.Join(words, "")
+		if finishReason == "" {
+			finishReason = "MAX_TOKENS"
+		}
+	}
+	if finishReason == "" {
+		finishReason = "COMPLETE"
+	}
+	llama := inference.RuntimeType == "LLAMA"
+	if llama {
+		finishReason = genericFinishReason(finishReason)
+	}
+	if inference.IsEcho {
+		text = inference.Prompt + text
+		words = append([]string{inference.Prompt}, words...)
+	}
+
+	if !inference.IsStream {
+		generations := inference.NumGenerations
+		if generations == 0 {
+			generations = 1
+		}
+		response := map[string]interface{}{"runtimeType": inference.RuntimeType}
+		var texts []interface{}
+		for i := 0; i < generations; i++ {
+			if llama {
+				texts = append(texts, map[string]interface{}{"index": i, "text": text, "finishReason": finishReason})
+			} else {
+				texts = append(texts, map[string]interface{}{"id": fmt.Sprintf("generation-%d", i), "text": text, "finishReason": finishReason})
+			}
+		}
+		if llama {
+			response["choices"] = texts
+		} else {
+			response["generatedTexts"] = texts
+		}
+		writeJSON(rw, map[string]interface{}{
+			"modelId":           req.ServingMode.modelID(),
+			"modelVersion":      "1.0",
+			"inferenceResponse": response,
+		})
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.WriteHeader(http.StatusOK)
+
+	for _, word := range words {
+		writeEvent(rw, map[string]interface{}{"index": 0, "text": word})
+	}
+
+	// Like COHERE chat, the COHERE runtime repeats the complete text with the finish reason
+	final := map[string]interface{}{"index": 0, "finishReason": finishReason}
+	if !llama {
+		final["text"] = text
+	}
+	writeEvent(rw, final)
+}
+
+// embedTextRequest is the body of an embedText request.
+type embedTextRequest struct {
+	CompartmentID string      `json:"compartmentId"`
+	ServingMode   servingMode `json:"servingMode"`
+	Inputs        []string    `json:"inputs"`
+	IsEcho        bool        `json:"isEcho"`
+}
+
+func (g *GenAI) serveEmbedText(rw http.ResponseWriter, body []byte, reply Reply) {
+	var req embedTextRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "Invalid request body: "+err.Error())
+		return
+	}
+
+	err := validateTarget(req.CompartmentID, req.ServingMode)
+	switch {
+	case err != nil:
+	case len(req.Inputs) == 0:
+		err = fmt.Errorf("inputs must not be empty")
+	case len(req.Inputs) > maxEmbedInputs:
+		err = fmt.Errorf("inputs must not contain more than %d items", maxEmbedInputs)
+	}
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	embeddings := reply.Embeddings
+	if embeddings == nil {
+		embeddings = make([][]float64, len(req.Inputs))
+		for i, input := range req.Inputs {
+			embeddings[i] = embedding(input)
+		}
+	}
+
+	usage := reply.Usage
+	if usage == nil {
+		usage = &Usage{PromptTokens: countTokens(strings.Join(req.Inputs, " "))}
+		usage.TotalTokens = usage.PromptTokens
+	}
+
+	resp := map[string]interface{}{
+		"id":           "embed-fake",
+		"modelId":      req.ServingMode.modelID(),
+		"modelVersion": "1.0",
+		"embeddings":   embeddings,
+		"usage":        usage,
+	}
+	if req.IsEcho {
+		resp["inputs"] = req.Inputs
+	}
+	writeJSON(rw, resp)
+}
+
+// embedding computes a deterministic unit vector from the input text.
+func embedding(input string) []float64 {
+	sum := sha256.Sum256([]byte(input))
+	vector := make([]float64, EmbeddingDimensions)
+	var norm float64
+	for i := range vector {
+		v := float64(binary.BigEndian.Uint16(sum[(2*i)%len(sum):]))/math.MaxUint16*2 - 1
+		vector[i] = v
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+// rerankTextRequest is the body of a rerankText request.
+type rerankTextRequest struct {
+	CompartmentID string      `json:"compartmentId"`
+	ServingMode   servingMode `json:"servingMode"`
+	Input         string      `json:"input"`
+	Documents     []string    `json:"documents"`
+	TopN          int         `json:"topN"`
+	IsEcho        bool        `json:"isEcho"`
+}
+
+// documentRank is the rank of a document in a rerankText response.
+type documentRank struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevanceScore"`
+	Document       *rerankDocument `json:"document,omitempty"`
+}
+
+// rerankDocument is a document echoed in a rerankText response.
+type rerankDocument struct {
+	Text string `json:"text"`
+}
+
+func (g *GenAI) serveRerankText(rw http.ResponseWriter, body []byte, reply Reply) {
+	var req rerankTextRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "Invalid request body: "+err.Error())
+		return
+	}
+
+	err := validateTarget(req.CompartmentID, req.ServingMode)
+	switch {
+	case err != nil:
+	case req.Input == "":
+		err = fmt.Errorf("input must not be empty")
+	case len(req.Documents) == 0:
+		err = fmt.Errorf("documents must not be empty")
+	case req.TopN < 0:
+		err = fmt.Errorf("topN must not be negative")
+	}
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	ranks := make([]documentRank, len(req.Documents))
+	for i, document := range req.Documents {
+		ranks[i] = documentRank{Index: i, RelevanceScore: relevance(req.Input, document)}
+		if i < len(reply.Scores) {
+			ranks[i].RelevanceScore = reply.Scores[i]
+		}
+		if req.IsEcho {
+			ranks[i].Document = &rerankDocument{Text: document}
+		}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		return ranks[i].RelevanceScore > ranks[j].RelevanceScore
+	})
+	if req.TopN > 0 && req.TopN < len(ranks) {
+		ranks = ranks[:req.TopN]
+	}
+
+	writeJSON(rw, map[string]interface{}{
+		"id":            "rerank-fake",
+		"modelId":       req.ServingMode.modelID(),
+		"modelVersion":  "1.0",
+		"documentRanks": ranks,
+	})
+}
+
+// relevance scores a document by the fraction of the distinct query words it contains.
+func relevance(query, document string) float64 {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		words[w] = true
+	}
+	contained := make(map[string]bool)
+	for _, w := range strings.Fields(strings.ToLower(document)) {
+		if words[w] {
+			contained[w] = true
+		}
+	}
+	return float64(len(contained)) / float64(len(words))
+}
+
+// applyGuardrailsRequest is the body of an applyGuardrails request.
+type applyGuardrailsRequest struct {
+	CompartmentID string `json:"compartmentId"`
+	Input         struct {
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	} `json:"input"`
+	GuardrailConfigs struct {
+		ContentModerationConfig *struct {
+			Categories []string `json:"categories"`
+		} `json:"contentModerationConfig"`
+		PersonallyIdentifiableInformationConfig *struct {
+			Types []string `json:"types"`
+		} `json:"personallyIdentifiableInformationConfig"`
+		PromptInjectionConfig *struct{} `json:"promptInjectionConfig"`
+	} `json:"guardrailConfigs"`
+}
+
+// emailPattern finds the email addresses reported as personal data.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// serveApplyGuardrails scores the text of an applyGuardrails request. Unless scripted, the
+// OVERALL category scores 1 for texts containing "unsafe", prompt injection scores 1 for texts
+// containing "ignore previous instructions", and email addresses are the only personal data.
+func (g *GenAI) serveApplyGuardrails(rw http.ResponseWriter, body []byte, reply Reply) {
+	var req applyGuardrailsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", "Invalid request body: "+err.Error())
+		return
+	}
+
+	configs := req.GuardrailConfigs
+	var err error
+	switch {
+	case req.CompartmentID == "":
+		err = fmt.Errorf("compartmentId must not be empty")
+	case req.Input.Type != "TEXT":
+		err = fmt.Errorf("input.type %q is not supported", req.Input.Type)
+	case req.Input.Content == "":
+		err = fmt.Errorf("input.content must not be empty")
+	case configs.ContentModerationConfig == nil && configs.PersonallyIdentifiableInformationConfig == nil && configs.PromptInjectionConfig == nil:
+		err = fmt.Errorf("guardrailConfigs must not be empty")
+	}
+	if err != nil {
+		writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+
+	text := strings.ToLower(req.Input.Content)
+	score := func(name string, computed bool) float64 {
+		if s, ok := reply.Moderation[name]; ok {
+			return s
+		}
+		if computed {
+			return 1
+		}
+		return 0
+	}
+
+	results := map[string]interface{}{}
+	if config := configs.ContentModerationConfig; config != nil {
+		categories := []map[string]interface{}{}
+		for _, name := range config.Categories {
+			switch name {
+			case "OVERALL", "BLOCKLIST":
+			default:
+				writeServiceError(rw, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("category %q is not supported", name))
+				return
+			}
+			categories = append(categories, map[string]interface{}{
+				"name":  name,
+				"score": score(name, name == "OVERALL" && strings.Contains(text, "unsafe")),
+			})
+		}
+		results["contentModeration"] = map[string]interface{}{"categories": categories}
+	}
+	if configs.PromptInjectionConfig != nil {
+		results["promptInjection"] = map[string]interface{}{
+			"score": score("PROMPT_INJECTION", strings.Contains(text, "ignore previous instructions")),
+		}
+	}
+	if config := configs.PersonallyIdentifiableInformationConfig; config != nil {
+		entities := []map[string]interface{}{}
+		for _, piiType := range config.Types {
+			if piiType != "EMAIL" {
+				continue
+			}
+			for _, loc := range emailPattern.FindAllStringIndex(req.Input.Content, -1) {
+				entities = append(entities, map[string]interface{}{
+					"text":   req.Input.Content[loc[0]:loc[1]],
+					"label":  "EMAIL",
+					"offset": loc[0],
+					"length": loc[1] - loc[0],
+					"score":  1.0,
+				})
+			}
+		}
+		results["personallyIdentifiableInformation"] = entities
+	}
+
+	writeJSON(rw, map[string]interface{}{"results": results})
+}
+
+// countTokens approximates the number of tokens of text by its number of words.
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+// writeJSON writes a successful JSON response.
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+// writeEvent writes and flushes a single server-sent event.
+func writeEvent(rw http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	var buf bytes.Buffer
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+	_, _ = rw.Write(buf.Bytes())
+	if flusher, ok := rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,253 @@
+// Package ocitest provides fake OCI services for tests: an Instance Metadata Service (IMDS) vending
+// instance principal certificates, an X.509 federation endpoint issuing security tokens, and a
+// GenAI inference endpoint accepting the requests signed with those tokens.
+//
+// The servers are plain httptest servers, reachable from the ocisdk package through the
+// OCI_METADATA_BASE_URL and OCI_SDK_AUTH_CLIENT_REGION_URL environment variables, which Start sets.
+// The GenAI endpoint is reached through the endpoint setting of the plugin.
+// The package only depends on the standard library so that ocisdk's own tests can use it.
+package ocitest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Paths served by the IMDS, relative to its base URL.
+const (
+	RegionPath                  = "/instance/region"
+	LeafCertificatePath         = "/identity/cert.pem"
+	LeafCertificateKeyPath      = "/identity/key.pem"
+	IntermediateCertificatePath = "/identity/intermediate.pem"
+)
+
+// basePath is the path prefix of the IMDS v2 API.
+const basePath = "/opc/v2"
+
+// Environment is a running IMDS and federation endpoint.
+type Environment struct {
+	IMDS       *IMDS
+	Federation *Federation
+}
+
+// Start starts an IMDS and a federation endpoint for the given region and tenancy, points the
+// ocisdk environment variables at them and stops them when the test ends.
+func Start(t testing.TB, region, tenancyID string) *Environment {
+	t.Helper()
+
+	imds, err := NewIMDS(region, tenancyID)
+	if err != nil {
+		t.Fatalf("failed to start fake IMDS: %v", err)
+	}
+	t.Cleanup(imds.Close)
+
+	federation, err := NewFederation(imds)
+	if err != nil {
+		t.Fatalf("failed to start fake federation endpoint: %v", err)
+	}
+	t.Cleanup(federation.Close)
+
+	t.Setenv("OCI_METADATA_BASE_URL", imds.URL())
+	t.Setenv("OCI_SDK_AUTH_CLIENT_REGION_URL", federation.URL())
+
+	return &Environment{IMDS: imds, Federation: federation}
+}
+
+// IMDS is a fake Instance Metadata Service. It acts as the certificate authority of the instance
+// principal: its intermediate certificate signs the leaf certificates it serves.
+type IMDS struct {
+	server *httptest.Server
+	region string
+
+	intermediateKey  *rsa.PrivateKey
+	intermediate     *x509.Certificate
+	intermediatePEM  []byte
+	intermediatePool *x509.CertPool
+
+	mu       sync.Mutex
+	leaf     *x509.Certificate
+	leafPEM  []byte
+	keyPEM   []byte
+	failures map[string]int
+	requests map[string]int
+}
+
+// NewIMDS starts an IMDS for an instance of the given tenancy in region.
+func NewIMDS(region, tenancyID string) (*IMDS, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "PKISVC Identity Intermediate " + region},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &IMDS{
+		region:           region,
+		intermediateKey:  key,
+		intermediate:     intermediate,
+		intermediatePEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		intermediatePool: x509.NewCertPool(),
+		failures:         make(map[string]int),
+		requests:         make(map[string]int),
+	}
+	m.intermediatePool.AddCert(intermediate)
+
+	if err := m.Rotate(tenancyID); err != nil {
+		return nil, err
+	}
+
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m, nil
+}
+
+// URL returns the base URL of the IMDS v2 API, the value of OCI_METADATA_BASE_URL.
+func (m *IMDS) URL() string {
+	return m.server.URL + basePath
+}
+
+// Close stops the server.
+func (m *IMDS) Close() {
+	m.server.Close()
+}
+
+// Rotate issues a new leaf certificate and key for an instance of tenancyID.
+func (m *IMDS) Rotate(tenancyID string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: "ocid1.instance.oc1..fake",
+			OrganizationalUnit: []string{
+				"opc-certtype:instance",
+				"opc-instance:ocid1.instance.oc1..fake",
+				"opc-compartment:" + tenancyID,
+				"opc-tenant:" + tenancyID,
+			},
+		},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(2 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, m.intermediate, &key.PublicKey, m.intermediateKey)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leaf = leaf
+	m.leafPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	m.keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return nil
+}
+
+// Leaf returns the current leaf certificate.
+func (m *IMDS) Leaf() *x509.Certificate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.leaf
+}
+
+// Fail makes the IMDS answer requests to path with status. A status of 0 restores normal operation.
+func (m *IMDS) Fail(path string, status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if status == 0 {
+		delete(m.failures, path)
+		return
+	}
+	m.failures[path] = status
+}
+
+// Requests returns the number of requests received for path.
+func (m *IMDS) Requests(path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[path]
+}
+
+// verify checks that a certificate was issued by the IMDS.
+func (m *IMDS) verify(cert *x509.Certificate) error {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:     m.intermediatePool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+func (m *IMDS) serveHTTP(rw http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, basePath+"/") || req.Method != http.MethodGet {
+		http.NotFound(rw, req)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, basePath)
+
+	m.mu.Lock()
+	m.requests[path]++
+	status := m.failures[path]
+	leafPEM, keyPEM := m.leafPEM, m.keyPEM
+	m.mu.Unlock()
+
+	// IMDS v2 rejects requests without the Oracle bearer header
+	if req.Header.Get("Authorization") != "Bearer Oracle" {
+		http.Error(rw, "missing Authorization header", http.StatusUnauthorized)
+		return
+	}
+	if status != 0 {
+		http.Error(rw, fmt.Sprintf("injected failure %d", status), status)
+		return
+	}
+
+	switch path {
+	case RegionPath:
+		_, _ = rw.Write([]byte(m.region))
+	case LeafCertificatePath:
+		_, _ = rw.Write(leafPEM)
+	case LeafCertificateKeyPath:
+		_, _ = rw.Write(keyPEM)
+	case IntermediateCertificatePath:
+		_, _ = rw.Write(m.intermediatePEM)
+	default:
+		http.NotFound(rw, req)
+	}
+}
@@ -0,0 +1,330 @@
+package ocitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSignature(t *testing.T) {
+	header := `Signature version="1",headers="date (request-target) host",keyId="ST$token",algorithm="rsa-sha256",signature="c2lnbmF0dXJl"`
+
+	s, err := ParseSignature(header)
+	if err != nil {
+		t.Fatalf("failed to parse signature: %v", err)
+	}
+	if s.KeyID != "ST$token" || len(s.Headers) != 3 || string(s.Signature) != "signature" {
+		t.Errorf("unexpected signature: %+v", s)
+	}
+
+	invalid := []string{
+		"Bearer token",
+		strings.Replace(header, `version="1"`, `version="2"`, 1),
+		strings.Replace(header, "rsa-sha256", "hmac-sha256", 1),
+		strings.Replace(header, `keyId="ST$token"`, `keyId=""`, 1),
+		strings.Replace(header, "c2lnbmF0dXJl", "!", 1),
+	}
+	for _, h := range invalid {
+		if _, err := ParseSignature(h); err == nil {
+			t.Errorf("expected error for %s", h)
+		}
+	}
+}
+
+func TestIMDS_RequiresBearerHeader(t *testing.T) {
+	imds, err := NewIMDS("us-chicago-1", "ocid1.tenancy.oc1..fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imds.Close()
+
+	resp, err := http.Get(imds.URL() + RegionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestFederation_RejectsUnsignedRequests(t *testing.T) {
+	env := Start(t, "us-chicago-1", "ocid1.tenancy.oc1..fake")
+
+	resp, err := http.Post(env.Federation.URL()+FederationPath, "application/json", strings.NewReader(`{"certificate":"","publicKey":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", resp.StatusCode)
+	}
+	if env.Federation.Rejected() != 1 {
+		t.Errorf("expected 1 rejected request, got %d", env.Federation.Rejected())
+	}
+}
+
+func TestFederation_VerifyRequestRejectsExpiredToken(t *testing.T) {
+	env := Start(t, "us-chicago-1", "ocid1.tenancy.oc1..fake")
+
+	token, err := env.Federation.issueToken("ocid1.tenancy.oc1..fake", &env.Federation.key.PublicKey, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/20231130/models", bytes.NewReader(nil))
+	req.Header.Set("Authorization", `Signature version="1",headers="date (request-target) host",keyId="ST$`+token+`",algorithm="rsa-sha256",signature="c2ln"`)
+
+	err = env.Federation.VerifyRequest(req, nil)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected expired token error, got %v", err)
+	}
+}
+
+// acceptAll is a Verifier accepting every request.
+func acceptAll(*http.Request, []byte) error { return nil }
+
+// postAction sends an unsigned request to a GenAI action and returns the response and its body.
+func postAction(t *testing.T, g *GenAI, action, body string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Post(g.URL()+ActionsPath+action, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestGenAI_RejectsUnsignedRequests(t *testing.T) {
+	env := Start(t, "us-chicago-1", "ocid1.tenancy.oc1..fake")
+	g := StartGenAI(t, env.Federation.VerifyRequest)
+
+	resp, _ := postAction(t, g, ChatAction, `{}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", resp.StatusCode)
+	}
+	if g.Rejected() != 1 || len(g.Requests(ChatAction)) != 0 {
+		t.Errorf("expected 1 rejected and no recorded request, got %d and %d", g.Rejected(), len(g.Requests(ChatAction)))
+	}
+}
+
+func TestGenAI_Chat(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			name:     "cohere",
+			request:  `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"COHERE","message":"Hi there"}}`,
+			expected: `{"chatResponse":{"apiFormat":"COHERE","finishReason":"COMPLETE","text":"Echo: Hi there","usage":{"promptTokens":2,"completionTokens":3,"totalTokens":5}},"modelId":"m","modelVersion":"1.0"}`,
+		},
+		{
+			name:     "generic",
+			request:  `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"GENERIC","messages":[{"role":"SYSTEM","content":[{"type":"TEXT","text":"Be brief"}]},{"role":"USER","content":[{"type":"TEXT","text":"Hi there"}]}]}}`,
+			expected: `{"chatResponse":{"apiFormat":"GENERIC","choices":[{"finishReason":"stop","index":0,"message":{"role":"ASSISTANT","content":[{"type":"TEXT","text":"Echo: Hi there"}]}}],"usage":{"promptTokens":4,"completionTokens":3,"totalTokens":7}},"modelId":"m","modelVersion":"1.0"}`,
+		},
+		{
+			name:    "cohere stream",
+			request: `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"COHERE","message":"Hi","isStream":true,"streamOptions":{"isIncludeUsage":true}}}`,
+			expected: "data: {\"apiFormat\":\"COHERE\",\"text\":\"Echo: \"}\n\n" +
+				"data: {\"apiFormat\":\"COHERE\",\"text\":\"Hi\"}\n\n" +
+				"data: {\"apiFormat\":\"COHERE\",\"finishReason\":\"COMPLETE\",\"text\":\"Echo: Hi\",\"usage\":{\"promptTokens\":1,\"completionTokens\":2,\"totalTokens\":3}}\n\n",
+		},
+		{
+			name:    "generic stream with max tokens",
+			request: `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"GENERIC","messages":[{"role":"USER","content":[{"type":"TEXT","text":"Hi"}]}],"maxTokens":1,"isStream":true}}`,
+			expected: "data: {\"index\":0,\"message\":{\"role\":\"ASSISTANT\",\"content\":[{\"type\":\"TEXT\",\"text\":\"Echo: \"}]}}\n\n" +
+				"data: {\"finishReason\":\"length\",\"index\":0}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := postAction(t, g, ChatAction, tt.request)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+			}
+			if strings.TrimSpace(body) != strings.TrimSpace(tt.expected) {
+				t.Errorf("expected body\n%s\ngot\n%s", tt.expected, body)
+			}
+		})
+	}
+}
+
+func TestGenAI_ValidatesRequests(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+
+	tests := []struct {
+		action  string
+		request string
+	}{
+		{ChatAction, `{"servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"COHERE","message":"Hi"}}`},
+		{ChatAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND"},"chatRequest":{"apiFormat":"COHERE","message":"Hi"}}`},
+		{ChatAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"COHERE"}}`},
+		{ChatAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"GENERIC"}}`},
+		{ChatAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"OPENAI","message":"Hi"}}`},
+		{EmbedTextAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inputs":[]}`},
+		{RerankTextAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"input":"q"}`},
+		{GenerateTextAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inferenceRequest":{"runtimeType":"COHERE"}}`},
+		{GenerateTextAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inferenceRequest":{"runtimeType":"OPENAI","prompt":"Hi"}}`},
+		{ApplyGuardrailsAction, `{"compartmentId":"c","input":{"type":"TEXT","content":"Hi"},"guardrailConfigs":{}}`},
+		{ApplyGuardrailsAction, `{"compartmentId":"c","input":{"type":"TEXT","content":"Hi"},"guardrailConfigs":{"contentModerationConfig":{"categories":["HATE"]}}}`},
+		{ChatAction, `not json`},
+	}
+
+	for _, tt := range tests {
+		resp, body := postAction(t, g, tt.action, tt.request)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"InvalidParameter"`) {
+			t.Errorf("expected InvalidParameter for %s, got %d: %s", tt.request, resp.StatusCode, body)
+		}
+	}
+
+	resp, _ := postAction(t, g, "summarizeText", `{}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for an unknown action, got %d", resp.StatusCode)
+	}
+}
+
+func TestGenAI_ScriptedRepliesAndThrottling(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+	request := `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"chatRequest":{"apiFormat":"COHERE","message":"Hi"}}`
+
+	g.Throttle(1)
+	g.Enqueue(ChatAction,
+		Reply{Text: "Scripted", FinishReason: "ERROR_TOXIC"},
+		ErrorReply(http.StatusInternalServerError, "InternalServerError", "boom"),
+	)
+
+	expected := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusTooManyRequests, `"TooManyRequests"`},
+		{http.StatusOK, `"finishReason":"ERROR_TOXIC","text":"Scripted"`},
+		{http.StatusInternalServerError, `"message":"boom"`},
+		{http.StatusOK, `"text":"Echo: Hi"`},
+	}
+	for i, e := range expected {
+		resp, body := postAction(t, g, ChatAction, request)
+		if resp.StatusCode != e.status || !strings.Contains(body, e.body) {
+			t.Errorf("request %d: expected %d with %s, got %d: %s", i, e.status, e.body, resp.StatusCode, body)
+		}
+		if resp.Header.Get("opc-request-id") == "" {
+			t.Errorf("request %d: expected an opc-request-id header", i)
+		}
+	}
+
+	if n := len(g.Requests(ChatAction)); n != len(expected) {
+		t.Errorf("expected %d recorded requests, got %d", len(expected), n)
+	}
+}
+
+func TestGenAI_GenerateText(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			name:     "cohere",
+			request:  `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inferenceRequest":{"runtimeType":"COHERE","prompt":"Hi there","numGenerations":2}}`,
+			expected: `{"inferenceResponse":{"generatedTexts":[{"finishReason":"COMPLETE","id":"generation-0","text":"Echo: Hi there"},{"finishReason":"COMPLETE","id":"generation-1","text":"Echo: Hi there"}],"runtimeType":"COHERE"},"modelId":"m","modelVersion":"1.0"}`,
+		},
+		{
+			name:     "llama with echo and max tokens",
+			request:  `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inferenceRequest":{"runtimeType":"LLAMA","prompt":"Hi there","maxTokens":2,"isEcho":true}}`,
+			expected: `{"inferenceResponse":{"choices":[{"finishReason":"length","index":0,"text":"Hi thereEcho: Hi "}],"runtimeType":"LLAMA"},"modelId":"m","modelVersion":"1.0"}`,
+		},
+		{
+			name:    "cohere stream",
+			request: `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inferenceRequest":{"runtimeType":"COHERE","prompt":"Hi","isStream":true}}`,
+			expected: "data: {\"index\":0,\"text\":\"Echo: \"}\n\n" +
+				"data: {\"index\":0,\"text\":\"Hi\"}\n\n" +
+				"data: {\"finishReason\":\"COMPLETE\",\"index\":0,\"text\":\"Echo: Hi\"}\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := postAction(t, g, GenerateTextAction, tt.request)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+			}
+			if strings.TrimSpace(body) != strings.TrimSpace(tt.expected) {
+				t.Errorf("expected body\n%s\ngot\n%s", tt.expected, body)
+			}
+		})
+	}
+}
+
+func TestGenAI_EmbedText(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+
+	_, body := postAction(t, g, EmbedTextAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"inputs":["a b","a b","c"]}`)
+	var resp struct {
+		Embeddings [][]float64 `json:"embeddings"`
+		Usage      Usage       `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(resp.Embeddings) != 3 || len(resp.Embeddings[0]) != EmbeddingDimensions {
+		t.Fatalf("expected 3 embeddings of %d dimensions, got %v", EmbeddingDimensions, resp.Embeddings)
+	}
+	var norm float64
+	for i, v := range resp.Embeddings[0] {
+		norm += v * v
+		if v != resp.Embeddings[1][i] {
+			t.Errorf("expected identical inputs to have identical embeddings")
+		}
+	}
+	if math.Abs(norm-1) > 1e-9 {
+		t.Errorf("expected unit vectors, got norm %f", norm)
+	}
+	if resp.Usage.PromptTokens != 5 {
+		t.Errorf("expected 5 prompt tokens, got %d", resp.Usage.PromptTokens)
+	}
+}
+
+func TestGenAI_RerankText(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+
+	_, body := postAction(t, g, RerankTextAction, `{"compartmentId":"c","servingMode":{"servingType":"ON_DEMAND","modelId":"m"},"input":"red apple","documents":["banana","a red apple","red car"],"topN":2,"isEcho":true}`)
+	expected := `{"documentRanks":[{"index":1,"relevanceScore":1,"document":{"text":"a red apple"}},{"index":2,"relevanceScore":0.5,"document":{"text":"red car"}}],"id":"rerank-fake","modelId":"m","modelVersion":"1.0"}`
+	if strings.TrimSpace(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+}
+
+func TestGenAI_ApplyGuardrails(t *testing.T) {
+	g := StartGenAI(t, acceptAll)
+
+	_, body := postAction(t, g, ApplyGuardrailsAction, `{"compartmentId":"c","input":{"type":"TEXT","content":"Unsafe: mail a@b.io"},"guardrailConfigs":{"contentModerationConfig":{"categories":["OVERALL","BLOCKLIST"]},"personallyIdentifiableInformationConfig":{"types":["EMAIL"]},"promptInjectionConfig":{}}}`)
+	expected := `{"results":{"contentModeration":{"categories":[{"name":"OVERALL","score":1},{"name":"BLOCKLIST","score":0}]},"personallyIdentifiableInformation":[{"label":"EMAIL","length":6,"offset":13,"score":1,"text":"a@b.io"}],"promptInjection":{"score":0}}}`
+	if strings.TrimSpace(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+
+	g.Enqueue(ApplyGuardrailsAction, Reply{Moderation: map[string]float64{"PROMPT_INJECTION": 0.75}})
+	_, body = postAction(t, g, ApplyGuardrailsAction, `{"compartmentId":"c","input":{"type":"TEXT","content":"Hi"},"guardrailConfigs":{"promptInjectionConfig":{}}}`)
+	if expected := `{"results":{"promptInjection":{"score":0.75}}}`; strings.TrimSpace(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+}
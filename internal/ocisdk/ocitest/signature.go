@@ -0,0 +1,120 @@
+package ocitest
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Signature is a parsed OCI request signature, as found in the Authorization header.
+type Signature struct {
+	Version   string
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+var signatureParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ParseSignature parses the Authorization header of a signed request.
+func ParseSignature(authorization string) (Signature, error) {
+	if !strings.HasPrefix(authorization, "Signature ") {
+		return Signature{}, errors.New("authorization is not a signature")
+	}
+
+	params := map[string]string{}
+	for _, match := range signatureParam.FindAllStringSubmatch(authorization, -1) {
+		params[match[1]] = match[2]
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return Signature{}, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	s := Signature{
+		Version:   params["version"],
+		KeyID:     params["keyId"],
+		Algorithm: params["algorithm"],
+		Headers:   strings.Fields(params["headers"]),
+		Signature: signature,
+	}
+	switch {
+	case s.Version != "1":
+		return s, fmt.Errorf("unsupported signature version %q", s.Version)
+	case s.Algorithm != "rsa-sha256":
+		return s, fmt.Errorf("unsupported signature algorithm %q", s.Algorithm)
+	case s.KeyID == "":
+		return s, errors.New("missing keyId")
+	case len(s.Signature) == 0:
+		return s, errors.New("missing signature")
+	}
+	return s, nil
+}
+
+// SigningString reconstructs the string the client signed from a received request.
+func (s Signature) SigningString(req *http.Request) string {
+	lines := make([]string, len(s.Headers))
+	for i, header := range s.Headers {
+		var value string
+		switch header {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+		default:
+			value = req.Header.Get(header)
+		}
+		lines[i] = header + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Verify checks the signature of a received request with key. When the request has a body,
+// body must be its content: the body hash and length must then be covered by the signature.
+func (s Signature) Verify(req *http.Request, body []byte, key *rsa.PublicKey) error {
+	for _, required := range []string{"date", "(request-target)"} {
+		if !s.covers(required) {
+			return fmt.Errorf("signature does not cover %s", required)
+		}
+	}
+
+	if req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		for _, required := range []string{"content-length", "content-type", "x-content-sha256"} {
+			if !s.covers(required) {
+				return fmt.Errorf("signature does not cover %s", required)
+			}
+		}
+
+		sum := sha256.Sum256(body)
+		if req.Header.Get("X-Content-Sha256") != base64.StdEncoding.EncodeToString(sum[:]) {
+			return errors.New("x-content-sha256 does not match the body")
+		}
+		if req.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+			return errors.New("content-length does not match the body")
+		}
+	}
+
+	hashed := sha256.Sum256([]byte(s.SigningString(req)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], s.Signature); err != nil {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+func (s Signature) covers(header string) bool {
+	for _, h := range s.Headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
+}
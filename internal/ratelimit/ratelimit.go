@@ -0,0 +1,329 @@
+// Package ratelimit enforces per-minute request and token budgets for the OCI GenAI proxy plugin.
+//
+// Budgets are tracked with token buckets that refill continuously over a one minute window.
+// A request reserves its estimated token cost when it arrives and the reservation is settled
+// with the actual usage reported by the model once the response is known.
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// window is the period over which budgets are replenished.
+const window = time.Minute
+
+// idleTimeout is how long an unused bucket is kept before it is evicted.
+const idleTimeout = 10 * time.Minute
+
+// Key identifies the caller a budget applies to. The end user reported by the client is not
+// part of it: a client could otherwise get a fresh budget by sending a new user each time.
+type Key struct {
+	// ClientKey identifies the API key used by the client
+	ClientKey string
+
+	// Model is the requested model ID
+	Model string
+}
+
+func (k Key) String() string {
+	return k.ClientKey + "|" + k.Model
+}
+
+// Status describes the state of the budgets for a key.
+// It is used to populate the x-ratelimit-* response headers.
+type Status struct {
+	LimitRequests     int
+	RemainingRequests int
+	ResetRequests     time.Duration
+	LimitTokens       int
+	RemainingTokens   int
+	ResetTokens       time.Duration
+
+	// Exceeded is "requests" or "tokens" when the corresponding budget rejected the request.
+	Exceeded string
+
+	// RetryAfter is how long the client should wait before retrying a rejected request.
+	RetryAfter time.Duration
+}
+
+// SetHeaders writes the OpenAI compatible x-ratelimit-* headers for the status.
+func (s Status) SetHeaders(h http.Header) {
+	if s.LimitRequests > 0 {
+		h.Set("x-ratelimit-limit-requests", strconv.Itoa(s.LimitRequests))
+		h.Set("x-ratelimit-remaining-requests", strconv.Itoa(s.RemainingRequests))
+		h.Set("x-ratelimit-reset-requests", formatReset(s.ResetRequests))
+	}
+
+	if s.LimitTokens > 0 {
+		h.Set("x-ratelimit-limit-tokens", strconv.Itoa(s.LimitTokens))
+		h.Set("x-ratelimit-remaining-tokens", strconv.Itoa(s.RemainingTokens))
+		h.Set("x-ratelimit-reset-tokens", formatReset(s.ResetTokens))
+	}
+
+	if s.Exceeded != "" {
+		h.Set("Retry-After", strconv.Itoa(int((s.RetryAfter+time.Second-1)/time.Second)))
+	}
+}
+
+// Message returns an OpenAI style description of why the request was rejected.
+func (s Status) Message(model string) string {
+	if s.Exceeded == "requests" {
+		return fmt.Sprintf("Rate limit reached for %s on requests per min (RPM): Limit %d, Remaining %d. Please try again in %s.",
+			model, s.LimitRequests, s.RemainingRequests, formatReset(s.RetryAfter))
+	}
+	return fmt.Sprintf("Rate limit reached for %s on tokens per min (TPM): Limit %d, Remaining %d. Please try again in %s.",
+		model, s.LimitTokens, s.RemainingTokens, formatReset(s.RetryAfter))
+}
+
+// formatReset renders a duration the way OpenAI does in its reset headers (e.g. "1s", "6m0s", "20ms").
+func formatReset(d time.Duration) string {
+	if d <= 0 {
+		return "0s"
+	}
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// bucket is a token bucket refilled continuously at capacity per window.
+// The level may drop below zero when actual usage exceeds the reservation.
+type bucket struct {
+	capacity float64
+	level    float64
+	updated  time.Time
+}
+
+func newBucket(capacity int, now time.Time) *bucket {
+	return &bucket{capacity: float64(capacity), level: float64(capacity), updated: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.level += b.capacity * float64(elapsed) / float64(window)
+	if b.level > b.capacity {
+		b.level = b.capacity
+	}
+	b.updated = now
+}
+
+// wait returns how long until the bucket holds n units.
+func (b *bucket) wait(n float64) time.Duration {
+	if b.level >= n {
+		return 0
+	}
+	return time.Duration((n - b.level) / b.capacity * float64(window))
+}
+
+func (b *bucket) remaining() int {
+	if b.level < 0 {
+		return 0
+	}
+	return int(b.level)
+}
+
+// entry holds the buckets of a single key.
+type entry struct {
+	id       string
+	requests *bucket
+	tokens   *bucket
+	lastUsed time.Time
+}
+
+// Limiter enforces request and token budgets per key. The keys are held in least recently used
+// order, so that idle keys and, past the configured maximum, the least recently used ones are
+// evicted first.
+type Limiter struct {
+	cfg     config.RateLimit
+	mu      sync.Mutex
+	entries map[string]*list.Element // Values are *entry
+	lru     *list.List               // Most recently used first
+	now     func() time.Time
+}
+
+// New creates a limiter for the given configuration.
+func New(cfg config.RateLimit) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// limitsFor returns the request and token budgets for a model.
+func (l *Limiter) limitsFor(model string) (int, int) {
+	for _, m := range l.cfg.Models {
+		if m.Model == model {
+			return m.RequestsPerMinute, m.TokensPerMinute
+		}
+	}
+	return l.cfg.RequestsPerMinute, l.cfg.TokensPerMinute
+}
+
+// Reserve attempts to admit a request for key that is estimated to consume tokens.
+// If the request is admitted, the returned reservation must be settled once the
+// response is known. If it is rejected, the reservation is nil and the status
+// describes which budget was exceeded.
+func (l *Limiter) Reserve(key Key, tokens int) (*Reservation, Status) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	e := l.entryFor(key, now)
+
+	var status Status
+	if e.requests != nil {
+		e.requests.refill(now)
+		if wait := e.requests.wait(1); wait > 0 {
+			status.Exceeded = "requests"
+			status.RetryAfter = wait
+		}
+	}
+
+	if e.tokens != nil && status.Exceeded == "" {
+		e.tokens.refill(now)
+		// A request larger than the whole budget can never succeed, so only require a full bucket.
+		need := float64(tokens)
+		if need > e.tokens.capacity {
+			need = e.tokens.capacity
+		}
+		if wait := e.tokens.wait(need); wait > 0 {
+			status.Exceeded = "tokens"
+			status.RetryAfter = wait
+		}
+	}
+
+	if status.Exceeded == "" {
+		if e.requests != nil {
+			e.requests.level--
+		}
+		if e.tokens != nil {
+			e.tokens.level -= float64(tokens)
+		}
+	}
+
+	l.fillStatus(&status, e)
+
+	if status.Exceeded != "" {
+		return nil, status
+	}
+
+	return &Reservation{limiter: l, entry: e, reserved: tokens}, status
+}
+
+// entryFor returns the entry of key, created when missing, and marks it used at now.
+func (l *Limiter) entryFor(key Key, now time.Time) *entry {
+	id := key.String()
+	if element, ok := l.entries[id]; ok {
+		l.lru.MoveToFront(element)
+		e := element.Value.(*entry)
+		e.lastUsed = now
+		return e
+	}
+
+	if l.cfg.MaxKeys > 0 && len(l.entries) >= l.cfg.MaxKeys {
+		l.evict(l.lru.Back())
+	}
+
+	rpm, tpm := l.limitsFor(key.Model)
+	e := &entry{id: id, lastUsed: now}
+	if rpm > 0 {
+		e.requests = newBucket(rpm, now)
+	}
+	if tpm > 0 {
+		e.tokens = newBucket(tpm, now)
+	}
+	l.entries[id] = l.lru.PushFront(e)
+	return e
+}
+
+func (l *Limiter) evict(element *list.Element) {
+	l.lru.Remove(element)
+	delete(l.entries, element.Value.(*entry).id)
+}
+
+func (l *Limiter) fillStatus(status *Status, e *entry) {
+	if e.requests != nil {
+		status.LimitRequests = int(e.requests.capacity)
+		status.RemainingRequests = e.requests.remaining()
+		status.ResetRequests = e.requests.wait(e.requests.capacity)
+	}
+	if e.tokens != nil {
+		status.LimitTokens = int(e.tokens.capacity)
+		status.RemainingTokens = e.tokens.remaining()
+		status.ResetTokens = e.tokens.wait(e.tokens.capacity)
+	}
+}
+
+// sweep evicts buckets that have been idle long enough to be full again.
+func (l *Limiter) sweep(now time.Time) {
+	for element := l.lru.Back(); element != nil; element = l.lru.Back() {
+		if now.Sub(element.Value.(*entry).lastUsed) <= idleTimeout {
+			return
+		}
+		l.evict(element)
+	}
+}
+
+// Reservation is an admitted request whose token cost has not been settled yet.
+type Reservation struct {
+	limiter  *Limiter
+	entry    *entry
+	reserved int
+	done     bool
+}
+
+// Settle replaces the estimated token cost with the actual usage reported by the model.
+func (r *Reservation) Settle(actual int) {
+	r.adjust(actual)
+}
+
+// Release returns the reserved tokens to the budget. It is used when the request
+// failed before the model consumed any tokens. The request itself is still counted.
+func (r *Reservation) Release() {
+	r.adjust(0)
+}
+
+func (r *Reservation) adjust(actual int) {
+	if r == nil {
+		return
+	}
+
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+
+	if r.entry.tokens != nil {
+		r.entry.tokens.refill(r.limiter.now())
+		r.entry.tokens.level -= float64(actual - r.reserved)
+		if r.entry.tokens.level > r.entry.tokens.capacity {
+			r.entry.tokens.level = r.entry.tokens.capacity
+		}
+	}
+}
+
+// EstimateTokens estimates the token cost of an OCI chat request once transformed, so after the
+// parameter policies clamped its max_tokens: the prompt size, estimated like the context window
+// checks do, plus the number of tokens the model may generate.
+func EstimateTokens(model string, req types.ChatRequest) int {
+	return models.PromptTokens(model, req) + req.MaxTokens
+}
@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// fakeClock returns a controllable time source for the limiter.
+func fakeClock(l *Limiter) *time.Time {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return &now
+}
+
+func TestReserve_RequestBudget(t *testing.T) {
+	limiter := New(config.RateLimit{RequestsPerMinute: 2})
+	fakeClock(limiter)
+	key := Key{ClientKey: "key", Model: "cohere.command-r-plus"}
+
+	for i := 0; i < 2; i++ {
+		if res, status := limiter.Reserve(key, 10); res == nil {
+			t.Fatalf("expected request %d to be admitted, got %+v", i, status)
+		}
+	}
+
+	res, status := limiter.Reserve(key, 10)
+	if res != nil {
+		t.Fatal("expected third request to be rejected")
+	}
+	if status.Exceeded != "requests" {
+		t.Errorf("expected requests budget to be exceeded, got %q", status.Exceeded)
+	}
+	if status.RetryAfter != 30*time.Second {
+		t.Errorf("expected retry after 30s, got %s", status.RetryAfter)
+	}
+}
+
+func TestReserve_TokenBudgetRefills(t *testing.T) {
+	limiter := New(config.RateLimit{TokensPerMinute: 1000})
+	now := fakeClock(limiter)
+	key := Key{ClientKey: "key", Model: "model"}
+
+	if res, _ := limiter.Reserve(key, 800); res == nil {
+		t.Fatal("expected first request to be admitted")
+	}
+
+	res, status := limiter.Reserve(key, 400)
+	if res != nil {
+		t.Fatal("expected second request to be rejected")
+	}
+	if status.Exceeded != "tokens" {
+		t.Errorf("expected tokens budget to be exceeded, got %q", status.Exceeded)
+	}
+	if status.RemainingTokens != 200 {
+		t.Errorf("expected 200 remaining tokens, got %d", status.RemainingTokens)
+	}
+
+	*now = now.Add(12 * time.Second)
+	if res, status := limiter.Reserve(key, 400); res == nil {
+		t.Fatalf("expected request to be admitted after refill, got %+v", status)
+	}
+}
+
+func TestReservation_Settle(t *testing.T) {
+	limiter := New(config.RateLimit{TokensPerMinute: 1000})
+	fakeClock(limiter)
+	key := Key{ClientKey: "key", Model: "model"}
+
+	res, _ := limiter.Reserve(key, 900)
+	res.Settle(100)
+
+	_, status := limiter.Reserve(key, 0)
+	if status.RemainingTokens != 900 {
+		t.Errorf("expected 900 remaining tokens after settling, got %d", status.RemainingTokens)
+	}
+
+	// Settling twice must not refund again
+	res.Settle(0)
+	_, status = limiter.Reserve(key, 0)
+	if status.RemainingTokens != 900 {
+		t.Errorf("expected settle to be applied once, got %d remaining", status.RemainingTokens)
+	}
+}
+
+func TestReservation_Release(t *testing.T) {
+	limiter := New(config.RateLimit{RequestsPerMinute: 10, TokensPerMinute: 1000})
+	fakeClock(limiter)
+	key := Key{ClientKey: "key", Model: "model"}
+
+	res, _ := limiter.Reserve(key, 500)
+	res.Release()
+
+	_, status := limiter.Reserve(key, 0)
+	if status.RemainingTokens != 1000 {
+		t.Errorf("expected tokens to be released, got %d remaining", status.RemainingTokens)
+	}
+	if status.RemainingRequests != 8 {
+		t.Errorf("expected released request to still count, got %d remaining", status.RemainingRequests)
+	}
+}
+
+func TestReserve_KeysAreIndependent(t *testing.T) {
+	limiter := New(config.RateLimit{RequestsPerMinute: 1})
+	fakeClock(limiter)
+
+	keys := []Key{
+		{ClientKey: "a", Model: "model"},
+		{ClientKey: "b", Model: "model"},
+		{ClientKey: "a", Model: "other"},
+	}
+
+	for _, key := range keys {
+		if res, _ := limiter.Reserve(key, 0); res == nil {
+			t.Errorf("expected first request for %v to be admitted", key)
+		}
+	}
+}
+
+func TestReserve_MaxKeys(t *testing.T) {
+	limiter := New(config.RateLimit{RequestsPerMinute: 1, MaxKeys: 2})
+	now := fakeClock(limiter)
+
+	first := Key{ClientKey: "key", Model: "model"}
+	limiter.Reserve(first, 0)
+	for _, model := range []string{"m1", "m2", "m3"} {
+		*now = now.Add(time.Second)
+		limiter.Reserve(Key{ClientKey: "key", Model: model}, 0)
+	}
+	if len(limiter.entries) != 2 || limiter.lru.Len() != 2 {
+		t.Fatalf("expected 2 budgets to be tracked, got %d", len(limiter.entries))
+	}
+
+	// The least recently used budgets were evicted, the most recent one is kept
+	if res, _ := limiter.Reserve(Key{ClientKey: "key", Model: "m3"}, 0); res != nil {
+		t.Error("expected the most recent budget to be kept")
+	}
+	if res, _ := limiter.Reserve(first, 0); res == nil {
+		t.Error("expected the evicted budget to start full again")
+	}
+}
+
+func TestReserve_EvictsIdleKeys(t *testing.T) {
+	limiter := New(config.RateLimit{RequestsPerMinute: 1})
+	now := fakeClock(limiter)
+
+	limiter.Reserve(Key{ClientKey: "a"}, 0)
+	*now = now.Add(idleTimeout / 2)
+	limiter.Reserve(Key{ClientKey: "b"}, 0)
+	*now = now.Add(idleTimeout/2 + time.Second)
+	limiter.Reserve(Key{ClientKey: "c"}, 0)
+
+	if _, ok := limiter.entries[Key{ClientKey: "a"}.String()]; ok || len(limiter.entries) != 2 {
+		t.Errorf("expected only the idle budget to be evicted, got %d budgets", len(limiter.entries))
+	}
+}
+
+func TestReserve_ModelOverrides(t *testing.T) {
+	limiter := New(config.RateLimit{
+		RequestsPerMinute: 100,
+		Models: []config.ModelRateLimit{
+			{Model: "expensive", RequestsPerMinute: 1},
+		},
+	})
+	fakeClock(limiter)
+
+	key := Key{ClientKey: "key", Model: "expensive"}
+	limiter.Reserve(key, 0)
+	if res, _ := limiter.Reserve(key, 0); res != nil {
+		t.Error("expected model override to limit the second request")
+	}
+
+	_, status := limiter.Reserve(Key{ClientKey: "key", Model: "cheap"}, 0)
+	if status.LimitRequests != 100 {
+		t.Errorf("expected default limit 100 for other models, got %d", status.LimitRequests)
+	}
+}
+
+func TestStatus_SetHeaders(t *testing.T) {
+	status := Status{
+		LimitRequests:     60,
+		RemainingRequests: 59,
+		ResetRequests:     time.Second,
+		LimitTokens:       150000,
+		RemainingTokens:   149984,
+		ResetTokens:       6 * time.Millisecond,
+		Exceeded:          "tokens",
+		RetryAfter:        1500 * time.Millisecond,
+	}
+
+	h := http.Header{}
+	status.SetHeaders(h)
+
+	expected := map[string]string{
+		"x-ratelimit-limit-requests":     "60",
+		"x-ratelimit-remaining-requests": "59",
+		"x-ratelimit-reset-requests":     "1s",
+		"x-ratelimit-limit-tokens":       "150000",
+		"x-ratelimit-remaining-tokens":   "149984",
+		"x-ratelimit-reset-tokens":       "6ms",
+		"Retry-After":                    "2",
+	}
+	for name, value := range expected {
+		if got := h.Get(name); got != value {
+			t.Errorf("expected header %s=%s, got %s", name, value, got)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	req := types.ChatRequest{
+		Messages: []types.GenericMessage{
+			{Role: "USER", Content: []types.GenericContent{{Type: "TEXT", Text: "12345678"}}},
+		},
+		MaxTokens: 100,
+	}
+
+	if got := EstimateTokens("model", req); got != 106 {
+		t.Errorf("expected 106 tokens, got %d", got)
+	}
+}
@@ -0,0 +1,223 @@
+// Package store keeps the conversations of stored OpenAI Responses API responses, so that clients
+// can continue a conversation through previous_response_id without resending it.
+//
+// Conversations are opaque values keyed on the response ID. Storage is pluggable through the
+// Store interface; an in-memory store with entry and TTL limits and a file store writing one file
+// per response are provided.
+package store
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// ErrNotFound is returned for responses that were never stored, or have expired.
+var ErrNotFound = errors.New("response not found")
+
+// Store stores the conversations of responses.
+type Store interface {
+	// Get returns the value stored for id, or ErrNotFound when it is missing or expired.
+	Get(id string) ([]byte, error)
+
+	// Put stores value for id.
+	Put(id string, value []byte) error
+}
+
+// New creates the store described by the configuration.
+func New(cfg config.Responses) (Store, error) {
+	var ttl time.Duration
+	if cfg.TTL != "" {
+		d, err := time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl %q: %w", cfg.TTL, err)
+		}
+		ttl = d
+	}
+
+	switch cfg.Store {
+	case "", "memory":
+		return NewMemory(cfg.MaxEntries, ttl), nil
+	case "file":
+		return NewFile(cfg.Path, ttl)
+	default:
+		return nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+}
+
+// Memory is an in-memory Store evicting the oldest responses once the number of entries exceeds
+// its limit.
+type Memory struct {
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu    sync.Mutex
+	order *list.List // most recently stored at the front
+	items map[string]*list.Element
+}
+
+type memoryEntry struct {
+	id      string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates a store holding at most maxEntries responses for at most ttl each.
+// A limit of 0 disables it.
+func NewMemory(maxEntries int, ttl time.Duration) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value stored for id.
+func (m *Memory) Get(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		m.remove(elem)
+		return nil, ErrNotFound
+	}
+	return entry.value, nil
+}
+
+// Put stores value for id, evicting the oldest responses beyond the entry limit.
+func (m *Memory) Put(id string, value []byte) error {
+	var expires time.Time
+	if m.ttl > 0 {
+		expires = m.now().Add(m.ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.items[id]; ok {
+		m.remove(elem)
+	}
+	m.items[id] = m.order.PushFront(&memoryEntry{id: id, value: value, expires: expires})
+
+	for m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Len returns the number of stored responses, including expired ones not yet evicted.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *Memory) remove(elem *list.Element) {
+	entry := elem.Value.(*memoryEntry)
+	m.order.Remove(elem)
+	delete(m.items, entry.id)
+}
+
+// File is a Store writing every response to its own file in a directory, so that stored
+// conversations survive restarts and can be shared by replicas mounting the same volume.
+// Expired files are removed when they are read.
+type File struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewFile creates a store writing responses to dir, which is created if needed, and keeping
+// them for at most ttl. A ttl of 0 keeps them forever.
+func NewFile(dir string, ttl time.Duration) (*File, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create response store directory: %w", err)
+	}
+	return &File{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+// Get returns the value stored for id.
+func (f *File) Get(id string) ([]byte, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+
+	path := f.path(id)
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.ttl > 0 && !f.now().Before(info.ModTime().Add(f.ttl)) {
+		_ = os.Remove(path)
+		return nil, ErrNotFound
+	}
+
+	value, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// Put stores value for id. The file is written under a temporary name first, so that readers
+// never see a partial response.
+func (f *File) Put(id string, value []byte) error {
+	if !validID(id) {
+		return fmt.Errorf("invalid response id %q", id)
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(value); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(id))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (f *File) path(id string) string {
+	return filepath.Join(f.dir, id+".json")
+}
+
+// validID reports whether id is safe to use as a file name. Response IDs are client input, so
+// anything but letters, digits, "-" and "_" is rejected.
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+func TestMemory_GetPut(t *testing.T) {
+	m := NewMemory(2, time.Hour)
+
+	if _, err := m.Get("resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing response, got %v", err)
+	}
+
+	for _, id := range []string{"resp_1", "resp_2", "resp_3"} {
+		if err := m.Put(id, []byte(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", m.Len())
+	}
+	if _, err := m.Get("resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected the oldest response to be evicted")
+	}
+	if value, err := m.Get("resp_3"); err != nil || string(value) != "resp_3" {
+		t.Errorf("expected resp_3, got %q, %v", value, err)
+	}
+}
+
+func TestMemory_Expiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewMemory(0, time.Minute)
+	m.now = func() time.Time { return now }
+
+	if err := m.Put("resp_1", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(59 * time.Second)
+	if _, err := m.Get("resp_1"); err != nil {
+		t.Errorf("expected the response before its ttl, got %v", err)
+	}
+
+	now = now.Add(time.Second)
+	if _, err := m.Get("resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the response to expire, got %v", err)
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected the expired response to be removed, got %d entries", m.Len())
+	}
+}
+
+func TestFile_GetPut(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "responses")
+	f, err := NewFile(dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Put("resp_abc", []byte(`{"items":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := f.Get("resp_abc"); err != nil || string(value) != `{"items":[]}` {
+		t.Errorf("expected the stored value, got %q, %v", value, err)
+	}
+	if _, err := f.Get("resp_missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing response, got %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "resp_abc.json" {
+		t.Errorf("expected a single response file, got %v", entries)
+	}
+
+	f.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := f.Get("resp_abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the response to expire, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "resp_abc.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the expired file to be removed")
+	}
+}
+
+func TestFile_InvalidID(t *testing.T) {
+	f, err := NewFile(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", "../secret", "resp/1", "resp.1"} {
+		if err := f.Put(id, []byte("value")); err == nil {
+			t.Errorf("%q: expected an error", id)
+		}
+		if _, err := f.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%q: expected ErrNotFound, got %v", id, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if s, err := New(config.New().Responses); err != nil {
+		t.Errorf("expected the default store, got %v", err)
+	} else if _, ok := s.(*Memory); !ok {
+		t.Errorf("expected a memory store, got %T", s)
+	}
+
+	s, err := New(config.Responses{Store: "file", Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*File); !ok {
+		t.Errorf("expected a file store, got %T", s)
+	}
+
+	if _, err := New(config.Responses{Store: "redis"}); err == nil {
+		t.Error("expected error for unknown store")
+	}
+}
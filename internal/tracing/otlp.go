@@ -0,0 +1,278 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/flusher"
+	"github.com/zalbiraw/ocigenai/internal/logging"
+)
+
+const (
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+	maxQueuedSpans       = 8192
+	instrumentationScope = "github.com/zalbiraw/ocigenai"
+)
+
+// OTLPExporter batches spans and posts them to an OTLP/HTTP collector using the JSON encoding.
+type OTLPExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	batchSize   int
+	client      *http.Client
+
+	mu      sync.Mutex
+	queue   []*Span
+	flusher *flusher.Flusher
+}
+
+// NewOTLPExporter creates an exporter posting to endpoint (e.g. http://collector:4318/v1/traces)
+// and starts its background flusher. Failed exports are logged to logger.
+func NewOTLPExporter(endpoint, serviceName string, headers map[string]string, batchSize int, interval time.Duration, logger *logging.Logger) *OTLPExporter {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	e := &OTLPExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		batchSize:   batchSize,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+	e.flusher = flusher.Start(interval, e.flush, func(err error) {
+		logger.Error("failed to export spans", "endpoint", endpoint, "error", err)
+	})
+	return e
+}
+
+// Export queues a finished span. Spans are dropped when the queue is full.
+func (e *OTLPExporter) Export(span *Span) {
+	e.mu.Lock()
+	if len(e.queue) >= maxQueuedSpans {
+		e.mu.Unlock()
+		return
+	}
+	e.queue = append(e.queue, span)
+	full := len(e.queue) >= e.batchSize
+	e.mu.Unlock()
+
+	if full {
+		e.flusher.Wake()
+	}
+}
+
+// Close stops the flusher and exports the queued spans.
+func (e *OTLPExporter) Close() error {
+	return e.flusher.Close()
+}
+
+// flush exports the queued spans in batches. Failed batches are dropped, as
+// traces are best effort and retrying could pile up unbounded work.
+func (e *OTLPExporter) flush() error {
+	for {
+		e.mu.Lock()
+		n := len(e.queue)
+		if n == 0 {
+			e.mu.Unlock()
+			return nil
+		}
+		if n > e.batchSize {
+			n = e.batchSize
+		}
+		batch := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		e.mu.Unlock()
+
+		if err := e.send(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch []*Span) error {
+	body, err := json.Marshal(Encode(e.serviceName, batch))
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post spans: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// The types below mirror the OTLP/HTTP JSON encoding of ExportTraceServiceRequest.
+
+// ExportRequest is the body of an OTLP trace export.
+type ExportRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans groups spans by the resource that produced them.
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+// Resource describes the entity producing telemetry.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// ScopeSpans groups spans by instrumentation scope.
+type ScopeSpans struct {
+	Scope Scope      `json:"scope"`
+	Spans []SpanData `json:"spans"`
+}
+
+// Scope identifies the instrumentation library.
+type Scope struct {
+	Name string `json:"name"`
+}
+
+// SpanData is the OTLP JSON representation of a span.
+type SpanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            SpanStatus `json:"status"`
+}
+
+// SpanStatus is the OTLP span status.
+type SpanStatus struct {
+	Code    StatusCode `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+// KeyValue is an OTLP attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is an OTLP attribute value. Exactly one field is set.
+type AnyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue `json:"arrayValue,omitempty"`
+}
+
+// ArrayValue is an OTLP array attribute value.
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+// Encode converts spans into an OTLP export request.
+func Encode(serviceName string, spans []*Span) ExportRequest {
+	data := make([]SpanData, 0, len(spans))
+	for _, span := range spans {
+		data = append(data, span.data())
+	}
+
+	return ExportRequest{
+		ResourceSpans: []ResourceSpans{{
+			Resource: Resource{Attributes: []KeyValue{
+				{Key: "service.name", Value: anyValue(serviceName)},
+			}},
+			ScopeSpans: []ScopeSpans{{
+				Scope: Scope{Name: instrumentationScope},
+				Spans: data,
+			}},
+		}},
+	}
+}
+
+func (s *Span) data() SpanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := SpanData{
+		TraceID:           s.context.TraceIDString(),
+		SpanID:            s.context.SpanIDString(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            SpanStatus{Code: s.status, Message: s.message},
+	}
+	if s.parent != [8]byte{} {
+		d.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		d.Attributes = append(d.Attributes, KeyValue{Key: key, Value: anyValue(s.attributes[key])})
+	}
+
+	return d
+}
+
+func anyValue(v interface{}) AnyValue {
+	switch value := v.(type) {
+	case string:
+		return AnyValue{StringValue: &value}
+	case bool:
+		return AnyValue{BoolValue: &value}
+	case int:
+		s := strconv.FormatInt(int64(value), 10)
+		return AnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		return AnyValue{IntValue: &s}
+	case float32:
+		f := float64(value)
+		return AnyValue{DoubleValue: &f}
+	case float64:
+		return AnyValue{DoubleValue: &value}
+	case []string:
+		values := make([]AnyValue, 0, len(value))
+		for _, item := range value {
+			values = append(values, anyValue(item))
+		}
+		return AnyValue{ArrayValue: &ArrayValue{Values: values}}
+	default:
+		s := fmt.Sprint(value)
+		return AnyValue{StringValue: &s}
+	}
+}
@@ -0,0 +1,253 @@
+// Package tracing implements W3C trace context propagation and spans exported over OTLP/HTTP JSON.
+//
+// It covers the small subset of OpenTelemetry the plugin needs and depends only on the
+// standard library, so it can be interpreted by Yaegi.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship of a span to its parent, using the OTLP enum values.
+type SpanKind int
+
+// Span kinds used by the plugin.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode is the OTLP span status code.
+type StatusCode int
+
+// Span status codes.
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+const flagSampled = 0x01
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid reports whether both the trace and span IDs are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// IsSampled reports whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// TraceIDString returns the trace ID as lowercase hex.
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDString returns the span ID as lowercase hex.
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// Traceparent renders the span context as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceIDString(), sc.SpanIDString(), sc.Flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+// It returns false if the value is missing or malformed.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+	// Version 00 has exactly four fields; future versions may append more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, false
+	}
+	return sc, true
+}
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(span *Span)
+	Close() error
+}
+
+// Tracer creates spans and hands finished ones to its exporter.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates a tracer exporting to the given exporter.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start creates a span. If parent is valid the span joins its trace and inherits its sampling
+// decision; otherwise a new sampled trace is started. A nil tracer returns a nil span,
+// on which every method is a no-op.
+func (t *Tracer) Start(parent SpanContext, name string, kind SpanKind) *Span {
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Flags = parent.Flags
+		span.parent = parent.SpanID
+	} else {
+		randomBytes(span.context.TraceID[:])
+		span.context.Flags = flagSampled
+	}
+	randomBytes(span.context.SpanID[:])
+
+	return span
+}
+
+// Close flushes and stops the exporter.
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
+
+func randomBytes(b []byte) {
+	// crypto/rand never fails on supported platforms; a zero ID would only make the span invalid.
+	_, _ = rand.Read(b)
+}
+
+// Span is a single timed operation within a trace.
+type Span struct {
+	tracer     *Tracer
+	context    SpanContext
+	parent     [8]byte
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	status     StatusCode
+	message    string
+	ended      bool
+}
+
+// Context returns the span's context, used to parent child spans and propagate the trace.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// Child starts a span parented to this one.
+func (s *Span) Child(name string, kind SpanKind) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.Start(s.context, name, kind)
+}
+
+// SetName renames the span, for names that depend on data known only after it started.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute records an attribute. Supported values are strings, booleans,
+// integers, floats and string slices.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = StatusError
+	s.message = err.Error()
+	s.mu.Unlock()
+}
+
+// SetStatus sets the span status explicitly.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = code
+	s.message = message
+	s.mu.Unlock()
+}
+
+// End finishes the span and exports it if it is sampled. Calling End more than once has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.IsSampled() {
+		s.tracer.exporter.Export(s)
+	}
+}
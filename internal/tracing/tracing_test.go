@@ -0,0 +1,185 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/logging"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, ok := ParseTraceparent(value)
+	if !ok {
+		t.Fatal("expected traceparent to be parsed")
+	}
+	if sc.TraceIDString() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected trace ID %s", sc.TraceIDString())
+	}
+	if sc.SpanIDString() != "00f067aa0ba902b7" {
+		t.Errorf("unexpected span ID %s", sc.SpanIDString())
+	}
+	if !sc.IsSampled() {
+		t.Error("expected sampled flag to be set")
+	}
+	if sc.Traceparent() != value {
+		t.Errorf("expected %s, got %s", value, sc.Traceparent())
+	}
+}
+
+func TestParseTraceparent_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+
+	for _, value := range tests {
+		if _, ok := ParseTraceparent(value); ok {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+// recordingExporter keeps exported spans in memory.
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *recordingExporter) Export(span *Span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+}
+
+func (e *recordingExporter) Close() error { return nil }
+
+func TestTracer_ChildSpansJoinTrace(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter)
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	root := tracer.Start(parent, "chat", SpanKindServer)
+	child := root.Child("sign", SpanKindInternal)
+	child.End()
+	root.End()
+	root.End()
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("expected 2 exported spans, got %d", len(exporter.spans))
+	}
+	if child.Context().TraceID != parent.TraceID || root.Context().TraceID != parent.TraceID {
+		t.Error("expected spans to join the inbound trace")
+	}
+
+	data := child.data()
+	if data.ParentSpanID != root.Context().SpanIDString() {
+		t.Errorf("expected parent span %s, got %s", root.Context().SpanIDString(), data.ParentSpanID)
+	}
+	if root.data().ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("expected root to be parented to the inbound span, got %s", root.data().ParentSpanID)
+	}
+}
+
+func TestTracer_UnsampledParent(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter)
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	tracer.Start(parent, "chat", SpanKindServer).End()
+
+	if len(exporter.spans) != 0 {
+		t.Errorf("expected unsampled span not to be exported, got %d", len(exporter.spans))
+	}
+}
+
+func TestNilSpan(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.Start(SpanContext{}, "chat", SpanKindServer)
+
+	// None of these may panic
+	span.SetAttribute("key", "value")
+	span.SetError(nil)
+	span.SetName("renamed")
+	span.Child("child", SpanKindInternal).End()
+	span.End()
+
+	if span.Context().IsValid() {
+		t.Error("expected nil span to have an invalid context")
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	received := make(chan ExportRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected configured header, got %q", req.Header.Get("Authorization"))
+		}
+		var body ExportRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode export: %v", err)
+		}
+		received <- body
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, "ocigenai", map[string]string{"Authorization": "Bearer secret"}, 10, time.Hour, logging.New(config.Logging{}, "test", io.Discard))
+	tracer := NewTracer(exporter)
+
+	span := tracer.Start(SpanContext{}, "chat cohere.command-r-plus", SpanKindServer)
+	span.SetAttribute("gen_ai.request.model", "cohere.command-r-plus")
+	span.SetAttribute("gen_ai.usage.input_tokens", 12)
+	span.SetAttribute("gen_ai.response.finish_reasons", []string{"stop"})
+	span.End()
+
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("failed to close tracer: %v", err)
+	}
+
+	var body ExportRequest
+	select {
+	case body = <-received:
+	default:
+		t.Fatal("expected spans to be exported on close")
+	}
+
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if *body.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "ocigenai" {
+		t.Error("expected service.name resource attribute")
+	}
+
+	got := spans[0]
+	if got.Name != "chat cohere.command-r-plus" || got.Kind != SpanKindServer {
+		t.Errorf("unexpected span %s kind %d", got.Name, got.Kind)
+	}
+	if got.TraceID != span.Context().TraceIDString() {
+		t.Errorf("expected trace ID %s, got %s", span.Context().TraceIDString(), got.TraceID)
+	}
+
+	attributes := make(map[string]AnyValue)
+	for _, kv := range got.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if v := attributes["gen_ai.usage.input_tokens"].IntValue; v == nil || *v != "12" {
+		t.Error("expected integer attribute to be encoded as a string")
+	}
+	if v := attributes["gen_ai.response.finish_reasons"].ArrayValue; v == nil || *v.Values[0].StringValue != "stop" {
+		t.Error("expected array attribute")
+	}
+}
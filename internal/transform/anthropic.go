@@ -0,0 +1,402 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// MessagesToOracleCloudRequest converts an Anthropic Messages request to Oracle Cloud GenAI format.
+// The request goes through the same conversation as OpenAI requests, so it is served by the same
+// API formats and configuration defaults. It returns an error for requests that are not valid.
+func (t *Transformer) MessagesToOracleCloudRequest(req types.MessagesRequest) (types.OracleCloudRequest, error) {
+	conv, err := fromMessagesRequest(req)
+	if err != nil {
+		return types.OracleCloudRequest{}, err
+	}
+	return t.toChatRequest(conv), nil
+}
+
+// fromMessagesRequest converts an Anthropic Messages request to a conversation. The system prompt
+// becomes a system message, tool_result blocks become tool messages preceding the rest of their
+// turn, and tool_use blocks become tool calls. Other block types are dropped.
+func fromMessagesRequest(req types.MessagesRequest) (conversation, error) {
+	if req.Model == "" {
+		return conversation{}, errors.New("model is required")
+	}
+	if len(req.Messages) == 0 {
+		return conversation{}, errors.New("messages must not be empty")
+	}
+
+	conv := conversation{
+		model:       req.Model,
+		maxTokens:   req.MaxTokens,
+		temperature: req.Temperature,
+		topP:        req.TopP,
+		topK:        req.TopK,
+		stop:        req.StopSequences,
+		stream:      req.Stream,
+		toolChoice:  fromAnthropicToolChoice(req.ToolChoice),
+	}
+
+	if len(req.System) > 0 {
+		system := chatMessage{role: "system"}
+		for _, block := range req.System {
+			if block.Type == "text" {
+				system.parts = append(system.parts, chatPart{text: block.Text})
+			}
+		}
+		conv.messages = append(conv.messages, system)
+	}
+
+	for i, msg := range req.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return conversation{}, fmt.Errorf("messages.%d: unsupported role %q", i, msg.Role)
+		}
+
+		turn := chatMessage{role: msg.Role}
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				turn.parts = append(turn.parts, chatPart{text: block.Text})
+			case "image":
+				image, err := imageURL(block.Source)
+				if err != nil {
+					return conversation{}, fmt.Errorf("messages.%d: %w", i, err)
+				}
+				turn.parts = append(turn.parts, chatPart{image: image})
+			case "tool_use":
+				turn.toolCalls = append(turn.toolCalls, chatToolCall{id: block.ID, name: block.Name, arguments: toolArguments(block.Input)})
+			case "tool_result":
+				result := chatMessage{role: "tool", toolCallID: block.ToolUseID}
+				for _, content := range block.Content {
+					if content.Type == "text" {
+						result.parts = append(result.parts, chatPart{text: content.Text})
+					}
+				}
+				conv.messages = append(conv.messages, result)
+			}
+		}
+		if len(turn.parts) > 0 || len(turn.toolCalls) > 0 {
+			conv.messages = append(conv.messages, turn)
+		}
+	}
+
+	for _, tool := range req.Tools {
+		conv.tools = append(conv.tools, chatTool{name: tool.Name, description: tool.Description, parameters: tool.InputSchema})
+	}
+	return conv, nil
+}
+
+// toolArguments returns the compact JSON encoding of a tool input, "{}" when there is none.
+func toolArguments(input json.RawMessage) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, input); err != nil || compact.Len() == 0 {
+		return "{}"
+	}
+	return compact.String()
+}
+
+// imageURL returns the URL of an image source; base64 encoded images become data URLs.
+func imageURL(source *types.ImageSource) (string, error) {
+	switch {
+	case source == nil:
+		return "", errors.New("image source is required")
+	case source.Type == "base64" && source.MediaType != "" && source.Data != "":
+		return "data:" + source.MediaType + ";base64," + source.Data, nil
+	case source.Type == "url" && source.URL != "":
+		return source.URL, nil
+	}
+	return "", fmt.Errorf("unsupported image source %q", source.Type)
+}
+
+// fromAnthropicToolChoice converts an Anthropic tool_choice: "auto", "any" (a tool must be
+// called), "none" or a named tool. Unknown values are dropped.
+func fromAnthropicToolChoice(choice *types.AnthropicToolChoice) *chatToolChoice {
+	if choice == nil {
+		return nil
+	}
+	switch choice.Type {
+	case "auto", "none":
+		return &chatToolChoice{mode: choice.Type}
+	case "any":
+		return &chatToolChoice{mode: "required"}
+	case "tool":
+		if choice.Name != "" {
+			return &chatToolChoice{mode: "function", name: choice.Name}
+		}
+	}
+	return nil
+}
+
+// MessagesChatRequest returns the chat completion request equivalent to an Anthropic Messages
+// request. It is used to rate limit, meter and count Messages requests like chat completions.
+func MessagesChatRequest(req types.MessagesRequest) types.ChatCompletionRequest {
+	chatReq := types.ChatCompletionRequest{
+		Model:     req.Model,
+		MaxTokens: req.MaxTokens,
+		Stream:    req.Stream,
+	}
+	if req.Metadata != nil {
+		chatReq.User = req.Metadata.UserID
+	}
+	if len(req.System) > 0 {
+		chatReq.Messages = append(chatReq.Messages, types.ChatCompletionMessage{Role: "system", Content: contentText(req.System)})
+	}
+	for _, msg := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, types.ChatCompletionMessage{Role: msg.Role, Content: contentText(msg.Content)})
+	}
+	return chatReq
+}
+
+// contentText returns the text of content blocks, including the text of tool results, one block per line.
+func contentText(content types.AnthropicContent) string {
+	var text []string
+	for _, block := range content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_result":
+			text = append(text, contentText(block.Content))
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+// messageID derives an Anthropic style message ID from a chat completion ID.
+func messageID(chatID string) string {
+	return "msg_" + strings.TrimPrefix(chatID, "chatcmpl-")
+}
+
+// StopReason converts an OCI finish reason to the Anthropic vocabulary. Messages ending with a
+// tool call stop for tool use whatever the reason reported.
+func StopReason(reason string, toolUse bool) string {
+	if toolUse {
+		return "tool_use"
+	}
+	if reason == "STOP_SEQUENCE" {
+		return "stop_sequence"
+	}
+	switch FinishReason(reason) {
+	case "length":
+		return "max_tokens"
+	case "tool_calls":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	}
+	return "end_turn"
+}
+
+// toAnthropicUsage converts OCI usage to the Anthropic format.
+func toAnthropicUsage(usage *types.Usage) types.AnthropicUsage {
+	if usage == nil {
+		return types.AnthropicUsage{}
+	}
+	return types.AnthropicUsage{InputTokens: usage.PromptTokens, OutputTokens: usage.CompletionTokens}
+}
+
+// ToMessagesResponse converts an Oracle Cloud GenAI chat response to an Anthropic Messages response.
+// The model requested by the client is reported when OCI does not echo the model ID. The grounding
+// information of Cohere models has no Anthropic equivalent and is not reported.
+func (t *Transformer) ToMessagesResponse(oracleResp types.OracleCloudResponse, model string) types.MessagesResponse {
+	result := toChatResult(oracleResp, model)
+
+	resp := types.MessagesResponse{
+		ID:      messageID(t.newID()),
+		Type:    "message",
+		Role:    "assistant",
+		Model:   result.model,
+		Content: []types.ContentBlock{},
+		Usage:   toAnthropicUsage(result.usage),
+	}
+	if len(result.choices) == 0 {
+		return resp
+	}
+
+	choice := result.choices[0]
+	if choice.text != "" {
+		resp.Content = append(resp.Content, types.ContentBlock{Type: "text", Text: choice.text})
+	}
+	for _, call := range choice.toolCalls {
+		resp.Content = append(resp.Content, toolUseBlock(call.id, call.name, call.arguments))
+	}
+	reason := StopReason(choice.finishReason, len(choice.toolCalls) > 0)
+	resp.StopReason = &reason
+	return resp
+}
+
+// toolUseBlock returns a tool_use block; arguments that are not a JSON document are replaced by
+// an empty input.
+func toolUseBlock(id, name, arguments string) types.ContentBlock {
+	block := types.ContentBlock{Type: "tool_use", ID: id, Name: name}
+	if arguments != "" && json.Valid([]byte(arguments)) {
+		block.Input = json.RawMessage(arguments)
+	}
+	return block
+}
+
+// anthropicErrorTypes maps HTTP status codes to Anthropic error types.
+var anthropicErrorTypes = map[int]string{
+	http.StatusBadRequest:            "invalid_request_error",
+	http.StatusUnauthorized:          "authentication_error",
+	http.StatusForbidden:             "permission_error",
+	http.StatusNotFound:              "not_found_error",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusTooManyRequests:       "rate_limit_error",
+	http.StatusServiceUnavailable:    "overloaded_error",
+}
+
+// ToAnthropicError returns the Anthropic error for a status code and message. Anthropic errors
+// are typed by status code only.
+func ToAnthropicError(status int, message string) types.AnthropicErrorResponse {
+	errType, ok := anthropicErrorTypes[status]
+	switch {
+	case ok:
+	case status >= http.StatusInternalServerError:
+		errType = "api_error"
+	default:
+		errType = "invalid_request_error"
+	}
+	return types.AnthropicErrorResponse{
+		Type:  "error",
+		Error: types.AnthropicError{Type: errType, Message: message},
+	}
+}
+
+// MessagesStream converts the events of a streamed OCI chat response into Anthropic Messages
+// events. Text and tool calls are streamed as content blocks. A new stream must be created for
+// every response.
+type MessagesStream struct {
+	*streamDecoder
+	id           string
+	started      bool
+	blocks       int    // Number of content blocks started
+	open         string // Type of the open content block, "" when none is open
+	toolUse      bool
+	finishReason string // Finish reason reported by OCI
+}
+
+// NewMessagesStream creates the stream translating a single streamed response.
+func (t *Transformer) NewMessagesStream(model string) *MessagesStream {
+	return &MessagesStream{
+		streamDecoder: &streamDecoder{model: model},
+		id:            messageID(t.newID()),
+	}
+}
+
+// start returns the message_start event opening the stream, the first time it is called.
+func (s *MessagesStream) start() []types.MessagesStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []types.MessagesStreamEvent{{
+		Type: "message_start",
+		Message: &types.MessagesResponse{
+			ID:      s.id,
+			Type:    "message",
+			Role:    "assistant",
+			Model:   s.model,
+			Content: []types.ContentBlock{},
+		},
+	}}
+}
+
+// openBlock starts a content block, closing the open one first.
+func (s *MessagesStream) openBlock(block types.ContentBlock) []types.MessagesStreamEvent {
+	events := s.closeBlock()
+	index := s.blocks
+	s.blocks++
+	s.open = block.Type
+	return append(events, types.MessagesStreamEvent{Type: "content_block_start", Index: &index, ContentBlock: &block})
+}
+
+// closeBlock returns the content_block_stop event closing the open content block, if any.
+func (s *MessagesStream) closeBlock() []types.MessagesStreamEvent {
+	if s.open == "" {
+		return nil
+	}
+	s.open = ""
+	index := s.blocks - 1
+	return []types.MessagesStreamEvent{{Type: "content_block_stop", Index: &index}}
+}
+
+// blockDelta returns a content_block_delta event for the open content block.
+func (s *MessagesStream) blockDelta(delta types.MessagesDelta) types.MessagesStreamEvent {
+	index := s.blocks - 1
+	return types.MessagesStreamEvent{Type: "content_block_delta", Index: &index, Delta: &delta}
+}
+
+// Event translates the data of one OCI event into zero or more Anthropic events. The first event
+// opens the stream with message_start.
+func (s *MessagesStream) Event(data []byte) ([]types.MessagesStreamEvent, error) {
+	deltas, err := s.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	events := s.start()
+	for _, delta := range deltas {
+		if delta.finishReason != "" {
+			s.finishReason = delta.finishReason
+			events = append(events, s.closeBlock()...)
+			continue
+		}
+
+		if delta.text != "" {
+			if s.open != "text" {
+				events = append(events, s.openBlock(types.ContentBlock{Type: "text"})...)
+			}
+			events = append(events, s.blockDelta(types.MessagesDelta{Type: "text_delta", Text: delta.text}))
+		}
+
+		for _, call := range delta.toolCalls {
+			if call.start {
+				s.toolUse = true
+				events = append(events, s.openBlock(types.ContentBlock{Type: "tool_use", ID: call.id, Name: call.name})...)
+			} else if s.open != "tool_use" {
+				continue
+			}
+			if call.arguments != "" {
+				events = append(events, s.blockDelta(types.MessagesDelta{Type: "input_json_delta", PartialJSON: call.arguments}))
+			}
+		}
+	}
+	return events, nil
+}
+
+// Finish returns the events that close the stream: the end of the open content block, the
+// message_delta event carrying the stop reason and usage, and message_stop.
+func (s *MessagesStream) Finish() []types.MessagesStreamEvent {
+	events := s.start()
+	events = append(events, s.closeBlock()...)
+
+	usage := toAnthropicUsage(s.usage)
+	delta := types.MessagesDelta{}
+	if s.finishReason != "" || s.toolUse {
+		delta.StopReason = StopReason(s.finishReason, s.toolUse)
+	}
+	return append(events,
+		types.MessagesStreamEvent{Type: "message_delta", Delta: &delta, Usage: &usage},
+		types.MessagesStreamEvent{Type: "message_stop"},
+	)
+}
+
+// ID returns the message ID.
+func (s *MessagesStream) ID() string {
+	return s.id
+}
+
+// StopReason returns the Anthropic stop reason, once the stream has reported a finish reason.
+func (s *MessagesStream) StopReason() string {
+	if s.finishReason == "" {
+		return ""
+	}
+	return StopReason(s.finishReason, s.toolUse)
+}
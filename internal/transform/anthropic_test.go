@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestMessagesToOracleCloudRequest_Defaults(t *testing.T) {
+	cfg := config.New()
+	transformer := New(cfg)
+
+	var req types.MessagesRequest
+	if err := json.Unmarshal([]byte(`{"model":"cohere.command-r-plus","messages":[{"role":"user","content":"Hi"}],"top_p":0}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := transformer.MessagesToOracleCloudRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := result.ChatRequest
+	if chat.APIFormat != "COHERE" || chat.Message != "Hi" {
+		t.Errorf("expected a COHERE request holding the last message, got %+v", chat)
+	}
+	if chat.MaxTokens != cfg.MaxTokens || chat.Temperature != cfg.Temperature || chat.TopK != cfg.TopK {
+		t.Errorf("expected the configured defaults, got %+v", chat)
+	}
+	if chat.TopP != 0 {
+		t.Errorf("expected the explicit top_p, got %v", chat.TopP)
+	}
+}
+
+func TestMessagesToOracleCloudRequest_Invalid(t *testing.T) {
+	transformer := New(config.New())
+
+	tests := map[string]string{
+		"missing model":    `{"messages":[{"role":"user","content":"Hi"}]}`,
+		"missing messages": `{"model":"m","messages":[]}`,
+		"unknown role":     `{"model":"m","messages":[{"role":"system","content":"Hi"}]}`,
+		"image source":     `{"model":"m","messages":[{"role":"user","content":[{"type":"image","source":{"type":"file"}}]}]}`,
+	}
+	for name, body := range tests {
+		var req types.MessagesRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := transformer.MessagesToOracleCloudRequest(req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFromAnthropicToolChoice(t *testing.T) {
+	tests := []struct {
+		choice   *types.AnthropicToolChoice
+		expected *chatToolChoice
+	}{
+		{nil, nil},
+		{&types.AnthropicToolChoice{Type: "auto"}, &chatToolChoice{mode: "auto"}},
+		{&types.AnthropicToolChoice{Type: "any"}, &chatToolChoice{mode: "required"}},
+		{&types.AnthropicToolChoice{Type: "none"}, &chatToolChoice{mode: "none"}},
+		{&types.AnthropicToolChoice{Type: "tool", Name: "get_weather"}, &chatToolChoice{mode: "function", name: "get_weather"}},
+		{&types.AnthropicToolChoice{Type: "tool"}, nil},
+	}
+
+	for _, tt := range tests {
+		result := fromAnthropicToolChoice(tt.choice)
+		if (result == nil) != (tt.expected == nil) || (result != nil && *result != *tt.expected) {
+			t.Errorf("%+v: expected %+v, got %+v", tt.choice, tt.expected, result)
+		}
+	}
+}
+
+func TestStopReason(t *testing.T) {
+	tests := []struct {
+		reason   string
+		toolUse  bool
+		expected string
+	}{
+		{"COMPLETE", false, "end_turn"},
+		{"stop", false, "end_turn"},
+		{"STOP_SEQUENCE", false, "stop_sequence"},
+		{"MAX_TOKENS", false, "max_tokens"},
+		{"length", false, "max_tokens"},
+		{"ERROR_TOXIC", false, "refusal"},
+		{"tool_calls", false, "tool_use"},
+		{"stop", true, "tool_use"},
+	}
+
+	for _, tt := range tests {
+		if result := StopReason(tt.reason, tt.toolUse); result != tt.expected {
+			t.Errorf("StopReason(%q, %v): expected %q, got %q", tt.reason, tt.toolUse, tt.expected, result)
+		}
+	}
+}
+
+func TestToAnthropicError(t *testing.T) {
+	tests := map[int]string{
+		http.StatusBadRequest:          "invalid_request_error",
+		http.StatusUnauthorized:        "authentication_error",
+		http.StatusTooManyRequests:     "rate_limit_error",
+		http.StatusInternalServerError: "api_error",
+		http.StatusBadGateway:          "api_error",
+		http.StatusServiceUnavailable:  "overloaded_error",
+	}
+
+	for status, expected := range tests {
+		result := ToAnthropicError(status, "failed")
+		if result.Type != "error" || result.Error.Type != expected || result.Error.Message != "failed" {
+			t.Errorf("status %d: expected error type %s, got %+v", status, expected, result)
+		}
+	}
+}
+
+func TestMessagesStream_Truncated(t *testing.T) {
+	transformer := New(config.New())
+	stream := transformer.NewMessagesStream("gpt-4")
+
+	events := stream.Finish()
+	expected := []string{"message_start", "message_delta", "message_stop"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %+v", expected, events)
+	}
+	for i := range expected {
+		if events[i].Type != expected[i] {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], events[i].Type)
+		}
+	}
+	if events[1].Delta.StopReason != "" || stream.StopReason() != "" {
+		t.Errorf("expected no stop reason for a stream without finish reason, got %+v", events[1].Delta)
+	}
+}
@@ -0,0 +1,351 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// OCI generateText runtimes.
+const (
+	runtimeCohere = "COHERE"
+	runtimeLlama  = "LLAMA"
+)
+
+// textRuntimes maps the legacy models served by the generateText action to their runtime.
+var textRuntimes = map[string]string{
+	"cohere.command":        runtimeCohere,
+	"cohere.command-light":  runtimeCohere,
+	"meta.llama-2-70b-chat": runtimeLlama,
+}
+
+// TextRuntime returns the generateText runtime serving a model, or "" for models that are only
+// served by the chat action.
+func TextRuntime(model string) string {
+	return textRuntimes[model]
+}
+
+// CompletionPrompt validates a text completion request and returns its prompt. The prompt is a
+// string or an array holding a single string; token arrays and batches of prompts are rejected.
+// Several completions (n > 1) require a model served by generateText or the GENERIC chat format.
+func (t *Transformer) CompletionPrompt(req types.CompletionRequest) (string, error) {
+	if req.Model == "" {
+		return "", errors.New("model is required")
+	}
+
+	var prompt string
+	if err := json.Unmarshal(req.Prompt, &prompt); err != nil {
+		var prompts []string
+		if err := json.Unmarshal(req.Prompt, &prompts); err != nil {
+			return "", errors.New("prompt must be a string or an array of strings")
+		}
+		if len(prompts) != 1 {
+			return "", errors.New("prompt must hold a single string; send one request per prompt or use a batch")
+		}
+		prompt = prompts[0]
+	}
+
+	if req.N > 1 && TextRuntime(req.Model) == "" && t.APIFormat(CompletionChatRequest(req, prompt)) != formatGeneric {
+		return "", errors.New("n greater than 1 is not supported by this model")
+	}
+	return prompt, nil
+}
+
+// CompletionChatRequest returns the single-turn chat request equivalent to a text completion
+// request. It is sent to models without generateText and used to estimate the cost of any
+// completion request.
+func CompletionChatRequest(req types.CompletionRequest, prompt string) types.ChatCompletionRequest {
+	return types.ChatCompletionRequest{
+		Model:            req.Model,
+		Messages:         []types.ChatCompletionMessage{{Role: "user", Content: prompt}},
+		MaxTokens:        req.MaxTokens,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Stop:             req.Stop,
+		User:             req.User,
+		Stream:           req.Stream,
+		StreamOptions:    req.StreamOptions,
+	}
+}
+
+// CompletionToOracleCloudRequest converts a text completion request for a model without
+// generateText to a single-turn chat request. Log probabilities are not available through chat.
+func (t *Transformer) CompletionToOracleCloudRequest(req types.CompletionRequest, prompt string) types.OracleCloudRequest {
+	oracleReq := t.toOracleCloudRequest(CompletionChatRequest(req, prompt), req.IsSet)
+	if req.N > 1 && oracleReq.ChatRequest.APIFormat == formatGeneric {
+		oracleReq.ChatRequest.NumGenerations = req.N
+	}
+	return oracleReq
+}
+
+// ToGenerateTextRequest converts a text completion request to an OCI generateText request for the
+// runtime serving its model. Sampling parameters fall back to the configured defaults, as for chat.
+func (t *Transformer) ToGenerateTextRequest(req types.CompletionRequest, prompt string) types.GenerateTextRequest {
+	runtime := TextRuntime(req.Model)
+	chat := t.toOracleCloudRequest(CompletionChatRequest(req, prompt), req.IsSet).ChatRequest
+
+	inference := types.InferenceRequest{
+		RuntimeType:      runtime,
+		Prompt:           prompt,
+		MaxTokens:        chat.MaxTokens,
+		Temperature:      chat.Temperature,
+		TopP:             chat.TopP,
+		TopK:             chat.TopK,
+		FrequencyPenalty: chat.FrequencyPenalty,
+		PresencePenalty:  chat.PresencePenalty,
+		IsEcho:           req.Echo,
+		IsStream:         req.Stream,
+	}
+	if req.N > 1 {
+		inference.NumGenerations = req.N
+	}
+
+	if runtime == runtimeLlama {
+		inference.Stop = req.Stop
+		inference.LogProbs = req.Logprobs
+	} else {
+		inference.StopSequences = req.Stop
+		if req.Logprobs != nil {
+			inference.ReturnLikelihoods = "GENERATION"
+		}
+	}
+
+	return types.GenerateTextRequest{
+		CompartmentID: t.config.CompartmentID,
+		ServingMode: types.ServingMode{
+			ModelID:     req.Model,
+			ServingType: "ON_DEMAND",
+		},
+		InferenceRequest: inference,
+	}
+}
+
+// completionID derives an OpenAI style text completion ID from a chat completion ID.
+func completionID(chatID string) string {
+	return "cmpl-" + strings.TrimPrefix(chatID, "chatcmpl-")
+}
+
+// GenerateTextToCompletion converts an OCI generateText response to an OpenAI text completion.
+// generateText does not report token usage.
+func (t *Transformer) GenerateTextToCompletion(resp types.GenerateTextResponse, model string) types.CompletionResponse {
+	if resp.ModelID != "" {
+		model = resp.ModelID
+	}
+
+	choices := []types.CompletionChoice{}
+	for i, text := range resp.InferenceResponse.GeneratedTexts {
+		reason := FinishReason(text.FinishReason)
+		choices = append(choices, types.CompletionChoice{
+			Text:         text.Text,
+			Index:        i,
+			Logprobs:     fromTokenLikelihoods(text.TokenLikelihoods),
+			FinishReason: &reason,
+		})
+	}
+	for _, choice := range resp.InferenceResponse.Choices {
+		reason := FinishReason(choice.FinishReason)
+		choices = append(choices, types.CompletionChoice{
+			Text:         choice.Text,
+			Index:        choice.Index,
+			Logprobs:     fromLlamaLogprobs(choice.Logprobs),
+			FinishReason: &reason,
+		})
+	}
+
+	return types.CompletionResponse{
+		ID:      completionID(t.newID()),
+		Object:  "text_completion",
+		Created: t.now().Unix(),
+		Model:   model,
+		Choices: choices,
+	}
+}
+
+// fromTokenLikelihoods converts the token likelihoods of the COHERE runtime to OpenAI log
+// probabilities. The runtime does not report alternatives, so the top log probabilities only
+// hold the generated token.
+func fromTokenLikelihoods(likelihoods []types.TokenLikelihood) *types.CompletionLogprobs {
+	if likelihoods == nil {
+		return nil
+	}
+
+	logprobs := &types.CompletionLogprobs{}
+	offset := 0
+	for _, l := range likelihoods {
+		logprobs.Tokens = append(logprobs.Tokens, l.Token)
+		logprobs.TokenLogprobs = append(logprobs.TokenLogprobs, l.Likelihood)
+		logprobs.TopLogprobs = append(logprobs.TopLogprobs, map[string]float64{l.Token: l.Likelihood})
+		logprobs.TextOffset = append(logprobs.TextOffset, offset)
+		offset += len(l.Token)
+	}
+	return logprobs
+}
+
+// fromLlamaLogprobs converts the log probabilities of the LLAMA runtime to the OpenAI format.
+func fromLlamaLogprobs(logprobs *types.LlamaLogprobs) *types.CompletionLogprobs {
+	if logprobs == nil {
+		return nil
+	}
+	return &types.CompletionLogprobs{
+		Tokens:        logprobs.Tokens,
+		TokenLogprobs: logprobs.TokenLogprobs,
+		TopLogprobs:   logprobs.TopLogprobs,
+		TextOffset:    logprobs.TextOffset,
+	}
+}
+
+// ChatToCompletion converts the OCI chat response to a text completion request, for models without
+// generateText, to an OpenAI text completion. With echo, the prompt is prepended to the texts.
+func (t *Transformer) ChatToCompletion(oracleResp types.OracleCloudResponse, model, prompt string, echo bool) types.CompletionResponse {
+	chat := t.ToOpenAIResponse(oracleResp, model)
+
+	choices := make([]types.CompletionChoice, 0, len(chat.Choices))
+	for _, choice := range chat.Choices {
+		text := choice.Message.Content
+		if echo {
+			text = prompt + text
+		}
+		reason := choice.FinishReason
+		choices = append(choices, types.CompletionChoice{Text: text, Index: choice.Index, FinishReason: &reason})
+	}
+
+	return types.CompletionResponse{
+		ID:      completionID(chat.ID),
+		Object:  "text_completion",
+		Created: chat.Created,
+		Model:   chat.Model,
+		Choices: choices,
+		Usage:   chat.Usage,
+	}
+}
+
+// textStreamEvent is a single server-sent event of a streamed generateText response.
+type textStreamEvent struct {
+	Index        int    `json:"index"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finishReason"`
+}
+
+// CompletionStream converts the events of a streamed OCI response to a text completion request
+// into OpenAI text completion chunks. Chat streams are translated by a StreamTranslator first.
+// A new stream must be created for every response.
+type CompletionStream struct {
+	id           string
+	model        string
+	created      int64
+	chat         *StreamTranslator // Translates chat events; nil for generateText streams
+	echo         string            // Prompt still to be sent before the completion, for chat streams
+	finishReason string
+}
+
+// NewCompletionStream creates the stream translating the response to a text completion request.
+func (t *Transformer) NewCompletionStream(req types.CompletionRequest, prompt string) *CompletionStream {
+	s := &CompletionStream{
+		model:   req.Model,
+		created: t.now().Unix(),
+	}
+	if TextRuntime(req.Model) != "" {
+		s.id = completionID(t.newID())
+		return s
+	}
+
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	s.chat = t.NewStreamTranslator(req.Model, includeUsage)
+	s.id = completionID(s.chat.ID())
+	if req.Echo {
+		s.echo = prompt
+	}
+	return s
+}
+
+func (s *CompletionStream) chunk(index int, text string, finishReason *string) types.CompletionResponse {
+	return types.CompletionResponse{
+		ID:      s.id,
+		Object:  "text_completion",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []types.CompletionChoice{{Text: text, Index: index, FinishReason: finishReason}},
+	}
+}
+
+// Event translates the data of one OCI event into zero or more text completion chunks.
+//
+// Like chat events in the COHERE format, the generateText event carrying the finish reason only
+// produces the closing chunk.
+func (s *CompletionStream) Event(data []byte) ([]types.CompletionResponse, error) {
+	if s.chat != nil {
+		chunks, err := s.chat.Event(data)
+		if err != nil {
+			return nil, err
+		}
+		return s.fromChatChunks(chunks), nil
+	}
+
+	var event textStreamEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	if event.FinishReason != "" {
+		reason := FinishReason(event.FinishReason)
+		s.finishReason = reason
+		return []types.CompletionResponse{s.chunk(event.Index, "", &reason)}, nil
+	}
+	if event.Text == "" {
+		return nil, nil
+	}
+	return []types.CompletionResponse{s.chunk(event.Index, event.Text, nil)}, nil
+}
+
+// fromChatChunks converts chat chunks to text completion chunks. The role chunk opening the
+// stream carries the echoed prompt, if any; chunks without text are dropped.
+func (s *CompletionStream) fromChatChunks(chunks []types.ChatCompletionChunk) []types.CompletionResponse {
+	var converted []types.CompletionResponse
+	for _, c := range chunks {
+		if len(c.Choices) == 0 {
+			if c.Usage != nil {
+				usage := s.chunk(0, "", nil)
+				usage.Choices = []types.CompletionChoice{}
+				usage.Usage = c.Usage
+				converted = append(converted, usage)
+			}
+			continue
+		}
+
+		choice := c.Choices[0]
+		text := choice.Delta.Content
+		if choice.Delta.Role != "" && s.echo != "" {
+			text = s.echo + text
+			s.echo = ""
+		}
+		if choice.FinishReason != nil {
+			s.finishReason = *choice.FinishReason
+		}
+		if text == "" && choice.FinishReason == nil {
+			continue
+		}
+		converted = append(converted, s.chunk(choice.Index, text, choice.FinishReason))
+	}
+	return converted
+}
+
+// Finish returns the chunks that close the stream: the usage chunk of chat streams when requested.
+func (s *CompletionStream) Finish() []types.CompletionResponse {
+	if s.chat == nil {
+		return nil
+	}
+	return s.fromChatChunks(s.chat.Finish())
+}
+
+// ID returns the completion ID shared by every chunk.
+func (s *CompletionStream) ID() string {
+	return s.id
+}
+
+// FinishReason returns the OpenAI finish reason, once the stream has reported one.
+func (s *CompletionStream) FinishReason() string {
+	return s.finishReason
+}
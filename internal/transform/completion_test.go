@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestToGenerateTextRequest(t *testing.T) {
+	cfg := config.New()
+	cfg.CompartmentID = "test-compartment-id"
+	transformer := New(cfg)
+
+	logprobs := 2
+	var req types.CompletionRequest
+	if err := json.Unmarshal([]byte(`{"model":"meta.llama-2-70b-chat","prompt":"Once","temperature":0,"stop":["."],"logprobs":2,"echo":true}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	result := transformer.ToGenerateTextRequest(req, "Once")
+	inference := result.InferenceRequest
+	if result.CompartmentID != "test-compartment-id" || result.ServingMode.ModelID != "meta.llama-2-70b-chat" {
+		t.Errorf("unexpected target %s %+v", result.CompartmentID, result.ServingMode)
+	}
+	if inference.RuntimeType != "LLAMA" || inference.Prompt != "Once" || !inference.IsEcho {
+		t.Errorf("unexpected inference request %+v", inference)
+	}
+	if inference.Temperature != 0 || inference.MaxTokens != cfg.MaxTokens || inference.TopP != cfg.TopP {
+		t.Errorf("expected the explicit temperature and the configured defaults, got %+v", inference)
+	}
+	if len(inference.Stop) != 1 || inference.StopSequences != nil {
+		t.Errorf("expected the LLAMA stop field, got %+v", inference)
+	}
+	if inference.LogProbs == nil || *inference.LogProbs != logprobs || inference.ReturnLikelihoods != "" {
+		t.Errorf("expected the LLAMA logProbs field, got %+v", inference)
+	}
+
+	req.Model = "cohere.command"
+	inference = transformer.ToGenerateTextRequest(req, "Once").InferenceRequest
+	if inference.RuntimeType != "COHERE" || len(inference.StopSequences) != 1 || inference.Stop != nil {
+		t.Errorf("expected the COHERE stopSequences field, got %+v", inference)
+	}
+	if inference.ReturnLikelihoods != "GENERATION" || inference.LogProbs != nil {
+		t.Errorf("expected the COHERE returnLikelihoods field, got %+v", inference)
+	}
+}
+
+func TestGenerateTextToCompletion_Logprobs(t *testing.T) {
+	transformer := New(config.New())
+
+	cohere := types.GenerateTextResponse{InferenceResponse: types.InferenceResponse{
+		RuntimeType: "COHERE",
+		GeneratedTexts: []types.GeneratedText{{
+			Text:             "Hi there",
+			FinishReason:     "MAX_TOKENS",
+			TokenLikelihoods: []types.TokenLikelihood{{Token: "Hi", Likelihood: -0.1}, {Token: " there", Likelihood: -0.5}},
+		}},
+	}}
+	result := transformer.GenerateTextToCompletion(cohere, "cohere.command")
+	choice := result.Choices[0]
+	if choice.FinishReason == nil || *choice.FinishReason != "length" {
+		t.Errorf("expected finish reason length, got %v", choice.FinishReason)
+	}
+	if choice.Logprobs == nil || len(choice.Logprobs.Tokens) != 2 || choice.Logprobs.TextOffset[1] != 2 ||
+		choice.Logprobs.TokenLogprobs[1] != -0.5 || choice.Logprobs.TopLogprobs[1][" there"] != -0.5 {
+		t.Errorf("unexpected log probabilities %+v", choice.Logprobs)
+	}
+
+	llama := types.GenerateTextResponse{InferenceResponse: types.InferenceResponse{
+		RuntimeType: "LLAMA",
+		Choices: []types.LlamaChoice{{
+			Index:        0,
+			Text:         "Hi",
+			FinishReason: "stop",
+			Logprobs:     &types.LlamaLogprobs{Tokens: []string{"Hi"}, TokenLogprobs: []float64{-0.1}, TextOffset: []int{0}},
+		}},
+	}}
+	result = transformer.GenerateTextToCompletion(llama, "meta.llama-2-70b-chat")
+	if result.Object != "text_completion" || result.Choices[0].Text != "Hi" || result.Choices[0].Logprobs.Tokens[0] != "Hi" {
+		t.Errorf("unexpected completion %+v", result)
+	}
+}
+
+func TestCompletionStream_Chat(t *testing.T) {
+	transformer := New(config.New())
+	req := types.CompletionRequest{Model: "gpt-4", Echo: true}
+	stream := transformer.NewCompletionStream(req, "Say ")
+
+	var texts []string
+	for _, event := range []string{
+		`{"apiFormat":"COHERE","text":"hi"}`,
+		`{"apiFormat":"COHERE","text":"hi","finishReason":"COMPLETE"}`,
+	} {
+		chunks, err := stream.Event([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, chunk := range chunks {
+			texts = append(texts, chunk.Choices[0].Text)
+		}
+	}
+
+	expected := []string{"Say ", "hi", ""}
+	if len(texts) != len(expected) {
+		t.Fatalf("expected chunks %q, got %q", expected, texts)
+	}
+	for i := range expected {
+		if texts[i] != expected[i] {
+			t.Errorf("expected chunk %d to be %q, got %q", i, expected[i], texts[i])
+		}
+	}
+	if stream.FinishReason() != "stop" || stream.ID()[:5] != "cmpl-" {
+		t.Errorf("unexpected stream summary %s %s", stream.ID(), stream.FinishReason())
+	}
+}
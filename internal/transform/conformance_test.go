@@ -0,0 +1,270 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// update regenerates the golden files: go test ./internal/transform -run TestConformance -update
+var update = flag.Bool("update", false, "regenerate the golden files of the conformance fixtures")
+
+// conformanceDir holds the conformance fixtures. Each fixture NAME.json holds a client request
+// and the OCI answer to it; NAME.golden.json holds the expected OCI request and client answer.
+// Requests are OpenAI chat completions unless the fixture selects the Anthropic or Responses dialect.
+const conformanceDir = "testdata/conformance"
+
+// fixture is the input of a conformance case. Exactly one of Response, Events and Error is set.
+type fixture struct {
+	// Config is applied on top of the default configuration
+	Config json.RawMessage `json:"config"`
+
+	// Dialect is "anthropic" for Anthropic Messages requests, "responses" for OpenAI Responses
+	// requests, empty for OpenAI chat completions
+	Dialect string `json:"dialect"`
+
+	// Request is the OpenAI chat completion, Anthropic Messages or Responses request sent by the client
+	Request json.RawMessage `json:"request"`
+
+	// Response is the OCI chat response
+	Response json.RawMessage `json:"response"`
+
+	// Events are the data of the server-sent events of a streamed OCI chat response
+	Events []json.RawMessage `json:"events"`
+
+	// Error is the OCI error response
+	Error *fixtureError `json:"error"`
+}
+
+// fixtureError is an OCI error response.
+type fixtureError struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// golden is the expected output of a conformance case.
+type golden struct {
+	// OCIRequest is the request sent to OCI
+	OCIRequest types.OracleCloudRequest `json:"ociRequest"`
+
+	// Response is the OpenAI chat completion returned to the client
+	Response *types.ChatCompletionResponse `json:"response,omitempty"`
+
+	// Chunks are the OpenAI chunks streamed to the client, before the final [DONE]
+	Chunks []types.ChatCompletionChunk `json:"chunks,omitempty"`
+
+	// Message is the Anthropic message returned to the client
+	Message *types.MessagesResponse `json:"message,omitempty"`
+
+	// Events are the Anthropic events streamed to the client
+	Events []types.MessagesStreamEvent `json:"events,omitempty"`
+
+	// Responses is the Responses API response returned to the client
+	Responses *types.ResponsesResponse `json:"responses,omitempty"`
+
+	// ResponsesEvents are the Responses API events streamed to the client
+	ResponsesEvents []types.ResponsesStreamEvent `json:"responsesEvents,omitempty"`
+
+	// Error is the error returned to the client
+	Error *goldenError `json:"error,omitempty"`
+}
+
+// goldenError is an OpenAI or Anthropic error response.
+type goldenError struct {
+	Status int         `json:"status"`
+	Body   interface{} `json:"body"`
+}
+
+func TestConformance(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(conformanceDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".golden.json") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			actual := runFixture(t, path)
+			goldenPath := filepath.Join(conformanceDir, name+".golden.json")
+
+			if *update {
+				if err := os.WriteFile(goldenPath, actual, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("failed to read golden file, run with -update to create it: %v", err)
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("output differs from %s, run with -update if the change is intended\nexpected:\n%s\ngot:\n%s", goldenPath, expected, actual)
+			}
+		})
+	}
+}
+
+// runFixture translates the request and the OCI answer of a fixture and returns the golden output.
+func runFixture(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+
+	cfg := config.New()
+	cfg.CompartmentID = "ocid1.compartment.oc1..conformance"
+	if f.Config != nil {
+		if err := json.Unmarshal(f.Config, cfg); err != nil {
+			t.Fatalf("invalid fixture config: %v", err)
+		}
+	}
+	transformer := New(cfg)
+	transformer.newID = func() string { return "chatcmpl-conformance" }
+	transformer.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	var out golden
+	switch f.Dialect {
+	case "anthropic":
+		out = runAnthropicFixture(t, transformer, f)
+	case "responses":
+		out = runResponsesFixture(t, transformer, f)
+	default:
+		out = runOpenAIFixture(t, transformer, f)
+	}
+
+	actual, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(actual, '\n')
+}
+
+// runOpenAIFixture translates a fixture holding an OpenAI chat completion request.
+func runOpenAIFixture(t *testing.T, transformer *Transformer, f fixture) golden {
+	t.Helper()
+
+	var request types.ChatCompletionRequest
+	if err := json.Unmarshal(f.Request, &request); err != nil {
+		t.Fatalf("invalid fixture request: %v", err)
+	}
+
+	out := golden{OCIRequest: transformer.ToOracleCloudRequest(request)}
+	switch {
+	case f.Error != nil:
+		out.Error = &goldenError{Status: f.Error.Status, Body: ToOpenAIError(f.Error.Status, f.Error.Body)}
+	case f.Events != nil:
+		includeUsage := request.StreamOptions != nil && request.StreamOptions.IncludeUsage
+		stream := transformer.NewStreamTranslator(request.Model, includeUsage)
+		for _, event := range f.Events {
+			chunks, err := stream.Event(event)
+			if err != nil {
+				t.Fatalf("failed to translate event %s: %v", event, err)
+			}
+			out.Chunks = append(out.Chunks, chunks...)
+		}
+		out.Chunks = append(out.Chunks, stream.Finish()...)
+	default:
+		var response types.OracleCloudResponse
+		if err := json.Unmarshal(f.Response, &response); err != nil {
+			t.Fatalf("invalid fixture response: %v", err)
+		}
+		translated := transformer.ToOpenAIResponse(response, request.Model)
+		out.Response = &translated
+	}
+	return out
+}
+
+// runAnthropicFixture translates a fixture holding an Anthropic Messages request.
+func runAnthropicFixture(t *testing.T, transformer *Transformer, f fixture) golden {
+	t.Helper()
+
+	var request types.MessagesRequest
+	if err := json.Unmarshal(f.Request, &request); err != nil {
+		t.Fatalf("invalid fixture request: %v", err)
+	}
+	oracleReq, err := transformer.MessagesToOracleCloudRequest(request)
+	if err != nil {
+		t.Fatalf("invalid fixture request: %v", err)
+	}
+
+	out := golden{OCIRequest: oracleReq}
+	switch {
+	case f.Error != nil:
+		upstream := ToOpenAIError(f.Error.Status, f.Error.Body).Error
+		out.Error = &goldenError{Status: f.Error.Status, Body: ToAnthropicError(f.Error.Status, upstream.Message)}
+	case f.Events != nil:
+		stream := transformer.NewMessagesStream(request.Model)
+		for _, event := range f.Events {
+			events, err := stream.Event(event)
+			if err != nil {
+				t.Fatalf("failed to translate event %s: %v", event, err)
+			}
+			out.Events = append(out.Events, events...)
+		}
+		out.Events = append(out.Events, stream.Finish()...)
+	default:
+		var response types.OracleCloudResponse
+		if err := json.Unmarshal(f.Response, &response); err != nil {
+			t.Fatalf("invalid fixture response: %v", err)
+		}
+		translated := transformer.ToMessagesResponse(response, request.Model)
+		out.Message = &translated
+	}
+	return out
+}
+
+// runResponsesFixture translates a fixture holding an OpenAI Responses request.
+func runResponsesFixture(t *testing.T, transformer *Transformer, f fixture) golden {
+	t.Helper()
+
+	var request types.ResponsesRequest
+	if err := json.Unmarshal(f.Request, &request); err != nil {
+		t.Fatalf("invalid fixture request: %v", err)
+	}
+	oracleReq, err := transformer.ResponsesToOracleCloudRequest(request, nil)
+	if err != nil {
+		t.Fatalf("invalid fixture request: %v", err)
+	}
+
+	out := golden{OCIRequest: oracleReq}
+	switch {
+	case f.Error != nil:
+		out.Error = &goldenError{Status: f.Error.Status, Body: ToOpenAIError(f.Error.Status, f.Error.Body)}
+	case f.Events != nil:
+		stream := transformer.NewResponsesStream(request)
+		for _, event := range f.Events {
+			events, err := stream.Event(event)
+			if err != nil {
+				t.Fatalf("failed to translate event %s: %v", event, err)
+			}
+			out.ResponsesEvents = append(out.ResponsesEvents, events...)
+		}
+		out.ResponsesEvents = append(out.ResponsesEvents, stream.Finish()...)
+	default:
+		var response types.OracleCloudResponse
+		if err := json.Unmarshal(f.Response, &response); err != nil {
+			t.Fatalf("invalid fixture response: %v", err)
+		}
+		translated := transformer.ToResponsesResponse(response, request)
+		out.Responses = &translated
+	}
+	return out
+}
@@ -0,0 +1,314 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// conversation is a chat request in the dialect-neutral form shared by the client APIs. Each
+// dialect converts its requests to a conversation, which toChatRequest converts to OCI, so that
+// every dialect is served by the same OCI backend.
+type conversation struct {
+	model     string
+	messages  []chatMessage
+	maxTokens int // 0 uses the configured default
+
+	// Sampling parameters; nil uses the configured default
+	temperature      *float64
+	topP             *float64
+	topK             *int
+	frequencyPenalty *float64
+	presencePenalty  *float64
+
+	stop       []string
+	stream     bool
+	tools      []chatTool
+	toolChoice *chatToolChoice
+
+	// Cohere RAG extensions
+	documents           []json.RawMessage
+	citationQuality     string
+	isSearchQueriesOnly bool
+}
+
+// chatMessage is a message of a conversation. Roles use the OpenAI vocabulary: "system",
+// "user", "assistant" and "tool".
+type chatMessage struct {
+	role       string
+	name       string
+	parts      []chatPart
+	toolCalls  []chatToolCall // Tool calls generated by the model, on assistant messages
+	toolCallID string         // Tool call answered, on tool messages
+}
+
+// chatCompletionRequest returns the chat completion request equivalent to the conversation, with
+// the text of every message. It is used to rate limit, meter and count the requests of the other
+// dialects like chat completions.
+func (c conversation) chatCompletionRequest() types.ChatCompletionRequest {
+	chatReq := types.ChatCompletionRequest{Model: c.model, MaxTokens: c.maxTokens, Stream: c.stream}
+	for _, msg := range c.messages {
+		chatReq.Messages = append(chatReq.Messages, types.ChatCompletionMessage{Role: msg.role, Content: msg.text()})
+	}
+	return chatReq
+}
+
+// text returns the text parts of the message, one per line.
+func (m chatMessage) text() string {
+	var text []string
+	for _, part := range m.parts {
+		if part.image == "" {
+			text = append(text, part.text)
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+// chatPart is a text or image part of a message.
+type chatPart struct {
+	text   string
+	image  string // Image URL or data URL; set for image parts only
+	detail string // Image fidelity: "auto", "low" or "high"
+}
+
+// chatTool is a function the model may call.
+type chatTool struct {
+	name        string
+	description string
+	parameters  json.RawMessage // JSON Schema of the arguments
+}
+
+// chatToolCall is a function call generated by the model.
+type chatToolCall struct {
+	id        string
+	name      string
+	arguments string // JSON encoded arguments
+}
+
+// chatToolChoice controls which tool is called: mode is "none", "auto", "required" or
+// "function", in which case name is the function to call.
+type chatToolChoice struct {
+	mode string
+	name string
+}
+
+// chatResult is an OCI chat response in the dialect-neutral form rendered by every dialect.
+type chatResult struct {
+	model   string
+	choices []chatChoice
+	usage   *types.Usage
+}
+
+// chatChoice is a single generated message.
+type chatChoice struct {
+	index        int
+	text         string
+	toolCalls    []chatToolCall
+	finishReason string              // Finish reason reported by OCI
+	grounding    *types.OCIExtension // Grounding information of COHERE responses
+}
+
+// toChatResult converts an OCI chat response. The model requested by the client is reported when
+// OCI does not echo the model ID.
+func toChatResult(oracleResp types.OracleCloudResponse, model string) chatResult {
+	if oracleResp.ModelID != "" {
+		model = oracleResp.ModelID
+	}
+	resp := oracleResp.ChatResponse
+
+	result := chatResult{model: model, usage: resp.Usage}
+	if len(resp.Choices) == 0 {
+		result.choices = []chatChoice{{
+			text:         resp.Text,
+			finishReason: resp.FinishReason,
+			grounding:    cohereExtension(resp),
+		}}
+		return result
+	}
+
+	for _, choice := range resp.Choices {
+		converted := chatChoice{
+			index:        choice.Index,
+			text:         genericText(choice.Message),
+			finishReason: choice.FinishReason,
+		}
+		for _, call := range choice.Message.ToolCalls {
+			converted.toolCalls = append(converted.toolCalls, chatToolCall{id: call.ID, name: call.Name, arguments: call.Arguments})
+		}
+		result.choices = append(result.choices, converted)
+	}
+	return result
+}
+
+// chatDelta is an increment of a streamed chat response. A delta carries either generated
+// content, grounding information or the finish reason.
+type chatDelta struct {
+	text         string
+	toolCalls    []toolCallDelta
+	grounding    *types.OCIExtension
+	finishReason string // Finish reason reported by OCI, on the delta closing the message
+}
+
+// toolCallDelta is a fragment of a streamed tool call. The fragment starting a tool call carries
+// its ID and name; the following ones continue its arguments.
+type toolCallDelta struct {
+	index     int
+	start     bool
+	id        string
+	name      string
+	arguments string
+}
+
+// streamEvent is a single server-sent event of a streamed OCI chat response. COHERE events
+// carry text, GENERIC events a message delta.
+type streamEvent struct {
+	APIFormat        string                `json:"apiFormat"`
+	Text             string                `json:"text"`
+	Message          *types.GenericMessage `json:"message"`
+	FinishReason     string                `json:"finishReason"`
+	Usage            *types.Usage          `json:"usage"`
+	Citations        []types.Citation      `json:"citations"`
+	Documents        []json.RawMessage     `json:"documents"`
+	SearchQueries    []types.SearchQuery   `json:"searchQueries"`
+	IsSearchRequired *bool                 `json:"isSearchRequired"`
+}
+
+// streamDecoder decodes the events of a streamed OCI chat response into deltas and assembles
+// the complete response along the way. A new decoder must be created for every response.
+type streamDecoder struct {
+	model     string
+	generic   bool
+	usage     *types.Usage
+	response  types.ChatResponse
+	text      strings.Builder
+	toolCalls []types.GenericToolCall
+}
+
+// decode decodes the data of one OCI event into zero or more deltas.
+//
+// In the COHERE format, OCI repeats the complete text on the event that carries the finish
+// reason, so that event only produces the closing delta. GENERIC events only carry deltas.
+func (d *streamDecoder) decode(data []byte) ([]chatDelta, error) {
+	var event streamEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	if event.Usage != nil {
+		d.usage = event.Usage
+	}
+	if event.APIFormat != "" {
+		d.response.APIFormat = event.APIFormat
+	}
+
+	var deltas []chatDelta
+	if event.Message != nil {
+		d.generic = true
+		if delta, ok := d.genericDelta(*event.Message); ok {
+			deltas = append(deltas, delta)
+		}
+	}
+
+	if ext := d.grounding(event); ext != nil {
+		deltas = append(deltas, chatDelta{grounding: ext})
+	}
+
+	if event.FinishReason != "" {
+		d.response.FinishReason = event.FinishReason
+		return append(deltas, chatDelta{finishReason: event.FinishReason}), nil
+	}
+
+	if event.Text != "" {
+		d.text.WriteString(event.Text)
+		deltas = append(deltas, chatDelta{text: event.Text})
+	}
+	return deltas, nil
+}
+
+// grounding collects the grounding information of a COHERE event and returns the part not sent
+// yet. The event carrying the finish reason may repeat what earlier events reported, so its
+// information is only used for the fields no earlier event reported.
+func (d *streamDecoder) grounding(event streamEvent) *types.OCIExtension {
+	final := event.FinishReason != ""
+	var ext types.OCIExtension
+	found := false
+
+	if len(event.Citations) > 0 && !(final && len(d.response.Citations) > 0) {
+		d.response.Citations = append(d.response.Citations, event.Citations...)
+		ext.Citations = toOCICitations(event.Citations)
+		found = true
+	}
+	if len(event.Documents) > 0 && !(final && len(d.response.Documents) > 0) {
+		d.response.Documents = append(d.response.Documents, event.Documents...)
+		ext.Documents = event.Documents
+		found = true
+	}
+	if len(event.SearchQueries) > 0 && !(final && len(d.response.SearchQueries) > 0) {
+		d.response.SearchQueries = append(d.response.SearchQueries, event.SearchQueries...)
+		ext.SearchQueries = toSearchQueryTexts(event.SearchQueries)
+		found = true
+	}
+	if event.IsSearchRequired != nil && d.response.IsSearchRequired == nil {
+		d.response.IsSearchRequired = event.IsSearchRequired
+		ext.IsSearchRequired = event.IsSearchRequired
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+	return &ext
+}
+
+// genericDelta decodes a GENERIC message delta. A tool call with an ID or a name starts a new
+// tool call; one without continues the arguments of the last tool call.
+func (d *streamDecoder) genericDelta(msg types.GenericMessage) (chatDelta, bool) {
+	var delta chatDelta
+	if text := genericText(msg); text != "" {
+		d.text.WriteString(text)
+		delta.text = text
+	}
+
+	for _, call := range msg.ToolCalls {
+		if (call.ID != "" || call.Name != "") || len(d.toolCalls) == 0 {
+			index := len(d.toolCalls)
+			d.toolCalls = append(d.toolCalls, call)
+			delta.toolCalls = append(delta.toolCalls, toolCallDelta{
+				index:     index,
+				start:     true,
+				id:        call.ID,
+				name:      call.Name,
+				arguments: call.Arguments,
+			})
+			continue
+		}
+
+		index := len(d.toolCalls) - 1
+		d.toolCalls[index].Arguments += call.Arguments
+		delta.toolCalls = append(delta.toolCalls, toolCallDelta{index: index, arguments: call.Arguments})
+	}
+
+	return delta, delta.text != "" || len(delta.toolCalls) > 0
+}
+
+// Response assembles the complete OCI response from the events seen so far.
+// It is only complete once the stream has reported a finish reason.
+func (d *streamDecoder) Response() types.OracleCloudResponse {
+	response := d.response
+	response.Usage = d.usage
+	if !d.generic {
+		response.Text = d.text.String()
+		return types.OracleCloudResponse{ModelID: d.model, ChatResponse: response}
+	}
+
+	if response.APIFormat == "" {
+		response.APIFormat = "GENERIC"
+	}
+	message := types.GenericMessage{Role: "ASSISTANT", ToolCalls: d.toolCalls}
+	if d.text.Len() > 0 {
+		message.Content = []types.GenericContent{{Type: "TEXT", Text: d.text.String()}}
+	}
+	response.Choices = []types.GenericChoice{{Index: 0, Message: message, FinishReason: response.FinishReason}}
+	return types.OracleCloudResponse{ModelID: d.model, ChatResponse: response}
+}
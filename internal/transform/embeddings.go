@@ -0,0 +1,77 @@
+package transform
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// EmbeddingsToEmbedTextRequest converts an OpenAI embeddings request to an OCI embedText request.
+// Inputs longer than the context of the model are truncated at the end, as OpenAI rejects them
+// instead and clients do not expect partial failures.
+func (t *Transformer) EmbeddingsToEmbedTextRequest(req types.EmbeddingRequest) (types.EmbedTextRequest, error) {
+	switch req.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		return types.EmbedTextRequest{}, fmt.Errorf("unsupported encoding_format %q", req.EncodingFormat)
+	}
+	return t.toEmbedTextRequest(req.Model, req.Input, true)
+}
+
+// toEmbedTextRequest returns the OCI embedText request embedding inputs with model.
+func (t *Transformer) toEmbedTextRequest(model string, inputs []string, truncate bool) (types.EmbedTextRequest, error) {
+	embedReq := types.EmbedTextRequest{
+		CompartmentID: t.config.CompartmentID,
+		ServingMode: types.ServingMode{
+			ModelID:     model,
+			ServingType: "ON_DEMAND",
+		},
+		Inputs:   inputs,
+		Truncate: "END",
+	}
+	if !truncate {
+		embedReq.Truncate = "NONE"
+	}
+
+	if model == "" {
+		return embedReq, errors.New("model is required")
+	}
+	if len(inputs) == 0 {
+		return embedReq, errors.New("input is required")
+	}
+	return embedReq, nil
+}
+
+// ToEmbeddingResponse converts an OCI embedText response to an OpenAI embeddings response, with
+// the embeddings encoded as requested.
+func ToEmbeddingResponse(resp types.EmbedTextResponse, req types.EmbeddingRequest) types.EmbeddingResponse {
+	embeddingResp := types.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]types.Embedding, 0, len(resp.Embeddings)),
+		Model:  req.Model,
+	}
+	for i, vector := range resp.Embeddings {
+		var embedding interface{} = vector
+		if req.EncodingFormat == "base64" {
+			embedding = encodeEmbedding(vector)
+		}
+		embeddingResp.Data = append(embeddingResp.Data, types.Embedding{Object: "embedding", Index: i, Embedding: embedding})
+	}
+	if resp.Usage != nil {
+		embeddingResp.Usage = types.EmbeddingUsage{PromptTokens: resp.Usage.PromptTokens, TotalTokens: resp.Usage.TotalTokens}
+	}
+	return embeddingResp
+}
+
+// encodeEmbedding encodes a vector as OpenAI does for the base64 format: little-endian float32s.
+func encodeEmbedding(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(value)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
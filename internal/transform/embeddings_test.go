@@ -0,0 +1,64 @@
+package transform
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestEmbeddingsToEmbedTextRequest(t *testing.T) {
+	cfg := config.New()
+	cfg.CompartmentID = "ocid1.compartment.oc1..test"
+	transformer := New(cfg)
+
+	req, err := transformer.EmbeddingsToEmbedTextRequest(types.EmbeddingRequest{Model: "m", Input: types.EmbedInput{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.CompartmentID != cfg.CompartmentID || req.ServingMode.ModelID != "m" || req.Truncate != "END" || len(req.Inputs) != 2 {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	invalid := []types.EmbeddingRequest{
+		{Input: types.EmbedInput{"a"}},
+		{Model: "m"},
+		{Model: "m", Input: types.EmbedInput{"a"}, EncodingFormat: "int8"},
+	}
+	for _, embeddingReq := range invalid {
+		if _, err := transformer.EmbeddingsToEmbedTextRequest(embeddingReq); err == nil {
+			t.Errorf("expected an error for %+v", embeddingReq)
+		}
+	}
+}
+
+func TestToEmbeddingResponse(t *testing.T) {
+	resp := types.EmbedTextResponse{
+		Embeddings: [][]float64{{0.5, -1}, {0.25, 2}},
+		Usage:      &types.Usage{PromptTokens: 3, TotalTokens: 3},
+	}
+
+	floats := ToEmbeddingResponse(resp, types.EmbeddingRequest{Model: "m"})
+	if floats.Object != "list" || floats.Model != "m" || len(floats.Data) != 2 || floats.Usage.PromptTokens != 3 {
+		t.Fatalf("unexpected response: %+v", floats)
+	}
+	if vector, ok := floats.Data[1].Embedding.([]float64); !ok || floats.Data[1].Index != 1 || vector[1] != 2 {
+		t.Errorf("expected the second embedding as floats, got %+v", floats.Data[1])
+	}
+
+	encoded := ToEmbeddingResponse(resp, types.EmbeddingRequest{Model: "m", EncodingFormat: "base64"})
+	text, ok := encoded.Data[0].Embedding.(string)
+	if !ok {
+		t.Fatalf("expected a base64 embedding, got %+v", encoded.Data[0])
+	}
+	raw, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(raw) != 8 {
+		t.Fatalf("expected 2 float32s, got %q: %v", text, err)
+	}
+	if got := math.Float32frombits(binary.LittleEndian.Uint32(raw[4:])); got != -1 {
+		t.Errorf("expected -1, got %v", got)
+	}
+}
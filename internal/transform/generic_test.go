@@ -0,0 +1,153 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// genericChat is the part of a GENERIC chat request the tests check, decoded from its JSON.
+type genericChat struct {
+	APIFormat string `json:"apiFormat"`
+	Messages  []struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			ImageURL *struct {
+				URL    string `json:"url"`
+				Detail string `json:"detail"`
+			} `json:"imageUrl"`
+		} `json:"content"`
+		ToolCalls []struct {
+			ID        string `json:"id"`
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"toolCalls"`
+		ToolCallID string `json:"toolCallId"`
+	} `json:"messages"`
+	Tools []struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"tools"`
+	ToolChoice *struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"toolChoice"`
+}
+
+// toGenericChat converts an OpenAI request body and decodes the chat request sent to OCI.
+func toGenericChat(t *testing.T, body string) genericChat {
+	t.Helper()
+	var openAIReq types.ChatCompletionRequest
+	if err := json.Unmarshal([]byte(body), &openAIReq); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	data, err := json.Marshal(New(config.New()).ToOracleCloudRequest(openAIReq).ChatRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chat genericChat
+	if err := json.Unmarshal(data, &chat); err != nil {
+		t.Fatal(err)
+	}
+	return chat
+}
+
+func TestToOracleCloudRequest_GenericTools(t *testing.T) {
+	chat := toGenericChat(t, `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "Sunny"}
+		],
+		"tools": [{"type": "function", "function": {"name": "weather", "parameters": {"type": "object"}}}],
+		"tool_choice": {"type": "function", "function": {"name": "weather"}}
+	}`)
+
+	if chat.APIFormat != "GENERIC" || len(chat.Messages) != 3 {
+		t.Fatalf("expected the GENERIC conversation, got %+v", chat)
+	}
+	call := chat.Messages[1].ToolCalls
+	if len(call) != 1 || call[0].ID != "call_1" || call[0].Type != "FUNCTION" || call[0].Name != "weather" || call[0].Arguments != `{"city":"Paris"}` {
+		t.Errorf("expected the assistant tool call to be passed on, got %+v", chat.Messages[1])
+	}
+	if tool := chat.Messages[2]; tool.Role != "TOOL" || tool.ToolCallID != "call_1" || len(tool.Content) != 1 || tool.Content[0].Text != "Sunny" {
+		t.Errorf("expected the tool result, got %+v", tool)
+	}
+	if len(chat.Tools) != 1 || chat.Tools[0].Type != "FUNCTION" || chat.Tools[0].Name != "weather" {
+		t.Errorf("expected the function tool, got %+v", chat.Tools)
+	}
+	if chat.ToolChoice == nil || chat.ToolChoice.Type != "FUNCTION" || chat.ToolChoice.Name != "weather" {
+		t.Errorf("expected the named tool choice, got %+v", chat.ToolChoice)
+	}
+}
+
+func TestToOracleCloudRequest_GenericImage(t *testing.T) {
+	chat := toGenericChat(t, `{
+		"model": "gpt-4",
+		"messages": [{"role": "user", "content": [
+			{"type": "text", "text": "What is this?"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA", "detail": "low"}}
+		]}]
+	}`)
+
+	// Images force the GENERIC format on models that would use COHERE
+	if chat.APIFormat != "GENERIC" || len(chat.Messages) != 1 {
+		t.Fatalf("expected a GENERIC message, got %+v", chat)
+	}
+	content := chat.Messages[0].Content
+	if len(content) != 2 || content[0].Type != "TEXT" || content[0].Text != "What is this?" {
+		t.Fatalf("expected the text and image parts, got %+v", content)
+	}
+	if image := content[1]; image.Type != "IMAGE" || image.ImageURL == nil || image.ImageURL.URL != "data:image/png;base64,AAAA" || image.ImageURL.Detail != "LOW" {
+		t.Errorf("expected the image part, got %+v", image)
+	}
+}
+
+func TestToOpenAIResponse_GenericToolCalls(t *testing.T) {
+	var oracleResp types.OracleCloudResponse
+	body := `{"modelId": "meta.llama-3.3-70b-instruct", "chatResponse": {"apiFormat": "GENERIC", "choices": [{
+		"index": 0,
+		"finishReason": "tool_calls",
+		"message": {"role": "ASSISTANT", "toolCalls": [{"id": "call_1", "type": "FUNCTION", "name": "weather", "arguments": "{\"city\":\"Paris\"}"}]}
+	}]}}`
+	if err := json.Unmarshal([]byte(body), &oracleResp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	data, err := json.Marshal(New(config.New()).ToOpenAIResponse(oracleResp, "meta.llama-3.3-70b-instruct"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Type     string `json:"type"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Choices) != 1 || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("expected a tool_calls choice, got %s", data)
+	}
+	calls := resp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Type != "function" || calls[0].Function.Name != "weather" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("expected the OpenAI tool call, got %s", data)
+	}
+}
@@ -0,0 +1,127 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Moderation categories reported besides the content moderation categories.
+const (
+	CategoryPromptInjection = "prompt_injection"
+	CategoryPII             = "pii"
+)
+
+// ToApplyGuardrailsRequest converts a text to an OCI applyGuardrails request running the
+// configured checks. Prompt injection is only checked in prompts.
+func (t *Transformer) ToApplyGuardrailsRequest(text string, prompt bool) types.ApplyGuardrailsRequest {
+	cfg := t.config.Moderation
+	req := types.ApplyGuardrailsRequest{
+		CompartmentID: t.config.CompartmentID,
+		Input:         types.GuardrailsInput{Type: "TEXT", Content: text},
+	}
+	if cfg.ContentThreshold > 0 && len(cfg.Categories) > 0 {
+		req.GuardrailConfigs.ContentModerationConfig = &types.ContentModerationConfig{Categories: cfg.Categories}
+	}
+	if len(cfg.PIITypes) > 0 {
+		req.GuardrailConfigs.PersonallyIdentifiableInformationConfig = &types.PersonallyIdentifiableInformationConfig{Types: cfg.PIITypes}
+	}
+	if prompt && cfg.PromptInjectionThreshold > 0 {
+		req.GuardrailConfigs.PromptInjectionConfig = &types.PromptInjectionConfig{}
+	}
+	return req
+}
+
+// ModerationInputs returns the texts of a moderations request: a string, an array of strings, or
+// an array of text parts.
+func ModerationInputs(req types.ModerationRequest) ([]string, error) {
+	if len(req.Input) == 0 {
+		return nil, errors.New("input is required")
+	}
+
+	var text string
+	if err := json.Unmarshal(req.Input, &text); err == nil {
+		if text == "" {
+			return nil, errors.New("input must not be empty")
+		}
+		return []string{text}, nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(req.Input, &parts); err != nil || len(parts) == 0 {
+		return nil, errors.New("input must be a string or a non-empty array")
+	}
+	texts := make([]string, 0, len(parts))
+	for i, raw := range parts {
+		if err := json.Unmarshal(raw, &text); err == nil && text != "" {
+			texts = append(texts, text)
+			continue
+		}
+		var part types.ContentPart
+		if err := json.Unmarshal(raw, &part); err != nil || part.Type != "text" || part.Text == "" {
+			return nil, fmt.Errorf("input[%d]: must be a string or a text part", i)
+		}
+		texts = append(texts, part.Text)
+	}
+	return texts, nil
+}
+
+// ToModerationResult scores the outcome of an applyGuardrails request against the configured
+// thresholds. Content moderation categories are reported in lower case, personal data under
+// "pii" and, by type, "pii/<type>".
+func ToModerationResult(resp types.ApplyGuardrailsResponse, cfg config.Moderation) types.ModerationResult {
+	result := types.ModerationResult{Categories: map[string]bool{}, CategoryScores: map[string]float64{}}
+	flag := func(category string, score, threshold float64) {
+		if current, ok := result.CategoryScores[category]; !ok || score > current {
+			result.CategoryScores[category] = score
+		}
+		flagged := score >= threshold
+		result.Categories[category] = result.Categories[category] || flagged
+		result.Flagged = result.Flagged || flagged
+	}
+
+	if moderation := resp.Results.ContentModeration; moderation != nil && cfg.ContentThreshold > 0 {
+		for _, category := range moderation.Categories {
+			flag(strings.ToLower(category.Name), category.Score, cfg.ContentThreshold)
+		}
+	}
+	if injection := resp.Results.PromptInjection; injection != nil && cfg.PromptInjectionThreshold > 0 {
+		flag(CategoryPromptInjection, injection.Score, cfg.PromptInjectionThreshold)
+	}
+	if len(cfg.PIITypes) > 0 {
+		result.Categories[CategoryPII] = false
+		result.CategoryScores[CategoryPII] = 0
+		for _, entity := range resp.Results.PersonallyIdentifiableInformation {
+			flag(CategoryPII, entity.Score, cfg.PIIThreshold)
+			flag(CategoryPII+"/"+strings.ToLower(entity.Label), entity.Score, cfg.PIIThreshold)
+		}
+	}
+	return result
+}
+
+// FlaggedCategories returns the flagged categories of a moderation result, in a stable order.
+func FlaggedCategories(result types.ModerationResult) []string {
+	var flagged []string
+	for category, ok := range result.Categories {
+		if ok {
+			flagged = append(flagged, category)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// ToModerationResponse converts the moderation results of the inputs of a request to an OpenAI
+// moderations response.
+func (t *Transformer) ToModerationResponse(results []types.ModerationResult, model string) types.ModerationResponse {
+	return types.ModerationResponse{
+		ID:      "modr-" + strings.TrimPrefix(t.newID(), "chatcmpl-"),
+		Model:   model,
+		Results: results,
+	}
+}
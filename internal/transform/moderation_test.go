@@ -0,0 +1,93 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestToApplyGuardrailsRequest(t *testing.T) {
+	cfg := config.New()
+	cfg.CompartmentID = "test-compartment-id"
+	cfg.Moderation.PIITypes = []string{"EMAIL"}
+	transformer := New(cfg)
+
+	req := transformer.ToApplyGuardrailsRequest("Hello", true)
+	if req.CompartmentID != "test-compartment-id" || req.Input.Type != "TEXT" || req.Input.Content != "Hello" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	configs := req.GuardrailConfigs
+	if configs.ContentModerationConfig == nil || !reflect.DeepEqual(configs.ContentModerationConfig.Categories, []string{"OVERALL", "BLOCKLIST"}) {
+		t.Errorf("expected the default categories, got %+v", configs.ContentModerationConfig)
+	}
+	if configs.PersonallyIdentifiableInformationConfig == nil || configs.PromptInjectionConfig == nil {
+		t.Errorf("expected PII and prompt injection checks, got %+v", configs)
+	}
+
+	// Generated text is not checked for prompt injection
+	if req := transformer.ToApplyGuardrailsRequest("Hello", false); req.GuardrailConfigs.PromptInjectionConfig != nil {
+		t.Error("expected no prompt injection check")
+	}
+}
+
+func TestModerationInputs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"Hello"`, []string{"Hello"}},
+		{`["Hello","World"]`, []string{"Hello", "World"}},
+		{`[{"type":"text","text":"Hello"}]`, []string{"Hello"}},
+	}
+	for _, tt := range tests {
+		texts, err := ModerationInputs(types.ModerationRequest{Input: json.RawMessage(tt.input)})
+		if err != nil || !reflect.DeepEqual(texts, tt.expected) {
+			t.Errorf("%s: expected %q, got %q (%v)", tt.input, tt.expected, texts, err)
+		}
+	}
+
+	for _, input := range []string{``, `""`, `[]`, `42`, `[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]`} {
+		if _, err := ModerationInputs(types.ModerationRequest{Input: json.RawMessage(input)}); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestToModerationResult(t *testing.T) {
+	cfg := config.New().Moderation
+	cfg.PIITypes = []string{"EMAIL", "PERSON"}
+	cfg.PIIThreshold = 0.8
+
+	resp := types.ApplyGuardrailsResponse{Results: types.GuardrailsResults{
+		ContentModeration: &types.ContentModerationResult{Categories: []types.CategoryScore{{Name: "OVERALL", Score: 0.2}, {Name: "BLOCKLIST", Score: 0}}},
+		PromptInjection:   &types.PromptInjectionResult{Score: 0.4},
+		PersonallyIdentifiableInformation: []types.PersonallyIdentifiableInformationResult{
+			{Label: "EMAIL", Score: 0.9},
+			{Label: "PERSON", Score: 0.6},
+		},
+	}}
+
+	result := ToModerationResult(resp, cfg)
+	if !result.Flagged {
+		t.Error("expected the result to be flagged")
+	}
+	expectedCategories := map[string]bool{"overall": false, "blocklist": false, "prompt_injection": false, "pii": true, "pii/email": true, "pii/person": false}
+	if !reflect.DeepEqual(result.Categories, expectedCategories) {
+		t.Errorf("expected categories %v, got %v", expectedCategories, result.Categories)
+	}
+	if result.CategoryScores["pii"] != 0.9 || result.CategoryScores["prompt_injection"] != 0.4 {
+		t.Errorf("unexpected scores %v", result.CategoryScores)
+	}
+	if flagged := FlaggedCategories(result); !reflect.DeepEqual(flagged, []string{"pii", "pii/email"}) {
+		t.Errorf("expected pii and pii/email to be flagged, got %v", flagged)
+	}
+
+	// Checks disabled by a zero threshold are not reported
+	cfg.PromptInjectionThreshold = 0
+	if result := ToModerationResult(resp, cfg); len(result.Categories) != 5 {
+		t.Errorf("expected prompt injection not to be reported, got %v", result.Categories)
+	}
+}
@@ -0,0 +1,371 @@
+package transform
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// OllamaChatToOracleCloudRequest converts an Ollama chat request to Oracle Cloud GenAI format.
+// The request goes through the same conversation as OpenAI requests, so it is served by the same
+// API formats and configuration defaults. It returns an error for requests that are not valid.
+func (t *Transformer) OllamaChatToOracleCloudRequest(req types.OllamaChatRequest) (types.OracleCloudRequest, error) {
+	conv, err := fromOllamaChatRequest(req)
+	if err != nil {
+		return types.OracleCloudRequest{}, err
+	}
+	return t.toChatRequest(conv), nil
+}
+
+// fromOllamaChatRequest converts an Ollama chat request to a conversation.
+//
+// Ollama tool calls have no ID, so the calls of assistant messages are numbered, and each tool
+// message answers the first unanswered call of the function it names, or the first unanswered
+// call when it names none.
+func fromOllamaChatRequest(req types.OllamaChatRequest) (conversation, error) {
+	if req.Model == "" {
+		return conversation{}, errors.New("model is required")
+	}
+	if len(req.Messages) == 0 {
+		return conversation{}, errors.New("messages must not be empty")
+	}
+
+	conv := fromOllamaOptions(req.Model, req.Options)
+	conv.stream = req.IsStreaming()
+
+	var calls int
+	var pending []chatToolCall
+	for i, msg := range req.Messages {
+		converted := chatMessage{role: msg.Role}
+		switch msg.Role {
+		case "system", "user", "assistant":
+		case "tool":
+			for j, call := range pending {
+				if msg.ToolName == "" || call.name == msg.ToolName {
+					converted.toolCallID = call.id
+					pending = append(pending[:j], pending[j+1:]...)
+					break
+				}
+			}
+		default:
+			return conversation{}, fmt.Errorf("messages[%d]: unsupported role %q", i, msg.Role)
+		}
+
+		if msg.Content != "" {
+			converted.parts = append(converted.parts, chatPart{text: msg.Content})
+		}
+		for _, image := range msg.Images {
+			converted.parts = append(converted.parts, chatPart{image: imageDataURL(image)})
+		}
+		for _, call := range msg.ToolCalls {
+			calls++
+			converted.toolCalls = append(converted.toolCalls, chatToolCall{
+				id:        "call_" + strconv.Itoa(calls),
+				name:      call.Function.Name,
+				arguments: toolArguments(call.Function.Arguments),
+			})
+		}
+		pending = append(pending, converted.toolCalls...)
+		conv.messages = append(conv.messages, converted)
+	}
+
+	for _, tool := range req.Tools {
+		// Only function tools are supported
+		if tool.Type != "function" {
+			continue
+		}
+		conv.tools = append(conv.tools, chatTool{
+			name:        tool.Function.Name,
+			description: tool.Function.Description,
+			parameters:  tool.Function.Parameters,
+		})
+	}
+	return conv, nil
+}
+
+// OllamaGenerateToOracleCloudRequest converts an Ollama generate request to Oracle Cloud GenAI
+// format, as a single-turn chat holding the system message and the prompt. It returns an error
+// for requests that are not valid.
+func (t *Transformer) OllamaGenerateToOracleCloudRequest(req types.OllamaGenerateRequest) (types.OracleCloudRequest, error) {
+	conv, err := fromOllamaGenerateRequest(req)
+	if err != nil {
+		return types.OracleCloudRequest{}, err
+	}
+	return t.toChatRequest(conv), nil
+}
+
+// fromOllamaGenerateRequest converts an Ollama generate request to a conversation.
+func fromOllamaGenerateRequest(req types.OllamaGenerateRequest) (conversation, error) {
+	if req.Model == "" {
+		return conversation{}, errors.New("model is required")
+	}
+	if req.Prompt == "" {
+		return conversation{}, errors.New("prompt must not be empty")
+	}
+
+	conv := fromOllamaOptions(req.Model, req.Options)
+	conv.stream = req.IsStreaming()
+	if req.System != "" {
+		conv.messages = append(conv.messages, chatMessage{role: "system", parts: []chatPart{{text: req.System}}})
+	}
+
+	prompt := chatMessage{role: "user", parts: []chatPart{{text: req.Prompt}}}
+	for _, image := range req.Images {
+		prompt.parts = append(prompt.parts, chatPart{image: imageDataURL(image)})
+	}
+	conv.messages = append(conv.messages, prompt)
+	return conv, nil
+}
+
+// fromOllamaOptions returns a conversation with the model and the parameters of Ollama options.
+func fromOllamaOptions(model string, options *types.OllamaOptions) conversation {
+	conv := conversation{model: model}
+	if options == nil {
+		return conv
+	}
+	conv.temperature = options.Temperature
+	conv.topP = options.TopP
+	conv.topK = options.TopK
+	conv.frequencyPenalty = options.FrequencyPenalty
+	conv.presencePenalty = options.PresencePenalty
+	conv.stop = options.Stop
+	if options.NumPredict > 0 {
+		conv.maxTokens = options.NumPredict
+	}
+	return conv
+}
+
+// imageDataURL returns the data URL of a base64 encoded image, whose media type is sniffed from
+// its first bytes.
+func imageDataURL(image string) string {
+	prefix := image
+	if len(prefix) > 64 {
+		prefix = prefix[:64]
+	}
+	head, _ := base64.StdEncoding.DecodeString(prefix[:len(prefix)/4*4])
+	return "data:" + http.DetectContentType(head) + ";base64," + image
+}
+
+// OllamaChatRequest returns the chat completion request equivalent to an Ollama chat request. It
+// is used to rate limit, meter and count Ollama requests like chat completions.
+func OllamaChatRequest(req types.OllamaChatRequest) types.ChatCompletionRequest {
+	conv, _ := fromOllamaChatRequest(req)
+	chatReq := conv.chatCompletionRequest()
+	chatReq.Model = req.Model
+	return chatReq
+}
+
+// OllamaGenerateRequest returns the chat completion request equivalent to an Ollama generate
+// request. It is used to rate limit, meter and count Ollama requests like chat completions.
+func OllamaGenerateRequest(req types.OllamaGenerateRequest) types.ChatCompletionRequest {
+	conv, _ := fromOllamaGenerateRequest(req)
+	chatReq := conv.chatCompletionRequest()
+	chatReq.Model = req.Model
+	return chatReq
+}
+
+// DoneReason converts an OCI finish reason to the Ollama vocabulary: "length" for responses
+// stopped by the token limit, "stop" for all others.
+func DoneReason(reason string) string {
+	if FinishReason(reason) == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// toOllamaMetrics converts OCI usage to the Ollama token counts.
+func toOllamaMetrics(usage *types.Usage) types.OllamaMetrics {
+	if usage == nil {
+		return types.OllamaMetrics{}
+	}
+	return types.OllamaMetrics{PromptEvalCount: usage.PromptTokens, EvalCount: usage.CompletionTokens}
+}
+
+// toOllamaToolCalls converts tool calls; arguments that are not a JSON object are replaced by an
+// empty object.
+func toOllamaToolCalls(calls []chatToolCall) []types.OllamaToolCall {
+	var converted []types.OllamaToolCall
+	for _, call := range calls {
+		arguments := json.RawMessage("{}")
+		if strings.HasPrefix(strings.TrimSpace(call.arguments), "{") && json.Valid([]byte(call.arguments)) {
+			arguments = json.RawMessage(call.arguments)
+		}
+		converted = append(converted, types.OllamaToolCall{Function: types.OllamaFunctionCall{Name: call.name, Arguments: arguments}})
+	}
+	return converted
+}
+
+// createdAt returns the current time in the format of Ollama responses.
+func (t *Transformer) createdAt() string {
+	return t.now().UTC().Format(time.RFC3339Nano)
+}
+
+// ToOllamaChatResponse converts an Oracle Cloud GenAI chat response to an Ollama chat response.
+// The model requested by the client is reported when OCI does not echo the model ID.
+func (t *Transformer) ToOllamaChatResponse(oracleResp types.OracleCloudResponse, model string) types.OllamaChatResponse {
+	result := toChatResult(oracleResp, model)
+
+	resp := types.OllamaChatResponse{
+		Model:         result.model,
+		CreatedAt:     t.createdAt(),
+		Message:       types.OllamaMessage{Role: "assistant"},
+		Done:          true,
+		DoneReason:    "stop",
+		OllamaMetrics: toOllamaMetrics(result.usage),
+	}
+	if len(result.choices) > 0 {
+		choice := result.choices[0]
+		resp.Message.Content = choice.text
+		resp.Message.ToolCalls = toOllamaToolCalls(choice.toolCalls)
+		resp.DoneReason = DoneReason(choice.finishReason)
+	}
+	return resp
+}
+
+// OllamaLoadResponse returns the response to a chat request without messages, which Ollama
+// clients send to load a model. There is nothing to load, so the response is immediate.
+func (t *Transformer) OllamaLoadResponse(model string) types.OllamaChatResponse {
+	return types.OllamaChatResponse{
+		Model:      model,
+		CreatedAt:  t.createdAt(),
+		Message:    types.OllamaMessage{Role: "assistant"},
+		Done:       true,
+		DoneReason: "load",
+	}
+}
+
+// ToOllamaGenerateResponse converts an Ollama chat response, or a line of its stream, to the
+// equivalent generate response.
+func ToOllamaGenerateResponse(resp types.OllamaChatResponse) types.OllamaGenerateResponse {
+	return types.OllamaGenerateResponse{
+		Model:         resp.Model,
+		CreatedAt:     resp.CreatedAt,
+		Response:      resp.Message.Content,
+		Done:          resp.Done,
+		DoneReason:    resp.DoneReason,
+		OllamaMetrics: resp.OllamaMetrics,
+	}
+}
+
+// OllamaEmbedToEmbedTextRequest converts an Ollama embed request to an OCI embedText request.
+// Inputs longer than the context of the model are truncated at the end unless the request
+// disables truncation.
+func (t *Transformer) OllamaEmbedToEmbedTextRequest(req types.OllamaEmbedRequest) (types.EmbedTextRequest, error) {
+	return t.toEmbedTextRequest(req.Model, req.Input, req.Truncate == nil || *req.Truncate)
+}
+
+// ToOllamaEmbedResponse converts an OCI embedText response to an Ollama embed response.
+func ToOllamaEmbedResponse(resp types.EmbedTextResponse, model string) types.OllamaEmbedResponse {
+	embedResp := types.OllamaEmbedResponse{Model: model, Embeddings: resp.Embeddings}
+	if embedResp.Embeddings == nil {
+		embedResp.Embeddings = [][]float64{}
+	}
+	if resp.Usage != nil {
+		embedResp.PromptEvalCount = resp.Usage.PromptTokens
+	}
+	return embedResp
+}
+
+// OllamaTags returns the configured models in the format of the Ollama /api/tags endpoint. The
+// family of a model is the vendor prefix of its OCI ID, and its digest is derived from its name.
+func (t *Transformer) OllamaTags() types.OllamaTagsResponse {
+	resp := types.OllamaTagsResponse{Models: []types.OllamaModel{}}
+	modified := t.createdAt()
+	for _, model := range t.config.Ollama.Models {
+		family := model
+		if i := strings.IndexByte(model, '.'); i > 0 {
+			family = model[:i]
+		}
+		digest := sha256.Sum256([]byte(model))
+		resp.Models = append(resp.Models, types.OllamaModel{
+			Name:       model,
+			Model:      model,
+			ModifiedAt: modified,
+			Digest:     hex.EncodeToString(digest[:]),
+			Details: types.OllamaModelDetails{
+				Format:   "oci",
+				Family:   family,
+				Families: []string{family},
+			},
+		})
+	}
+	return resp
+}
+
+// OllamaStream converts the events of a streamed OCI chat response into the lines of an Ollama
+// chat stream. Text is streamed as it is generated; tool calls are sent whole once the model has
+// finished them, as Ollama does. A new stream must be created for every response.
+type OllamaStream struct {
+	*streamDecoder
+	createdAt    string
+	finishReason string // Finish reason reported by OCI
+}
+
+// NewOllamaStream creates the stream translating a single streamed response.
+func (t *Transformer) NewOllamaStream(model string) *OllamaStream {
+	return &OllamaStream{
+		streamDecoder: &streamDecoder{model: model},
+		createdAt:     t.createdAt(),
+	}
+}
+
+// line returns a line of the stream carrying a message increment.
+func (s *OllamaStream) line(message types.OllamaMessage) types.OllamaChatResponse {
+	message.Role = "assistant"
+	return types.OllamaChatResponse{Model: s.model, CreatedAt: s.createdAt, Message: message}
+}
+
+// Event translates the data of one OCI event into zero or more lines.
+func (s *OllamaStream) Event(data []byte) ([]types.OllamaChatResponse, error) {
+	deltas, err := s.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []types.OllamaChatResponse
+	for _, delta := range deltas {
+		if delta.finishReason != "" {
+			s.finishReason = delta.finishReason
+			if len(s.toolCalls) > 0 {
+				var calls []chatToolCall
+				for _, call := range s.toolCalls {
+					calls = append(calls, chatToolCall{id: call.ID, name: call.Name, arguments: call.Arguments})
+				}
+				lines = append(lines, s.line(types.OllamaMessage{ToolCalls: toOllamaToolCalls(calls)}))
+			}
+			continue
+		}
+		if delta.text != "" {
+			lines = append(lines, s.line(types.OllamaMessage{Content: delta.text}))
+		}
+	}
+	return lines, nil
+}
+
+// Finish returns the final line of the stream, carrying the done reason and the token counts.
+func (s *OllamaStream) Finish() types.OllamaChatResponse {
+	line := s.line(types.OllamaMessage{})
+	line.Done = true
+	line.DoneReason = s.DoneReason()
+	line.OllamaMetrics = toOllamaMetrics(s.usage)
+	return line
+}
+
+// DoneReason returns the Ollama done reason, "stop" when the stream reported no finish reason.
+func (s *OllamaStream) DoneReason() string {
+	return DoneReason(s.finishReason)
+}
+
+// FinishReason returns the OpenAI finish reason, once the stream has reported one.
+func (s *OllamaStream) FinishReason() string {
+	return FinishReason(s.finishReason)
+}
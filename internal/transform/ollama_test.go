@@ -0,0 +1,134 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestFromOllamaChatRequest_ToolCalls(t *testing.T) {
+	var req types.OllamaChatRequest
+	if err := json.Unmarshal([]byte(`{
+		"model": "m",
+		"messages": [
+			{"role": "user", "content": "Weather in Paris and Rome?"},
+			{"role": "assistant", "tool_calls": [
+				{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}},
+				{"function": {"name": "get_time", "arguments": {}}},
+				{"function": {"name": "get_weather", "arguments": {"city": "Rome"}}}
+			]},
+			{"role": "tool", "tool_name": "get_time", "content": "noon"},
+			{"role": "tool", "tool_name": "get_weather", "content": "Sunny"},
+			{"role": "tool", "content": "Rainy"}
+		]
+	}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	conv, err := fromOllamaChatRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := conv.messages[1].toolCalls
+	if len(calls) != 3 || calls[0].arguments != `{"city":"Paris"}` {
+		t.Fatalf("expected the tool calls with their arguments, got %+v", calls)
+	}
+	expected := []string{calls[1].id, calls[0].id, calls[2].id}
+	for i, msg := range conv.messages[2:] {
+		if msg.toolCallID != expected[i] {
+			t.Errorf("tool message %d: expected to answer %s, got %q", i, expected[i], msg.toolCallID)
+		}
+	}
+}
+
+func TestFromOllamaGenerateRequest(t *testing.T) {
+	temperature := 0.3
+	req := types.OllamaGenerateRequest{
+		Model:   "m",
+		System:  "Be brief.",
+		Prompt:  "Describe this",
+		Images:  []string{"iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="},
+		Options: &types.OllamaOptions{Temperature: &temperature, NumPredict: 32, Stop: []string{"\n"}},
+	}
+
+	conv, err := fromOllamaGenerateRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conv.stream || conv.maxTokens != 32 || conv.temperature != &temperature || len(conv.stop) != 1 {
+		t.Errorf("expected the options to be converted, got %+v", conv)
+	}
+	if len(conv.messages) != 2 || conv.messages[0].role != "system" || conv.messages[1].parts[0].text != "Describe this" {
+		t.Fatalf("expected the system message and the prompt, got %+v", conv.messages)
+	}
+	if image := conv.messages[1].parts[1].image; !strings.HasPrefix(image, "data:image/png;base64,iVBOR") {
+		t.Errorf("expected a PNG data URL, got %s", image)
+	}
+}
+
+func TestOllamaToOracleCloudRequest_Invalid(t *testing.T) {
+	transformer := New(config.New())
+
+	chats := map[string]types.OllamaChatRequest{
+		"missing model":    {Messages: []types.OllamaMessage{{Role: "user", Content: "Hi"}}},
+		"missing messages": {Model: "m"},
+		"unknown role":     {Model: "m", Messages: []types.OllamaMessage{{Role: "developer", Content: "Hi"}}},
+	}
+	for name, req := range chats {
+		if _, err := transformer.OllamaChatToOracleCloudRequest(req); err == nil {
+			t.Errorf("chat %s: expected an error", name)
+		}
+	}
+	if _, err := transformer.OllamaGenerateToOracleCloudRequest(types.OllamaGenerateRequest{Model: "m"}); err == nil {
+		t.Error("generate without prompt: expected an error")
+	}
+}
+
+func TestOllamaEmbedToEmbedTextRequest(t *testing.T) {
+	transformer := New(config.New())
+	truncate := false
+
+	req, err := transformer.OllamaEmbedToEmbedTextRequest(types.OllamaEmbedRequest{Model: "m", Input: types.EmbedInput{"a"}, Truncate: &truncate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Truncate != "NONE" || req.ServingMode.ModelID != "m" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if _, err := transformer.OllamaEmbedToEmbedTextRequest(types.OllamaEmbedRequest{Model: "m"}); err == nil {
+		t.Error("expected an error without input")
+	}
+}
+
+func TestOllamaStream_ToolCalls(t *testing.T) {
+	transformer := New(config.New())
+	stream := transformer.NewOllamaStream("m")
+
+	var lines []types.OllamaChatResponse
+	for _, event := range []string{
+		`{"apiFormat":"GENERIC","index":0,"message":{"role":"ASSISTANT","toolCalls":[{"type":"FUNCTION","id":"c1","name":"get_weather","arguments":"{\"city\":"}]}}`,
+		`{"apiFormat":"GENERIC","index":0,"message":{"role":"ASSISTANT","toolCalls":[{"type":"FUNCTION","arguments":"\"Paris\"}"}]}}`,
+		`{"apiFormat":"GENERIC","index":0,"finishReason":"tool_calls"}`,
+	} {
+		events, err := stream.Event([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, events...)
+	}
+	lines = append(lines, stream.Finish())
+
+	if len(lines) != 2 {
+		t.Fatalf("expected the tool calls to be sent whole, got %+v", lines)
+	}
+	calls := lines[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].Function.Name != "get_weather" || string(calls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+	if !lines[1].Done || lines[1].DoneReason != "stop" || stream.FinishReason() != "tool_calls" {
+		t.Errorf("unexpected final line: %+v", lines[1])
+	}
+}
@@ -0,0 +1,104 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// policyState holds the parameter policies a transformer applies and their outcome.
+type policyState struct {
+	policies  []config.ParameterPolicy
+	overrides map[string]float64 // Parameters changed by the policies, by name
+	err       error              // Set when the client set a forbidden parameter
+}
+
+// WithPolicies returns a copy of the transformer applying parameter policies to the chat requests
+// it converts, for a single request: the policies matching the model of the request are applied
+// in order. PolicyOverrides reports their outcome.
+func (t *Transformer) WithPolicies(policies []config.ParameterPolicy) *Transformer {
+	if len(policies) == 0 {
+		return t
+	}
+	c := *t
+	c.policy = &policyState{policies: policies}
+	return &c
+}
+
+// PolicyOverrides returns the parameters changed by the policies of the transformer, as
+// name=value in a stable order, or an error when the request set a forbidden parameter.
+func (t *Transformer) PolicyOverrides() ([]string, error) {
+	if t.policy == nil {
+		return nil, nil
+	}
+	if t.policy.err != nil {
+		return nil, t.policy.err
+	}
+	overrides := make([]string, 0, len(t.policy.overrides))
+	for name, value := range t.policy.overrides {
+		overrides = append(overrides, name+"="+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	sort.Strings(overrides)
+	return overrides, nil
+}
+
+// applyPolicies applies the policies matching model to the parameters of a chat request, by
+// name; set reports the parameters set by the client.
+func (t *Transformer) applyPolicies(model string, values map[string]float64, set map[string]bool) {
+	if t.policy == nil {
+		return
+	}
+	apply := func(name string, value float64) {
+		if values[name] == value {
+			return
+		}
+		values[name] = value
+		if t.policy.overrides == nil {
+			t.policy.overrides = map[string]float64{}
+		}
+		t.policy.overrides[name] = value
+	}
+
+	for _, policy := range t.policy.policies {
+		if !matchesModel(policy.Models, model) {
+			continue
+		}
+		for _, name := range policy.Forbid {
+			if set[name] && t.policy.err == nil {
+				t.policy.err = fmt.Errorf("the parameter %s is not allowed by the policy %s", name, policy.Name)
+			}
+		}
+		for name, value := range policy.Defaults {
+			if !set[name] {
+				apply(name, value)
+			}
+		}
+		for name, min := range policy.Min {
+			if values[name] < min {
+				apply(name, min)
+			}
+		}
+		for name, max := range policy.Max {
+			if values[name] > max {
+				apply(name, max)
+			}
+		}
+	}
+}
+
+// matchesModel reports whether a model is one of models, where a trailing * matches a prefix.
+// Every model matches an empty list.
+func matchesModel(models []string, model string) bool {
+	if len(models) == 0 {
+		return true
+	}
+	for _, m := range models {
+		if m == model || (strings.HasSuffix(m, "*") && strings.HasPrefix(model, strings.TrimSuffix(m, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package transform
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestWithPolicies(t *testing.T) {
+	policies := []config.ParameterPolicy{
+		{Name: "interns", Max: map[string]float64{"max_tokens": 2048}},
+		{Name: "cohere", Models: []string{"cohere.*"}, Defaults: map[string]float64{"temperature": 0.3}, Min: map[string]float64{"top_p": 0.9}},
+		{Name: "llama", Models: []string{"meta.llama-3.1-70b-instruct"}, Forbid: []string{"temperature"}},
+	}
+	transformer := New(config.New()).WithPolicies(policies)
+
+	var openAIReq types.ChatCompletionRequest
+	body := `{"model":"cohere.command-r-plus","messages":[{"role":"user","content":"hi"}],"max_tokens":4096,"top_p":0}`
+	if err := json.Unmarshal([]byte(body), &openAIReq); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	chat := transformer.ToOracleCloudRequest(openAIReq).ChatRequest
+	if chat.MaxTokens != 2048 || chat.Temperature != 0.3 || chat.TopP != 0.9 {
+		t.Errorf("expected max_tokens 2048, temperature 0.3 and top_p 0.9, got %+v", chat)
+	}
+	overrides, err := transformer.PolicyOverrides()
+	if err != nil || !reflect.DeepEqual(overrides, []string{"max_tokens=2048", "temperature=0.3", "top_p=0.9"}) {
+		t.Errorf("unexpected overrides %v (%v)", overrides, err)
+	}
+
+	// Explicit zeros are set by the client, so they are not replaced by defaults
+	transformer = New(config.New()).WithPolicies(policies)
+	openAIReq = types.ChatCompletionRequest{}
+	body = `{"model":"cohere.command-r-plus","messages":[{"role":"user","content":"hi"}],"temperature":0}`
+	if err := json.Unmarshal([]byte(body), &openAIReq); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if chat := transformer.ToOracleCloudRequest(openAIReq).ChatRequest; chat.Temperature != 0 {
+		t.Errorf("expected explicit temperature 0, got %f", chat.Temperature)
+	}
+
+	transformer = New(config.New()).WithPolicies(policies)
+	openAIReq = types.ChatCompletionRequest{}
+	body = `{"model":"meta.llama-3.1-70b-instruct","messages":[{"role":"user","content":"hi"}],"temperature":0}`
+	if err := json.Unmarshal([]byte(body), &openAIReq); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	transformer.ToOracleCloudRequest(openAIReq)
+	if _, err := transformer.PolicyOverrides(); err == nil {
+		t.Error("expected an error for the forbidden temperature")
+	}
+}
+
+func TestWithPolicies_None(t *testing.T) {
+	transformer := New(config.New())
+	if transformer.WithPolicies(nil) != transformer {
+		t.Error("expected the transformer itself when no policy applies")
+	}
+	if overrides, err := transformer.PolicyOverrides(); overrides != nil || err != nil {
+		t.Errorf("expected no overrides, got %v (%v)", overrides, err)
+	}
+}
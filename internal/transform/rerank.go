@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// ToRerankTextRequest converts a rerank request to an OCI rerankText request.
+// Documents may be strings or objects with a text field, as accepted by the Cohere and Jina APIs.
+func (t *Transformer) ToRerankTextRequest(req types.RerankRequest) (types.RerankTextRequest, error) {
+	rerankReq := types.RerankTextRequest{
+		CompartmentID: t.config.CompartmentID,
+		ServingMode: types.ServingMode{
+			ModelID:     req.Model,
+			ServingType: "ON_DEMAND",
+		},
+		Input:  req.Query,
+		TopN:   req.TopN,
+		IsEcho: req.ReturnDocuments,
+	}
+
+	if req.Model == "" {
+		return rerankReq, errors.New("model is required")
+	}
+	if req.Query == "" {
+		return rerankReq, errors.New("query is required")
+	}
+	if len(req.Documents) == 0 {
+		return rerankReq, errors.New("documents are required")
+	}
+	if req.TopN != nil && *req.TopN < 1 {
+		return rerankReq, errors.New("top_n must be positive")
+	}
+
+	for i, document := range req.Documents {
+		text, err := rerankDocumentText(document)
+		if err != nil {
+			return rerankReq, fmt.Errorf("documents[%d]: %w", i, err)
+		}
+		rerankReq.Documents = append(rerankReq.Documents, text)
+	}
+
+	return rerankReq, nil
+}
+
+// rerankDocumentText returns the text of a rerank document, either a string or an object with a text field.
+func rerankDocumentText(document json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(document, &text); err == nil {
+		return text, nil
+	}
+
+	var object types.RerankDocument
+	if err := json.Unmarshal(document, &object); err != nil || object.Text == "" {
+		return "", errors.New("must be a string or an object with a text field")
+	}
+	return object.Text, nil
+}
+
+// ToRerankResponse converts an OCI rerankText response to a rerank response, most relevant
+// documents first.
+func ToRerankResponse(resp types.RerankTextResponse, model string) types.RerankResponse {
+	results := make([]types.RerankResult, 0, len(resp.DocumentRanks))
+	for _, rank := range resp.DocumentRanks {
+		results = append(results, types.RerankResult{
+			Index:          rank.Index,
+			RelevanceScore: rank.RelevanceScore,
+			Document:       rank.Document,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].RelevanceScore > results[j].RelevanceScore
+	})
+
+	return types.RerankResponse{ID: resp.ID, Model: model, Results: results}
+}
@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestToRerankTextRequest(t *testing.T) {
+	cfg := config.New()
+	cfg.CompartmentID = "test-compartment-id"
+	transformer := New(cfg)
+
+	topN := 2
+	req := types.RerankRequest{
+		Model: "cohere.rerank-v3.5",
+		Query: "penguins",
+		Documents: []json.RawMessage{
+			json.RawMessage(`"emperor penguins"`),
+			json.RawMessage(`{"text":"polar bears","title":"ignored"}`),
+		},
+		TopN:            &topN,
+		ReturnDocuments: true,
+	}
+
+	result, err := transformer.ToRerankTextRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CompartmentID != "test-compartment-id" || result.ServingMode.ModelID != "cohere.rerank-v3.5" || result.ServingMode.ServingType != "ON_DEMAND" {
+		t.Errorf("unexpected target %s %+v", result.CompartmentID, result.ServingMode)
+	}
+	if result.Input != "penguins" || result.TopN == nil || *result.TopN != 2 || !result.IsEcho {
+		t.Errorf("unexpected parameters %+v", result)
+	}
+	if len(result.Documents) != 2 || result.Documents[0] != "emperor penguins" || result.Documents[1] != "polar bears" {
+		t.Errorf("expected the document texts, got %q", result.Documents)
+	}
+}
+
+func TestToRerankTextRequest_Invalid(t *testing.T) {
+	transformer := New(config.New())
+	zero := 0
+
+	tests := map[string]types.RerankRequest{
+		"missing model":     {Query: "q", Documents: []json.RawMessage{json.RawMessage(`"d"`)}},
+		"missing query":     {Model: "m", Documents: []json.RawMessage{json.RawMessage(`"d"`)}},
+		"missing documents": {Model: "m", Query: "q"},
+		"invalid document":  {Model: "m", Query: "q", Documents: []json.RawMessage{json.RawMessage(`{"title":"t"}`)}},
+		"zero top_n":        {Model: "m", Query: "q", Documents: []json.RawMessage{json.RawMessage(`"d"`)}, TopN: &zero},
+	}
+	for name, req := range tests {
+		if _, err := transformer.ToRerankTextRequest(req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestToRerankResponse(t *testing.T) {
+	resp := types.RerankTextResponse{
+		ID:      "rerank-id",
+		ModelID: "cohere.rerank-v3.5",
+		DocumentRanks: []types.DocumentRank{
+			{Index: 1, RelevanceScore: 0.2},
+			{Index: 0, RelevanceScore: 0.9, Document: &types.RerankDocument{Text: "emperor penguins"}},
+			{Index: 2, RelevanceScore: 0.2},
+		},
+	}
+
+	result := ToRerankResponse(resp, "rerank")
+	if result.ID != "rerank-id" || result.Model != "rerank" {
+		t.Errorf("unexpected response %+v", result)
+	}
+	order := []int{0, 1, 2}
+	for i, r := range result.Results {
+		if r.Index != order[i] {
+			t.Errorf("expected result %d to be document %d, got %d", i, order[i], r.Index)
+		}
+	}
+	if result.Results[0].Document == nil || result.Results[0].Document.Text != "emperor penguins" {
+		t.Errorf("expected the echoed document, got %+v", result.Results[0].Document)
+	}
+}
@@ -0,0 +1,264 @@
+package transform
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// finishReasons maps Cohere finish reasons reported by OCI to their OpenAI equivalents.
+var finishReasons = map[string]string{
+	"COMPLETE":      "stop",
+	"USER_CANCEL":   "stop",
+	"ERROR":         "stop",
+	"MAX_TOKENS":    "length",
+	"ERROR_LIMIT":   "length",
+	"ERROR_TOXIC":   "content_filter",
+	"STOP_SEQUENCE": "stop",
+}
+
+// FinishReason converts an OCI finish reason to the OpenAI vocabulary.
+// Unknown reasons are passed through unchanged.
+func FinishReason(reason string) string {
+	if mapped, ok := finishReasons[reason]; ok {
+		return mapped
+	}
+	return reason
+}
+
+// toCompletionUsage converts OCI usage to the OpenAI format.
+func toCompletionUsage(usage *types.Usage) *types.CompletionUsage {
+	if usage == nil {
+		return nil
+	}
+	return &types.CompletionUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// ToOpenAIResponse converts an Oracle Cloud GenAI chat response to an OpenAI ChatCompletion response.
+// The model requested by the client is reported when OCI does not echo the model ID.
+func (t *Transformer) ToOpenAIResponse(oracleResp types.OracleCloudResponse, model string) types.ChatCompletionResponse {
+	result := toChatResult(oracleResp, model)
+
+	choices := make([]types.ChatCompletionChoice, 0, len(result.choices))
+	for _, choice := range result.choices {
+		message := types.ChatCompletionMessage{Role: "assistant", Content: choice.text, OCI: choice.grounding}
+		for _, call := range choice.toolCalls {
+			message.ToolCalls = append(message.ToolCalls, types.ToolCall{
+				ID:       call.id,
+				Type:     "function",
+				Function: types.FunctionCall{Name: call.name, Arguments: call.arguments},
+			})
+		}
+		choices = append(choices, types.ChatCompletionChoice{
+			Index:        choice.index,
+			Message:      message,
+			FinishReason: FinishReason(choice.finishReason),
+		})
+	}
+
+	return types.ChatCompletionResponse{
+		ID:      t.newID(),
+		Object:  "chat.completion",
+		Created: t.now().Unix(),
+		Model:   result.model,
+		Choices: choices,
+		Usage:   toCompletionUsage(result.usage),
+	}
+}
+
+// cohereExtension returns the grounding information of a COHERE response, or nil if there is none.
+func cohereExtension(resp types.ChatResponse) *types.OCIExtension {
+	ext := &types.OCIExtension{
+		Citations:        toOCICitations(resp.Citations),
+		Documents:        resp.Documents,
+		SearchQueries:    toSearchQueryTexts(resp.SearchQueries),
+		IsSearchRequired: resp.IsSearchRequired,
+	}
+	if ext.Citations == nil && ext.Documents == nil && ext.SearchQueries == nil && ext.IsSearchRequired == nil {
+		return nil
+	}
+	return ext
+}
+
+// toOCICitations converts COHERE citations to the OpenAI extension format.
+func toOCICitations(citations []types.Citation) []types.OCICitation {
+	var converted []types.OCICitation
+	for _, c := range citations {
+		converted = append(converted, types.OCICitation{Start: c.Start, End: c.End, Text: c.Text, DocumentIDs: c.DocumentIDs})
+	}
+	return converted
+}
+
+// toSearchQueryTexts returns the text of COHERE search queries.
+func toSearchQueryTexts(queries []types.SearchQuery) []string {
+	var texts []string
+	for _, q := range queries {
+		texts = append(texts, q.Text)
+	}
+	return texts
+}
+
+// genericText returns the text parts of a GENERIC message.
+func genericText(msg types.GenericMessage) string {
+	var text strings.Builder
+	for _, content := range msg.Content {
+		if content.Type == "TEXT" {
+			text.WriteString(content.Text)
+		}
+	}
+	return text.String()
+}
+
+// ociError is the error document returned by OCI.
+type ociError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorCode returns the OCI error code of an error response body, or "Unknown".
+func ErrorCode(body []byte) string {
+	var upstream ociError
+	if err := json.Unmarshal(body, &upstream); err != nil || upstream.Code == "" {
+		return "Unknown"
+	}
+	return upstream.Code
+}
+
+// ToOpenAIError converts an OCI error response to the OpenAI error format.
+func ToOpenAIError(status int, body []byte) types.ErrorResponse {
+	var upstream ociError
+	_ = json.Unmarshal(body, &upstream)
+
+	message := upstream.Message
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	var errType string
+	switch {
+	case status == http.StatusUnauthorized:
+		errType = "authentication_error"
+	case status == http.StatusForbidden:
+		errType = "permission_error"
+	case status == http.StatusNotFound:
+		errType = "not_found_error"
+	case status == http.StatusTooManyRequests:
+		errType = "rate_limit_error"
+	case status >= http.StatusInternalServerError:
+		errType = "server_error"
+	default:
+		errType = "invalid_request_error"
+	}
+
+	return types.ErrorResponse{
+		Error: types.APIError{Message: message, Type: errType, Code: upstream.Code},
+	}
+}
+
+// StreamTranslator converts the events of a streamed OCI chat response into OpenAI chunks.
+// A new translator must be created for every response.
+type StreamTranslator struct {
+	*streamDecoder
+	id           string
+	created      int64
+	includeUsage bool
+	started      bool
+	finishReason string
+}
+
+// NewStreamTranslator creates a translator for a single streamed response.
+// When includeUsage is set, a final chunk carrying the token usage is emitted by Finish.
+func (t *Transformer) NewStreamTranslator(model string, includeUsage bool) *StreamTranslator {
+	return &StreamTranslator{
+		streamDecoder: &streamDecoder{model: model},
+		id:            t.newID(),
+		created:       t.now().Unix(),
+		includeUsage:  includeUsage,
+	}
+}
+
+func (s *StreamTranslator) chunk(delta types.ChatCompletionDelta, finishReason *string) types.ChatCompletionChunk {
+	return types.ChatCompletionChunk{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []types.ChatCompletionChunkChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+	}
+}
+
+// Event translates the data of one OCI event into zero or more OpenAI chunks. The first event
+// opens the stream with the assistant role.
+func (s *StreamTranslator) Event(data []byte) ([]types.ChatCompletionChunk, error) {
+	deltas, err := s.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []types.ChatCompletionChunk
+	if !s.started {
+		s.started = true
+		chunks = append(chunks, s.chunk(types.ChatCompletionDelta{Role: "assistant"}, nil))
+	}
+
+	for _, delta := range deltas {
+		switch {
+		case delta.finishReason != "":
+			s.finishReason = FinishReason(delta.finishReason)
+			reason := s.finishReason
+			chunks = append(chunks, s.chunk(types.ChatCompletionDelta{}, &reason))
+		case delta.grounding != nil:
+			chunks = append(chunks, s.chunk(types.ChatCompletionDelta{OCI: delta.grounding}, nil))
+		default:
+			chunks = append(chunks, s.chunk(toOpenAIDelta(delta), nil))
+		}
+	}
+	return chunks, nil
+}
+
+// toOpenAIDelta converts the generated content of a delta to an OpenAI delta.
+func toOpenAIDelta(delta chatDelta) types.ChatCompletionDelta {
+	converted := types.ChatCompletionDelta{Content: delta.text}
+	for _, call := range delta.toolCalls {
+		index := call.index
+		toolCall := types.ToolCall{Index: &index, Function: types.FunctionCall{Arguments: call.arguments}}
+		if call.start {
+			toolCall.ID = call.id
+			toolCall.Type = "function"
+			toolCall.Function.Name = call.name
+		}
+		converted.ToolCalls = append(converted.ToolCalls, toolCall)
+	}
+	return converted
+}
+
+// Finish returns the chunks that close the stream: the usage chunk when requested and reported.
+func (s *StreamTranslator) Finish() []types.ChatCompletionChunk {
+	if !s.includeUsage || s.usage == nil {
+		return nil
+	}
+	return []types.ChatCompletionChunk{{
+		ID:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []types.ChatCompletionChunkChoice{},
+		Usage:   toCompletionUsage(s.usage),
+	}}
+}
+
+// ID returns the completion ID shared by every chunk.
+func (s *StreamTranslator) ID() string {
+	return s.id
+}
+
+// FinishReason returns the OpenAI finish reason, once the stream has reported one.
+func (s *StreamTranslator) FinishReason() string {
+	return s.finishReason
+}
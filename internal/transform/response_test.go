@@ -0,0 +1,140 @@
+package transform
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func newTestTransformer() *Transformer {
+	t := New(config.New())
+	t.newID = func() string { return "chatcmpl-test" }
+	t.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return t
+}
+
+func TestToOpenAIResponse(t *testing.T) {
+	transformer := newTestTransformer()
+
+	oracleResp := types.OracleCloudResponse{
+		ModelID: "cohere.command-r-plus",
+		ChatResponse: types.ChatResponse{
+			APIFormat:    "COHERE",
+			Text:         "Hello!",
+			FinishReason: "MAX_TOKENS",
+			Usage:        &types.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7},
+		},
+	}
+
+	resp := transformer.ToOpenAIResponse(oracleResp, "requested-model")
+
+	if resp.ID != "chatcmpl-test" || resp.Object != "chat.completion" || resp.Created != 1700000000 {
+		t.Errorf("unexpected envelope %+v", resp)
+	}
+	if resp.Model != "cohere.command-r-plus" {
+		t.Errorf("expected model from OCI response, got %s", resp.Model)
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("expected 1 choice, got %d", len(resp.Choices))
+	}
+	if resp.Choices[0].Message.Role != "assistant" || resp.Choices[0].Message.Content != "Hello!" {
+		t.Errorf("unexpected message %+v", resp.Choices[0].Message)
+	}
+	if resp.Choices[0].FinishReason != "length" {
+		t.Errorf("expected finish reason length, got %s", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 7 {
+		t.Errorf("expected usage to be translated, got %+v", resp.Usage)
+	}
+}
+
+func TestToOpenAIError(t *testing.T) {
+	resp := ToOpenAIError(http.StatusNotFound, []byte(`{"code":"NotAuthorizedOrNotFound","message":"model not found"}`))
+	if resp.Error.Type != "not_found_error" || resp.Error.Code != "NotAuthorizedOrNotFound" || resp.Error.Message != "model not found" {
+		t.Errorf("unexpected error %+v", resp.Error)
+	}
+
+	resp = ToOpenAIError(http.StatusBadGateway, []byte("not json"))
+	if resp.Error.Type != "server_error" || resp.Error.Message != "Bad Gateway" {
+		t.Errorf("unexpected error %+v", resp.Error)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	if code := ErrorCode([]byte(`{"code":"TooManyRequests","message":"slow down"}`)); code != "TooManyRequests" {
+		t.Errorf("expected the OCI error code, got %q", code)
+	}
+	if code := ErrorCode([]byte("not json")); code != "Unknown" {
+		t.Errorf("expected Unknown, got %q", code)
+	}
+}
+
+func TestStreamTranslator(t *testing.T) {
+	stream := newTestTransformer().NewStreamTranslator("cohere.command-r-plus", true)
+
+	var chunks []types.ChatCompletionChunk
+	for _, event := range []string{
+		`{"apiFormat":"COHERE","text":"Hel"}`,
+		`{"apiFormat":"COHERE","text":"lo"}`,
+		`{"apiFormat":"COHERE","text":"Hello","finishReason":"COMPLETE","usage":{"promptTokens":3,"completionTokens":2,"totalTokens":5}}`,
+	} {
+		translated, err := stream.Event([]byte(event))
+		if err != nil {
+			t.Fatalf("failed to translate event: %v", err)
+		}
+		chunks = append(chunks, translated...)
+	}
+	chunks = append(chunks, stream.Finish()...)
+
+	if len(chunks) != 5 {
+		t.Fatalf("expected 5 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Error("expected first chunk to carry the assistant role")
+	}
+	if chunks[1].Choices[0].Delta.Content != "Hel" || chunks[2].Choices[0].Delta.Content != "lo" {
+		t.Error("expected text deltas to be forwarded")
+	}
+	if reason := chunks[3].Choices[0].FinishReason; reason == nil || *reason != "stop" || chunks[3].Choices[0].Delta.Content != "" {
+		t.Error("expected closing chunk with finish reason and no repeated text")
+	}
+	if len(chunks[4].Choices) != 0 || chunks[4].Usage == nil || chunks[4].Usage.TotalTokens != 5 {
+		t.Errorf("expected usage chunk, got %+v", chunks[4])
+	}
+	for _, chunk := range chunks {
+		if chunk.ID != "chatcmpl-test" || chunk.Object != "chat.completion.chunk" {
+			t.Errorf("unexpected chunk envelope %+v", chunk)
+		}
+	}
+	if stream.FinishReason() != "stop" {
+		t.Errorf("expected finish reason stop, got %s", stream.FinishReason())
+	}
+
+	assembled := stream.Response().ChatResponse
+	if assembled.Text != "Hello" || assembled.FinishReason != "COMPLETE" || assembled.APIFormat != "COHERE" || assembled.Usage.TotalTokens != 5 {
+		t.Errorf("unexpected assembled response %+v", assembled)
+	}
+}
+
+func TestStreamTranslator_UsageNotRequested(t *testing.T) {
+	stream := newTestTransformer().NewStreamTranslator("cohere.command-r-plus", false)
+
+	if _, err := stream.Event([]byte(`{"finishReason":"COMPLETE","usage":{"totalTokens":5}}`)); err != nil {
+		t.Fatalf("failed to translate event: %v", err)
+	}
+	if chunks := stream.Finish(); len(chunks) != 0 {
+		t.Errorf("expected no usage chunk, got %d", len(chunks))
+	}
+}
+
+func TestToOracleCloudRequest_Stream(t *testing.T) {
+	transformer := New(config.New())
+
+	result := transformer.ToOracleCloudRequest(types.ChatCompletionRequest{Model: "m", Stream: true})
+	if !result.ChatRequest.IsStream || !result.ChatRequest.StreamOptions.IsIncludeUsage {
+		t.Error("expected streaming with usage to be requested")
+	}
+}
@@ -0,0 +1,443 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// ResponsesToOracleCloudRequest converts an OpenAI Responses request to Oracle Cloud GenAI format.
+// history holds the items of the stored conversation continued through previous_response_id;
+// they precede the input of the request. The request goes through the same conversation as chat
+// completions, so it is served by the same API formats and configuration defaults. It returns an
+// error for requests that are not valid.
+func (t *Transformer) ResponsesToOracleCloudRequest(req types.ResponsesRequest, history []types.ResponseItem) (types.OracleCloudRequest, error) {
+	conv, err := fromResponsesRequest(req, history)
+	if err != nil {
+		return types.OracleCloudRequest{}, err
+	}
+	return t.toChatRequest(conv), nil
+}
+
+// fromResponsesRequest converts a Responses request and the history it continues to a
+// conversation. The instructions become a system message, function_call items become tool calls
+// of the preceding assistant message and function_call_output items become tool messages. Other
+// item types are dropped.
+func fromResponsesRequest(req types.ResponsesRequest, history []types.ResponseItem) (conversation, error) {
+	if req.Model == "" {
+		return conversation{}, errors.New("model is required")
+	}
+	if len(req.Input) == 0 {
+		return conversation{}, errors.New("input must not be empty")
+	}
+
+	conv := conversation{
+		model:       req.Model,
+		maxTokens:   req.MaxOutputTokens,
+		temperature: req.Temperature,
+		topP:        req.TopP,
+		stream:      req.Stream,
+		toolChoice:  fromResponsesToolChoice(req.ToolChoice),
+	}
+	if req.Instructions != "" {
+		conv.messages = append(conv.messages, chatMessage{role: "system", parts: []chatPart{{text: req.Instructions}}})
+	}
+
+	items := make([]types.ResponseItem, 0, len(history)+len(req.Input))
+	items = append(append(items, history...), req.Input...)
+	for i, item := range items {
+		switch item.Type {
+		case "", "message":
+			msg, err := fromResponseMessage(item)
+			if err != nil {
+				return conversation{}, fmt.Errorf("input.%d: %w", i-len(history), err)
+			}
+			conv.messages = append(conv.messages, msg)
+		case "function_call":
+			call := chatToolCall{id: item.CallID, name: item.Name, arguments: item.Arguments}
+			if last := len(conv.messages) - 1; last >= 0 && conv.messages[last].role == "assistant" {
+				conv.messages[last].toolCalls = append(conv.messages[last].toolCalls, call)
+				continue
+			}
+			conv.messages = append(conv.messages, chatMessage{role: "assistant", toolCalls: []chatToolCall{call}})
+		case "function_call_output":
+			conv.messages = append(conv.messages, chatMessage{role: "tool", toolCallID: item.CallID, parts: []chatPart{{text: item.Output}}})
+		}
+	}
+
+	for _, tool := range req.Tools {
+		// Only function tools are supported
+		if tool.Type != "function" {
+			continue
+		}
+		conv.tools = append(conv.tools, chatTool{name: tool.Name, description: tool.Description, parameters: tool.Parameters})
+	}
+	return conv, nil
+}
+
+// fromResponseMessage converts a message item. Developer messages are system messages.
+func fromResponseMessage(item types.ResponseItem) (chatMessage, error) {
+	msg := chatMessage{role: item.Role}
+	switch item.Role {
+	case "user", "assistant", "system":
+	case "developer":
+		msg.role = "system"
+	default:
+		return chatMessage{}, fmt.Errorf("unsupported role %q", item.Role)
+	}
+
+	for _, part := range item.Content {
+		switch part.Type {
+		case "input_text", "output_text":
+			msg.parts = append(msg.parts, chatPart{text: part.Text})
+		case "input_image":
+			if part.ImageURL == "" {
+				return chatMessage{}, errors.New("input_image requires an image_url")
+			}
+			msg.parts = append(msg.parts, chatPart{image: part.ImageURL, detail: part.Detail})
+		}
+	}
+	return msg, nil
+}
+
+// fromResponsesToolChoice converts a Responses tool_choice, either "none", "auto", "required" or
+// a named function in the flat format. Unknown values are dropped.
+func fromResponsesToolChoice(raw json.RawMessage) *chatToolChoice {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "none", "auto", "required":
+			return &chatToolChoice{mode: mode}
+		}
+		return nil
+	}
+
+	var named struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Name == "" {
+		return nil
+	}
+	return &chatToolChoice{mode: "function", name: named.Name}
+}
+
+// ResponsesChatRequest returns the chat completion request equivalent to a Responses request and
+// the history it continues. It is used to rate limit, meter and count Responses requests like
+// chat completions.
+func ResponsesChatRequest(req types.ResponsesRequest, history []types.ResponseItem) types.ChatCompletionRequest {
+	conv, _ := fromResponsesRequest(req, history)
+	chatReq := conv.chatCompletionRequest()
+	chatReq.Model = req.Model
+	chatReq.User = req.User
+	return chatReq
+}
+
+// responseID derives a Responses style response ID from a chat completion ID.
+func responseID(chatID string) string {
+	return "resp_" + strings.TrimPrefix(chatID, "chatcmpl-")
+}
+
+// outputItemID derives the ID of an output item from the response ID: prefix is "msg" for
+// messages and "fc" for function calls, index the position of the item in the output.
+func outputItemID(id, prefix string, index int) string {
+	return prefix + "_" + strings.TrimPrefix(id, "resp_") + "_" + strconv.Itoa(index)
+}
+
+// responseStatus returns the status of a response from its OCI finish reason: responses stopped by
+// the token limit or the content filter are incomplete.
+func responseStatus(reason string) (string, *types.IncompleteDetails) {
+	switch FinishReason(reason) {
+	case "length":
+		return "incomplete", &types.IncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		return "incomplete", &types.IncompleteDetails{Reason: "content_filter"}
+	}
+	return "completed", nil
+}
+
+// toResponsesUsage converts OCI usage to the Responses format.
+func toResponsesUsage(usage *types.Usage) *types.ResponsesUsage {
+	if usage == nil {
+		return nil
+	}
+	return &types.ResponsesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+}
+
+// newResponse returns a response of req without output.
+func (t *Transformer) newResponse(req types.ResponsesRequest, model string) types.ResponsesResponse {
+	resp := types.ResponsesResponse{
+		ID:        responseID(t.newID()),
+		Object:    "response",
+		CreatedAt: t.now().Unix(),
+		Status:    "in_progress",
+		Model:     model,
+		Output:    []types.ResponseItem{},
+		Store:     req.IsStored(),
+	}
+	if req.PreviousResponseID != "" {
+		previous := req.PreviousResponseID
+		resp.PreviousResponseID = &previous
+	}
+	return resp
+}
+
+// ToResponsesResponse converts an Oracle Cloud GenAI chat response to a Responses response to req.
+// The model requested by the client is reported when OCI does not echo the model ID. The generated
+// text becomes a message item, followed by a function_call item per tool call.
+func (t *Transformer) ToResponsesResponse(oracleResp types.OracleCloudResponse, req types.ResponsesRequest) types.ResponsesResponse {
+	result := toChatResult(oracleResp, req.Model)
+
+	resp := t.newResponse(req, result.model)
+	resp.Status = "completed"
+	resp.Usage = toResponsesUsage(result.usage)
+	if len(result.choices) == 0 {
+		return resp
+	}
+
+	choice := result.choices[0]
+	if choice.text != "" {
+		resp.Output = append(resp.Output, types.ResponseItem{
+			Type:    "message",
+			ID:      outputItemID(resp.ID, "msg", len(resp.Output)),
+			Status:  "completed",
+			Role:    "assistant",
+			Content: types.ResponseContents{{Type: "output_text", Text: choice.text}},
+		})
+	}
+	for _, call := range choice.toolCalls {
+		resp.Output = append(resp.Output, types.ResponseItem{
+			Type:      "function_call",
+			ID:        outputItemID(resp.ID, "fc", len(resp.Output)),
+			Status:    "completed",
+			CallID:    call.id,
+			Name:      call.name,
+			Arguments: call.arguments,
+		})
+	}
+	resp.Status, resp.IncompleteDetails = responseStatus(choice.finishReason)
+	return resp
+}
+
+// ResponsesStream converts the events of a streamed OCI chat response into Responses API events.
+// Text is streamed as a message item and tool calls as function_call items. A new stream must be
+// created for every response.
+type ResponsesStream struct {
+	*streamDecoder
+	response     types.ResponsesResponse // Response being streamed, holding the output items
+	sequence     int
+	started      bool
+	open         bool   // Whether the last output item is still streaming
+	finishReason string // Finish reason reported by OCI
+}
+
+// NewResponsesStream creates the stream translating a single streamed response to req.
+func (t *Transformer) NewResponsesStream(req types.ResponsesRequest) *ResponsesStream {
+	return &ResponsesStream{
+		streamDecoder: &streamDecoder{model: req.Model},
+		response:      t.newResponse(req, req.Model),
+	}
+}
+
+// event numbers an event of the stream.
+func (s *ResponsesStream) event(event types.ResponsesStreamEvent) types.ResponsesStreamEvent {
+	event.SequenceNumber = s.sequence
+	s.sequence++
+	return event
+}
+
+// snapshot returns a copy of the response as streamed so far.
+func (s *ResponsesStream) snapshot() *types.ResponsesResponse {
+	resp := s.response
+	resp.Output = append([]types.ResponseItem{}, s.response.Output...)
+	return &resp
+}
+
+// start returns the response.created and response.in_progress events opening the stream, the
+// first time it is called.
+func (s *ResponsesStream) start() []types.ResponsesStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []types.ResponsesStreamEvent{
+		s.event(types.ResponsesStreamEvent{Type: "response.created", Response: s.snapshot()}),
+		s.event(types.ResponsesStreamEvent{Type: "response.in_progress", Response: s.snapshot()}),
+	}
+}
+
+// openItem starts an output item, closing the open one first. Message items start with an empty
+// output_text part.
+func (s *ResponsesStream) openItem(item types.ResponseItem) []types.ResponsesStreamEvent {
+	events := s.closeItem()
+	index := len(s.response.Output)
+	prefix := "msg"
+	if item.Type == "function_call" {
+		prefix = "fc"
+	}
+	item.ID = outputItemID(s.response.ID, prefix, index)
+	item.Status = "in_progress"
+	s.response.Output = append(s.response.Output, item)
+	s.open = true
+
+	added := item
+	events = append(events, s.event(types.ResponsesStreamEvent{Type: "response.output_item.added", OutputIndex: &index, Item: &added}))
+	if item.Type != "message" {
+		return events
+	}
+
+	content := 0
+	part := types.ResponseContent{Type: "output_text"}
+	s.response.Output[index].Content = types.ResponseContents{part}
+	return append(events, s.event(types.ResponsesStreamEvent{
+		Type:         "response.content_part.added",
+		ItemID:       item.ID,
+		OutputIndex:  &index,
+		ContentIndex: &content,
+		Part:         &part,
+	}))
+}
+
+// closeItem returns the events completing the open output item, if any.
+func (s *ResponsesStream) closeItem() []types.ResponsesStreamEvent {
+	if !s.open {
+		return nil
+	}
+	s.open = false
+	index := len(s.response.Output) - 1
+	item := &s.response.Output[index]
+	item.Status = "completed"
+
+	var events []types.ResponsesStreamEvent
+	if item.Type == "message" {
+		content := 0
+		part := item.Content[0]
+		events = append(events,
+			s.event(types.ResponsesStreamEvent{Type: "response.output_text.done", ItemID: item.ID, OutputIndex: &index, ContentIndex: &content, Text: part.Text}),
+			s.event(types.ResponsesStreamEvent{Type: "response.content_part.done", ItemID: item.ID, OutputIndex: &index, ContentIndex: &content, Part: &part}),
+		)
+	} else {
+		events = append(events, s.event(types.ResponsesStreamEvent{
+			Type:        "response.function_call_arguments.done",
+			ItemID:      item.ID,
+			OutputIndex: &index,
+			Arguments:   item.Arguments,
+		}))
+	}
+	done := *item
+	return append(events, s.event(types.ResponsesStreamEvent{Type: "response.output_item.done", OutputIndex: &index, Item: &done}))
+}
+
+// openType returns the type of the open output item, "" when none is open.
+func (s *ResponsesStream) openType() string {
+	if !s.open {
+		return ""
+	}
+	return s.response.Output[len(s.response.Output)-1].Type
+}
+
+// Event translates the data of one OCI event into zero or more Responses events. The first event
+// opens the stream with response.created and response.in_progress.
+func (s *ResponsesStream) Event(data []byte) ([]types.ResponsesStreamEvent, error) {
+	deltas, err := s.decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	events := s.start()
+	for _, delta := range deltas {
+		if delta.finishReason != "" {
+			s.finishReason = delta.finishReason
+			events = append(events, s.closeItem()...)
+			continue
+		}
+
+		if delta.text != "" {
+			if s.openType() != "message" {
+				events = append(events, s.openItem(types.ResponseItem{Type: "message", Role: "assistant"})...)
+			}
+			index := len(s.response.Output) - 1
+			item := &s.response.Output[index]
+			item.Content[0].Text += delta.text
+			content := 0
+			events = append(events, s.event(types.ResponsesStreamEvent{
+				Type:         "response.output_text.delta",
+				ItemID:       item.ID,
+				OutputIndex:  &index,
+				ContentIndex: &content,
+				Delta:        delta.text,
+			}))
+		}
+
+		for _, call := range delta.toolCalls {
+			if call.start {
+				events = append(events, s.openItem(types.ResponseItem{Type: "function_call", CallID: call.id, Name: call.name})...)
+			} else if s.openType() != "function_call" {
+				continue
+			}
+			if call.arguments == "" {
+				continue
+			}
+			index := len(s.response.Output) - 1
+			item := &s.response.Output[index]
+			item.Arguments += call.arguments
+			events = append(events, s.event(types.ResponsesStreamEvent{
+				Type:        "response.function_call_arguments.delta",
+				ItemID:      item.ID,
+				OutputIndex: &index,
+				Delta:       call.arguments,
+			}))
+		}
+	}
+	return events, nil
+}
+
+// Finish returns the events that close the stream: the end of the open output item and
+// response.completed, or response.incomplete when the response stopped early or the stream ended
+// without a finish reason.
+func (s *ResponsesStream) Finish() []types.ResponsesStreamEvent {
+	events := s.start()
+	events = append(events, s.closeItem()...)
+
+	resp := s.Result()
+	eventType := "response.completed"
+	if resp.Status != "completed" {
+		eventType = "response.incomplete"
+	}
+	return append(events, s.event(types.ResponsesStreamEvent{Type: eventType, Response: &resp}))
+}
+
+// Result returns the response streamed so far, with its status and usage once the stream has
+// reported a finish reason.
+func (s *ResponsesStream) Result() types.ResponsesResponse {
+	resp := *s.snapshot()
+	resp.Usage = toResponsesUsage(s.usage)
+	if s.finishReason == "" {
+		resp.Status = "incomplete"
+		return resp
+	}
+	resp.Status, resp.IncompleteDetails = responseStatus(s.finishReason)
+	return resp
+}
+
+// ID returns the response ID.
+func (s *ResponsesStream) ID() string {
+	return s.response.ID
+}
+
+// FinishReason returns the OpenAI finish reason, once the stream has reported one.
+func (s *ResponsesStream) FinishReason() string {
+	return FinishReason(s.finishReason)
+}
@@ -0,0 +1,136 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestResponsesToOracleCloudRequest_History(t *testing.T) {
+	transformer := New(config.New())
+
+	var req types.ResponsesRequest
+	if err := json.Unmarshal([]byte(`{"model":"meta.llama-3.3-70b-instruct","instructions":"Be brief.","input":"And Rome?"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	history := []types.ResponseItem{
+		{Type: "message", Role: "user", Content: types.ResponseContents{{Type: "input_text", Text: "Weather in Paris?"}}},
+		{Type: "message", Role: "assistant", Content: types.ResponseContents{{Type: "output_text", Text: "Sunny."}}},
+	}
+
+	result, err := transformer.ResponsesToOracleCloudRequest(req, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := result.ChatRequest.Messages
+	expected := []struct{ role, text string }{
+		{"SYSTEM", "Be brief."},
+		{"USER", "Weather in Paris?"},
+		{"ASSISTANT", "Sunny."},
+		{"USER", "And Rome?"},
+	}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %+v", len(expected), messages)
+	}
+	for i, msg := range messages {
+		if msg.Role != expected[i].role || len(msg.Content) != 1 || msg.Content[0].Text != expected[i].text {
+			t.Errorf("message %d: expected %s %q, got %+v", i, expected[i].role, expected[i].text, msg)
+		}
+	}
+}
+
+func TestResponsesToOracleCloudRequest_Invalid(t *testing.T) {
+	transformer := New(config.New())
+
+	tests := map[string]string{
+		"missing model": `{"input":"Hi"}`,
+		"missing input": `{"model":"m","input":[]}`,
+		"unknown role":  `{"model":"m","input":[{"role":"tool","content":"Hi"}]}`,
+		"image file id": `{"model":"m","input":[{"role":"user","content":[{"type":"input_image","file_id":"file-1"}]}]}`,
+	}
+	for name, body := range tests {
+		var req types.ResponsesRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := transformer.ResponsesToOracleCloudRequest(req, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFromResponsesToolChoice(t *testing.T) {
+	tests := []struct {
+		choice   string
+		expected *chatToolChoice
+	}{
+		{``, nil},
+		{`"auto"`, &chatToolChoice{mode: "auto"}},
+		{`"required"`, &chatToolChoice{mode: "required"}},
+		{`"any"`, nil},
+		{`{"type":"function","name":"get_weather"}`, &chatToolChoice{mode: "function", name: "get_weather"}},
+		{`{"type":"function"}`, nil},
+		{`{"type":"web_search_preview"}`, nil},
+	}
+
+	for _, tt := range tests {
+		result := fromResponsesToolChoice(json.RawMessage(tt.choice))
+		if (result == nil) != (tt.expected == nil) || (result != nil && *result != *tt.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tt.choice, tt.expected, result)
+		}
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	tests := []struct {
+		reason     string
+		status     string
+		incomplete string
+	}{
+		{"COMPLETE", "completed", ""},
+		{"tool_calls", "completed", ""},
+		{"MAX_TOKENS", "incomplete", "max_output_tokens"},
+		{"length", "incomplete", "max_output_tokens"},
+		{"ERROR_TOXIC", "incomplete", "content_filter"},
+	}
+
+	for _, tt := range tests {
+		status, details := responseStatus(tt.reason)
+		reason := ""
+		if details != nil {
+			reason = details.Reason
+		}
+		if status != tt.status || reason != tt.incomplete {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.reason, tt.status, tt.incomplete, status, reason)
+		}
+	}
+}
+
+func TestResponsesStream_Truncated(t *testing.T) {
+	transformer := New(config.New())
+	stream := transformer.NewResponsesStream(types.ResponsesRequest{Model: "gpt-4"})
+
+	events, err := stream.Event([]byte(`{"apiFormat":"COHERE","text":"Hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = append(events, stream.Finish()...)
+
+	last := events[len(events)-1]
+	if last.Type != "response.incomplete" || last.Response == nil || last.Response.Status != "incomplete" {
+		t.Errorf("expected the stream to end incomplete, got %+v", last)
+	}
+	if len(last.Response.Output) != 1 || last.Response.Output[0].Content[0].Text != "Hello" {
+		t.Errorf("expected the streamed text in the output, got %+v", last.Response.Output)
+	}
+	for i, event := range events {
+		if event.SequenceNumber != i {
+			t.Errorf("expected event %d to be numbered %d, got %d", i, i, event.SequenceNumber)
+		}
+	}
+	if stream.FinishReason() != "" {
+		t.Errorf("expected no finish reason, got %q", stream.FinishReason())
+	}
+}
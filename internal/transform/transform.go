@@ -1,9 +1,16 @@
-// Package transform handles the conversion between OpenAI API format and Oracle Cloud GenAI format.
-// It provides functionality to transform OpenAI ChatCompletion requests into the format
-// expected by Oracle Cloud's Generative AI service.
+// Package transform handles the conversion between the client API formats and Oracle Cloud GenAI format.
+// It provides functionality to transform OpenAI ChatCompletion and Anthropic Messages requests into
+// the format expected by Oracle Cloud's Generative AI service, and to translate the responses back.
+// Both dialects are converted to a dialect-neutral conversation, so they share a single OCI backend.
 package transform
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
 	"github.com/zalbiraw/ocigenai/internal/config"
 	"github.com/zalbiraw/ocigenai/pkg/types"
 )
@@ -11,83 +18,418 @@ import (
 // Transformer handles the conversion between different API formats.
 type Transformer struct {
 	config *config.Config
+	newID  func() string    // Generates completion IDs
+	now    func() time.Time // Clock used for creation timestamps
+	policy *policyState     // Parameter policies of a single request; nil when none apply
 }
 
 // New creates a new transformer with the given configuration.
 func New(cfg *config.Config) *Transformer {
 	return &Transformer{
 		config: cfg,
+		newID:  newCompletionID,
+		now:    time.Now,
 	}
 }
 
+// newCompletionID generates an OpenAI style chat completion ID.
+func newCompletionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
 // ToOracleCloudRequest converts an OpenAI ChatCompletion request to Oracle Cloud GenAI format.
-// It extracts the last message as the prompt and applies configuration defaults where needed.
+// It selects the API format of the request and applies configuration defaults where needed.
 //
 // The transformation process:
-// 1. Extracts the last message from the conversation as the main prompt
-// 2. Uses OpenAI request parameters if provided, otherwise falls back to config defaults
-// 3. Constructs the Oracle Cloud request structure with proper serving mode and chat parameters.
+//  1. Converts the request to the dialect-neutral conversation shared with the other client APIs
+//  2. Selects the COHERE or GENERIC API format (see APIFormat)
+//  3. For COHERE, extracts the last message from the conversation as the main prompt; for GENERIC,
+//     converts the whole conversation, tools and image parts
+//  4. Uses the request parameters if provided, otherwise falls back to config defaults, and
+//     applies the parameter policies of the transformer (see WithPolicies)
+//  5. Constructs the Oracle Cloud request structure with proper serving mode and chat parameters.
 func (t *Transformer) ToOracleCloudRequest(openAIReq types.ChatCompletionRequest) types.OracleCloudRequest {
-	// Extract the last message as the prompt
-	// In a typical conversation, the last message is what we want to respond to
-	message := ""
-	if len(openAIReq.Messages) > 0 {
-		message = openAIReq.Messages[len(openAIReq.Messages)-1].Content
+	return t.toOracleCloudRequest(openAIReq, openAIReq.IsSet)
+}
+
+// toOracleCloudRequest converts a chat request; isSet reports whether a sampling parameter was
+// explicitly set by the client.
+func (t *Transformer) toOracleCloudRequest(openAIReq types.ChatCompletionRequest, isSet func(field string) bool) types.OracleCloudRequest {
+	return t.toChatRequest(fromOpenAIRequest(openAIReq, isSet))
+}
+
+// fromOpenAIRequest converts an OpenAI chat request to a conversation. Sampling parameters are
+// only set when non-zero or explicitly set by the client, so that omitted parameters fall back
+// to the configured defaults while explicit zeros are honoured.
+func fromOpenAIRequest(openAIReq types.ChatCompletionRequest, isSet func(field string) bool) conversation {
+	sampling := func(field string, value float32) *float64 {
+		if value == 0 && !isSet(field) {
+			return nil
+		}
+		v := float64(value)
+		return &v
 	}
 
-	// Use OpenAI request values if provided, otherwise use config defaults
-	// This allows per-request customization while maintaining sensible defaults
+	conv := conversation{
+		model:               openAIReq.Model,
+		maxTokens:           openAIReq.MaxTokens,
+		temperature:         sampling("temperature", openAIReq.Temperature),
+		topP:                sampling("top_p", openAIReq.TopP),
+		frequencyPenalty:    sampling("frequency_penalty", openAIReq.FrequencyPenalty),
+		presencePenalty:     sampling("presence_penalty", openAIReq.PresencePenalty),
+		stop:                openAIReq.Stop,
+		stream:              openAIReq.Stream,
+		toolChoice:          fromOpenAIToolChoice(openAIReq.ToolChoice),
+		documents:           openAIReq.Documents,
+		citationQuality:     openAIReq.CitationQuality,
+		isSearchQueriesOnly: openAIReq.IsSearchQueriesOnly,
+	}
 
-	maxTokens := t.config.MaxTokens
-	if openAIReq.MaxTokens != 0 {
-		maxTokens = openAIReq.MaxTokens
+	for _, msg := range openAIReq.Messages {
+		converted := chatMessage{role: msg.Role, name: msg.Name, toolCallID: msg.ToolCallID}
+		switch {
+		case msg.Parts != nil:
+			for _, part := range msg.Parts {
+				switch {
+				case part.Type == "text":
+					converted.parts = append(converted.parts, chatPart{text: part.Text})
+				case part.Type == "image_url" && part.ImageURL != nil:
+					converted.parts = append(converted.parts, chatPart{image: part.ImageURL.URL, detail: part.ImageURL.Detail})
+				}
+			}
+		case msg.Content != "":
+			converted.parts = []chatPart{{text: msg.Content}}
+		}
+		for _, call := range msg.ToolCalls {
+			converted.toolCalls = append(converted.toolCalls, chatToolCall{id: call.ID, name: call.Function.Name, arguments: call.Function.Arguments})
+		}
+		conv.messages = append(conv.messages, converted)
 	}
 
-	temperature := t.config.Temperature
-	if openAIReq.Temperature != 0 {
-		temperature = float64(openAIReq.Temperature)
+	for _, tool := range openAIReq.Tools {
+		// Only function tools are supported
+		if tool.Type != "function" {
+			continue
+		}
+		conv.tools = append(conv.tools, chatTool{
+			name:        tool.Function.Name,
+			description: tool.Function.Description,
+			parameters:  tool.Function.Parameters,
+		})
 	}
+	return conv
+}
 
-	topP := t.config.TopP
-	if openAIReq.TopP != 0 {
-		topP = float64(openAIReq.TopP)
+// fromOpenAIToolChoice converts an OpenAI tool_choice, either "none", "auto", "required" or
+// a named function. Unknown values are dropped.
+func fromOpenAIToolChoice(raw json.RawMessage) *chatToolChoice {
+	if len(raw) == 0 {
+		return nil
 	}
 
-	frequencyPenalty := t.config.FrequencyPenalty
-	if openAIReq.FrequencyPenalty != 0 {
-		frequencyPenalty = float64(openAIReq.FrequencyPenalty)
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "none", "auto", "required":
+			return &chatToolChoice{mode: mode}
+		}
+		return nil
 	}
 
-	presencePenalty := t.config.PresencePenalty
-	if openAIReq.PresencePenalty != 0 {
-		presencePenalty = float64(openAIReq.PresencePenalty)
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return nil
 	}
+	return &chatToolChoice{mode: "function", name: named.Function.Name}
+}
+
+// toChatRequest converts a conversation to an OCI chat request. This is the OCI backend shared by
+// every client API.
+func (t *Transformer) toChatRequest(conv conversation) types.OracleCloudRequest {
+	apiFormat := t.apiFormat(conv)
 
-	topK := t.config.TopK
+	// Use the request values if provided, otherwise use config defaults
+	// This allows per-request customization while maintaining sensible defaults,
+	// within the bounds of the parameter policies.
+	values, set := t.parameters(conv)
+	t.applyPolicies(conv.model, values, set)
 
 	// Construct the Oracle Cloud request structure
 	oracleReq := types.OracleCloudRequest{
 		CompartmentID: t.config.CompartmentID,
 		ServingMode: types.ServingMode{
-			ModelID:     openAIReq.Model,
+			ModelID:     conv.model,
 			ServingType: "ON_DEMAND", // Standard serving type for OCI GenAI
 		},
 		ChatRequest: types.ChatRequest{
-			MaxTokens:        maxTokens,
-			Temperature:      temperature,
-			FrequencyPenalty: frequencyPenalty,
-			PresencePenalty:  presencePenalty,
-			TopP:             topP,
-			TopK:             topK,
-			IsStream:         false, // Currently not supporting streaming
+			MaxTokens:        int(values["max_tokens"]),
+			Temperature:      values["temperature"],
+			FrequencyPenalty: values["frequency_penalty"],
+			PresencePenalty:  values["presence_penalty"],
+			TopP:             values["top_p"],
+			TopK:             int(values["top_k"]),
+			IsStream:         conv.stream,
 			StreamOptions: types.StreamOptions{
-				IsIncludeUsage: false,
+				// Usage is always requested for streams so the plugin can account for it;
+				// it is only forwarded to clients that asked for it
+				IsIncludeUsage: conv.stream,
 			},
-			ChatHistory: []interface{}{}, // Empty for now, could be enhanced to include conversation history
-			Message:     message,
-			APIFormat:   "COHERE", // Default API format for OCI GenAI
+			APIFormat: apiFormat,
 		},
 	}
 
+	if apiFormat == formatGeneric {
+		oracleReq.ChatRequest.Messages = toGenericMessages(conv.messages)
+		oracleReq.ChatRequest.Tools = toGenericTools(conv.tools)
+		oracleReq.ChatRequest.ToolChoice = toGenericToolChoice(conv.toolChoice)
+		oracleReq.ChatRequest.Stop = conv.stop
+		return oracleReq
+	}
+
+	// The last message is the prompt; system messages become the preamble, and the messages
+	// before the prompt the chat history
+	oracleReq.ChatRequest.PreambleOverride, oracleReq.ChatRequest.ChatHistory, oracleReq.ChatRequest.Message = toCohereChat(conv.messages)
+
+	// Ground the answer on the documents sent through the extension fields
+	oracleReq.ChatRequest.Documents = toCohereDocuments(conv.documents)
+	oracleReq.ChatRequest.CitationQuality = strings.ToUpper(conv.citationQuality)
+	oracleReq.ChatRequest.IsSearchQueriesOnly = conv.isSearchQueriesOnly
+	oracleReq.ChatRequest.StopSequences = conv.stop
+
 	return oracleReq
 }
+
+// cohereRoles maps conversation roles to COHERE chat history roles.
+var cohereRoles = map[string]string{
+	"user":      "USER",
+	"assistant": "CHATBOT",
+	"tool":      "TOOL",
+}
+
+// toCohereChat converts a conversation to the COHERE format: the system and developer messages,
+// joined, the chat history and the message to respond to, the last one.
+func toCohereChat(messages []chatMessage) (string, []types.CohereMessage, string) {
+	var preamble []string
+	var turns []chatMessage
+	for _, msg := range messages {
+		if msg.role == "system" || msg.role == "developer" {
+			preamble = append(preamble, msg.text())
+			continue
+		}
+		turns = append(turns, msg)
+	}
+	if len(turns) == 0 {
+		return strings.Join(preamble, "\n\n"), nil, ""
+	}
+
+	// Tool results refer to their call, which is looked up by ID
+	calls := make(map[string]types.CohereToolCall)
+	var history []types.CohereMessage
+	for _, msg := range turns[:len(turns)-1] {
+		role, ok := cohereRoles[msg.role]
+		if !ok {
+			role = strings.ToUpper(msg.role)
+		}
+		cohere := types.CohereMessage{Role: role}
+		switch role {
+		case "TOOL":
+			cohere.ToolResults = []types.CohereToolResult{{
+				Call:    calls[msg.toolCallID],
+				Outputs: []json.RawMessage{toolOutput(msg.text())},
+			}}
+		default:
+			cohere.Message = msg.text()
+			for _, call := range msg.toolCalls {
+				parameters := json.RawMessage("{}")
+				if call.arguments != "" {
+					parameters = toolOutput(call.arguments)
+				}
+				converted := types.CohereToolCall{Name: call.name, Parameters: parameters}
+				calls[call.id] = converted
+				cohere.ToolCalls = append(cohere.ToolCalls, converted)
+			}
+		}
+		history = append(history, cohere)
+	}
+	return strings.Join(preamble, "\n\n"), history, turns[len(turns)-1].text()
+}
+
+// toolOutput returns text as a JSON object: as is when it is one, otherwise wrapped in a result
+// field, as COHERE tool calls and results must be objects.
+func toolOutput(text string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &object); err == nil && object != nil {
+		return json.RawMessage(text)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"result": text})
+	return wrapped
+}
+
+// toCohereDocuments converts RAG documents to the COHERE format. Documents sent as plain
+// strings become objects with a single text field.
+func toCohereDocuments(documents []json.RawMessage) []json.RawMessage {
+	var converted []json.RawMessage
+	for _, document := range documents {
+		var text string
+		if err := json.Unmarshal(document, &text); err == nil {
+			document, _ = json.Marshal(map[string]string{"text": text})
+		}
+		converted = append(converted, document)
+	}
+	return converted
+}
+
+// parameters returns the chat parameters of a conversation by their OpenAI name, falling back to
+// the configured defaults, and the parameters set by the client.
+func (t *Transformer) parameters(conv conversation) (map[string]float64, map[string]bool) {
+	values := map[string]float64{
+		"max_tokens":        float64(t.config.MaxTokens),
+		"temperature":       t.config.Temperature,
+		"top_p":             t.config.TopP,
+		"top_k":             float64(t.config.TopK),
+		"frequency_penalty": t.config.FrequencyPenalty,
+		"presence_penalty":  t.config.PresencePenalty,
+	}
+	set := make(map[string]bool, len(values))
+	if conv.maxTokens != 0 {
+		values["max_tokens"], set["max_tokens"] = float64(conv.maxTokens), true
+	}
+	sampling := map[string]*float64{
+		"temperature":       conv.temperature,
+		"top_p":             conv.topP,
+		"frequency_penalty": conv.frequencyPenalty,
+		"presence_penalty":  conv.presencePenalty,
+	}
+	for name, value := range sampling {
+		if value != nil {
+			values[name], set[name] = *value, true
+		}
+	}
+	if conv.topK != nil {
+		values["top_k"], set["top_k"] = float64(*conv.topK), true
+	}
+	return values, set
+}
+
+// OCI chat API formats.
+const (
+	formatCohere  = "COHERE"
+	formatGeneric = "GENERIC"
+)
+
+// genericModelFamilies are the prefixes of the model families only served in the GENERIC format.
+var genericModelFamilies = []string{"meta.", "google.", "xai.", "openai.", "mistral."}
+
+// APIFormat returns the OCI chat API format of a request: the configured format if any,
+// otherwise GENERIC for the model families that require it and for requests the COHERE
+// format cannot express (tools, tool messages and images), and COHERE for everything else.
+// Cohere models always use the COHERE format; their tools and images are not forwarded.
+// Likewise, RAG documents are only forwarded in the COHERE format.
+func (t *Transformer) APIFormat(openAIReq types.ChatCompletionRequest) string {
+	return t.apiFormat(fromOpenAIRequest(openAIReq, openAIReq.IsSet))
+}
+
+// apiFormat returns the OCI chat API format of a conversation (see APIFormat).
+func (t *Transformer) apiFormat(conv conversation) string {
+	if t.config.APIFormat != "" {
+		return t.config.APIFormat
+	}
+	if strings.HasPrefix(conv.model, "cohere.") {
+		return formatCohere
+	}
+	for _, family := range genericModelFamilies {
+		if strings.HasPrefix(conv.model, family) {
+			return formatGeneric
+		}
+	}
+
+	if len(conv.tools) > 0 {
+		return formatGeneric
+	}
+	for _, msg := range conv.messages {
+		if msg.role == "tool" || len(msg.toolCalls) > 0 {
+			return formatGeneric
+		}
+		for _, part := range msg.parts {
+			if part.image != "" {
+				return formatGeneric
+			}
+		}
+	}
+	return formatCohere
+}
+
+// genericRoles maps conversation roles to GENERIC roles.
+var genericRoles = map[string]string{
+	"system":    "SYSTEM",
+	"developer": "SYSTEM",
+	"user":      "USER",
+	"assistant": "ASSISTANT",
+	"tool":      "TOOL",
+}
+
+// toGenericMessages converts a conversation to GENERIC messages.
+func toGenericMessages(messages []chatMessage) []types.GenericMessage {
+	converted := make([]types.GenericMessage, 0, len(messages))
+	for _, msg := range messages {
+		role, ok := genericRoles[msg.role]
+		if !ok {
+			role = strings.ToUpper(msg.role)
+		}
+
+		generic := types.GenericMessage{Role: role, Name: msg.name, ToolCallID: msg.toolCallID}
+		for _, part := range msg.parts {
+			if part.image != "" {
+				generic.Content = append(generic.Content, types.GenericContent{
+					Type:     "IMAGE",
+					ImageURL: &types.GenericImageURL{URL: part.image, Detail: strings.ToUpper(part.detail)},
+				})
+				continue
+			}
+			generic.Content = append(generic.Content, types.GenericContent{Type: "TEXT", Text: part.text})
+		}
+
+		for _, call := range msg.toolCalls {
+			generic.ToolCalls = append(generic.ToolCalls, types.GenericToolCall{
+				ID:        call.id,
+				Type:      "FUNCTION",
+				Name:      call.name,
+				Arguments: call.arguments,
+			})
+		}
+		converted = append(converted, generic)
+	}
+	return converted
+}
+
+// toGenericTools converts function tools to GENERIC tools.
+func toGenericTools(tools []chatTool) []types.GenericTool {
+	var converted []types.GenericTool
+	for _, tool := range tools {
+		converted = append(converted, types.GenericTool{
+			Type:        "FUNCTION",
+			Name:        tool.name,
+			Description: tool.description,
+			Parameters:  tool.parameters,
+		})
+	}
+	return converted
+}
+
+// toGenericToolChoice converts a tool choice to the GENERIC format.
+func toGenericToolChoice(choice *chatToolChoice) *types.GenericToolChoice {
+	if choice == nil {
+		return nil
+	}
+	if choice.mode == "function" {
+		return &types.GenericToolChoice{Type: "FUNCTION", Name: choice.name}
+	}
+	return &types.GenericToolChoice{Type: strings.ToUpper(choice.mode)}
+}
@@ -1,6 +1,7 @@
 package transform
 
 import (
+	"encoding/json"
 	"math"
 	"testing"
 
@@ -240,3 +241,89 @@ func TestToOracleCloudRequest_StreamingDefaults(t *testing.T) {
 		t.Errorf("expected empty chat history, got %d items", len(result.ChatRequest.ChatHistory))
 	}
 }
+
+func TestToOracleCloudRequest_CohereHistory(t *testing.T) {
+	transformer := New(config.New())
+
+	var openAIReq types.ChatCompletionRequest
+	body := `{"model": "cohere.command-r-plus", "messages": [
+		{"role": "system", "content": "You are terse."},
+		{"role": "user", "content": "What is the weather in Paris?"},
+		{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "sunny"},
+		{"role": "assistant", "content": "It is sunny."},
+		{"role": "user", "content": "And tomorrow?"}
+	]}`
+	if err := json.Unmarshal([]byte(body), &openAIReq); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	chatReq := transformer.ToOracleCloudRequest(openAIReq).ChatRequest
+
+	if chatReq.PreambleOverride != "You are terse." {
+		t.Errorf("expected the system message as the preamble, got %q", chatReq.PreambleOverride)
+	}
+	if chatReq.Message != "And tomorrow?" {
+		t.Errorf("expected the last message as the prompt, got %q", chatReq.Message)
+	}
+
+	history, err := json.Marshal(chatReq.ChatHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"role":"USER","message":"What is the weather in Paris?"},` +
+		`{"role":"CHATBOT","toolCalls":[{"name":"weather","parameters":{"city":"Paris"}}]},` +
+		`{"role":"TOOL","toolResults":[{"call":{"name":"weather","parameters":{"city":"Paris"}},"outputs":[{"result":"sunny"}]}]},` +
+		`{"role":"CHATBOT","message":"It is sunny."}]`
+	if string(history) != expected {
+		t.Errorf("expected chat history %s, got %s", expected, history)
+	}
+}
+
+func TestToOracleCloudRequest_ExplicitZero(t *testing.T) {
+	transformer := New(config.New())
+
+	var openAIReq types.ChatCompletionRequest
+	body := `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0,"presence_penalty":0}`
+	if err := json.Unmarshal([]byte(body), &openAIReq); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+
+	result := transformer.ToOracleCloudRequest(openAIReq)
+
+	if result.ChatRequest.Temperature != 0 {
+		t.Errorf("expected explicit temperature 0, got %f", result.ChatRequest.Temperature)
+	}
+	if result.ChatRequest.TopP != 0.75 {
+		t.Errorf("expected default topP 0.75 when omitted, got %f", result.ChatRequest.TopP)
+	}
+}
+
+func TestAPIFormat(t *testing.T) {
+	tool := types.Tool{Type: "function", Function: types.FunctionDefinition{Name: "f"}}
+	image := types.ChatCompletionMessage{Role: "user", Parts: []types.ContentPart{{Type: "image_url", ImageURL: &types.ImageURL{URL: "https://example.com/a.png"}}}}
+	text := types.ChatCompletionMessage{Role: "user", Content: "hi"}
+
+	tests := []struct {
+		name       string
+		configured string
+		request    types.ChatCompletionRequest
+		expected   string
+	}{
+		{"unknown model", "", types.ChatCompletionRequest{Model: "gpt-4", Messages: []types.ChatCompletionMessage{text}}, "COHERE"},
+		{"cohere model", "", types.ChatCompletionRequest{Model: "cohere.command-r-08-2024", Tools: []types.Tool{tool}}, "COHERE"},
+		{"meta model", "", types.ChatCompletionRequest{Model: "meta.llama-3.3-70b-instruct", Messages: []types.ChatCompletionMessage{text}}, "GENERIC"},
+		{"tools", "", types.ChatCompletionRequest{Model: "gpt-4", Tools: []types.Tool{tool}}, "GENERIC"},
+		{"tool message", "", types.ChatCompletionRequest{Model: "gpt-4", Messages: []types.ChatCompletionMessage{{Role: "tool", ToolCallID: "c"}}}, "GENERIC"},
+		{"image", "", types.ChatCompletionRequest{Model: "gpt-4", Messages: []types.ChatCompletionMessage{image}}, "GENERIC"},
+		{"configured", "COHERE", types.ChatCompletionRequest{Model: "meta.llama-3.3-70b-instruct"}, "COHERE"},
+	}
+
+	for _, tt := range tests {
+		cfg := config.New()
+		cfg.APIFormat = tt.configured
+		if format := New(cfg).APIFormat(tt.request); format != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, format)
+		}
+	}
+}
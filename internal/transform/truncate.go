@@ -0,0 +1,45 @@
+package transform
+
+import "github.com/zalbiraw/ocigenai/pkg/types"
+
+// DropOldestTurn removes the oldest turn of the conversation of an OCI chat request: its first
+// message other than a system message, and the assistant and tool messages answering it. In the
+// GENERIC format, system messages and the last message are never removed; in the COHERE format,
+// turns are removed from the chat history, and the preamble and the message are kept. It returns
+// the number of messages removed, 0 when there is no turn left to remove.
+func DropOldestTurn(req *types.ChatRequest) int {
+	if req.APIFormat == formatCohere {
+		if len(req.ChatHistory) == 0 {
+			return 0
+		}
+		end := 1
+		for end < len(req.ChatHistory) && req.ChatHistory[end].Role != cohereRoles["user"] {
+			end++
+		}
+		req.ChatHistory = req.ChatHistory[end:]
+		if len(req.ChatHistory) == 0 {
+			req.ChatHistory = nil
+		}
+		return end
+	}
+
+	last := len(req.Messages) - 1
+	start := 0
+	for start < last && req.Messages[start].Role == genericRoles["system"] {
+		start++
+	}
+	if start >= last {
+		return 0
+	}
+
+	end := start + 1
+	for end < last && req.Messages[end].Role != genericRoles["user"] && req.Messages[end].Role != genericRoles["system"] {
+		end++
+	}
+
+	kept := make([]types.GenericMessage, 0, len(req.Messages)-(end-start))
+	kept = append(kept, req.Messages[:start]...)
+	kept = append(kept, req.Messages[end:]...)
+	req.Messages = kept
+	return end - start
+}
@@ -0,0 +1,81 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestDropOldestTurn(t *testing.T) {
+	message := func(role, text string) types.GenericMessage {
+		return types.GenericMessage{Role: role, Content: []types.GenericContent{{Type: "TEXT", Text: text}}}
+	}
+	texts := func(req types.ChatRequest) []string {
+		var texts []string
+		for _, msg := range req.Messages {
+			texts = append(texts, msg.Content[0].Text)
+		}
+		return texts
+	}
+
+	req := types.ChatRequest{Messages: []types.GenericMessage{
+		message("SYSTEM", "system"),
+		message("USER", "first"),
+		message("ASSISTANT", "call"),
+		message("TOOL", "result"),
+		message("USER", "second"),
+		message("ASSISTANT", "answer"),
+		message("USER", "last"),
+	}}
+
+	// A turn is dropped with the messages answering it
+	if dropped := DropOldestTurn(&req); dropped != 3 {
+		t.Fatalf("expected the first turn of 3 messages to be dropped, got %d", dropped)
+	}
+	if expected := []string{"system", "second", "answer", "last"}; !reflect.DeepEqual(texts(req), expected) {
+		t.Errorf("expected %v, got %v", expected, texts(req))
+	}
+
+	if dropped := DropOldestTurn(&req); dropped != 2 {
+		t.Fatalf("expected the second turn of 2 messages to be dropped, got %d", dropped)
+	}
+
+	// The system messages and the last message are kept
+	if dropped := DropOldestTurn(&req); dropped != 0 {
+		t.Errorf("expected nothing to be dropped, got %d messages", dropped)
+	}
+	if expected := []string{"system", "last"}; !reflect.DeepEqual(texts(req), expected) {
+		t.Errorf("expected %v, got %v", expected, texts(req))
+	}
+}
+
+func TestDropOldestTurn_Cohere(t *testing.T) {
+	req := types.ChatRequest{
+		APIFormat:        "COHERE",
+		PreambleOverride: "system",
+		ChatHistory: []types.CohereMessage{
+			{Role: "USER", Message: "first"},
+			{Role: "CHATBOT", ToolCalls: []types.CohereToolCall{{Name: "weather"}}},
+			{Role: "TOOL"},
+			{Role: "CHATBOT", Message: "answer"},
+			{Role: "USER", Message: "second"},
+		},
+		Message: "last",
+	}
+
+	if dropped := DropOldestTurn(&req); dropped != 4 {
+		t.Fatalf("expected the first turn of 4 messages to be dropped, got %d", dropped)
+	}
+	if dropped := DropOldestTurn(&req); dropped != 1 {
+		t.Fatalf("expected the second turn to be dropped, got %d messages", dropped)
+	}
+
+	// The preamble and the message are kept
+	if dropped := DropOldestTurn(&req); dropped != 0 {
+		t.Errorf("expected nothing to be dropped, got %d messages", dropped)
+	}
+	if req.PreambleOverride != "system" || req.Message != "last" || req.ChatHistory != nil {
+		t.Errorf("expected only the preamble and the message to be left, got %+v", req)
+	}
+}
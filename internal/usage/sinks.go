@@ -0,0 +1,184 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/flusher"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 10 * time.Second
+
+	// maxPendingBatches bounds the records kept while the webhook is unreachable.
+	maxPendingBatches = 100
+)
+
+// NewSink creates the sink described by the configuration.
+func NewSink(cfg config.UsageSink) (Sink, error) {
+	switch cfg.Type {
+	case "file":
+		return NewFileSink(cfg.Path)
+	case "stdout":
+		return NewWriterSink(os.Stdout), nil
+	case "webhook":
+		interval := defaultFlushInterval
+		if cfg.FlushInterval != "" {
+			d, err := time.ParseDuration(cfg.FlushInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid flushInterval %q: %w", cfg.FlushInterval, err)
+			}
+			interval = d
+		}
+		return NewWebhookSink(cfg.URL, cfg.BatchSize, interval, &http.Client{Timeout: 30 * time.Second}), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+}
+
+// WriterSink writes records as JSON lines to an io.Writer.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing JSON lines to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write encodes the record as a single JSON line.
+func (s *WriterSink) Write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the underlying writer if it is not stdout or stderr.
+func (s *WriterSink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok && s.w != os.Stdout && s.w != os.Stderr {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewFileSink creates a sink appending JSON lines to the file at path.
+func NewFileSink(path string) (*WriterSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage file: %w", err)
+	}
+	return NewWriterSink(file), nil
+}
+
+// WebhookSink posts batches of records as a JSON array to an HTTP endpoint.
+// A batch is sent when it is full or when the flush interval elapses.
+type WebhookSink struct {
+	url       string
+	batchSize int
+	client    *http.Client
+
+	mu      sync.Mutex
+	pending []Record
+	flusher *flusher.Flusher
+}
+
+// NewWebhookSink creates a webhook sink and starts its background flusher.
+func NewWebhookSink(url string, batchSize int, interval time.Duration, client *http.Client) *WebhookSink {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	s := &WebhookSink{
+		url:       url,
+		batchSize: batchSize,
+		client:    client,
+	}
+	s.flusher = flusher.Start(interval, s.flush, nil)
+	return s
+}
+
+// Write queues the record and wakes the flusher when the batch is full.
+func (s *WebhookSink) Write(rec Record) error {
+	s.mu.Lock()
+	if len(s.pending) >= s.batchSize*maxPendingBatches {
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, rec)
+	full := len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		s.flusher.Wake()
+	}
+	return nil
+}
+
+// Close stops the flusher and sends any pending records.
+func (s *WebhookSink) Close() error {
+	return s.flusher.Close()
+}
+
+// flush sends the pending records in batches. Records of a failed batch are
+// put back in the queue so they are retried on the next flush.
+func (s *WebhookSink) flush() error {
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		n := len(s.pending)
+		if n > s.batchSize {
+			n = s.batchSize
+		}
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+
+		if err := s.send(batch); err != nil {
+			s.mu.Lock()
+			s.pending = append(batch, s.pending...)
+			s.mu.Unlock()
+			return err
+		}
+	}
+}
+
+func (s *WebhookSink) send(batch []Record) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage batch: %w", err)
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post usage batch: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("usage webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package usage implements usage metering and cost accounting for the OCI GenAI proxy plugin.
+//
+// Every completed request produces a Record that is priced with a configurable per-model
+// price table, aggregated into in-memory totals and written to one or more sinks.
+package usage
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/logging"
+)
+
+// Record describes the usage of a single completed request.
+type Record struct {
+	// Time is when the request completed
+	Time time.Time `json:"time"`
+
+	// Key identifies the client API key (hashed)
+	Key string `json:"key"`
+
+	// User is the end user reported by the client, if any
+	User string `json:"user,omitempty"`
+
+	// Model is the requested model ID
+	Model string `json:"model"`
+
+	// Region is the OCI region that served the request
+	Region string `json:"region,omitempty"`
+
+	// Status is the HTTP status code returned to the client
+	Status int `json:"status"`
+
+	// PromptTokens is the number of prompt tokens reported by the model
+	PromptTokens int `json:"promptTokens"`
+
+	// CompletionTokens is the number of generated tokens reported by the model
+	CompletionTokens int `json:"completionTokens"`
+
+	// TotalTokens is the sum of prompt and completion tokens
+	TotalTokens int `json:"totalTokens"`
+
+	// LatencyMs is the time it took to serve the request in milliseconds
+	LatencyMs int64 `json:"latencyMs"`
+
+	// Cost is the estimated cost of the request according to the price table
+	Cost float64 `json:"cost"`
+}
+
+// Sink is a destination for usage records.
+type Sink interface {
+	Write(rec Record) error
+	Close() error
+}
+
+// Total aggregates the usage of a key, user and model.
+type Total struct {
+	Key              string  `json:"key"`
+	User             string  `json:"user,omitempty"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Ledger prices usage records, keeps running totals and forwards records to the sinks.
+type Ledger struct {
+	prices map[string]config.ModelPrice
+	sinks  []Sink
+	logger *logging.Logger
+	mu     sync.Mutex
+	totals map[string]*Total
+	since  time.Time
+}
+
+// New creates a ledger writing to the given sinks. Sink failures are not logged.
+func New(prices []config.ModelPrice, sinks ...Sink) *Ledger {
+	l := &Ledger{
+		prices: make(map[string]config.ModelPrice, len(prices)),
+		sinks:  sinks,
+		logger: logging.New(config.Logging{}, "", io.Discard),
+		totals: make(map[string]*Total),
+		since:  time.Now().UTC(),
+	}
+	for _, price := range prices {
+		l.prices[price.Model] = price
+	}
+	return l
+}
+
+// NewFromConfig creates a ledger and its sinks from the plugin configuration, logging sink
+// failures to logger.
+func NewFromConfig(cfg config.Usage, logger *logging.Logger) (*Ledger, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := NewSink(sinkCfg)
+		if err != nil {
+			for _, s := range sinks {
+				_ = s.Close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	l := New(cfg.Prices, sinks...)
+	l.logger = logger
+	return l, nil
+}
+
+// Cost estimates the cost of the given token counts for a model.
+// Models missing from the price table cost nothing.
+func (l *Ledger) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := l.prices[model]
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.PromptPerMillion + float64(completionTokens)*price.CompletionPerMillion) / 1e6
+}
+
+// Record prices the record, adds it to the totals and writes it to every sink.
+// Sink failures are logged and never fail the request.
+func (l *Ledger) Record(rec Record) {
+	rec.Cost = l.Cost(rec.Model, rec.PromptTokens, rec.CompletionTokens)
+
+	l.mu.Lock()
+	id := rec.Key + "|" + rec.User + "|" + rec.Model
+	total, ok := l.totals[id]
+	if !ok {
+		total = &Total{Key: rec.Key, User: rec.User, Model: rec.Model}
+		l.totals[id] = total
+	}
+	total.Requests++
+	total.PromptTokens += rec.PromptTokens
+	total.CompletionTokens += rec.CompletionTokens
+	total.TotalTokens += rec.TotalTokens
+	total.Cost += rec.Cost
+	l.mu.Unlock()
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(rec); err != nil {
+			l.logger.Error("failed to write usage record", "error", err)
+		}
+	}
+}
+
+// Filter selects the totals returned by Totals. Empty fields match everything.
+type Filter struct {
+	Key   string
+	User  string
+	Model string
+}
+
+func (f Filter) matches(t *Total) bool {
+	return (f.Key == "" || f.Key == t.Key) &&
+		(f.User == "" || f.User == t.User) &&
+		(f.Model == "" || f.Model == t.Model)
+}
+
+// Totals returns the totals matching the filter, sorted by key, user and model.
+func (l *Ledger) Totals(filter Filter) []Total {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	totals := make([]Total, 0, len(l.totals))
+	for _, total := range l.totals {
+		if filter.matches(total) {
+			totals = append(totals, *total)
+		}
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Key != totals[j].Key {
+			return totals[i].Key < totals[j].Key
+		}
+		if totals[i].User != totals[j].User {
+			return totals[i].User < totals[j].User
+		}
+		return totals[i].Model < totals[j].Model
+	})
+
+	return totals
+}
+
+// Close flushes and closes every sink.
+func (l *Ledger) Close() error {
+	var firstErr error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// summary is the document served by the admin endpoint.
+type summary struct {
+	Since            time.Time `json:"since"`
+	Requests         int       `json:"requests"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	Cost             float64   `json:"cost"`
+	Totals           []Total   `json:"totals"`
+}
+
+// ServeHTTP serves the usage totals as JSON. The key, user and model query
+// parameters narrow the totals down.
+func (l *Ledger) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	totals := l.Totals(Filter{Key: query.Get("key"), User: query.Get("user"), Model: query.Get("model")})
+
+	doc := summary{Since: l.since, Totals: totals}
+	for _, total := range totals {
+		doc.Requests += total.Requests
+		doc.PromptTokens += total.PromptTokens
+		doc.CompletionTokens += total.CompletionTokens
+		doc.TotalTokens += total.TotalTokens
+		doc.Cost += total.Cost
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(doc); err != nil {
+		l.logger.Error("failed to write usage totals", "error", err)
+	}
+}
@@ -0,0 +1,232 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+)
+
+// memorySink collects records in memory.
+type memorySink struct {
+	records []Record
+	closed  bool
+}
+
+func (s *memorySink) Write(rec Record) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func testPrices() []config.ModelPrice {
+	return []config.ModelPrice{
+		{Model: "cohere.command-r-plus", PromptPerMillion: 3, CompletionPerMillion: 15},
+	}
+}
+
+func TestLedger_Cost(t *testing.T) {
+	ledger := New(testPrices())
+
+	cost := ledger.Cost("cohere.command-r-plus", 1000, 2000)
+	if math.Abs(cost-0.033) > 1e-9 {
+		t.Errorf("expected cost 0.033, got %f", cost)
+	}
+
+	if cost := ledger.Cost("unknown", 1000, 2000); cost != 0 {
+		t.Errorf("expected unpriced model to cost nothing, got %f", cost)
+	}
+}
+
+func TestLedger_RecordAndTotals(t *testing.T) {
+	sink := &memorySink{}
+	ledger := New(testPrices(), sink)
+
+	ledger.Record(Record{Key: "a", User: "alice", Model: "cohere.command-r-plus", PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150})
+	ledger.Record(Record{Key: "a", User: "alice", Model: "cohere.command-r-plus", PromptTokens: 200, CompletionTokens: 10, TotalTokens: 210})
+	ledger.Record(Record{Key: "b", Model: "meta.llama-3-70b-instruct", PromptTokens: 5, CompletionTokens: 5, TotalTokens: 10})
+
+	if len(sink.records) != 3 {
+		t.Fatalf("expected 3 records written to sink, got %d", len(sink.records))
+	}
+	if sink.records[0].Cost == 0 {
+		t.Error("expected record to be priced before being written")
+	}
+
+	totals := ledger.Totals(Filter{})
+	if len(totals) != 2 {
+		t.Fatalf("expected 2 totals, got %d", len(totals))
+	}
+
+	first := totals[0]
+	if first.Key != "a" || first.Requests != 2 || first.TotalTokens != 360 {
+		t.Errorf("unexpected totals for key a: %+v", first)
+	}
+
+	filtered := ledger.Totals(Filter{Model: "meta.llama-3-70b-instruct"})
+	if len(filtered) != 1 || filtered[0].Key != "b" {
+		t.Errorf("expected model filter to select key b, got %+v", filtered)
+	}
+
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if !sink.closed {
+		t.Error("expected sink to be closed")
+	}
+}
+
+func TestLedger_ServeHTTP(t *testing.T) {
+	ledger := New(testPrices())
+	ledger.Record(Record{Key: "a", Model: "cohere.command-r-plus", PromptTokens: 1000, CompletionTokens: 0, TotalTokens: 1000})
+	ledger.Record(Record{Key: "b", Model: "cohere.command-r-plus", PromptTokens: 1000, CompletionTokens: 0, TotalTokens: 1000})
+
+	rw := httptest.NewRecorder()
+	ledger.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/ocigenai/usage?key=a", nil))
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rw.Code)
+	}
+
+	var doc summary
+	if err := json.Unmarshal(rw.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse totals: %v", err)
+	}
+	if doc.Requests != 1 || doc.PromptTokens != 1000 || len(doc.Totals) != 1 {
+		t.Errorf("unexpected summary: %+v", doc)
+	}
+
+	rw = httptest.NewRecorder()
+	ledger.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/ocigenai/usage", nil))
+	if rw.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rw.Code)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("failed to create file sink: %v", err)
+	}
+	_ = sink.Write(Record{Key: "a", Model: "m", TotalTokens: 1})
+	_ = sink.Write(Record{Key: "b", Model: "m", TotalTokens: 2})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("failed to close file sink: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read usage file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var rec Record
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("failed to parse record: %v", err)
+	}
+	if rec.Key != "b" || rec.TotalTokens != 2 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	_ = sink.Write(Record{Key: "a", Model: "m"})
+
+	if !strings.HasSuffix(buf.String(), "\n") || !strings.Contains(buf.String(), `"key":"a"`) {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestWebhookSink_Batching(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]Record
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var batch []Record
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, 2, time.Hour, server.Client())
+	for i := 0; i < 5; i++ {
+		_ = sink.Write(Record{Key: "a", TotalTokens: i})
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	total := 0
+	for _, batch := range batches {
+		if len(batch) > 2 {
+			t.Errorf("expected batches of at most 2 records, got %d", len(batch))
+		}
+		total += len(batch)
+	}
+	if total != 5 {
+		t.Errorf("expected 5 records delivered, got %d", total)
+	}
+}
+
+func TestWebhookSink_RetriesFailedBatch(t *testing.T) {
+	var mu sync.Mutex
+	fail := true
+	delivered := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []Record
+		_ = json.NewDecoder(req.Body).Decode(&batch)
+		delivered += len(batch)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, 10, time.Hour, server.Client())
+	_ = sink.Write(Record{Key: "a"})
+
+	if err := sink.flush(); err == nil {
+		t.Fatal("expected flush to fail while the webhook is unavailable")
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if delivered != 1 {
+		t.Errorf("expected failed record to be retried, got %d delivered", delivered)
+	}
+}
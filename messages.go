@@ -0,0 +1,181 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// isMessagesRequest reports whether a request is an Anthropic Messages request, a POST to a path
+// ending with "/messages".
+func isMessagesRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/messages")
+}
+
+// anthropicHeaders are the Anthropic client headers that must not reach OCI.
+var anthropicHeaders = []string{"X-Api-Key", "Anthropic-Version", "Anthropic-Beta"}
+
+// serveMessages proxies an Anthropic Messages request to the OCI chat action and translates the
+// response, or its events, back to the Anthropic format. Errors are reported in the Anthropic
+// format. The request is rate limited, traced, metered and counted in the metrics like a chat
+// completion, but it is not cached.
+func (p *Proxy) serveMessages(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "chat", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "chat")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		ctx:       req.Context(),
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		dialect:   dialectAnthropic,
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+	for _, name := range anthropicHeaders {
+		req.Header.Del(name)
+	}
+
+	messagesReq, err := p.parseMessagesRequest(req)
+	if err != nil {
+		p.logger.Warn("failed to parse Messages request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse Messages request")
+		return
+	}
+	transformer := p.policyTransformer(ex)
+	oracleReq, err := transformer.MessagesToOracleCloudRequest(messagesReq)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	p.logger.Debug("Messages request parsed", "model", messagesReq.Model, "messages", len(messagesReq.Messages), "stream", messagesReq.Stream)
+	ex.request = transform.MessagesChatRequest(messagesReq)
+	ex.apiFormat = oracleReq.ChatRequest.APIFormat
+	span.SetName("chat " + messagesReq.Model)
+
+	if !p.enforcePolicies(rw, ex, transformer) {
+		return
+	}
+	texts, storeTexts := chatRequestTexts(&oracleReq)
+	if !p.guardInput(rw, ex, texts) || !p.moderateInput(rw, ex, texts) {
+		return
+	}
+	storeTexts()
+	if !p.fitContext(rw, ex, &oracleReq) || !p.reserve(rw, ex, oracleReq) {
+		return
+	}
+	setRequestAttributes(span, oracleReq)
+
+	body, err := json.Marshal(oracleReq)
+	if err == nil {
+		err = p.prepareOCIRequest(req, ex, body, chatActionPath)
+	}
+	if err != nil {
+		ex.reservation.Release()
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return
+	}
+
+	recorder := newResponseRecorder(rw, p.guardStreams(ex, func() streamTranslator {
+		ex.translateSpan = ex.span.Child("response-translate", tracing.SpanKindInternal)
+		return messagesStream{ex.settings.transformer.NewMessagesStream(messagesReq.Model)}
+	}))
+	p.forward(recorder, req, parent, span)
+
+	p.respondMessages(rw, ex, recorder)
+	p.complete(ex, recorder)
+}
+
+// parseMessagesRequest reads the request body and decodes the Anthropic Messages request.
+func (p *Proxy) parseMessagesRequest(req *http.Request) (types.MessagesRequest, error) {
+	var messagesReq types.MessagesRequest
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return messagesReq, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return messagesReq, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming Messages request", "body", p.logger.Body(body))
+	}
+
+	err = json.Unmarshal(body, &messagesReq)
+	return messagesReq, err
+}
+
+// respondMessages sends the OCI response recorded from the next handler to the client in the
+// Anthropic format.
+func (p *Proxy) respondMessages(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder) {
+	if recorder.stream != nil {
+		p.closeStream(ex, recorder)
+		return
+	}
+
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+
+	var oracleResp types.OracleCloudResponse
+	if !p.decodeResponse(rw, ex, recorder, translateSpan, &oracleResp) {
+		return
+	}
+
+	resp := ex.settings.transformer.ToMessagesResponse(oracleResp, ex.request.Model)
+	ex.responseID = resp.ID
+	if resp.StopReason != nil {
+		ex.finishReason = *resp.StopReason
+	}
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+// messagesStream streams Anthropic Messages events.
+type messagesStream struct {
+	*transform.MessagesStream
+}
+
+func (s messagesStream) translate(data []byte) ([]byte, error) {
+	events, err := s.Event(data)
+	if err != nil {
+		return nil, err
+	}
+	return appendMessagesEvents(nil, events)
+}
+
+func (s messagesStream) finish() ([]byte, error) {
+	return appendMessagesEvents(nil, s.Finish())
+}
+
+func (s messagesStream) summary() (string, string) {
+	return s.ID(), s.StopReason()
+}
+
+// appendMessagesEvents appends Anthropic events to out as server-sent events named after their type.
+func appendMessagesEvents(out []byte, events []types.MessagesStreamEvent) ([]byte, error) {
+	var err error
+	for _, event := range events {
+		out = append(out, "event: "+event.Type+"\n"...)
+		if out, err = appendDataEvent(out, event); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,192 @@
+package ocigenai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// readMessagesEvents decodes the events of a streamed Anthropic Messages response, checking that
+// each event is named after its type.
+func readMessagesEvents(t *testing.T, body []byte) []types.MessagesStreamEvent {
+	t.Helper()
+	var events []types.MessagesStreamEvent
+	var name string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var event types.MessagesStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("failed to decode event %s: %v", line, err)
+			}
+			if event.Type != name {
+				t.Errorf("expected event %s to carry its type, got %s", name, line)
+			}
+			events = append(events, event)
+		default:
+			t.Fatalf("unexpected line in event stream: %q", line)
+		}
+	}
+	return events
+}
+
+// anthropicHeader holds the headers of an Anthropic client, which sends its key in X-Api-Key.
+var anthropicHeader = []string{"Authorization", "", "X-Api-Key", "sk-ant-test", "Anthropic-Version", "2023-06-01"}
+
+func TestProxy_Messages(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/messages", `{
+		"model": "cohere.command-r-plus",
+		"max_tokens": 64,
+		"system": "You are terse.",
+		"messages": [{"role": "user", "content": [{"type": "text", "text": "Hello"}]}]
+	}`, anthropicHeader...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	if forwarded.URL.Path != "/20231130/actions/chat" {
+		t.Errorf("expected request to be routed to the chat action, got %s", forwarded.URL.Path)
+	}
+	if forwarded.Header.Get("X-Api-Key") != "" || forwarded.Header.Get("Anthropic-Version") != "" {
+		t.Errorf("expected the Anthropic headers to be removed, got %v", forwarded.Header)
+	}
+
+	var oracleReq types.OracleCloudRequest
+	if err := tp.genai.Requests(ocitest.ChatAction)[0].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	if oracleReq.ChatRequest.APIFormat != "COHERE" || oracleReq.ChatRequest.Message != "Hello" || oracleReq.ChatRequest.MaxTokens != 64 {
+		t.Errorf("unexpected chat request %+v", oracleReq.ChatRequest)
+	}
+	if oracleReq.ChatRequest.PreambleOverride != "You are terse." {
+		t.Errorf("expected the system prompt as the preamble, got %q", oracleReq.ChatRequest.PreambleOverride)
+	}
+
+	var message types.MessagesResponse
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if message.Type != "message" || message.Role != "assistant" || message.Model != "cohere.command-r-plus" || !strings.HasPrefix(message.ID, "msg_") {
+		t.Errorf("unexpected message: %s", body)
+	}
+	if len(message.Content) != 1 || message.Content[0].Type != "text" || message.Content[0].Text != "Echo: Hello" {
+		t.Errorf("expected a single text block, got %s", body)
+	}
+	if message.StopReason == nil || *message.StopReason != "end_turn" || message.Usage.InputTokens == 0 || message.Usage.OutputTokens != 2 {
+		t.Errorf("unexpected stop reason or usage: %s", body)
+	}
+}
+
+func TestProxy_MessagesStream(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/messages", `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"max_tokens": 64,
+		"stream": true,
+		"messages": [{"role": "user", "content": "Hello there"}]
+	}`, anthropicHeader...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	// message_start, one text block with a delta per word, message_delta and message_stop
+	events := readMessagesEvents(t, body)
+	expected := []string{"message_start", "content_block_start", "content_block_delta", "content_block_delta", "content_block_delta",
+		"content_block_stop", "message_delta", "message_stop"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %s", expected, body)
+	}
+	var text strings.Builder
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], event.Type)
+		}
+		if event.Type == "content_block_delta" {
+			text.WriteString(event.Delta.Text)
+		}
+	}
+	if text.String() != "Echo: Hello there" {
+		t.Errorf("expected streamed text %q, got %q", "Echo: Hello there", text.String())
+	}
+	final := events[6]
+	if final.Delta.StopReason != "end_turn" || final.Usage == nil || final.Usage.OutputTokens != 3 {
+		t.Errorf("expected the stop reason and usage, got %+v", final)
+	}
+}
+
+func TestProxy_MessagesErrors(t *testing.T) {
+	tp := newTestProxy(t, nil)
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.ErrorReply(http.StatusNotFound, "NotAuthorizedOrNotFound", "Unknown model."))
+
+	tests := []struct {
+		name    string
+		request string
+		status  int
+		errType string
+	}{
+		{"invalid json", `{"model":`, http.StatusBadRequest, "invalid_request_error"},
+		{"unknown role", `{"model":"gpt-4","max_tokens":8,"messages":[{"role":"system","content":"Hi"}]}`, http.StatusBadRequest, "invalid_request_error"},
+		{"upstream error", `{"model":"gpt-4","max_tokens":8,"messages":[{"role":"user","content":"Hi"}]}`, http.StatusNotFound, "not_found_error"},
+	}
+
+	for _, tt := range tests {
+		resp, body := tp.post(t, "/v1/messages", tt.request, anthropicHeader...)
+		var errResp types.AnthropicErrorResponse
+		if err := json.Unmarshal(body, &errResp); err != nil {
+			t.Fatalf("%s: failed to decode error: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.status || errResp.Type != "error" || errResp.Error.Type != tt.errType || errResp.Error.Message == "" {
+			t.Errorf("%s: expected a %d %s error, got %d: %s", tt.name, tt.status, tt.errType, resp.StatusCode, body)
+		}
+	}
+	if len(tp.forwarded) != 1 {
+		t.Errorf("expected only the valid request to be forwarded, got %d", len(tp.forwarded))
+	}
+}
+
+func TestProxy_MessagesClientKey(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.RateLimit.RequestsPerMinute = 1
+		cfg.Policies = []config.ParameterPolicy{
+			{Name: "interns", Keys: []string{"sk-ant-intern"}, Max: map[string]float64{"max_tokens": 16}},
+		}
+	})
+
+	postAs := func(key string) (*http.Response, []byte) {
+		return tp.post(t, "/v1/messages", `{"model":"gpt-4","max_tokens":64,"messages":[{"role":"user","content":"Hi"}]}`, "Authorization", "", "X-Api-Key", key)
+	}
+
+	// Without an Authorization header, X-Api-Key identifies the client
+	if resp, body := postAs("sk-ant-intern"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if maxTokens := tp.lastOCIRequest(t).ChatRequest.MaxTokens; maxTokens != 16 {
+		t.Errorf("expected the policy of the key to cap max_tokens at 16, got %d", maxTokens)
+	}
+	if resp, body := postAs("sk-ant-other"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected another key to have its own budget, got %d: %s", resp.StatusCode, body)
+	}
+	if maxTokens := tp.lastOCIRequest(t).ChatRequest.MaxTokens; maxTokens != 64 {
+		t.Errorf("expected max_tokens 64 for another key, got %d", maxTokens)
+	}
+	if resp, _ := postAs("sk-ant-intern"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the budget of the first key to be spent, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,164 @@
+package ocigenai
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/metrics"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+)
+
+// proxyMetrics holds the Prometheus metrics of the plugin. They are shared by every
+// plugin instance in the process so that a single scrape reflects all traffic.
+type proxyMetrics struct {
+	registry             *metrics.Registry
+	requests             *metrics.Counter
+	duration             *metrics.Histogram
+	timeToFirstToken     *metrics.Histogram
+	tokens               *metrics.Counter
+	upstreamErrors       *metrics.Counter
+	rateLimited          *metrics.Counter
+	cacheRequests        *metrics.Counter
+	tokenRefreshes       *metrics.Counter
+	tokenRefreshDuration *metrics.Histogram
+}
+
+var (
+	metricsOnce   sync.Once
+	sharedMetrics *proxyMetrics
+)
+
+// loadMetrics returns the process-wide plugin metrics, creating them on first use.
+func loadMetrics() *proxyMetrics {
+	metricsOnce.Do(func() {
+		registry := metrics.NewRegistry()
+		m := &proxyMetrics{
+			registry: registry,
+			requests: registry.NewCounter("ocigenai_requests_total",
+				"Requests processed by the plugin.", "model", "route", "status", "region", "api_format"),
+			duration: registry.NewHistogram("ocigenai_request_duration_seconds",
+				"Time to serve a request, including the upstream call.", metrics.DefaultBuckets,
+				"model", "route", "region", "api_format"),
+			timeToFirstToken: registry.NewHistogram("ocigenai_time_to_first_token_seconds",
+				"Time until the first streamed chunk reached the client.", metrics.DefaultBuckets,
+				"model", "route", "region", "api_format"),
+			tokens: registry.NewCounter("ocigenai_tokens_total",
+				"Tokens reported by the model.", "model", "route", "region", "api_format", "type"),
+			upstreamErrors: registry.NewCounter("ocigenai_upstream_errors_total",
+				"Error responses returned by OCI, by OCI error code.", "model", "region", "status", "code"),
+			rateLimited: registry.NewCounter("ocigenai_rate_limited_total",
+				"Requests rejected by the rate limiter.", "model", "budget"),
+			cacheRequests: registry.NewCounter("ocigenai_cache_requests_total",
+				"Cacheable requests, by cache result.", "model", "result"),
+			tokenRefreshes: registry.NewCounter("ocigenai_federation_token_refresh_total",
+				"Federation security token refresh attempts.", "result"),
+			tokenRefreshDuration: registry.NewHistogram("ocigenai_federation_token_refresh_duration_seconds",
+				"Time taken to refresh the federation security token.", metrics.DefaultBuckets, "result"),
+		}
+
+		ocisdk.TokenRefreshObserver = func(d time.Duration, err error) {
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			m.tokenRefreshes.Inc(result)
+			m.tokenRefreshDuration.Observe(d.Seconds(), result)
+		}
+
+		sharedMetrics = m
+	})
+	return sharedMetrics
+}
+
+// endpoints are the path suffixes of the endpoints served by the plugin, longest match first.
+var endpoints = []string{
+	"/chat/completions", "/completions", "/embeddings", "/moderations", "/rerank", "/messages",
+	"/responses", "/api/chat", "/api/generate", "/api/embed",
+}
+
+// endpointRoute returns the endpoint a request path matched, so that the route label does not
+// vary with the path prefix clients send. Paths are matched by suffix, like the requests.
+func endpointRoute(path string) string {
+	for _, endpoint := range endpoints {
+		if strings.HasSuffix(path, endpoint) {
+			return endpoint
+		}
+	}
+	return "other"
+}
+
+// otherModels are the OCI embedding and rerank models, which the model registry does not hold.
+var otherModels = map[string]bool{
+	"cohere.embed-english-v3.0":            true,
+	"cohere.embed-multilingual-v3.0":       true,
+	"cohere.embed-english-light-v3.0":      true,
+	"cohere.embed-multilingual-light-v3.0": true,
+	"cohere.embed-v4.0":                    true,
+	"cohere.rerank-v3.5":                   true,
+}
+
+// metricsModel returns the model label of an exchange: its model when it is a built-in model or
+// one named by the configuration, and "other" otherwise, so that the model names sent by clients
+// do not grow the label sets without bound.
+func metricsModel(ex *exchange) string {
+	model := ex.request.Model
+	if _, ok := ex.settings.known.Lookup(model); ok || otherModels[model] {
+		return model
+	}
+	cfg := ex.settings.config
+	for _, m := range cfg.Ollama.Models {
+		if m == model {
+			return model
+		}
+	}
+	for _, m := range cfg.RateLimit.Models {
+		if m.Model == model {
+			return model
+		}
+	}
+	return "other"
+}
+
+// observeRejection records a request the plugin rejected itself, with the status of its error.
+// The region label is empty when the request was rejected before a target was selected.
+func (m *proxyMetrics) observeRejection(ex *exchange, status int) {
+	region := ""
+	if ex.target != nil {
+		region = ex.target.identity.authenticator.Region()
+	}
+	model := metricsModel(ex)
+	m.requests.Inc(model, ex.route, strconv.Itoa(status), region, ex.apiFormat)
+	m.duration.Observe(time.Since(ex.start).Seconds(), model, ex.route, region, ex.apiFormat)
+}
+
+// observe records the metrics of a completed exchange.
+func (m *proxyMetrics) observe(ex *exchange, region string, recorder *responseRecorder) {
+	model := metricsModel(ex)
+	status := recorder.Status()
+
+	m.requests.Inc(model, ex.route, strconv.Itoa(status), region, ex.apiFormat)
+	m.duration.Observe(time.Since(ex.start).Seconds(), model, ex.route, region, ex.apiFormat)
+
+	if isEventStream(recorder.Header().Get("Content-Type")) && !recorder.firstWrite.IsZero() {
+		m.timeToFirstToken.Observe(recorder.firstWrite.Sub(ex.start).Seconds(), model, ex.route, region, ex.apiFormat)
+	}
+
+	if ex.usage != nil {
+		m.tokens.Add(float64(ex.usage.PromptTokens), model, ex.route, region, ex.apiFormat, "prompt")
+		m.tokens.Add(float64(ex.usage.CompletionTokens), model, ex.route, region, ex.apiFormat, "completion")
+	}
+
+	switch {
+	case ex.cached:
+		m.cacheRequests.Inc(model, "hit")
+	case ex.cacheKey != "":
+		m.cacheRequests.Inc(model, "miss")
+	}
+
+	if status >= 400 {
+		m.upstreamErrors.Inc(model, region, strconv.Itoa(status), transform.ErrorCode(recorder.body.Bytes()))
+	}
+}
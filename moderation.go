@@ -0,0 +1,248 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// isModerationsRequest reports whether a request is an OpenAI moderations request, a POST to a
+// path ending with "/moderations".
+func isModerationsRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/moderations")
+}
+
+// serveModerations answers an OpenAI moderations request with the OCI applyGuardrails action,
+// called once per input, in order, up to the configured number of inputs. Moderations requests
+// are rate limited, traced, metered and counted in the metrics under their model, but they are
+// not cached. OCI reports no usage for applyGuardrails, so the tokens of the inputs are metered as
+// prompt tokens.
+func (p *Proxy) serveModerations(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "moderations", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "moderations")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		ctx:       req.Context(),
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	moderationReq, err := p.parseModerationRequest(req)
+	if err != nil {
+		p.logger.Warn("failed to parse moderations request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse moderations request")
+		return
+	}
+	inputs, err := transform.ModerationInputs(moderationReq)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	p.logger.Debug("moderations request parsed", "model", moderationReq.Model, "inputs", len(inputs))
+	if limit := ex.settings.config.Moderation.MaxInputs; limit > 0 && len(inputs) > limit {
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "",
+			fmt.Sprintf("Too many inputs: %d, at most %d are allowed", len(inputs), limit))
+		return
+	}
+	ex.request.Model = moderationReq.Model
+
+	tokens := 0
+	for _, input := range inputs {
+		tokens += models.CountTokens(ex.request.Model, input)
+	}
+	if !p.reserveTokens(rw, ex, tokens) {
+		return
+	}
+
+	var recorder *responseRecorder
+	results := make([]types.ModerationResult, 0, len(inputs))
+	for _, input := range inputs {
+		recorder, err = p.applyGuardrails(ex, span, input, true)
+		if err != nil {
+			ex.reservation.Release()
+			span.SetError(err)
+			p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+			return
+		}
+		var resp types.ApplyGuardrailsResponse
+		if !p.decodeResponse(rw, ex, recorder, span, &resp) {
+			p.complete(ex, recorder)
+			return
+		}
+		results = append(results, transform.ToModerationResult(resp, ex.settings.config.Moderation))
+	}
+
+	writeJSON(rw, http.StatusOK, ex.target.transformer.ToModerationResponse(results, moderationReq.Model))
+	ex.usage = &types.Usage{PromptTokens: tokens, TotalTokens: tokens}
+	p.complete(ex, recorder)
+}
+
+// parseModerationRequest reads the request body and decodes the moderations request.
+func (p *Proxy) parseModerationRequest(req *http.Request) (types.ModerationRequest, error) {
+	var moderationReq types.ModerationRequest
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return moderationReq, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return moderationReq, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming moderations request", "body", p.logger.Body(body))
+	}
+
+	err = json.Unmarshal(body, &moderationReq)
+	return moderationReq, err
+}
+
+// applyGuardrails sends text to the OCI applyGuardrails action of the exchange's target, signed
+// with its identity, within span, and returns the recorded response. Prompt injection is only
+// checked in prompts.
+func (p *Proxy) applyGuardrails(ex *exchange, span *tracing.Span, text string, prompt bool) (*responseRecorder, error) {
+	body, err := json.Marshal(ex.target.transformer.ToApplyGuardrailsRequest(text, prompt))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ex.ctx, http.MethodPost, ex.target.identity.endpoint.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.prepareOCIRequest(req, ex, body, applyGuardrailsActionPath); err != nil {
+		return nil, err
+	}
+
+	// The checks are never streamed, so the recorder buffers the whole response
+	recorder := newResponseRecorder(nil, nil)
+	p.forward(recorder, req, tracing.SpanContext{}, span)
+	return recorder, nil
+}
+
+// moderate checks text with the OCI applyGuardrails action and scores the outcome against the
+// configured thresholds.
+func (p *Proxy) moderate(ex *exchange, text string, prompt bool) (types.ModerationResult, error) {
+	span := ex.span.Child("moderation", tracing.SpanKindInternal)
+	defer span.End()
+
+	recorder, err := p.applyGuardrails(ex, span, text, prompt)
+	if err != nil {
+		span.SetError(err)
+		return types.ModerationResult{}, err
+	}
+	if recorder.Status() >= http.StatusBadRequest {
+		err = fmt.Errorf("applyGuardrails failed with status %d: %s", recorder.Status(), transform.ToOpenAIError(recorder.Status(), recorder.body.Bytes()).Error.Message)
+		span.SetError(err)
+		return types.ModerationResult{}, err
+	}
+
+	var resp types.ApplyGuardrailsResponse
+	if err := json.Unmarshal(recorder.body.Bytes(), &resp); err != nil {
+		span.SetError(err)
+		return types.ModerationResult{}, fmt.Errorf("failed to parse applyGuardrails response: %w", err)
+	}
+	result := transform.ToModerationResult(resp, ex.settings.config.Moderation)
+	span.SetAttribute("moderation.flagged", result.Flagged)
+	return result, nil
+}
+
+// moderateInput checks the prompts of an exchange with OCI applyGuardrails, when input checks
+// are enabled. Flagged prompts are rejected with a content_filter error and false is returned;
+// so are prompts that cannot be checked, unless the checks fail open.
+func (p *Proxy) moderateInput(rw http.ResponseWriter, ex *exchange, texts []*string) bool {
+	if !ex.settings.config.Moderation.Input {
+		return true
+	}
+
+	prompt := make([]string, 0, len(texts))
+	for _, text := range texts {
+		if *text != "" {
+			prompt = append(prompt, *text)
+		}
+	}
+	if len(prompt) == 0 {
+		return true
+	}
+
+	result, err := p.moderate(ex, strings.Join(prompt, "\n\n"), true)
+	if err != nil {
+		p.logger.Error("failed to moderate prompt", "error", err)
+		if ex.settings.config.Moderation.FailOpen {
+			return true
+		}
+		p.reject(rw, ex, http.StatusBadGateway, "server_error", "", "Failed to moderate the request")
+		return false
+	}
+	if !result.Flagged {
+		return true
+	}
+
+	categories := strings.Join(transform.FlaggedCategories(result), ", ")
+	p.logger.Info("moderation flagged", "stage", "input", "categories", categories, "key", ex.clientKey)
+	ex.span.SetAttribute("moderation.flagged_categories", categories)
+	p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", contentFilter,
+		"The request was flagged by moderation: "+categories+".")
+	return false
+}
+
+// moderateOutput checks the texts of a decoded OCI chat or generateText response with OCI
+// applyGuardrails, when output checks are enabled. Flagged choices are withheld, and so are
+// choices that cannot be checked, unless the checks fail open. The texts are checked
+// concurrently, so that the response waits for the slowest check rather than for all of them.
+func (p *Proxy) moderateOutput(ex *exchange, v interface{}) {
+	if !ex.settings.config.Moderation.Output {
+		return
+	}
+
+	texts := generatedTexts(v)
+	results := make([]types.ModerationResult, len(texts))
+	errs := make([]error, len(texts))
+	var wg sync.WaitGroup
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = p.moderate(ex, *texts[i].text, false)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, generated := range texts {
+		result, err := results[i], errs[i]
+		if err != nil {
+			p.logger.Error("failed to moderate response", "error", err)
+			if !ex.settings.config.Moderation.FailOpen {
+				generated.withhold()
+			}
+			continue
+		}
+		if result.Flagged {
+			categories := strings.Join(transform.FlaggedCategories(result), ", ")
+			p.logger.Info("moderation flagged", "stage", "output", "categories", categories, "key", ex.clientKey)
+			ex.span.SetAttribute("moderation.flagged_categories", categories)
+			generated.withhold()
+		}
+	}
+}
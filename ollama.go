@@ -0,0 +1,303 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// Endpoints of the Ollama API served by the plugin.
+const (
+	ollamaChat     = "chat"
+	ollamaGenerate = "generate"
+	ollamaEmbed    = "embed"
+	ollamaTags     = "tags"
+)
+
+// ollamaEndpoint returns the Ollama endpoint a request is sent to: a POST to a path ending with
+// "/api/chat", "/api/generate" or "/api/embed", or a GET to a path ending with "/api/tags". It
+// returns "" for other requests.
+func ollamaEndpoint(req *http.Request) string {
+	if req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/api/tags") {
+		return ollamaTags
+	}
+	if req.Method != http.MethodPost {
+		return ""
+	}
+	for _, endpoint := range []string{ollamaChat, ollamaGenerate, ollamaEmbed} {
+		if strings.HasSuffix(req.URL.Path, "/api/"+endpoint) {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// serveOllama serves a request to an Ollama endpoint. Errors are reported in the Ollama format.
+func (p *Proxy) serveOllama(rw http.ResponseWriter, req *http.Request, endpoint string) {
+	switch endpoint {
+	case ollamaTags:
+		writeJSON(rw, http.StatusOK, p.current().transformer.OllamaTags())
+	case ollamaEmbed:
+		p.serveOllamaEmbed(rw, req)
+	default:
+		p.serveOllamaChat(rw, req, endpoint == ollamaGenerate)
+	}
+}
+
+// serveOllamaChat proxies an Ollama chat or generate request to the OCI chat action and
+// translates the response, or its events, back to newline-delimited JSON. Requests without
+// messages or prompt, which Ollama clients send to load a model, are answered right away. The
+// request is rate limited, traced, metered and counted in the metrics like a chat completion, but
+// it is not cached.
+func (p *Proxy) serveOllamaChat(rw http.ResponseWriter, req *http.Request, generate bool) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "chat", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "chat")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		ctx:       req.Context(),
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		dialect:   dialectOllama,
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	body, err := p.readOllamaRequest(req)
+	var model string
+	var oracleReq types.OracleCloudRequest
+	var loaded bool
+	transformer := p.policyTransformer(ex)
+	if generate {
+		var generateReq types.OllamaGenerateRequest
+		if err == nil {
+			err = json.Unmarshal(body, &generateReq)
+		}
+		if err == nil {
+			model, loaded = generateReq.Model, generateReq.Prompt == ""
+			ex.request = transform.OllamaGenerateRequest(generateReq)
+			oracleReq, err = transformer.OllamaGenerateToOracleCloudRequest(generateReq)
+		}
+	} else {
+		var chatReq types.OllamaChatRequest
+		if err == nil {
+			err = json.Unmarshal(body, &chatReq)
+		}
+		if err == nil {
+			model, loaded = chatReq.Model, len(chatReq.Messages) == 0
+			ex.request = transform.OllamaChatRequest(chatReq)
+			oracleReq, err = transformer.OllamaChatToOracleCloudRequest(chatReq)
+		}
+	}
+	switch {
+	case model == "" && err != nil:
+		p.logger.Warn("failed to parse Ollama request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse Ollama request")
+		return
+	case loaded:
+		writeOllamaResponse(rw, ex.settings.transformer.OllamaLoadResponse(model), generate)
+		return
+	case err != nil:
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	p.logger.Debug("Ollama request parsed", "model", model, "generate", generate, "stream", oracleReq.ChatRequest.IsStream)
+	ex.apiFormat = oracleReq.ChatRequest.APIFormat
+	span.SetName("chat " + model)
+
+	if !p.enforcePolicies(rw, ex, transformer) {
+		return
+	}
+	texts, storeTexts := chatRequestTexts(&oracleReq)
+	if !p.guardInput(rw, ex, texts) || !p.moderateInput(rw, ex, texts) {
+		return
+	}
+	storeTexts()
+	if !p.fitContext(rw, ex, &oracleReq) || !p.reserve(rw, ex, oracleReq) {
+		return
+	}
+	setRequestAttributes(span, oracleReq)
+
+	body, err = json.Marshal(oracleReq)
+	if err == nil {
+		err = p.prepareOCIRequest(req, ex, body, chatActionPath)
+	}
+	if err != nil {
+		ex.reservation.Release()
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return
+	}
+
+	recorder := newResponseRecorder(rw, p.guardStreams(ex, func() streamTranslator {
+		ex.translateSpan = ex.span.Child("response-translate", tracing.SpanKindInternal)
+		return ollamaStream{ex.settings.transformer.NewOllamaStream(model), generate}
+	}))
+	p.forward(recorder, req, parent, span)
+
+	p.respondOllama(rw, ex, recorder, model, generate)
+	p.complete(ex, recorder)
+}
+
+// readOllamaRequest reads the body of an Ollama request.
+func (p *Proxy) readOllamaRequest(req *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming Ollama request", "body", p.logger.Body(body))
+	}
+	return body, nil
+}
+
+// respondOllama sends the OCI response recorded from the next handler to the client as an Ollama
+// chat or generate response.
+func (p *Proxy) respondOllama(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder, model string, generate bool) {
+	if recorder.stream != nil {
+		p.closeStream(ex, recorder)
+		return
+	}
+
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+
+	var oracleResp types.OracleCloudResponse
+	if !p.decodeResponse(rw, ex, recorder, translateSpan, &oracleResp) {
+		return
+	}
+
+	ex.finishReason = transform.FinishReason(oracleResp.ChatResponse.FinishReason)
+	if len(oracleResp.ChatResponse.Choices) > 0 {
+		ex.finishReason = transform.FinishReason(oracleResp.ChatResponse.Choices[0].FinishReason)
+	}
+	writeOllamaResponse(rw, ex.settings.transformer.ToOllamaChatResponse(oracleResp, model), generate)
+}
+
+// writeOllamaResponse answers the client with a chat response, converted to a generate response
+// for generate requests.
+func writeOllamaResponse(rw http.ResponseWriter, resp types.OllamaChatResponse, generate bool) {
+	if generate {
+		writeJSON(rw, http.StatusOK, transform.ToOllamaGenerateResponse(resp))
+		return
+	}
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+// serveOllamaEmbed proxies an Ollama embed request to the OCI embedText action. Embed requests
+// are rate limited, traced, metered and counted in the metrics under their model, but they are
+// not cached.
+func (p *Proxy) serveOllamaEmbed(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "embeddings", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "embeddings")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		dialect:   dialectOllama,
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	var embedReq types.OllamaEmbedRequest
+	body, err := p.readOllamaRequest(req)
+	if err == nil {
+		err = json.Unmarshal(body, &embedReq)
+	}
+	if err != nil {
+		p.logger.Warn("failed to parse Ollama embed request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse Ollama embed request")
+		return
+	}
+	p.logger.Debug("Ollama embed request parsed", "model", embedReq.Model, "inputs", len(embedReq.Input))
+	ex.request.Model = embedReq.Model
+	span.SetName("embeddings " + embedReq.Model)
+	span.SetAttribute("gen_ai.request.model", embedReq.Model)
+
+	embedTextReq, err := ex.target.transformer.OllamaEmbedToEmbedTextRequest(embedReq)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	recorder, embedTextResp, ok := p.embedText(rw, req, ex, parent, embedTextReq)
+	if ok {
+		writeJSON(rw, http.StatusOK, transform.ToOllamaEmbedResponse(embedTextResp, embedReq.Model))
+	}
+	if recorder != nil {
+		p.complete(ex, recorder)
+	}
+}
+
+// ollamaStream streams Ollama chat or generate responses as newline-delimited JSON.
+type ollamaStream struct {
+	*transform.OllamaStream
+	generate bool
+}
+
+func (s ollamaStream) translate(data []byte) ([]byte, error) {
+	lines, err := s.Event(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.appendLines(nil, lines)
+}
+
+func (s ollamaStream) finish() ([]byte, error) {
+	return s.appendLines(nil, []types.OllamaChatResponse{s.Finish()})
+}
+
+func (s ollamaStream) summary() (string, string) {
+	return "", s.FinishReason()
+}
+
+func (s ollamaStream) contentType() string {
+	return "application/x-ndjson"
+}
+
+// appendLines appends lines to out as JSON lines, converted to generate responses for generate streams.
+func (s ollamaStream) appendLines(out []byte, lines []types.OllamaChatResponse) ([]byte, error) {
+	for _, line := range lines {
+		var v interface{} = line
+		if s.generate {
+			v = transform.ToOllamaGenerateResponse(line)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(out, data...), '\n')
+	}
+	return out, nil
+}
@@ -0,0 +1,261 @@
+package ocigenai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// readOllamaLines decodes the lines of a streamed Ollama response.
+func readOllamaLines(t *testing.T, body []byte, v func() interface{}) {
+	t.Helper()
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), v()); err != nil {
+			t.Fatalf("failed to decode line %q: %v", scanner.Text(), err)
+		}
+	}
+}
+
+func TestProxy_OllamaChat(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/api/chat", `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"stream": false,
+		"messages": [{"role": "system", "content": "You are terse."}, {"role": "user", "content": "Hello"}],
+		"options": {"temperature": 0.2, "num_predict": 64}
+	}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	if forwarded.URL.Path != "/20231130/actions/chat" {
+		t.Errorf("expected request to be routed to the chat action, got %s", forwarded.URL.Path)
+	}
+	var oracleReq types.OracleCloudRequest
+	if err := tp.genai.Requests(ocitest.ChatAction)[0].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	if oracleReq.ChatRequest.MaxTokens != 64 || oracleReq.ChatRequest.Temperature != 0.2 || len(oracleReq.ChatRequest.Messages) != 2 {
+		t.Errorf("expected the options and messages to be forwarded, got %+v", oracleReq.ChatRequest)
+	}
+
+	var chatResp types.OllamaChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		t.Fatal(err)
+	}
+	if !chatResp.Done || chatResp.DoneReason != "stop" || chatResp.Message.Role != "assistant" || chatResp.Message.Content != "Echo: Hello" {
+		t.Errorf("unexpected response: %s", body)
+	}
+	if chatResp.Model != "meta.llama-3.3-70b-instruct" || chatResp.EvalCount != 2 || chatResp.PromptEvalCount == 0 {
+		t.Errorf("expected the model and token counts, got %s", body)
+	}
+}
+
+func TestProxy_OllamaChatStream(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	// Ollama streams unless the client opts out
+	resp, body := tp.post(t, "/api/chat", `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"messages": [{"role": "user", "content": "Hello there"}]
+	}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("expected a newline-delimited JSON stream, got %s", contentType)
+	}
+
+	var lines []types.OllamaChatResponse
+	readOllamaLines(t, body, func() interface{} {
+		lines = append(lines, types.OllamaChatResponse{})
+		return &lines[len(lines)-1]
+	})
+	if len(lines) != 4 {
+		t.Fatalf("expected a line per word and a final line, got %s", body)
+	}
+	var text string
+	for _, line := range lines[:3] {
+		if line.Done {
+			t.Errorf("expected only the last line to be done, got %+v", line)
+		}
+		text += line.Message.Content
+	}
+	if text != "Echo: Hello there" {
+		t.Errorf("expected the streamed text, got %q", text)
+	}
+	final := lines[3]
+	if !final.Done || final.DoneReason != "stop" || final.EvalCount != 3 {
+		t.Errorf("expected the final line to carry the done reason and counts, got %+v", final)
+	}
+}
+
+func TestProxy_OllamaGenerate(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/api/generate", `{"model":"meta.llama-3.3-70b-instruct","system":"Be brief.","prompt":"Hello","stream":false}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var generateResp types.OllamaGenerateResponse
+	if err := json.Unmarshal(body, &generateResp); err != nil {
+		t.Fatal(err)
+	}
+	if !generateResp.Done || generateResp.Response != "Echo: Hello" {
+		t.Errorf("unexpected response: %s", body)
+	}
+
+	resp, body = tp.post(t, "/api/generate", `{"model":"meta.llama-3.3-70b-instruct","prompt":"Hello"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var lines []types.OllamaGenerateResponse
+	readOllamaLines(t, body, func() interface{} {
+		lines = append(lines, types.OllamaGenerateResponse{})
+		return &lines[len(lines)-1]
+	})
+	var text string
+	for _, line := range lines {
+		text += line.Response
+	}
+	if text != "Echo: Hello" || len(lines) == 0 || !lines[len(lines)-1].Done {
+		t.Errorf("expected the streamed text and a final line, got %s", body)
+	}
+
+	// Requests without a prompt load the model and are not forwarded
+	resp, body = tp.post(t, "/api/generate", `{"model":"meta.llama-3.3-70b-instruct"}`)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"done_reason":"load"`) {
+		t.Errorf("expected a load response, got %d: %s", resp.StatusCode, body)
+	}
+	if len(tp.genai.Requests(ocitest.ChatAction)) != 2 {
+		t.Errorf("expected the load request not to be forwarded")
+	}
+}
+
+func TestProxy_OllamaCohere(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/api/chat", `{
+		"model": "cohere.command-r-plus",
+		"stream": false,
+		"messages": [
+			{"role": "system", "content": "You are terse."},
+			{"role": "user", "content": "Hello"},
+			{"role": "assistant", "content": "Hi."},
+			{"role": "user", "content": "How are you?"}
+		]
+	}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	chatReq := tp.lastOCIRequest(t).ChatRequest
+	if chatReq.APIFormat != "COHERE" || chatReq.PreambleOverride != "You are terse." || chatReq.Message != "How are you?" {
+		t.Errorf("expected the system message as the preamble and the last message as the prompt, got %+v", chatReq)
+	}
+	if len(chatReq.ChatHistory) != 2 || chatReq.ChatHistory[0].Role != "USER" || chatReq.ChatHistory[1].Role != "CHATBOT" || chatReq.ChatHistory[1].Message != "Hi." {
+		t.Errorf("expected the earlier messages as the chat history, got %+v", chatReq.ChatHistory)
+	}
+
+	resp, body = tp.post(t, "/api/generate", `{"model":"cohere.command-r-plus","system":"Be brief.","prompt":"Hello","stream":false}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	chatReq = tp.lastOCIRequest(t).ChatRequest
+	if chatReq.PreambleOverride != "Be brief." || chatReq.Message != "Hello" || len(chatReq.ChatHistory) != 0 {
+		t.Errorf("expected the system prompt as the preamble, got %+v", chatReq)
+	}
+}
+
+func TestProxy_OllamaEmbed(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/api/embed", `{"model":"cohere.embed-english-v3.0","input":["Hello","World"]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if path := tp.lastForwarded(t).URL.Path; path != "/20231130/actions/embedText" {
+		t.Errorf("expected request to be routed to the embedText action, got %s", path)
+	}
+	var embedReq types.EmbedTextRequest
+	if err := tp.genai.Requests(ocitest.EmbedTextAction)[0].Decode(&embedReq); err != nil {
+		t.Fatal(err)
+	}
+	if embedReq.ServingMode.ModelID != "cohere.embed-english-v3.0" || embedReq.Truncate != "END" || len(embedReq.Inputs) != 2 {
+		t.Errorf("unexpected embedText request: %+v", embedReq)
+	}
+
+	var embedResp types.OllamaEmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		t.Fatal(err)
+	}
+	if embedResp.Model != "cohere.embed-english-v3.0" || len(embedResp.Embeddings) != 2 || len(embedResp.Embeddings[0]) != ocitest.EmbeddingDimensions {
+		t.Errorf("expected an embedding per input, got %s", body)
+	}
+}
+
+func TestProxy_OllamaTags(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Ollama.Models = []string{"meta.llama-3.3-70b-instruct", "cohere.command-r-plus"}
+	})
+
+	req, err := http.NewRequest(http.MethodGet, tp.server.URL+"/api/tags", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := tp.do(t, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var tags types.OllamaTagsResponse
+	if err := json.Unmarshal(body, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.Models) != 2 || tags.Models[0].Name != "meta.llama-3.3-70b-instruct" || tags.Models[1].Details.Family != "cohere" {
+		t.Errorf("expected the configured models, got %s", body)
+	}
+	if len(tp.forwarded) != 0 {
+		t.Errorf("expected the tags request not to be forwarded, got %d", len(tp.forwarded))
+	}
+}
+
+func TestProxy_OllamaErrors(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.ErrorReply(http.StatusNotFound, "NotAuthorizedOrNotFound", "Unknown model."))
+	tests := []struct {
+		name    string
+		path    string
+		request string
+		status  int
+	}{
+		{"invalid json", "/api/chat", `{"model":`, http.StatusBadRequest},
+		{"unknown role", "/api/chat", `{"model":"m","messages":[{"role":"developer","content":"Hi"}]}`, http.StatusBadRequest},
+		{"missing embed input", "/api/embed", `{"model":"m"}`, http.StatusBadRequest},
+		{"upstream error", "/api/chat", `{"model":"m","stream":false,"messages":[{"role":"user","content":"Hi"}]}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		resp, body := tp.post(t, tt.path, tt.request)
+		var errResp types.OllamaError
+		if err := json.Unmarshal(body, &errResp); err != nil {
+			t.Fatalf("%s: failed to decode error: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.status || errResp.Error == "" {
+			t.Errorf("%s: expected a %d error, got %d: %s", tt.name, tt.status, resp.StatusCode, body)
+		}
+	}
+	if len(tp.forwarded) != 1 {
+		t.Errorf("expected only the valid request to be forwarded, got %d", len(tp.forwarded))
+	}
+}
@@ -1,37 +1,1640 @@
 // Package types defines the data structures used throughout the OCI GenAI proxy plugin.
 package types
 
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
 // ChatCompletionMessage represents a message in a chat completion conversation.
 type ChatCompletionMessage struct {
-	// Role is the role of the author of this message (e.g., "user", "assistant", "system")
+	// Role is the role of the author of this message (e.g., "user", "assistant", "system", "tool")
+	Role string `json:"role"`
+
+	// Content is the content of the message. For multimodal messages it holds the text of their parts.
+	Content string `json:"content"`
+
+	// Parts holds the content parts of a message whose content is an array, such as text and images
+	Parts []ContentPart `json:"-"`
+
+	// Name is an optional name of the author
+	Name string `json:"name,omitempty"`
+
+	// ToolCalls are the tool calls generated by the model, on assistant messages
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// ToolCallID is the tool call a tool message responds to
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// OCI carries the grounding information returned by Cohere models, on assistant messages
+	OCI *OCIExtension `json:"oci,omitempty"`
+}
+
+// UnmarshalJSON decodes a message whose content is either a string or an array of content parts.
+func (m *ChatCompletionMessage) UnmarshalJSON(data []byte) error {
+	type plain ChatCompletionMessage
+	var decoded struct {
+		plain
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*m = ChatCompletionMessage(decoded.plain)
+	content := bytes.TrimSpace(decoded.Content)
+	switch {
+	case len(content) == 0 || string(content) == "null":
+	case content[0] == '[':
+		if err := json.Unmarshal(content, &m.Parts); err != nil {
+			return err
+		}
+		var text []string
+		for _, part := range m.Parts {
+			if part.Type == "text" {
+				text = append(text, part.Text)
+			}
+		}
+		m.Content = strings.Join(text, "\n")
+	default:
+		if err := json.Unmarshal(content, &m.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the content parts, if any, in place of the content. The content of
+// assistant messages carrying only tool calls is null, as in the OpenAI API.
+func (m ChatCompletionMessage) MarshalJSON() ([]byte, error) {
+	type plain ChatCompletionMessage
+	var content interface{} = m.Content
+	switch {
+	case m.Parts != nil:
+		content = m.Parts
+	case m.Content == "" && len(m.ToolCalls) > 0:
+		content = nil
+	}
+	return json.Marshal(struct {
+		Role    string      `json:"role"`
+		Content interface{} `json:"content"`
+		plain
+	}{m.Role, content, plain(m)})
+}
+
+// OCIExtension is the extension field carrying OCI specific results on OpenAI messages and deltas.
+type OCIExtension struct {
+	// Citations link spans of the generated text to the documents supporting them
+	Citations []OCICitation `json:"citations,omitempty"`
+
+	// Documents are the documents cited
+	Documents []json.RawMessage `json:"documents,omitempty"`
+
+	// SearchQueries are the search queries generated for the conversation
+	SearchQueries []string `json:"search_queries,omitempty"`
+
+	// IsSearchRequired reports whether the model deems a search necessary to answer
+	IsSearchRequired *bool `json:"is_search_required,omitempty"`
+}
+
+// OCICitation links a span of the generated text to the documents supporting it.
+type OCICitation struct {
+	// Start and End are the character offsets of the cited span
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Text is the cited span
+	Text string `json:"text"`
+
+	// DocumentIDs identify the supporting documents
+	DocumentIDs []string `json:"document_ids"`
+}
+
+// ContentPart is a part of the content of a multimodal message.
+type ContentPart struct {
+	// Type is the type of the part: "text" or "image_url"
+	Type string `json:"type"`
+
+	// Text is the text of a text part
+	Text string `json:"text,omitempty"`
+
+	// ImageURL is the image of an image part
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL references an image by URL or as a base64 data URL.
+type ImageURL struct {
+	// URL is the image URL or data URL
+	URL string `json:"url"`
+
+	// Detail is the fidelity of the image understanding: "auto", "low" or "high"
+	Detail string `json:"detail,omitempty"`
+}
+
+// Tool is a tool the model may call.
+type Tool struct {
+	// Type is the type of the tool; only "function" is supported
+	Type string `json:"type"`
+
+	// Function describes the function
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function the model may call.
+type FunctionDefinition struct {
+	// Name is the name of the function
+	Name string `json:"name"`
+
+	// Description tells the model what the function does
+	Description string `json:"description,omitempty"`
+
+	// Parameters is the JSON Schema of the function arguments
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a call of a tool generated by the model.
+type ToolCall struct {
+	// Index is the position of the tool call in the message; only set on streamed deltas
+	Index *int `json:"index,omitempty"`
+
+	// ID identifies the tool call, so that tool messages can refer to it
+	ID string `json:"id,omitempty"`
+
+	// Type is the type of the tool; always "function"
+	Type string `json:"type,omitempty"`
+
+	// Function is the function called
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the function called by a tool call.
+type FunctionCall struct {
+	// Name is the name of the function
+	Name string `json:"name,omitempty"`
+
+	// Arguments are the JSON encoded arguments; streamed deltas carry a fragment of them
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionRequest represents a request to the OpenAI chat completion API.
+type ChatCompletionRequest struct {
+	// Model is the ID of the model to use
+	Model string `json:"model"`
+
+	// Messages is a list of messages comprising the conversation so far
+	Messages []ChatCompletionMessage `json:"messages"`
+
+	// MaxTokens is the maximum number of tokens to generate in the chat completion
+	MaxTokens int `json:"max_tokens,omitempty"`
+
+	// Temperature controls randomness (0.0 = deterministic, 2.0 = very random)
+	Temperature float32 `json:"temperature,omitempty"`
+
+	// TopP controls nucleus sampling
+	TopP float32 `json:"top_p,omitempty"`
+
+	// FrequencyPenalty reduces repetition of tokens based on their frequency
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty"`
+
+	// PresencePenalty reduces repetition of tokens based on their presence
+	PresencePenalty float32 `json:"presence_penalty,omitempty"`
+
+	// Stop are sequences that end the generation
+	Stop StopSequences `json:"stop,omitempty"`
+
+	// User is an optional identifier of the end user making the request
+	User string `json:"user,omitempty"`
+
+	// Stream requests the response as server-sent events
+	Stream bool `json:"stream,omitempty"`
+
+	// StreamOptions configures the streamed response
+	StreamOptions *ChatCompletionStreamOptions `json:"stream_options,omitempty"`
+
+	// Tools are the tools the model may call
+	Tools []Tool `json:"tools,omitempty"`
+
+	// ToolChoice controls which tool is called: "none", "auto", "required" or a named function
+	ToolChoice json.RawMessage `json:"tool_choice,omitempty"`
+
+	// Documents ground the answer of Cohere models; each is an object of string fields or a string.
+	// OpenAI SDKs send it through extra_body.
+	Documents []json.RawMessage `json:"documents,omitempty"`
+
+	// CitationQuality trades citation accuracy for speed: "accurate" or "fast"
+	CitationQuality string `json:"citation_quality,omitempty"`
+
+	// IsSearchQueriesOnly only generates search queries for the conversation, without answering
+	IsSearchQueriesOnly bool `json:"is_search_queries_only,omitempty"`
+
+	// explicit records the top-level fields present in the decoded JSON document
+	explicit map[string]bool
+}
+
+// UnmarshalJSON decodes the request and records which fields were explicitly set,
+// so that an explicit zero (e.g. "temperature": 0) can be told apart from an omitted field.
+func (r *ChatCompletionRequest) UnmarshalJSON(data []byte) error {
+	type plain ChatCompletionRequest
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*r = ChatCompletionRequest(decoded)
+	r.explicit = make(map[string]bool, len(fields))
+	for name, value := range fields {
+		if string(value) != "null" {
+			r.explicit[name] = true
+		}
+	}
+	return nil
+}
+
+// IsSet reports whether the named JSON field was present in the decoded request.
+// It is always false for requests that were not decoded from JSON.
+func (r ChatCompletionRequest) IsSet(field string) bool {
+	return r.explicit[field]
+}
+
+// StopSequences are the sequences ending a generation, sent either as a single string or as an array.
+type StopSequences []string
+
+// UnmarshalJSON decodes a single stop sequence or an array of them.
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StopSequences{single}
+		return nil
+	}
+
+	var sequences []string
+	if err := json.Unmarshal(data, &sequences); err != nil {
+		return err
+	}
+	*s = sequences
+	return nil
+}
+
+// ChatCompletionStreamOptions configures a streamed chat completion.
+type ChatCompletionStreamOptions struct {
+	// IncludeUsage adds a final chunk reporting token usage
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatCompletionResponse represents a response from the OpenAI chat completion API.
+type ChatCompletionResponse struct {
+	// ID is a unique identifier for the chat completion
+	ID string `json:"id"`
+
+	// Object is always "chat.completion"
+	Object string `json:"object"`
+
+	// Created is the Unix timestamp in seconds of when the completion was created
+	Created int64 `json:"created"`
+
+	// Model is the model used for the completion
+	Model string `json:"model"`
+
+	// Choices is the list of generated completions
+	Choices []ChatCompletionChoice `json:"choices"`
+
+	// Usage reports token consumption
+	Usage *CompletionUsage `json:"usage,omitempty"`
+}
+
+// ChatCompletionChoice is a single completion in a chat completion response.
+type ChatCompletionChoice struct {
+	// Index is the position of the choice in the list of choices
+	Index int `json:"index"`
+
+	// Message is the generated message
+	Message ChatCompletionMessage `json:"message"`
+
+	// FinishReason explains why the generation stopped (e.g., "stop", "length")
+	FinishReason string `json:"finish_reason"`
+}
+
+// CompletionUsage reports token consumption in the OpenAI API format.
+type CompletionUsage struct {
+	// PromptTokens is the number of tokens in the prompt
+	PromptTokens int `json:"prompt_tokens"`
+
+	// CompletionTokens is the number of tokens generated by the model
+	CompletionTokens int `json:"completion_tokens"`
+
+	// TotalTokens is the sum of prompt and completion tokens
+	TotalTokens int `json:"total_tokens"`
+}
+
+// ChatCompletionChunk is a single server-sent event of a streamed chat completion.
+type ChatCompletionChunk struct {
+	// ID is the identifier of the chat completion, shared by every chunk
+	ID string `json:"id"`
+
+	// Object is always "chat.completion.chunk"
+	Object string `json:"object"`
+
+	// Created is the Unix timestamp in seconds of when the completion was created
+	Created int64 `json:"created"`
+
+	// Model is the model used for the completion
+	Model string `json:"model"`
+
+	// Choices holds the deltas of this chunk; it is empty for the usage chunk
+	Choices []ChatCompletionChunkChoice `json:"choices"`
+
+	// Usage is only set on the final chunk when usage was requested
+	Usage *CompletionUsage `json:"usage,omitempty"`
+}
+
+// ChatCompletionChunkChoice is the delta of a single choice within a chunk.
+type ChatCompletionChunkChoice struct {
+	// Index is the position of the choice in the list of choices
+	Index int `json:"index"`
+
+	// Delta is the incremental message content
+	Delta ChatCompletionDelta `json:"delta"`
+
+	// FinishReason is set on the last chunk of the choice
+	FinishReason *string `json:"finish_reason"`
+}
+
+// ChatCompletionDelta is an incremental update to the generated message.
+type ChatCompletionDelta struct {
+	// Role is only set on the first chunk
+	Role string `json:"role,omitempty"`
+
+	// Content is the generated text of this chunk
+	Content string `json:"content,omitempty"`
+
+	// ToolCalls are fragments of the tool calls generated, identified by their index
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// OCI carries the grounding information returned by Cohere models
+	OCI *OCIExtension `json:"oci,omitempty"`
+}
+
+// CompletionRequest represents a request to the legacy OpenAI text completion API.
+type CompletionRequest struct {
+	// Model is the ID of the model to use
+	Model string `json:"model"`
+
+	// Prompt is the text to complete: a string or an array of strings
+	Prompt json.RawMessage `json:"prompt"`
+
+	// MaxTokens is the maximum number of tokens to generate
+	MaxTokens int `json:"max_tokens,omitempty"`
+
+	// Temperature controls randomness (0.0 = deterministic, 2.0 = very random)
+	Temperature float32 `json:"temperature,omitempty"`
+
+	// TopP controls nucleus sampling
+	TopP float32 `json:"top_p,omitempty"`
+
+	// FrequencyPenalty reduces repetition of tokens based on their frequency
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty"`
+
+	// PresencePenalty reduces repetition of tokens based on their presence
+	PresencePenalty float32 `json:"presence_penalty,omitempty"`
+
+	// N is the number of completions to generate
+	N int `json:"n,omitempty"`
+
+	// Stop are sequences that end the generation
+	Stop StopSequences `json:"stop,omitempty"`
+
+	// Logprobs requests the log probabilities of the generated tokens and of this many alternatives
+	Logprobs *int `json:"logprobs,omitempty"`
+
+	// Echo prepends the prompt to the completion
+	Echo bool `json:"echo,omitempty"`
+
+	// User is an optional identifier of the end user making the request
+	User string `json:"user,omitempty"`
+
+	// Stream requests the response as server-sent events
+	Stream bool `json:"stream,omitempty"`
+
+	// StreamOptions configures the streamed response
+	StreamOptions *ChatCompletionStreamOptions `json:"stream_options,omitempty"`
+
+	// explicit records the top-level fields present in the decoded JSON document
+	explicit map[string]bool
+}
+
+// UnmarshalJSON decodes the request and records which fields were explicitly set.
+func (r *CompletionRequest) UnmarshalJSON(data []byte) error {
+	type plain CompletionRequest
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*r = CompletionRequest(decoded)
+	r.explicit = make(map[string]bool, len(fields))
+	for name, value := range fields {
+		if string(value) != "null" {
+			r.explicit[name] = true
+		}
+	}
+	return nil
+}
+
+// IsSet reports whether the named JSON field was present in the decoded request.
+func (r CompletionRequest) IsSet(field string) bool {
+	return r.explicit[field]
+}
+
+// CompletionResponse represents a response, or a streamed chunk, of the OpenAI text completion API.
+type CompletionResponse struct {
+	// ID is a unique identifier for the completion
+	ID string `json:"id"`
+
+	// Object is always "text_completion"
+	Object string `json:"object"`
+
+	// Created is the Unix timestamp in seconds of when the completion was created
+	Created int64 `json:"created"`
+
+	// Model is the model used for the completion
+	Model string `json:"model"`
+
+	// Choices is the list of generated completions
+	Choices []CompletionChoice `json:"choices"`
+
+	// Usage reports token consumption, when known
+	Usage *CompletionUsage `json:"usage,omitempty"`
+}
+
+// CompletionChoice is a single completion in a text completion response.
+type CompletionChoice struct {
+	// Text is the generated text, or a fragment of it in streamed chunks
+	Text string `json:"text"`
+
+	// Index is the position of the choice in the list of choices
+	Index int `json:"index"`
+
+	// Logprobs are the log probabilities of the generated tokens, when requested
+	Logprobs *CompletionLogprobs `json:"logprobs"`
+
+	// FinishReason explains why the generation stopped; null on streamed chunks until the last one
+	FinishReason *string `json:"finish_reason"`
+}
+
+// CompletionLogprobs are the log probabilities of the tokens of a text completion.
+type CompletionLogprobs struct {
+	// Tokens are the generated tokens
+	Tokens []string `json:"tokens"`
+
+	// TokenLogprobs are the log probabilities of the tokens
+	TokenLogprobs []float64 `json:"token_logprobs"`
+
+	// TopLogprobs are the most likely tokens at each position, with their log probabilities
+	TopLogprobs []map[string]float64 `json:"top_logprobs"`
+
+	// TextOffset are the character offsets of the tokens in the text
+	TextOffset []int `json:"text_offset"`
+}
+
+// RerankRequest is a rerank request in the format shared by the Cohere and Jina rerank APIs.
+type RerankRequest struct {
+	// Model is the identifier of the rerank model
+	Model string `json:"model"`
+
+	// Query is the text the documents are ranked against
+	Query string `json:"query"`
+
+	// Documents are the documents to rank, either strings or objects with a text field
+	Documents []json.RawMessage `json:"documents"`
+
+	// TopN limits the number of results returned; all documents are returned when nil
+	TopN *int `json:"top_n,omitempty"`
+
+	// ReturnDocuments includes the text of the documents in the results
+	ReturnDocuments bool `json:"return_documents,omitempty"`
+}
+
+// RerankResponse is the response to a RerankRequest.
+type RerankResponse struct {
+	// ID is the unique identifier of the response
+	ID string `json:"id"`
+
+	// Model is the model used to rank the documents
+	Model string `json:"model"`
+
+	// Results are the ranked documents, most relevant first
+	Results []RerankResult `json:"results"`
+}
+
+// RerankResult is the rank of a single document.
+type RerankResult struct {
+	// Index is the position of the document in the request
+	Index int `json:"index"`
+
+	// RelevanceScore is the relevance of the document to the query, between 0 and 1
+	RelevanceScore float64 `json:"relevance_score"`
+
+	// Document is the ranked document, when return_documents is set
+	Document *RerankDocument `json:"document,omitempty"`
+}
+
+// RerankDocument is the text of a ranked document.
+type RerankDocument struct {
+	// Text is the content of the document
+	Text string `json:"text"`
+}
+
+// ModerationRequest is an OpenAI moderations request.
+type ModerationRequest struct {
+	// Input is the text to check: a string, an array of strings, or an array of text parts
+	Input json.RawMessage `json:"input"`
+
+	// Model is reported back in the response
+	Model string `json:"model,omitempty"`
+}
+
+// ModerationResponse is the response to a ModerationRequest.
+type ModerationResponse struct {
+	// ID is the unique identifier of the response
+	ID string `json:"id"`
+
+	// Model is the model of the request
+	Model string `json:"model"`
+
+	// Results are the outcomes of the checks, one per input
+	Results []ModerationResult `json:"results"`
+}
+
+// ModerationResult is the outcome of the checks of a single input.
+type ModerationResult struct {
+	// Flagged reports whether any category is flagged
+	Flagged bool `json:"flagged"`
+
+	// Categories reports, by category, whether the score reached its threshold
+	Categories map[string]bool `json:"categories"`
+
+	// CategoryScores are the scores of the categories, between 0 and 1
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// MessagesRequest represents a request to the Anthropic Messages API.
+type MessagesRequest struct {
+	// Model is the ID of the model to use
+	Model string `json:"model"`
+
+	// System is the system prompt, either a string or an array of text blocks
+	System AnthropicContent `json:"system,omitempty"`
+
+	// Messages are the turns of the conversation, alternating between user and assistant
+	Messages []AnthropicMessage `json:"messages"`
+
+	// MaxTokens is the maximum number of tokens to generate
+	MaxTokens int `json:"max_tokens"`
+
+	// Temperature, TopP and TopK control sampling; nil leaves the configured defaults
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+
+	// StopSequences are sequences that end the generation
+	StopSequences []string `json:"stop_sequences,omitempty"`
+
+	// Stream requests the response as server-sent events
+	Stream bool `json:"stream,omitempty"`
+
+	// Tools are the tools the model may call
+	Tools []AnthropicTool `json:"tools,omitempty"`
+
+	// ToolChoice controls which tool is called
+	ToolChoice *AnthropicToolChoice `json:"tool_choice,omitempty"`
+
+	// Metadata describes the request
+	Metadata *AnthropicMetadata `json:"metadata,omitempty"`
+}
+
+// AnthropicMessage is a turn of an Anthropic conversation.
+type AnthropicMessage struct {
+	// Role is "user" or "assistant"
+	Role string `json:"role"`
+
+	// Content is the content of the turn, either a string or an array of content blocks
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent is a list of content blocks, sent either as a string or as an array of blocks.
+type AnthropicContent []ContentBlock
+
+// UnmarshalJSON decodes a string as a single text block, or an array of content blocks.
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = AnthropicContent{{Type: "text", Text: text}}
+		return nil
+	}
+
+	var blocks []ContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// ContentBlock is a block of the content of an Anthropic message.
+type ContentBlock struct {
+	// Type is the type of the block: "text", "image", "tool_use" or "tool_result"
+	Type string `json:"type"`
+
+	// Text is the text of a text block
+	Text string `json:"text,omitempty"`
+
+	// Source is the image of an image block
+	Source *ImageSource `json:"source,omitempty"`
+
+	// ID identifies the tool call of a tool_use block
+	ID string `json:"id,omitempty"`
+
+	// Name is the tool called by a tool_use block
+	Name string `json:"name,omitempty"`
+
+	// Input are the arguments of a tool_use block, as a JSON object
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// ToolUseID is the tool call answered by a tool_result block
+	ToolUseID string `json:"tool_use_id,omitempty"`
+
+	// Content is the result of a tool_result block
+	Content AnthropicContent `json:"content,omitempty"`
+
+	// IsError reports that the tool call of a tool_result block failed
+	IsError bool `json:"is_error,omitempty"`
+}
+
+// MarshalJSON encodes the fields of the block type only. Text blocks always carry their text
+// and tool_use blocks their input, as Anthropic clients expect.
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	switch b.Type {
+	case "text":
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{b.Type, b.Text})
+	case "tool_use":
+		input := b.Input
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		return json.Marshal(struct {
+			Type  string          `json:"type"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		}{b.Type, b.ID, b.Name, input})
+	}
+	type plain ContentBlock
+	return json.Marshal(plain(b))
+}
+
+// ImageSource is the image of an image block, either base64 encoded data or a URL.
+type ImageSource struct {
+	// Type is "base64" or "url"
+	Type string `json:"type"`
+
+	// MediaType is the type of base64 encoded data, e.g. "image/png"
+	MediaType string `json:"media_type,omitempty"`
+
+	// Data is the base64 encoded image
+	Data string `json:"data,omitempty"`
+
+	// URL is the image URL
+	URL string `json:"url,omitempty"`
+}
+
+// AnthropicTool is a tool the model may call.
+type AnthropicTool struct {
+	// Name is the name of the tool
+	Name string `json:"name"`
+
+	// Description tells the model what the tool does
+	Description string `json:"description,omitempty"`
+
+	// InputSchema is the JSON Schema of the tool input
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+}
+
+// AnthropicToolChoice controls which tool is called.
+type AnthropicToolChoice struct {
+	// Type is "auto", "any", "tool" or "none"
+	Type string `json:"type"`
+
+	// Name is the tool to call, when the type is "tool"
+	Name string `json:"name,omitempty"`
+}
+
+// AnthropicMetadata describes an Anthropic Messages request.
+type AnthropicMetadata struct {
+	// UserID is an opaque identifier of the end user making the request
+	UserID string `json:"user_id,omitempty"`
+}
+
+// MessagesResponse represents a response from the Anthropic Messages API.
+type MessagesResponse struct {
+	// ID is a unique identifier for the message
+	ID string `json:"id"`
+
+	// Type is always "message"
+	Type string `json:"type"`
+
+	// Role is always "assistant"
 	Role string `json:"role"`
 
-	// Content is the content of the message
-	Content string `json:"content"`
+	// Model is the model that generated the message
+	Model string `json:"model"`
+
+	// Content are the generated text and tool_use blocks
+	Content []ContentBlock `json:"content"`
+
+	// StopReason explains why the generation stopped: "end_turn", "max_tokens", "stop_sequence",
+	// "tool_use" or "refusal"; null until the generation stopped
+	StopReason *string `json:"stop_reason"`
+
+	// StopSequence is the stop sequence that ended the generation, if known
+	StopSequence *string `json:"stop_sequence"`
+
+	// Usage reports token consumption
+	Usage AnthropicUsage `json:"usage"`
+}
+
+// AnthropicUsage reports token consumption in the Anthropic API format.
+type AnthropicUsage struct {
+	// InputTokens is the number of tokens in the prompt
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of tokens generated by the model
+	OutputTokens int `json:"output_tokens"`
+}
+
+// MessagesStreamEvent is a server-sent event of a streamed Anthropic Messages response. Its type
+// is also the name of the event.
+type MessagesStreamEvent struct {
+	// Type is "message_start", "content_block_start", "content_block_delta",
+	// "content_block_stop", "message_delta" or "message_stop"
+	Type string `json:"type"`
+
+	// Message is the message being generated, on message_start events
+	Message *MessagesResponse `json:"message,omitempty"`
+
+	// Index is the position of the content block, on content block events
+	Index *int `json:"index,omitempty"`
+
+	// ContentBlock is the block started, on content_block_start events
+	ContentBlock *ContentBlock `json:"content_block,omitempty"`
+
+	// Delta is the increment of a content block or of the message
+	Delta *MessagesDelta `json:"delta,omitempty"`
+
+	// Usage reports token consumption, on message_delta events
+	Usage *AnthropicUsage `json:"usage,omitempty"`
+}
+
+// MessagesDelta is the increment carried by content_block_delta and message_delta events.
+type MessagesDelta struct {
+	// Type is "text_delta" or "input_json_delta", on content_block_delta events
+	Type string `json:"type,omitempty"`
+
+	// Text is the generated text, on text_delta deltas
+	Text string `json:"text,omitempty"`
+
+	// PartialJSON is a fragment of the tool input, on input_json_delta deltas
+	PartialJSON string `json:"partial_json,omitempty"`
+
+	// StopReason explains why the generation stopped, on message_delta events
+	StopReason string `json:"stop_reason,omitempty"`
+}
+
+// ResponsesRequest represents a request to the OpenAI Responses API.
+type ResponsesRequest struct {
+	// Model is the ID of the model to use
+	Model string `json:"model"`
+
+	// Input is the input of the model, either a string or an array of items
+	Input ResponseInput `json:"input"`
+
+	// Instructions is a system message inserted before the conversation. Instructions of a
+	// previous response are not carried over.
+	Instructions string `json:"instructions,omitempty"`
+
+	// PreviousResponseID continues the conversation of a stored response
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+
+	// Store keeps the response so that later requests can continue it; true when omitted
+	Store *bool `json:"store,omitempty"`
+
+	// MaxOutputTokens is the maximum number of tokens to generate
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+
+	// Temperature and TopP control sampling; nil leaves the configured defaults
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+
+	// Stream requests the response as server-sent events
+	Stream bool `json:"stream,omitempty"`
+
+	// Tools are the tools the model may call; only function tools are supported
+	Tools []ResponsesTool `json:"tools,omitempty"`
+
+	// ToolChoice controls which tool is called: "none", "auto", "required" or a named function
+	ToolChoice json.RawMessage `json:"tool_choice,omitempty"`
+
+	// User is an optional identifier of the end user making the request
+	User string `json:"user,omitempty"`
+}
+
+// IsStored reports whether the response is to be stored, which it is unless store is false.
+func (r ResponsesRequest) IsStored() bool {
+	return r.Store == nil || *r.Store
+}
+
+// ResponseInput is the input of a Responses request, sent either as a string or as an array of items.
+type ResponseInput []ResponseItem
+
+// UnmarshalJSON decodes a string as a single user message, or an array of items.
+func (in *ResponseInput) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*in = ResponseInput{{Type: "message", Role: "user", Content: ResponseContents{{Type: "input_text", Text: text}}}}
+		return nil
+	}
+
+	var items []ResponseItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*in = items
+	return nil
+}
+
+// ResponseItem is an item of the input or output of a response: a message, a function call
+// generated by the model or the output of a function call.
+type ResponseItem struct {
+	// Type is "message", "function_call" or "function_call_output"; input items without type are messages
+	Type string `json:"type,omitempty"`
+
+	// ID identifies an output item
+	ID string `json:"id,omitempty"`
+
+	// Status is "in_progress", "completed" or "incomplete", on output items
+	Status string `json:"status,omitempty"`
+
+	// Role is the author of a message: "user", "assistant", "system" or "developer"
+	Role string `json:"role,omitempty"`
+
+	// Content is the content of a message, either a string or an array of parts
+	Content ResponseContents `json:"content,omitempty"`
+
+	// CallID identifies the function call of function_call and function_call_output items
+	CallID string `json:"call_id,omitempty"`
+
+	// Name is the function called by a function_call item
+	Name string `json:"name,omitempty"`
+
+	// Arguments are the JSON encoded arguments of a function_call item
+	Arguments string `json:"arguments,omitempty"`
+
+	// Output is the result of a function_call_output item
+	Output string `json:"output,omitempty"`
+}
+
+// MarshalJSON encodes the fields of the item type only. Function calls always carry their
+// arguments, as OpenAI clients expect.
+func (i ResponseItem) MarshalJSON() ([]byte, error) {
+	switch i.Type {
+	case "function_call":
+		return json.Marshal(struct {
+			Type      string `json:"type"`
+			ID        string `json:"id,omitempty"`
+			CallID    string `json:"call_id"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+			Status    string `json:"status,omitempty"`
+		}{i.Type, i.ID, i.CallID, i.Name, i.Arguments, i.Status})
+	case "function_call_output":
+		return json.Marshal(struct {
+			Type   string `json:"type"`
+			ID     string `json:"id,omitempty"`
+			CallID string `json:"call_id"`
+			Output string `json:"output"`
+		}{i.Type, i.ID, i.CallID, i.Output})
+	}
+	content := i.Content
+	if content == nil {
+		content = ResponseContents{}
+	}
+	return json.Marshal(struct {
+		Type    string           `json:"type"`
+		ID      string           `json:"id,omitempty"`
+		Status  string           `json:"status,omitempty"`
+		Role    string           `json:"role"`
+		Content ResponseContents `json:"content"`
+	}{"message", i.ID, i.Status, i.Role, content})
+}
+
+// ResponseContents is the content of a message item, sent either as a string or as an array of parts.
+type ResponseContents []ResponseContent
+
+// UnmarshalJSON decodes a string as a single text part, or an array of parts.
+func (c *ResponseContents) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = ResponseContents{{Type: "input_text", Text: text}}
+		return nil
+	}
+
+	var parts []ResponseContent
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	*c = parts
+	return nil
+}
+
+// ResponseContent is a part of the content of a message item.
+type ResponseContent struct {
+	// Type is "input_text", "input_image" or "output_text"
+	Type string `json:"type"`
+
+	// Text is the text of text parts
+	Text string `json:"text,omitempty"`
+
+	// ImageURL is the image URL or data URL of input_image parts
+	ImageURL string `json:"image_url,omitempty"`
+
+	// Detail is the fidelity of the image understanding: "auto", "low" or "high"
+	Detail string `json:"detail,omitempty"`
+}
+
+// MarshalJSON encodes output_text parts with their text and an empty list of annotations, as
+// OpenAI clients expect.
+func (c ResponseContent) MarshalJSON() ([]byte, error) {
+	if c.Type == "output_text" {
+		return json.Marshal(struct {
+			Type        string        `json:"type"`
+			Text        string        `json:"text"`
+			Annotations []interface{} `json:"annotations"`
+		}{c.Type, c.Text, []interface{}{}})
+	}
+	type plain ResponseContent
+	return json.Marshal(plain(c))
+}
+
+// ResponsesTool is a function the model may call, in the flat format of the Responses API.
+type ResponsesTool struct {
+	// Type is the type of the tool; only "function" is supported
+	Type string `json:"type"`
+
+	// Name is the name of the function
+	Name string `json:"name"`
+
+	// Description tells the model what the function does
+	Description string `json:"description,omitempty"`
+
+	// Parameters is the JSON Schema of the function arguments
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ResponsesResponse represents a response object of the OpenAI Responses API.
+type ResponsesResponse struct {
+	// ID is a unique identifier for the response, usable as previous_response_id when stored
+	ID string `json:"id"`
+
+	// Object is always "response"
+	Object string `json:"object"`
+
+	// CreatedAt is the Unix timestamp in seconds of when the response was created
+	CreatedAt int64 `json:"created_at"`
+
+	// Status is "in_progress", "completed" or "incomplete"
+	Status string `json:"status"`
+
+	// IncompleteDetails explains why an incomplete response stopped
+	IncompleteDetails *IncompleteDetails `json:"incomplete_details"`
+
+	// Model is the model used for the response
+	Model string `json:"model"`
+
+	// Output are the generated message and function call items
+	Output []ResponseItem `json:"output"`
+
+	// PreviousResponseID is the response this one continues, if any
+	PreviousResponseID *string `json:"previous_response_id"`
+
+	// Store reports whether the response was stored
+	Store bool `json:"store"`
+
+	// Usage reports token consumption, once the response is complete
+	Usage *ResponsesUsage `json:"usage"`
+}
+
+// IncompleteDetails explains why a response is incomplete.
+type IncompleteDetails struct {
+	// Reason is "max_output_tokens" or "content_filter"
+	Reason string `json:"reason"`
+}
+
+// ResponsesUsage reports token consumption in the Responses API format.
+type ResponsesUsage struct {
+	// InputTokens is the number of tokens in the input
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of tokens generated by the model
+	OutputTokens int `json:"output_tokens"`
+
+	// TotalTokens is the sum of the input and output tokens
+	TotalTokens int `json:"total_tokens"`
+}
+
+// ResponsesStreamEvent is a server-sent event of a streamed Responses API response. Its type
+// is also the name of the event.
+type ResponsesStreamEvent struct {
+	// Type is the event type, e.g. "response.created" or "response.output_text.delta"
+	Type string `json:"type"`
+
+	// SequenceNumber orders the events of the stream
+	SequenceNumber int `json:"sequence_number"`
+
+	// Response is the response, on response lifecycle events
+	Response *ResponsesResponse `json:"response,omitempty"`
+
+	// OutputIndex is the position of the output item, on item, part and delta events
+	OutputIndex *int `json:"output_index,omitempty"`
+
+	// ItemID identifies the output item, on part and delta events
+	ItemID string `json:"item_id,omitempty"`
+
+	// ContentIndex is the position of the content part, on part and text events
+	ContentIndex *int `json:"content_index,omitempty"`
+
+	// Item is the output item, on output_item events
+	Item *ResponseItem `json:"item,omitempty"`
+
+	// Part is the content part, on content_part events
+	Part *ResponseContent `json:"part,omitempty"`
+
+	// Delta is the generated text or arguments fragment, on delta events
+	Delta string `json:"delta,omitempty"`
+
+	// Text is the complete text, on response.output_text.done events
+	Text string `json:"text,omitempty"`
+
+	// Arguments are the complete arguments, on response.function_call_arguments.done events
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// OllamaChatRequest represents a request to the Ollama /api/chat endpoint.
+type OllamaChatRequest struct {
+	// Model is the name of the model to use
+	Model string `json:"model"`
+
+	// Messages are the messages of the conversation; none only loads the model
+	Messages []OllamaMessage `json:"messages"`
+
+	// Tools are the functions the model may call, in the OpenAI format
+	Tools []Tool `json:"tools,omitempty"`
+
+	// Format requests JSON output; it is not supported and ignored
+	Format json.RawMessage `json:"format,omitempty"`
+
+	// Options are the model parameters
+	Options *OllamaOptions `json:"options,omitempty"`
+
+	// Stream streams the response as newline-delimited JSON; true when omitted
+	Stream *bool `json:"stream,omitempty"`
+
+	// KeepAlive controls how long the model stays loaded; it is ignored
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// IsStreaming reports whether the response is streamed, which it is unless stream is false.
+func (r OllamaChatRequest) IsStreaming() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+// OllamaMessage is a message of an Ollama chat conversation.
+type OllamaMessage struct {
+	// Role is "system", "user", "assistant" or "tool"
+	Role string `json:"role"`
+
+	// Content is the text of the message
+	Content string `json:"content"`
+
+	// Images are base64 encoded images, on user messages
+	Images []string `json:"images,omitempty"`
+
+	// ToolCalls are the function calls generated by the model, on assistant messages
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+
+	// ToolName is the function whose result the message holds, on tool messages
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// OllamaToolCall is a function call generated by the model.
+type OllamaToolCall struct {
+	// Function is the function called and its arguments
+	Function OllamaFunctionCall `json:"function"`
+}
+
+// OllamaFunctionCall is the function and the arguments of an Ollama tool call.
+type OllamaFunctionCall struct {
+	// Name is the name of the function
+	Name string `json:"name"`
+
+	// Arguments are the arguments of the call, as a JSON object
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// OllamaOptions are the model parameters of an Ollama request. Options that have no OCI
+// equivalent are ignored.
+type OllamaOptions struct {
+	// Temperature, TopP and TopK control sampling; nil leaves the configured defaults
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+
+	// NumPredict is the maximum number of tokens to generate; 0 or less leaves the configured default
+	NumPredict int `json:"num_predict,omitempty"`
+
+	// Stop are the sequences where generation stops
+	Stop []string `json:"stop,omitempty"`
+
+	// FrequencyPenalty and PresencePenalty penalize repetitions; nil leaves the configured defaults
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+}
+
+// OllamaGenerateRequest represents a request to the Ollama /api/generate endpoint.
+type OllamaGenerateRequest struct {
+	// Model is the name of the model to use
+	Model string `json:"model"`
+
+	// Prompt is the prompt to complete; an empty prompt only loads the model
+	Prompt string `json:"prompt"`
+
+	// System is the system message of the prompt
+	System string `json:"system,omitempty"`
+
+	// Images are base64 encoded images
+	Images []string `json:"images,omitempty"`
+
+	// Format requests JSON output; it is not supported and ignored
+	Format json.RawMessage `json:"format,omitempty"`
+
+	// Options are the model parameters
+	Options *OllamaOptions `json:"options,omitempty"`
+
+	// Stream streams the response as newline-delimited JSON; true when omitted
+	Stream *bool `json:"stream,omitempty"`
+
+	// KeepAlive controls how long the model stays loaded; it is ignored
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// IsStreaming reports whether the response is streamed, which it is unless stream is false.
+func (r OllamaGenerateRequest) IsStreaming() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+// OllamaMetrics are the token counts and timings reported by Ollama responses. Durations are in
+// nanoseconds.
+type OllamaMetrics struct {
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	LoadDuration       int64 `json:"load_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+// OllamaChatResponse is a response of the Ollama /api/chat endpoint, or a line of its stream.
+type OllamaChatResponse struct {
+	// Model is the model that generated the response
+	Model string `json:"model"`
+
+	// CreatedAt is the RFC 3339 time of the response
+	CreatedAt string `json:"created_at"`
+
+	// Message is the generated message, or its increment in streams
+	Message OllamaMessage `json:"message"`
+
+	// Done is set on the final response
+	Done bool `json:"done"`
+
+	// DoneReason is "stop", "length" or "load", on the final response
+	DoneReason string `json:"done_reason,omitempty"`
+
+	OllamaMetrics
+}
+
+// OllamaGenerateResponse is a response of the Ollama /api/generate endpoint, or a line of its stream.
+type OllamaGenerateResponse struct {
+	// Model is the model that generated the response
+	Model string `json:"model"`
+
+	// CreatedAt is the RFC 3339 time of the response
+	CreatedAt string `json:"created_at"`
+
+	// Response is the generated text, or its increment in streams
+	Response string `json:"response"`
+
+	// Done is set on the final response
+	Done bool `json:"done"`
+
+	// DoneReason is "stop", "length" or "load", on the final response
+	DoneReason string `json:"done_reason,omitempty"`
+
+	OllamaMetrics
+}
+
+// OllamaEmbedRequest represents a request to the Ollama /api/embed endpoint.
+type OllamaEmbedRequest struct {
+	// Model is the name of the embedding model to use
+	Model string `json:"model"`
+
+	// Input is the text to embed, either a string or an array of strings
+	Input EmbedInput `json:"input"`
+
+	// Truncate truncates inputs longer than the context of the model; true when omitted
+	Truncate *bool `json:"truncate,omitempty"`
+
+	// KeepAlive controls how long the model stays loaded; it is ignored
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// EmbedInput is the input of an Ollama or OpenAI embedding request, sent either as a string or as
+// an array of strings.
+type EmbedInput []string
+
+// UnmarshalJSON decodes a string as a single input, or an array of strings.
+func (in *EmbedInput) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*in = EmbedInput{text}
+		return nil
+	}
+
+	var inputs []string
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return err
+	}
+	*in = inputs
+	return nil
+}
+
+// OllamaEmbedResponse is the response of the Ollama /api/embed endpoint.
+type OllamaEmbedResponse struct {
+	// Model is the model that computed the embeddings
+	Model string `json:"model"`
+
+	// Embeddings are the embeddings of the inputs, in order
+	Embeddings [][]float64 `json:"embeddings"`
+
+	// TotalDuration, LoadDuration and PromptEvalCount are the timings in nanoseconds and the token count
+	TotalDuration   int64 `json:"total_duration,omitempty"`
+	LoadDuration    int64 `json:"load_duration,omitempty"`
+	PromptEvalCount int   `json:"prompt_eval_count,omitempty"`
+}
+
+// OllamaTagsResponse is the response of the Ollama /api/tags endpoint, listing the available models.
+type OllamaTagsResponse struct {
+	// Models are the available models
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaModel describes an available model.
+type OllamaModel struct {
+	// Name and Model are the name of the model
+	Name  string `json:"name"`
+	Model string `json:"model"`
+
+	// ModifiedAt is the RFC 3339 time the model was last modified
+	ModifiedAt string `json:"modified_at"`
+
+	// Size is the size of the model in bytes; 0 for remote models
+	Size int64 `json:"size"`
+
+	// Digest identifies the model version
+	Digest string `json:"digest"`
+
+	// Details describes the model
+	Details OllamaModelDetails `json:"details"`
+}
+
+// OllamaModelDetails describes the family and format of a model.
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// EmbeddingRequest represents an OpenAI embeddings request.
+type EmbeddingRequest struct {
+	// Model is the ID of the embedding model to use
+	Model string `json:"model"`
+
+	// Input is the text to embed, either a string or an array of strings
+	Input EmbedInput `json:"input"`
+
+	// EncodingFormat is "float" (the default) or "base64"
+	EncodingFormat string `json:"encoding_format,omitempty"`
+
+	// Dimensions is the number of dimensions of the embeddings; OCI models have fixed dimensions
+	Dimensions int `json:"dimensions,omitempty"`
+
+	// User is the end user on whose behalf the request is made
+	User string `json:"user,omitempty"`
+}
+
+// EmbeddingResponse is the response to an OpenAI embeddings request.
+type EmbeddingResponse struct {
+	// Object is always "list"
+	Object string `json:"object"`
+
+	// Data are the embeddings of the inputs, in order
+	Data []Embedding `json:"data"`
+
+	// Model is the model that computed the embeddings
+	Model string `json:"model"`
+
+	// Usage reports the tokens of the inputs
+	Usage EmbeddingUsage `json:"usage"`
+}
+
+// Embedding is the embedding of one input.
+type Embedding struct {
+	// Object is always "embedding"
+	Object string `json:"object"`
+
+	// Index is the position of the input in the request
+	Index int `json:"index"`
+
+	// Embedding is the vector, as an array of floats or a base64 string of little-endian float32s
+	Embedding interface{} `json:"embedding"`
+}
+
+// EmbeddingUsage reports the tokens of an embeddings request.
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// File is an uploaded file of the OpenAI Files API.
+type File struct {
+	// ID identifies the file, e.g. "file-abc123"
+	ID string `json:"id"`
+
+	// Object is always "file"
+	Object string `json:"object"`
+
+	// Bytes is the size of the file
+	Bytes int64 `json:"bytes"`
+
+	// CreatedAt is the Unix time the file was created
+	CreatedAt int64 `json:"created_at"`
+
+	// Filename is the name of the file
+	Filename string `json:"filename"`
+
+	// Purpose is "batch" for batch inputs and "batch_output" for batch results
+	Purpose string `json:"purpose"`
+
+	// Status is always "processed"
+	Status string `json:"status"`
+}
+
+// FileList is a list of files.
+type FileList struct {
+	// Object is always "list"
+	Object string `json:"object"`
+
+	// Data are the files
+	Data []File `json:"data"`
+
+	// HasMore reports whether more files follow
+	HasMore bool `json:"has_more"`
+}
+
+// FileDeleted is the response to a file deletion.
+type FileDeleted struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+// BatchCreateRequest represents a request creating a batch.
+type BatchCreateRequest struct {
+	// InputFileID is the ID of the uploaded JSONL file holding the requests
+	InputFileID string `json:"input_file_id"`
+
+	// Endpoint is the endpoint all requests of the batch are sent to, e.g. "/v1/chat/completions"
+	Endpoint string `json:"endpoint"`
+
+	// CompletionWindow is the time frame within which the batch is processed; only "24h" is supported
+	CompletionWindow string `json:"completion_window"`
+
+	// Metadata is a set of key-value pairs attached to the batch
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Batch is a batch of the OpenAI Batch API.
+type Batch struct {
+	// ID identifies the batch, e.g. "batch_abc123"
+	ID string `json:"id"`
+
+	// Object is always "batch"
+	Object string `json:"object"`
+
+	// Endpoint is the endpoint the requests are sent to
+	Endpoint string `json:"endpoint"`
+
+	// Errors lists the problems found while validating the input file
+	Errors *BatchErrors `json:"errors"`
+
+	// InputFileID is the ID of the input file
+	InputFileID string `json:"input_file_id"`
+
+	// CompletionWindow is the time frame within which the batch is processed
+	CompletionWindow string `json:"completion_window"`
+
+	// Status is validating, failed, in_progress, finalizing, completed, expired, cancelling or cancelled
+	Status string `json:"status"`
+
+	// OutputFileID and ErrorFileID are the IDs of the files holding the successful and failed
+	// results, once the batch has ended
+	OutputFileID *string `json:"output_file_id"`
+	ErrorFileID  *string `json:"error_file_id"`
+
+	// Unix times of the status changes of the batch
+	CreatedAt    int64  `json:"created_at"`
+	InProgressAt *int64 `json:"in_progress_at"`
+	ExpiresAt    *int64 `json:"expires_at"`
+	FinalizingAt *int64 `json:"finalizing_at"`
+	CompletedAt  *int64 `json:"completed_at"`
+	FailedAt     *int64 `json:"failed_at"`
+	ExpiredAt    *int64 `json:"expired_at"`
+	CancellingAt *int64 `json:"cancelling_at"`
+	CancelledAt  *int64 `json:"cancelled_at"`
+
+	// RequestCounts counts the requests of the batch
+	RequestCounts BatchRequestCounts `json:"request_counts"`
+
+	// Metadata is the set of key-value pairs attached to the batch
+	Metadata map[string]string `json:"metadata"`
+}
+
+// BatchErrors lists the validation errors of a batch.
+type BatchErrors struct {
+	// Object is always "list"
+	Object string `json:"object"`
+
+	// Data are the errors
+	Data []BatchError `json:"data"`
+}
+
+// BatchError describes a problem with a batch or one of its requests.
+type BatchError struct {
+	// Code is a machine-readable error code
+	Code string `json:"code"`
+
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+
+	// Param is the parameter that caused the error, if any
+	Param *string `json:"param,omitempty"`
+
+	// Line is the line of the input file that caused the error, if any
+	Line *int `json:"line,omitempty"`
+}
+
+// BatchRequestCounts counts the requests of a batch.
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
 }
 
-// ChatCompletionRequest represents a request to the OpenAI chat completion API.
-type ChatCompletionRequest struct {
-	// Model is the ID of the model to use
-	Model string `json:"model"`
+// BatchList is a page of batches.
+type BatchList struct {
+	// Object is always "list"
+	Object string `json:"object"`
 
-	// Messages is a list of messages comprising the conversation so far
-	Messages []ChatCompletionMessage `json:"messages"`
+	// Data are the batches, most recent first
+	Data []Batch `json:"data"`
 
-	// MaxTokens is the maximum number of tokens to generate in the chat completion
-	MaxTokens int `json:"maxTokens,omitempty"`
+	// FirstID and LastID are the IDs of the first and last batches of the page
+	FirstID string `json:"first_id,omitempty"`
+	LastID  string `json:"last_id,omitempty"`
 
-	// Temperature controls randomness (0.0 = deterministic, 2.0 = very random)
-	Temperature float32 `json:"temperature,omitempty"`
+	// HasMore reports whether more batches follow
+	HasMore bool `json:"has_more"`
+}
 
-	// TopP controls nucleus sampling
-	TopP float32 `json:"topP,omitempty"`
+// BatchRequestLine is a line of a batch input file.
+type BatchRequestLine struct {
+	// CustomID identifies the request in the results; it must be unique within the batch
+	CustomID string `json:"custom_id"`
 
-	// FrequencyPenalty reduces repetition of tokens based on their frequency
-	FrequencyPenalty float32 `json:"frequencyPenalty,omitempty"`
+	// Method is always "POST"
+	Method string `json:"method"`
 
-	// PresencePenalty reduces repetition of tokens based on their presence
-	PresencePenalty float32 `json:"presencePenalty,omitempty"`
+	// URL is the endpoint of the batch
+	URL string `json:"url"`
+
+	// Body is the request sent to the endpoint
+	Body json.RawMessage `json:"body"`
+}
+
+// BatchResultLine is a line of a batch output or error file.
+type BatchResultLine struct {
+	// ID identifies the result, e.g. "batch_req_abc123"
+	ID string `json:"id"`
+
+	// CustomID is the custom ID of the request
+	CustomID string `json:"custom_id"`
+
+	// Response is the response to the request, if it was sent
+	Response *BatchResponse `json:"response"`
+
+	// Error describes why the request could not be sent
+	Error *BatchError `json:"error"`
+}
+
+// BatchResponse is the response to a request of a batch.
+type BatchResponse struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int `json:"status_code"`
+
+	// RequestID identifies the request
+	RequestID string `json:"request_id"`
+
+	// Body is the response body
+	Body json.RawMessage `json:"body"`
+}
+
+// OllamaError is the error document returned to Ollama clients.
+type OllamaError struct {
+	// Error describes what went wrong
+	Error string `json:"error"`
+}
+
+// AnthropicErrorResponse is the error envelope returned to Anthropic clients.
+type AnthropicErrorResponse struct {
+	// Type is always "error"
+	Type string `json:"type"`
+
+	// Error describes what went wrong
+	Error AnthropicError `json:"error"`
+}
+
+// AnthropicError represents an error in the Anthropic API format.
+type AnthropicError struct {
+	// Type is the category of the error (e.g., "invalid_request_error", "rate_limit_error")
+	Type string `json:"type"`
+
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+}
+
+// ErrorResponse is the error envelope returned to OpenAI clients.
+type ErrorResponse struct {
+	// Error describes what went wrong
+	Error APIError `json:"error"`
+}
+
+// APIError represents an error in the OpenAI API format.
+type APIError struct {
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+
+	// Type is the category of the error (e.g., "invalid_request_error", "tokens")
+	Type string `json:"type"`
+
+	// Param is the request parameter that caused the error, if any
+	Param *string `json:"param"`
+
+	// Code is a machine-readable error code (e.g., "rate_limit_exceeded")
+	Code string `json:"code,omitempty"`
 }
 
 // ServingMode represents the serving configuration for Oracle Cloud GenAI.
@@ -69,8 +1672,8 @@ type ChatRequest struct {
 	// TopP controls nucleus sampling (0.0 = most focused, 1.0 = least focused)
 	TopP float64 `json:"topP"`
 
-	// TopK limits the number of highest probability tokens to consider
-	TopK int `json:"topK"`
+	// TopK limits the number of highest probability tokens to consider; 0 leaves the model default
+	TopK int `json:"topK,omitempty"`
 
 	// IsStream determines if the response should be streamed
 	IsStream bool `json:"isStream"`
@@ -78,16 +1681,169 @@ type ChatRequest struct {
 	// StreamOptions configures streaming behavior
 	StreamOptions StreamOptions `json:"streamOptions"`
 
-	// ChatHistory contains previous messages in the conversation
-	ChatHistory []interface{} `json:"chatHistory"`
+	// PreambleOverride replaces the default system prompt of the model, in the COHERE format
+	PreambleOverride string `json:"preambleOverride,omitempty"`
 
-	// Message is the current user message to process
-	Message string `json:"message"`
+	// ChatHistory contains previous messages in the conversation, in the COHERE format
+	ChatHistory []CohereMessage `json:"chatHistory,omitempty"`
+
+	// Message is the current user message to process, in the COHERE format
+	Message string `json:"message,omitempty"`
+
+	// Documents ground the answer, in the COHERE format
+	Documents []json.RawMessage `json:"documents,omitempty"`
+
+	// CitationQuality is "ACCURATE" or "FAST", in the COHERE format
+	CitationQuality string `json:"citationQuality,omitempty"`
+
+	// IsSearchQueriesOnly only generates search queries, in the COHERE format
+	IsSearchQueriesOnly bool `json:"isSearchQueriesOnly,omitempty"`
+
+	// StopSequences end the generation, in the COHERE format
+	StopSequences []string `json:"stopSequences,omitempty"`
+
+	// Stop ends the generation, in the GENERIC format
+	Stop []string `json:"stop,omitempty"`
 
-	// APIFormat specifies the API format to use (e.g., "COHERE")
+	// NumGenerations is the number of responses generated, in the GENERIC format
+	NumGenerations int `json:"numGenerations,omitempty"`
+
+	// Messages is the conversation, in the GENERIC format
+	Messages []GenericMessage `json:"messages,omitempty"`
+
+	// Tools are the tools the model may call, in the GENERIC format
+	Tools []GenericTool `json:"tools,omitempty"`
+
+	// ToolChoice controls which tool is called, in the GENERIC format
+	ToolChoice *GenericToolChoice `json:"toolChoice,omitempty"`
+
+	// APIFormat specifies the API format to use: "COHERE" or "GENERIC"
 	APIFormat string `json:"apiFormat"`
 }
 
+// CohereMessage is a message of the chat history in the COHERE API format.
+type CohereMessage struct {
+	// Role is the author of the message: "USER", "CHATBOT" or "TOOL"
+	Role string `json:"role"`
+
+	// Message is the text of the message, on user and chatbot messages
+	Message string `json:"message,omitempty"`
+
+	// ToolCalls are the tool calls generated by the model, on chatbot messages
+	ToolCalls []CohereToolCall `json:"toolCalls,omitempty"`
+
+	// ToolResults are the results of the tool calls, on tool messages
+	ToolResults []CohereToolResult `json:"toolResults,omitempty"`
+}
+
+// CohereToolCall is a tool call in the COHERE API format.
+type CohereToolCall struct {
+	// Name is the name of the tool called
+	Name string `json:"name"`
+
+	// Parameters are the arguments of the call, as a JSON object
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// CohereToolResult is the result of a tool call in the COHERE API format.
+type CohereToolResult struct {
+	// Call is the tool call answered
+	Call CohereToolCall `json:"call"`
+
+	// Outputs are the results of the call, as JSON objects
+	Outputs []json.RawMessage `json:"outputs"`
+}
+
+// GenericMessage is a message of a chat in the GENERIC API format.
+type GenericMessage struct {
+	// Role is the author of the message: "SYSTEM", "USER", "ASSISTANT" or "TOOL"
+	Role string `json:"role"`
+
+	// Content holds the text and image parts of the message
+	Content []GenericContent `json:"content,omitempty"`
+
+	// Name is an optional name of the author
+	Name string `json:"name,omitempty"`
+
+	// ToolCalls are the tool calls generated by the model, on assistant messages
+	ToolCalls []GenericToolCall `json:"toolCalls,omitempty"`
+
+	// ToolCallID is the tool call a tool message responds to
+	ToolCallID string `json:"toolCallId,omitempty"`
+}
+
+// GenericContent is a part of the content of a GENERIC message.
+type GenericContent struct {
+	// Type is the type of the part: "TEXT" or "IMAGE"
+	Type string `json:"type"`
+
+	// Text is the text of a text part
+	Text string `json:"text,omitempty"`
+
+	// ImageURL is the image of an image part
+	ImageURL *GenericImageURL `json:"imageUrl,omitempty"`
+}
+
+// GenericImageURL references the image of a GENERIC image part.
+type GenericImageURL struct {
+	// URL is the image URL or base64 data URL
+	URL string `json:"url"`
+
+	// Detail is the fidelity of the image understanding: "AUTO", "LOW" or "HIGH"
+	Detail string `json:"detail,omitempty"`
+}
+
+// GenericTool is a function the model may call, in the GENERIC format.
+type GenericTool struct {
+	// Type is always "FUNCTION"
+	Type string `json:"type"`
+
+	// Name is the name of the function
+	Name string `json:"name"`
+
+	// Description tells the model what the function does
+	Description string `json:"description,omitempty"`
+
+	// Parameters is the JSON Schema of the function arguments
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// GenericToolCall is a call of a function generated by the model, in the GENERIC format.
+type GenericToolCall struct {
+	// ID identifies the tool call
+	ID string `json:"id,omitempty"`
+
+	// Type is always "FUNCTION"
+	Type string `json:"type,omitempty"`
+
+	// Name is the name of the function
+	Name string `json:"name,omitempty"`
+
+	// Arguments are the JSON encoded arguments
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// GenericToolChoice controls which tool is called, in the GENERIC format.
+type GenericToolChoice struct {
+	// Type is "NONE", "AUTO", "REQUIRED" or "FUNCTION"
+	Type string `json:"type"`
+
+	// Name is the function to call when Type is "FUNCTION"
+	Name string `json:"name,omitempty"`
+}
+
+// GenericChoice is a generated message of a GENERIC chat response.
+type GenericChoice struct {
+	// Index is the position of the choice in the list of choices
+	Index int `json:"index"`
+
+	// Message is the generated message
+	Message GenericMessage `json:"message"`
+
+	// FinishReason explains why the generation stopped (e.g., "stop", "length", "tool_calls")
+	FinishReason string `json:"finishReason,omitempty"`
+}
+
 // OracleCloudRequest represents the complete request structure for Oracle Cloud GenAI.
 // This is the final format that gets sent to the OCI GenAI service.
 type OracleCloudRequest struct {
@@ -101,6 +1857,407 @@ type OracleCloudRequest struct {
 	ChatRequest ChatRequest `json:"chatRequest"`
 }
 
+// Usage reports the number of tokens consumed by an Oracle Cloud GenAI request.
+type Usage struct {
+	// PromptTokens is the number of tokens in the prompt
+	PromptTokens int `json:"promptTokens"`
+
+	// CompletionTokens is the number of tokens generated by the model
+	CompletionTokens int `json:"completionTokens"`
+
+	// TotalTokens is the sum of prompt and completion tokens
+	TotalTokens int `json:"totalTokens"`
+}
+
+// ChatResponse represents the chat result returned by Oracle Cloud GenAI.
+type ChatResponse struct {
+	// APIFormat is the API format of the response (e.g., "COHERE")
+	APIFormat string `json:"apiFormat"`
+
+	// Text is the generated text, in the COHERE format
+	Text string `json:"text,omitempty"`
+
+	// Choices are the generated messages, in the GENERIC format
+	Choices []GenericChoice `json:"choices,omitempty"`
+
+	// Citations link spans of the text to the documents supporting them, in the COHERE format
+	Citations []Citation `json:"citations,omitempty"`
+
+	// Documents are the documents cited, in the COHERE format
+	Documents []json.RawMessage `json:"documents,omitempty"`
+
+	// SearchQueries are the search queries generated, in the COHERE format
+	SearchQueries []SearchQuery `json:"searchQueries,omitempty"`
+
+	// IsSearchRequired reports whether a search is necessary to answer, in the COHERE format
+	IsSearchRequired *bool `json:"isSearchRequired,omitempty"`
+
+	// FinishReason explains why the generation stopped
+	FinishReason string `json:"finishReason,omitempty"`
+
+	// Usage reports token consumption, if provided by the model
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Citation links a span of the generated text to the documents supporting it.
+type Citation struct {
+	// Start and End are the character offsets of the cited span
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Text is the cited span
+	Text string `json:"text"`
+
+	// DocumentIDs identify the supporting documents
+	DocumentIDs []string `json:"documentIds"`
+}
+
+// SearchQuery is a search query generated by a Cohere model.
+type SearchQuery struct {
+	// Text is the query
+	Text string `json:"text"`
+}
+
+// OracleCloudResponse represents the complete response structure from Oracle Cloud GenAI chat.
+type OracleCloudResponse struct {
+	// ModelID is the identifier of the model that generated the response
+	ModelID string `json:"modelId"`
+
+	// ModelVersion is the version of the model
+	ModelVersion string `json:"modelVersion,omitempty"`
+
+	// ChatResponse contains the generated result
+	ChatResponse ChatResponse `json:"chatResponse"`
+}
+
+// GenerateTextRequest is a generateText request to Oracle Cloud GenAI, served by the legacy
+// text generation models.
+type GenerateTextRequest struct {
+	// CompartmentID is the OCI compartment where the GenAI service is located
+	CompartmentID string `json:"compartmentId"`
+
+	// ServingMode specifies the model and serving configuration
+	ServingMode ServingMode `json:"servingMode"`
+
+	// InferenceRequest holds the prompt and the generation parameters
+	InferenceRequest InferenceRequest `json:"inferenceRequest"`
+}
+
+// InferenceRequest holds the parameters of a generateText request. RuntimeType selects the
+// COHERE or LLAMA runtime; some fields only apply to one of them.
+type InferenceRequest struct {
+	// RuntimeType is "COHERE" or "LLAMA"
+	RuntimeType string `json:"runtimeType"`
+
+	// Prompt is the text to complete
+	Prompt string `json:"prompt"`
+
+	// MaxTokens is the maximum number of tokens to generate
+	MaxTokens int `json:"maxTokens"`
+
+	// Temperature controls randomness
+	Temperature float64 `json:"temperature"`
+
+	// TopP controls nucleus sampling
+	TopP float64 `json:"topP"`
+
+	// TopK limits the number of highest probability tokens to consider; 0 leaves the model default
+	TopK int `json:"topK,omitempty"`
+
+	// FrequencyPenalty reduces repetition of tokens based on their frequency
+	FrequencyPenalty float64 `json:"frequencyPenalty"`
+
+	// PresencePenalty reduces repetition of tokens based on their presence
+	PresencePenalty float64 `json:"presencePenalty"`
+
+	// NumGenerations is the number of texts generated
+	NumGenerations int `json:"numGenerations,omitempty"`
+
+	// IsEcho includes the prompt in the generated texts
+	IsEcho bool `json:"isEcho"`
+
+	// IsStream streams the generated text as server-sent events
+	IsStream bool `json:"isStream"`
+
+	// StopSequences end the generation, for the COHERE runtime
+	StopSequences []string `json:"stopSequences,omitempty"`
+
+	// ReturnLikelihoods is "NONE", "GENERATION" or "ALL", for the COHERE runtime
+	ReturnLikelihoods string `json:"returnLikelihoods,omitempty"`
+
+	// Stop ends the generation, for the LLAMA runtime
+	Stop []string `json:"stop,omitempty"`
+
+	// LogProbs is the number of most likely tokens returned at each position, for the LLAMA runtime
+	LogProbs *int `json:"logProbs,omitempty"`
+}
+
+// GenerateTextResponse is the response of Oracle Cloud GenAI to a generateText request.
+type GenerateTextResponse struct {
+	// ModelID is the identifier of the model that generated the text
+	ModelID string `json:"modelId"`
+
+	// ModelVersion is the version of the model
+	ModelVersion string `json:"modelVersion,omitempty"`
+
+	// InferenceResponse holds the generated texts
+	InferenceResponse InferenceResponse `json:"inferenceResponse"`
+}
+
+// InferenceResponse holds the texts of a generateText response: GeneratedTexts for the COHERE
+// runtime, Choices for the LLAMA runtime.
+type InferenceResponse struct {
+	// RuntimeType is "COHERE" or "LLAMA"
+	RuntimeType string `json:"runtimeType"`
+
+	// GeneratedTexts are the texts generated by the COHERE runtime
+	GeneratedTexts []GeneratedText `json:"generatedTexts,omitempty"`
+
+	// Choices are the texts generated by the LLAMA runtime
+	Choices []LlamaChoice `json:"choices,omitempty"`
+}
+
+// GeneratedText is a text generated by the COHERE runtime.
+type GeneratedText struct {
+	// ID identifies the generation
+	ID string `json:"id,omitempty"`
+
+	// Text is the generated text
+	Text string `json:"text"`
+
+	// FinishReason explains why the generation stopped
+	FinishReason string `json:"finishReason,omitempty"`
+
+	// TokenLikelihoods are the log likelihoods of the tokens, when requested
+	TokenLikelihoods []TokenLikelihood `json:"tokenLikelihoods,omitempty"`
+}
+
+// TokenLikelihood is the log likelihood of a generated token.
+type TokenLikelihood struct {
+	// Token is the token
+	Token string `json:"token"`
+
+	// Likelihood is the log likelihood of the token
+	Likelihood float64 `json:"likelihood"`
+}
+
+// LlamaChoice is a text generated by the LLAMA runtime.
+type LlamaChoice struct {
+	// Index is the position of the text in the list of texts
+	Index int `json:"index"`
+
+	// Text is the generated text
+	Text string `json:"text"`
+
+	// FinishReason explains why the generation stopped
+	FinishReason string `json:"finishReason,omitempty"`
+
+	// Logprobs are the log probabilities of the tokens, when requested
+	Logprobs *LlamaLogprobs `json:"logprobs,omitempty"`
+}
+
+// LlamaLogprobs are the log probabilities of the tokens of a LLAMA runtime text.
+type LlamaLogprobs struct {
+	// TextOffset are the character offsets of the tokens in the text
+	TextOffset []int `json:"textOffset"`
+
+	// TokenLogprobs are the log probabilities of the tokens
+	TokenLogprobs []float64 `json:"tokenLogprobs"`
+
+	// Tokens are the generated tokens
+	Tokens []string `json:"tokens"`
+
+	// TopLogprobs are the most likely tokens at each position, with their log probabilities
+	TopLogprobs []map[string]float64 `json:"topLogprobs"`
+}
+
+// RerankTextRequest is a rerankText request to Oracle Cloud GenAI.
+type RerankTextRequest struct {
+	// CompartmentID is the OCI compartment where the GenAI service is located
+	CompartmentID string `json:"compartmentId"`
+
+	// ServingMode specifies the model and serving configuration
+	ServingMode ServingMode `json:"servingMode"`
+
+	// Input is the query the documents are ranked against
+	Input string `json:"input"`
+
+	// Documents are the texts to rank
+	Documents []string `json:"documents"`
+
+	// TopN limits the number of documents returned
+	TopN *int `json:"topN,omitempty"`
+
+	// IsEcho includes the documents in the response
+	IsEcho bool `json:"isEcho"`
+}
+
+// RerankTextResponse is the response of Oracle Cloud GenAI to a rerankText request.
+type RerankTextResponse struct {
+	// ID is the unique identifier of the response
+	ID string `json:"id"`
+
+	// ModelID is the identifier of the model that ranked the documents
+	ModelID string `json:"modelId"`
+
+	// ModelVersion is the version of the model
+	ModelVersion string `json:"modelVersion,omitempty"`
+
+	// DocumentRanks are the ranked documents
+	DocumentRanks []DocumentRank `json:"documentRanks"`
+}
+
+// DocumentRank is the rank of a document in a rerankText response.
+type DocumentRank struct {
+	// Index is the position of the document in the request
+	Index int `json:"index"`
+
+	// RelevanceScore is the relevance of the document to the input
+	RelevanceScore float64 `json:"relevanceScore"`
+
+	// Document is the ranked document, when the request set IsEcho
+	Document *RerankDocument `json:"document,omitempty"`
+}
+
+// ApplyGuardrailsRequest is an applyGuardrails request to Oracle Cloud GenAI.
+type ApplyGuardrailsRequest struct {
+	// CompartmentID is the OCI compartment where the GenAI service is located
+	CompartmentID string `json:"compartmentId"`
+
+	// Input is the text to check
+	Input GuardrailsInput `json:"input"`
+
+	// GuardrailConfigs selects the checks applied to the input
+	GuardrailConfigs GuardrailConfigs `json:"guardrailConfigs"`
+}
+
+// GuardrailsInput is the text checked by an applyGuardrails request.
+type GuardrailsInput struct {
+	// Type is always "TEXT"
+	Type string `json:"type"`
+
+	// Content is the text
+	Content string `json:"content"`
+
+	// LanguageCode is the language of the text; the service detects it when empty
+	LanguageCode string `json:"languageCode,omitempty"`
+}
+
+// GuardrailConfigs selects the checks of an applyGuardrails request; nil checks are skipped.
+type GuardrailConfigs struct {
+	// ContentModerationConfig scores the text in content moderation categories
+	ContentModerationConfig *ContentModerationConfig `json:"contentModerationConfig,omitempty"`
+
+	// PersonallyIdentifiableInformationConfig detects personal data
+	PersonallyIdentifiableInformationConfig *PersonallyIdentifiableInformationConfig `json:"personallyIdentifiableInformationConfig,omitempty"`
+
+	// PromptInjectionConfig scores the text as a prompt injection attempt
+	PromptInjectionConfig *PromptInjectionConfig `json:"promptInjectionConfig,omitempty"`
+}
+
+// ContentModerationConfig configures content moderation.
+type ContentModerationConfig struct {
+	// Categories are the categories scored: OVERALL and BLOCKLIST
+	Categories []string `json:"categories"`
+}
+
+// PersonallyIdentifiableInformationConfig configures personal data detection.
+type PersonallyIdentifiableInformationConfig struct {
+	// Types are the types of personal data detected, such as EMAIL
+	Types []string `json:"types"`
+}
+
+// PromptInjectionConfig configures prompt injection detection.
+type PromptInjectionConfig struct{}
+
+// ApplyGuardrailsResponse is the response of Oracle Cloud GenAI to an applyGuardrails request.
+type ApplyGuardrailsResponse struct {
+	// Results are the outcomes of the requested checks
+	Results GuardrailsResults `json:"results"`
+}
+
+// GuardrailsResults are the outcomes of the checks of an applyGuardrails request.
+type GuardrailsResults struct {
+	// ContentModeration are the scores of the content moderation categories
+	ContentModeration *ContentModerationResult `json:"contentModeration,omitempty"`
+
+	// PersonallyIdentifiableInformation is the personal data detected in the text
+	PersonallyIdentifiableInformation []PersonallyIdentifiableInformationResult `json:"personallyIdentifiableInformation,omitempty"`
+
+	// PromptInjection is the prompt injection score
+	PromptInjection *PromptInjectionResult `json:"promptInjection,omitempty"`
+}
+
+// ContentModerationResult holds the scores of the content moderation categories.
+type ContentModerationResult struct {
+	// Categories are the scores by category
+	Categories []CategoryScore `json:"categories"`
+}
+
+// CategoryScore is the score of a content moderation category.
+type CategoryScore struct {
+	// Name is the category
+	Name string `json:"name"`
+
+	// Score is between 0 and 1
+	Score float64 `json:"score"`
+}
+
+// PersonallyIdentifiableInformationResult is personal data detected in a text.
+type PersonallyIdentifiableInformationResult struct {
+	// Text is the personal data itself
+	Text string `json:"text"`
+
+	// Label is the type of the personal data, such as EMAIL
+	Label string `json:"label"`
+
+	// Offset and Length locate the personal data in the text
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+
+	// Score is the confidence of the detection, between 0 and 1
+	Score float64 `json:"score"`
+}
+
+// PromptInjectionResult is the prompt injection score of a text.
+type PromptInjectionResult struct {
+	// Score is between 0 and 1
+	Score float64 `json:"score"`
+}
+
+// EmbedTextRequest is an embedText request to Oracle Cloud GenAI.
+type EmbedTextRequest struct {
+	// CompartmentID is the OCI compartment where the GenAI service is located
+	CompartmentID string `json:"compartmentId"`
+
+	// ServingMode specifies the model and serving configuration
+	ServingMode ServingMode `json:"servingMode"`
+
+	// Inputs are the texts to embed
+	Inputs []string `json:"inputs"`
+
+	// Truncate is how inputs longer than the context of the model are truncated: "NONE", "START" or "END"
+	Truncate string `json:"truncate,omitempty"`
+}
+
+// EmbedTextResponse is the response of Oracle Cloud GenAI to an embedText request.
+type EmbedTextResponse struct {
+	// ID is the unique identifier of the response
+	ID string `json:"id"`
+
+	// ModelID is the identifier of the model that computed the embeddings
+	ModelID string `json:"modelId"`
+
+	// ModelVersion is the version of the model
+	ModelVersion string `json:"modelVersion,omitempty"`
+
+	// Embeddings are the embeddings of the inputs, in order
+	Embeddings [][]float64 `json:"embeddings"`
+
+	// Usage reports the tokens of the inputs
+	Usage *Usage `json:"usage,omitempty"`
+}
+
 // InstanceMetadata represents the metadata response from Oracle Cloud Instance Metadata Service.
 // This contains the certificates and private key needed for Instance Principal authentication.
 type InstanceMetadata struct {
@@ -1,8 +1,9 @@
 // Package ocigenai is a Traefik plugin that proxies OpenAI API requests to Oracle Cloud Infrastructure (OCI) Generative AI service.
 //
-// The plugin intercepts POST requests to /chat/completions, transforms them from OpenAI format
-// to OCI GenAI format, adds OCI Instance Principal authentication, and forwards them to the
-// configured OCI GenAI endpoint.
+// The plugin intercepts POST requests to /chat/completions, /completions, /responses, /messages,
+// /rerank and the Ollama /api endpoints, and the /embeddings requests of batches, transforms them from the OpenAI, Anthropic, Cohere and
+// Ollama formats to OCI GenAI format, adds OCI Instance Principal authentication, forwards them to
+// the configured OCI GenAI endpoint and translates the responses back.
 //
 // Key features:
 // - Seamless OpenAI to OCI GenAI API translation
@@ -15,31 +16,65 @@ package ocigenai
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/zalbiraw/ocigenai/internal/batch"
+	"github.com/zalbiraw/ocigenai/internal/cache"
 	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/logging"
 	"github.com/zalbiraw/ocigenai/internal/ocisdk"
+	"github.com/zalbiraw/ocigenai/internal/ratelimit"
+	"github.com/zalbiraw/ocigenai/internal/store"
+	"github.com/zalbiraw/ocigenai/internal/tracing"
 	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/internal/usage"
 	"github.com/zalbiraw/ocigenai/pkg/types"
 )
 
 // Proxy represents the main plugin instance that handles request proxying.
 // It contains all the necessary components for transforming and authenticating requests.
 type Proxy struct {
-	next          http.Handler           // Next handler in the middleware chain
-	config        *config.Config         // Plugin configuration
-	name          string                 // Plugin instance name
-	transformer   *transform.Transformer // Request transformer
-	authenticator *ocisdk.Authenticator  // OCI authenticator
+	next              http.Handler                               // Next handler in the middleware chain
+	base              *config.Config                             // Inline plugin configuration, overridden by the reload file
+	name              string                                     // Plugin instance name
+	live              atomic.Value                               // Current *settings
+	instancePrincipal *ocisdk.Authenticator                      // Instance principal, created once an identity uses it
+	authenticators    map[authenticatorKey]*ocisdk.Authenticator // Authenticators of the configured identities
+	reloadMu          sync.Mutex                                 // Serializes reloads
+	reloadHash        [sha256.Size]byte                          // Hash of the last reload file applied or rejected, with its secret files
+	secretFiles       []string                                   // Secret files referenced by the last reload file
+	stopWatching      context.CancelFunc                         // Stops watching the reload file, nil when disabled
+	watchDone         chan struct{}                              // Closed once the reload file is no longer watched
+	ledger            *usage.Ledger                              // Usage ledger, nil when disabled
+	metrics           *proxyMetrics                              // Prometheus metrics, nil when disabled
+	tracer            *tracing.Tracer                            // Span tracer, nil when tracing is disabled
+	logger            *logging.Logger                            // Structured logger tagged with the instance name
+	cache             cache.Backend                              // Response cache, nil when disabled
+	cacheTTL          time.Duration                              // Lifetime of cached responses
+	responses         store.Store                                // Conversations of stored Responses API responses
+	batches           *batch.Manager                             // Files and batches, nil when disabled
+	stopBatches       context.CancelFunc                         // Stops running batches
+	batchesDone       chan struct{}                              // Closed once running batches have stopped
+	closeOnce         sync.Once                                  // Guards Close
 }
 
 // New creates a new Proxy plugin instance.
-// It validates the configuration and initializes all necessary components.
+// It applies the reload file, if any, resolves the secret references of the configuration,
+// validates it and initializes all necessary components. The reload file is then watched until
+// ctx is done.
 //
 // Parameters:
 //   - ctx: Context for the plugin initialization
@@ -49,51 +84,662 @@ type Proxy struct {
 //
 // Returns the configured plugin handler or an error if configuration is invalid.
 func New(ctx context.Context, next http.Handler, cfg *config.Config, name string) (http.Handler, error) {
-	if err := cfg.Validate(); err != nil {
+	base := cfg
+	var data []byte
+	if base.Reload.File != "" {
+		var err error
+		if data, err = os.ReadFile(base.Reload.File); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	cfg, secrets, files, err := loadConfig(base, data)
+	if err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	proxy := &Proxy{
+		next:           next,
+		base:           base,
+		name:           name,
+		authenticators: make(map[authenticatorKey]*ocisdk.Authenticator),
+		reloadHash:     reloadHash(data, files),
+		secretFiles:    files,
+		logger:         logging.New(cfg.Logging, name, os.Stdout),
+	}
+	proxy.logger.AddSecrets(secrets...)
+
 	// Initialize components
-	transformer := transform.New(cfg)
-	authenticator := ocisdk.New()
+	s, err := proxy.newSettings(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	proxy.live.Store(s)
+
+	if cfg.Metrics.Enabled {
+		proxy.metrics = loadMetrics()
+	}
+
+	if cfg.Usage.Enabled {
+		ledger, err := usage.NewFromConfig(cfg.Usage, proxy.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create usage ledger: %w", err)
+		}
+		proxy.ledger = ledger
+	}
+
+	if cfg.Cache.Enabled {
+		if cfg.Cache.TTL != "" {
+			ttl, err := time.ParseDuration(cfg.Cache.TTL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cache ttl: %w", err)
+			}
+			proxy.cacheTTL = ttl
+		}
+		proxy.cache = cache.NewLRU(cfg.Cache.MaxEntries, cfg.Cache.MaxBytes)
+	}
+
+	responses, err := store.New(cfg.Responses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create response store: %w", err)
+	}
+	proxy.responses = responses
+
+	if cfg.Tracing.Enabled {
+		var interval time.Duration
+		if cfg.Tracing.FlushInterval != "" {
+			d, err := time.ParseDuration(cfg.Tracing.FlushInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tracing flushInterval: %w", err)
+			}
+			interval = d
+		}
+		exporter := tracing.NewOTLPExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, cfg.Tracing.Headers, cfg.Tracing.BatchSize, interval, proxy.logger)
+		proxy.tracer = tracing.NewTracer(exporter)
+	}
+
+	if cfg.Batches.Enabled {
+		manager, err := batch.New(cfg.Batches, proxy.executeBatchRequest, proxy.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch manager: %w", err)
+		}
+		proxy.batches = manager
+
+		batchCtx, stop := context.WithCancel(ctx)
+		proxy.stopBatches = stop
+		proxy.batchesDone = make(chan struct{})
+		go func() {
+			defer close(proxy.batchesDone)
+			manager.Run(batchCtx)
+		}()
+	}
+
+	if base.Reload.File != "" {
+		interval, err := time.ParseDuration(base.Reload.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reload interval: %w", err)
+		}
+
+		watchCtx, stop := context.WithCancel(ctx)
+		proxy.stopWatching = stop
+		proxy.watchDone = make(chan struct{})
+		go proxy.watch(watchCtx, interval)
+	}
+
+	// Flush pending usage records and spans when Traefik discards this middleware instance
+	go func() {
+		<-ctx.Done()
+		if closeErr := proxy.Close(); closeErr != nil {
+			proxy.logger.Error("failed to close plugin", "error", closeErr)
+		}
+	}()
+
+	return proxy, nil
+}
 
-	return &Proxy{
-		next:          next,
-		config:        cfg,
-		name:          name,
-		transformer:   transformer,
-		authenticator: authenticator,
-	}, nil
+// Close stops running batches, flushes the usage ledger and exports pending spans. It is called
+// when the context passed to New is done, and may be called earlier by embedders that shut down
+// synchronously.
+func (p *Proxy) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		if p.stopWatching != nil {
+			p.stopWatching()
+			<-p.watchDone
+		}
+		// Batch requests in flight are metered, so batches stop before the ledger closes
+		if p.batches != nil {
+			p.stopBatches()
+			<-p.batchesDone
+		}
+		if p.ledger != nil {
+			if closeErr := p.ledger.Close(); closeErr != nil {
+				err = fmt.Errorf("failed to close usage ledger: %w", closeErr)
+			}
+		}
+		if p.tracer != nil {
+			if closeErr := p.tracer.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to export spans: %w", closeErr)
+			}
+		}
+	})
+	return err
 }
 
 // ServeHTTP implements the http.Handler interface and processes incoming requests.
 //
-// The plugin only processes POST requests to paths ending with "/chat/completions".
+// The plugin only processes POST requests to paths ending with "/chat/completions", the
+// embeddings requests of batches, and rerank, text completion, Anthropic Messages, Responses and Ollama requests (see
+// serveEmbeddings, serveRerank, serveCompletion, serveMessages, serveResponses and serveOllama),
+// and moderations requests when moderation is enabled (see serveModerations).
 // All other requests are passed through to the next handler unchanged.
 //
 // For matching requests, the plugin:
 // 1. Parses the OpenAI ChatCompletion request
 // 2. Transforms it to OCI GenAI format
-// 3. Adds OCI Instance Principal authentication headers
-// 4. Forwards the request to the next handler.
+// 3. Applies the guardrails and moderation checks to the prompt, if configured
+// 4. Reserves the estimated cost against the rate limit budgets, if enabled
+// 5. Adds OCI Instance Principal authentication headers
+// 6. Forwards the request to the next handler and translates the response back to the OpenAI format
+// 7. Accounts for the actual usage.
+//
+// When enabled, usage totals and Prometheus metrics are served on their configured paths, and the
+// Files and Batch APIs on paths ending with "/files" and "/batches" (see serveBatches).
 func (p *Proxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	log.Printf("[%s] Request received: %s %s", p.name, req.Method, req.URL.Path)
+	p.logger.Debug("request received", "method", req.Method, "path", req.URL.Path)
+
+	if usageCfg := p.current().config.Usage; p.ledger != nil && usageCfg.AdminToken != "" && req.URL.Path == usageCfg.AdminPath {
+		p.serveUsage(rw, req, usageCfg.AdminToken)
+		return
+	}
+
+	if p.metrics != nil && req.URL.Path == p.current().config.Metrics.Path {
+		p.metrics.registry.ServeHTTP(rw, req)
+		return
+	}
+
+	if p.batches != nil {
+		if resource, id, action := batchRoute(req.URL.Path); resource != "" {
+			p.serveBatches(rw, req, resource, id, action)
+			return
+		}
+	}
+
+	if isEmbeddingsRequest(req) && isBatchRequest(req) {
+		p.serveEmbeddings(rw, req)
+		return
+	}
+
+	if p.current().config.Moderation.Enabled && isModerationsRequest(req) {
+		p.serveModerations(rw, req)
+		return
+	}
+
+	if isRerankRequest(req) {
+		p.serveRerank(rw, req)
+		return
+	}
+
+	if isCompletionRequest(req) {
+		p.serveCompletion(rw, req)
+		return
+	}
+
+	if isMessagesRequest(req) {
+		p.serveMessages(rw, req)
+		return
+	}
+
+	if isResponsesRequest(req) {
+		p.serveResponses(rw, req)
+		return
+	}
+
+	if endpoint := ollamaEndpoint(req); endpoint != "" {
+		p.serveOllama(rw, req, endpoint)
+		return
+	}
 
 	// Only process POST requests to /chat/completions
 	if !p.shouldProcessRequest(req) {
-		log.Printf("[%s] Request filtered out - not processing", p.name)
+		p.logger.Debug("request filtered out, not processing", "path", req.URL.Path)
 		p.next.ServeHTTP(rw, req)
 		return
 	}
 
-	// Process the OpenAI request
-	if err := p.processOpenAIRequest(rw, req); err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "chat", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "chat")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		ctx:   req.Context(),
+		start: time.Now(),
+		route: endpointRoute(req.URL.Path),
+		// The client key must be captured before the Authorization header is replaced by the OCI signature
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	// Parse the OpenAI request
+	parseSpan := span.Child("parse", tracing.SpanKindInternal)
+	openAIReq, err := p.parseOpenAIRequest(req)
+	parseSpan.SetError(err)
+	parseSpan.End()
+	if err != nil {
+		p.logger.Warn("failed to parse OpenAI request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse OpenAI request")
+		return
+	}
+	p.logger.Debug("OpenAI request parsed", "model", openAIReq.Model, "messages", len(openAIReq.Messages), "stream", openAIReq.Stream)
+	ex.request = openAIReq
+	span.SetName("chat " + openAIReq.Model)
+
+	// Transform to Oracle Cloud format
+	transformSpan := span.Child("transform", tracing.SpanKindInternal)
+	transformer := p.policyTransformer(ex)
+	oracleReq := transformer.ToOracleCloudRequest(openAIReq)
+	transformSpan.End()
+
+	// Enforce the parameter policies
+	if !p.enforcePolicies(rw, ex, transformer) {
+		return
+	}
+
+	// Apply the input guardrails and moderation
+	texts, storeTexts := chatRequestTexts(&oracleReq)
+	if !p.guardInput(rw, ex, texts) || !p.moderateInput(rw, ex, texts) {
+		return
+	}
+	storeTexts()
+
+	// Enforce the context window of the model, on the masked request, and the rate limit budgets
+	if !p.fitContext(rw, ex, &oracleReq) || !p.reserve(rw, ex, oracleReq) {
+		return
+	}
+
+	// Process the Oracle Cloud request
+	if err := p.processOpenAIRequest(req, ex, oracleReq); err != nil {
+		ex.reservation.Release()
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
 		return
 	}
+	ex.apiFormat = oracleReq.ChatRequest.APIFormat
+	setRequestAttributes(span, oracleReq)
 
-	// Forward to next handler
-	p.next.ServeHTTP(rw, req)
+	// Serve repeated requests from the response cache
+	if p.cache != nil && p.isCacheable(req, oracleReq) {
+		key, keyErr := cache.Key(oracleReq)
+		if keyErr != nil {
+			p.logger.Warn("failed to compute cache key", "error", keyErr)
+		} else if cached, ok := p.cache.Get(key); ok && p.serveCached(rw, ex, cached) {
+			return
+		} else {
+			ex.cacheKey = key
+			rw.Header().Set(cacheStatusHeader, "MISS")
+			span.SetAttribute("ocigenai.cache.hit", false)
+		}
+	}
+
+	// Forward to next handler, translate the response and account for the reported usage
+	recorder := p.newRecorder(rw, ex)
+	p.forward(recorder, req, parent, span)
+
+	p.respond(rw, ex, recorder)
+	p.store(ex, recorder)
+	p.complete(ex, recorder)
+}
+
+// forward passes the signed OCI request to the next handler within an upstream span.
+func (p *Proxy) forward(recorder *responseRecorder, req *http.Request, parent tracing.SpanContext, span *tracing.Span) {
+	// Correlate the OCI request with the trace. Neither header is covered by the signature.
+	traceContext := parent
+	if span != nil {
+		traceContext = span.Context()
+	}
+	if traceContext.IsValid() {
+		req.Header.Set("opc-request-id", strings.ToUpper(traceContext.TraceIDString()))
+	}
+
+	upstreamSpan := span.Child("upstream", tracing.SpanKindClient)
+	if upstreamSpan != nil {
+		req.Header.Set("traceparent", upstreamSpan.Context().Traceparent())
+	}
+
+	p.next.ServeHTTP(recorder, req)
+
+	upstreamSpan.SetAttribute("http.response.status_code", recorder.Status())
+	if recorder.Status() >= http.StatusBadRequest {
+		upstreamSpan.SetStatus(tracing.StatusError, transform.ErrorCode(recorder.body.Bytes()))
+	}
+	upstreamSpan.End()
+}
+
+// reserve reserves the estimated cost of the OCI chat request of the exchange against the rate
+// limit budgets. When a budget is exhausted, the client is answered with a rate limit error and
+// false is returned.
+func (p *Proxy) reserve(rw http.ResponseWriter, ex *exchange, oracleReq types.OracleCloudRequest) bool {
+	if ex.settings.limiter == nil {
+		return true
+	}
+	return p.reserveTokens(rw, ex, ratelimit.EstimateTokens(oracleReq.ServingMode.ModelID, oracleReq.ChatRequest))
+}
+
+// reserveTokens reserves an estimate of the tokens of a request against the rate limit budgets of
+// its key and model, like reserve, for the requests other than chat.
+func (p *Proxy) reserveTokens(rw http.ResponseWriter, ex *exchange, tokens int) bool {
+	if ex.settings.limiter == nil {
+		return true
+	}
+
+	model := ex.request.Model
+	key := ratelimit.Key{ClientKey: ex.clientKey, Model: model}
+	reservation, status := ex.settings.limiter.Reserve(key, tokens)
+	status.SetHeaders(rw.Header())
+	if reservation == nil {
+		p.logger.Info("rate limit exceeded", "budget", status.Exceeded, "model", model, "key", ex.clientKey)
+		if p.metrics != nil {
+			p.metrics.rateLimited.Inc(metricsModel(ex), status.Exceeded)
+		}
+		ex.span.SetAttribute("http.response.status_code", http.StatusTooManyRequests)
+		ex.span.SetStatus(tracing.StatusError, "rate limit exceeded")
+		p.reject(rw, ex, http.StatusTooManyRequests, status.Exceeded, "rate_limit_exceeded", status.Message(model))
+		return false
+	}
+	ex.reservation = reservation
+	return true
+}
+
+// newRecorder creates the recorder capturing the upstream response of an exchange.
+func (p *Proxy) newRecorder(rw http.ResponseWriter, ex *exchange) *responseRecorder {
+	includeUsage := ex.request.StreamOptions != nil && ex.request.StreamOptions.IncludeUsage
+	return newResponseRecorder(rw, p.guardStreams(ex, func() streamTranslator {
+		ex.translateSpan = ex.span.Child("response-translate", tracing.SpanKindInternal)
+		return chatStream{ex.settings.transformer.NewStreamTranslator(ex.request.Model, includeUsage)}
+	}))
+}
+
+// cacheStatusHeader tells clients whether a cacheable response was served from the cache.
+const cacheStatusHeader = "X-Cache"
+
+// isCacheable reports whether the response to a request may be cached: the request must be
+// deterministic, or the client must have opted in.
+func (p *Proxy) isCacheable(req *http.Request, oracleReq types.OracleCloudRequest) bool {
+	if cache.IsDeterministic(oracleReq) {
+		return true
+	}
+	optIn, _ := strconv.ParseBool(req.Header.Get(p.current().config.Cache.OptInHeader))
+	return optIn
+}
+
+// serveCached replays a cached OCI response through the regular translation path.
+// Streaming clients receive the response re-chunked as server-sent events.
+// It returns false, without writing anything, if the cached entry cannot be decoded.
+func (p *Proxy) serveCached(rw http.ResponseWriter, ex *exchange, cached []byte) bool {
+	var oracleResp types.OracleCloudResponse
+	if err := json.Unmarshal(cached, &oracleResp); err != nil {
+		p.logger.Warn("failed to decode cached response", "error", err)
+		return false
+	}
+
+	p.logger.Debug("serving response from cache", "model", ex.request.Model, "stream", ex.request.Stream)
+	ex.cached = true
+	ex.span.SetAttribute("ocigenai.cache.hit", true)
+	rw.Header().Set(cacheStatusHeader, "HIT")
+
+	recorder := p.newRecorder(rw, ex)
+	if ex.request.Stream {
+		recorder.Header().Set("Content-Type", "text/event-stream")
+		recorder.WriteHeader(http.StatusOK)
+		_, _ = recorder.Write(cache.Replay(oracleResp))
+	} else {
+		recorder.Header().Set("Content-Type", "application/json")
+		recorder.WriteHeader(http.StatusOK)
+		_, _ = recorder.Write(cached)
+	}
+
+	p.respond(rw, ex, recorder)
+	p.complete(ex, recorder)
+	return true
+}
+
+// store caches a successful, complete upstream response of a cacheable exchange.
+func (p *Proxy) store(ex *exchange, recorder *responseRecorder) {
+	if ex.cacheKey == "" || recorder.Status() != http.StatusOK {
+		return
+	}
+
+	value := recorder.body.Bytes()
+	if recorder.stream != nil {
+		translator := recorder.stream
+		if guarded, ok := translator.(*guardedStream); ok {
+			translator = guarded.streamTranslator
+		}
+		stream, ok := translator.(chatStream)
+		if !ok {
+			return
+		}
+		response := stream.Response()
+		if response.ChatResponse.FinishReason == "" {
+			return
+		}
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			return
+		}
+		value = encoded
+	} else if ex.responseID == "" {
+		// The response could not be translated
+		return
+	}
+
+	stored := make([]byte, len(value))
+	copy(stored, value)
+	p.cache.Set(ex.cacheKey, stored, p.cacheTTL)
+}
+
+// genAISystem identifies the GenAI provider in the gen_ai.system span attribute.
+const genAISystem = "oci.genai"
+
+// setRequestAttributes records the effective request parameters using the GenAI semantic conventions.
+func setRequestAttributes(span *tracing.Span, oracleReq types.OracleCloudRequest) {
+	span.SetAttribute("gen_ai.request.model", oracleReq.ServingMode.ModelID)
+	span.SetAttribute("gen_ai.request.max_tokens", oracleReq.ChatRequest.MaxTokens)
+	span.SetAttribute("gen_ai.request.temperature", oracleReq.ChatRequest.Temperature)
+	span.SetAttribute("gen_ai.request.top_p", oracleReq.ChatRequest.TopP)
+	span.SetAttribute("gen_ai.request.frequency_penalty", oracleReq.ChatRequest.FrequencyPenalty)
+	span.SetAttribute("gen_ai.request.presence_penalty", oracleReq.ChatRequest.PresencePenalty)
+	if oracleReq.ChatRequest.TopK > 0 {
+		span.SetAttribute("gen_ai.request.top_k", oracleReq.ChatRequest.TopK)
+	}
+}
+
+// exchange carries the state of a single proxied request through the plugin.
+type exchange struct {
+	ctx           context.Context // Context of the client request
+	start         time.Time
+	route         string // Endpoint served, the metrics label of the request path
+	clientKey     string
+	dialect       string // Client API dialect; "" for OpenAI
+	request       types.ChatCompletionRequest
+	apiFormat     string
+	reservation   *ratelimit.Reservation
+	usage         *types.Usage
+	span          *tracing.Span
+	translateSpan *tracing.Span
+	responseID    string
+	finishReason  string
+	cacheKey      string
+	cached        bool
+	target        *target   // Compartment and identity the request is sent to
+	settings      *settings // Settings current when the request arrived
+}
+
+// Client API dialects other than OpenAI.
+const (
+	dialectAnthropic = "anthropic" // Anthropic Messages API
+	dialectOllama    = "ollama"    // Ollama API
+)
+
+// writeError answers the client with an error in the format of the API it called.
+func (ex *exchange) writeError(rw http.ResponseWriter, status int, errType, code, message string) {
+	if ex.dialect == dialectAnthropic {
+		writeJSON(rw, status, transform.ToAnthropicError(status, message))
+		return
+	}
+	if ex.dialect == dialectOllama {
+		writeJSON(rw, status, types.OllamaError{Error: message})
+		return
+	}
+	writeError(rw, status, errType, code, message)
+}
+
+// reject answers the client with an error raised by the plugin itself, before or instead of a
+// call to OCI, and counts it in the request metrics, which complete records for the other
+// requests.
+func (p *Proxy) reject(rw http.ResponseWriter, ex *exchange, status int, errType, code, message string) {
+	if p.metrics != nil {
+		p.metrics.observeRejection(ex, status)
+	}
+	ex.writeError(rw, status, errType, code, message)
+}
+
+// serveUsage serves the usage totals to requests bearing the admin token, and rejects the others
+// with a 401 error.
+func (p *Proxy) serveUsage(rw http.ResponseWriter, req *http.Request, token string) {
+	bearer := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+		p.logger.Warn("usage totals request rejected", "key", p.clientKey(req))
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(rw, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "A valid admin token is required to read the usage totals.")
+		return
+	}
+	p.ledger.ServeHTTP(rw, req)
+}
+
+// respond sends the OCI response recorded from the next handler to the client in the OpenAI format.
+// Streams have already been translated while they were received and only need to be terminated.
+func (p *Proxy) respond(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder) {
+	if recorder.stream != nil {
+		p.closeStream(ex, recorder)
+		return
+	}
+
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+
+	var oracleResp types.OracleCloudResponse
+	if !p.decodeResponse(rw, ex, recorder, translateSpan, &oracleResp) {
+		return
+	}
+
+	resp := ex.settings.transformer.ToOpenAIResponse(oracleResp, ex.request.Model)
+	ex.responseID = resp.ID
+	ex.finishReason = resp.Choices[0].FinishReason
+	writeJSON(rw, http.StatusOK, resp)
+}
+
+// closeStream terminates a translated event stream and records its outcome.
+func (p *Proxy) closeStream(ex *exchange, recorder *responseRecorder) {
+	err := recorder.finishStream()
+	if err != nil {
+		p.logger.Warn("failed to write streamed response", "error", err)
+	}
+	ex.responseID, ex.finishReason = recorder.stream.summary()
+	ex.translateSpan.SetError(err)
+	ex.translateSpan.End()
+}
+
+// decodeResponse copies the upstream headers to the client and decodes the recorded OCI response
+// into v. Error responses, and responses that cannot be decoded, are answered with an error in
+// the format of the exchange instead, and false is returned.
+func (p *Proxy) decodeResponse(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder, span *tracing.Span, v interface{}) bool {
+	copyHeaders(rw.Header(), recorder.Header())
+
+	if recorder.Status() >= http.StatusBadRequest {
+		upstream := transform.ToOpenAIError(recorder.Status(), recorder.body.Bytes()).Error
+		ex.writeError(rw, recorder.Status(), upstream.Type, upstream.Code, upstream.Message)
+		return false
+	}
+
+	if err := json.Unmarshal(recorder.body.Bytes(), v); err != nil {
+		p.logger.Error("failed to parse OCI response", "error", err)
+		span.SetError(err)
+		ex.writeError(rw, http.StatusBadGateway, "server_error", "", "Failed to parse OCI response")
+		return false
+	}
+	p.guardOutput(ex, v)
+	p.moderateOutput(ex, v)
+	return true
+}
+
+// complete settles the rate limit reservation, records usage, observes metrics and
+// annotates the trace once the response has been sent to the client.
+func (p *Proxy) complete(ex *exchange, recorder *responseRecorder) {
+	if !ex.cached {
+		if usage := parseUsage(recorder.Header().Get("Content-Type"), recorder.body.Bytes()); usage != nil {
+			ex.usage = usage
+		}
+	}
+	switch {
+	case ex.cached:
+		// Cached responses consume no model tokens
+		ex.reservation.Settle(0)
+	case ex.usage != nil:
+		ex.reservation.Settle(ex.usage.TotalTokens)
+	case recorder.Status() >= http.StatusBadRequest:
+		ex.reservation.Release()
+	}
+
+	region := ex.target.identity.authenticator.Region()
+
+	if p.ledger != nil {
+		rec := usage.Record{
+			Time:      time.Now().UTC(),
+			Key:       ex.clientKey,
+			User:      ex.request.User,
+			Model:     ex.request.Model,
+			Region:    region,
+			Status:    recorder.Status(),
+			LatencyMs: time.Since(ex.start).Milliseconds(),
+		}
+		if ex.usage != nil {
+			rec.PromptTokens = ex.usage.PromptTokens
+			rec.CompletionTokens = ex.usage.CompletionTokens
+			rec.TotalTokens = ex.usage.TotalTokens
+		}
+		p.ledger.Record(rec)
+	}
+
+	if p.metrics != nil {
+		p.metrics.observe(ex, region, recorder)
+	}
+
+	span := ex.span
+	span.SetAttribute("cloud.region", region)
+	span.SetAttribute("http.response.status_code", recorder.Status())
+	span.SetAttribute("gen_ai.response.model", ex.request.Model)
+	if ex.responseID != "" {
+		span.SetAttribute("gen_ai.response.id", ex.responseID)
+	}
+	if ex.finishReason != "" {
+		span.SetAttribute("gen_ai.response.finish_reasons", []string{ex.finishReason})
+	}
+	if ex.usage != nil {
+		span.SetAttribute("gen_ai.usage.input_tokens", ex.usage.PromptTokens)
+		span.SetAttribute("gen_ai.usage.output_tokens", ex.usage.CompletionTokens)
+	}
+	if recorder.Status() >= http.StatusBadRequest {
+		span.SetStatus(tracing.StatusError, transform.ErrorCode(recorder.body.Bytes()))
+	}
 }
 
 // shouldProcessRequest determines if a request should be processed by this plugin.
@@ -102,54 +748,143 @@ func (p *Proxy) shouldProcessRequest(req *http.Request) bool {
 	return shouldProcess
 }
 
-// processOpenAIRequest handles the transformation and authentication of OpenAI requests.
-func (p *Proxy) processOpenAIRequest(rw http.ResponseWriter, req *http.Request) error {
+// clientKey identifies the API key used by the client for rate limiting and usage metering.
+// The key is hashed so that credentials are never kept in memory as plain text.
+func (p *Proxy) clientKey(req *http.Request) string {
+	// Requests of a batch are sent on behalf of its owner
+	if owner, ok := req.Context().Value(batchOwnerKey{}).(string); ok {
+		return owner
+	}
+	value := req.Header.Get(p.current().config.RateLimit.KeyHeader)
+	if value == "" && isMessagesRequest(req) {
+		// Anthropic clients send their key in X-Api-Key rather than Authorization
+		value = req.Header.Get("X-Api-Key")
+	}
+	if value == "" {
+		return "anonymous"
+	}
+	return hashKey(value)
+}
+
+// hashKey returns the client key of the value of the key header.
+func hashKey(value string) string {
+	value = strings.TrimSpace(strings.TrimPrefix(value, "Bearer "))
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// parseOpenAIRequest reads the request body and decodes the OpenAI ChatCompletion request.
+func (p *Proxy) parseOpenAIRequest(req *http.Request) (types.ChatCompletionRequest, error) {
+	var openAIReq types.ChatCompletionRequest
+
 	// Read the request body
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read request body: %w", err)
+		return openAIReq, fmt.Errorf("failed to read request body: %w", err)
 	}
 
 	// Close the original body
 	if closeErr := req.Body.Close(); closeErr != nil {
-		return fmt.Errorf("failed to close request body: %w", closeErr)
+		return openAIReq, fmt.Errorf("failed to close request body: %w", closeErr)
 	}
 
-	// Parse OpenAI request
-	var openAIReq types.ChatCompletionRequest
-	if unmarshalErr := json.Unmarshal(body, &openAIReq); unmarshalErr != nil {
-		log.Printf("[%s] Failed to parse OpenAI request: %v", p.name, unmarshalErr)
-		http.Error(rw, "Failed to parse OpenAI request", http.StatusBadRequest)
-		return unmarshalErr // Return the actual error for proper error handling
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming OpenAI request", "body", p.logger.Body(body))
 	}
-	log.Printf("[%s] OpenAI request parsed successfully: model=%s, messages=%d", p.name, openAIReq.Model, len(openAIReq.Messages))
 
-	// Transform to Oracle Cloud format
-	oracleReq := p.transformer.ToOracleCloudRequest(openAIReq)
+	if err := json.Unmarshal(body, &openAIReq); err != nil {
+		return openAIReq, err
+	}
+
+	return openAIReq, nil
+}
 
-	// Marshal the Oracle Cloud request
+// processOpenAIRequest marshals the Oracle Cloud request transformed from an OpenAI request and
+// replaces the original body with it, signed for the exchange's target.
+func (p *Proxy) processOpenAIRequest(req *http.Request, ex *exchange, oracleReq types.OracleCloudRequest) error {
 	oracleBody, err := json.Marshal(oracleReq)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Oracle Cloud request: %w", err)
 	}
+	return p.prepareOCIRequest(req, ex, oracleBody, chatActionPath)
+}
 
+// prepareOCIRequest replaces the body of req with the OCI request body, routes it to the OCI
+// action at path of the exchange's target and signs it with the target's identity.
+func (p *Proxy) prepareOCIRequest(req *http.Request, ex *exchange, body []byte, path string) error {
 	// Replace request body with transformed content
-	req.Body = io.NopCloser(bytes.NewReader(oracleBody))
-	req.ContentLength = int64(len(oracleBody))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
 	req.Header.Set("Content-Type", "application/json")
 
+	// The response is translated, so it must not be compressed
+	req.Header.Del("Accept-Encoding")
+
+	// Route the request to the OCI action; the signature covers the host and path
+	routeToOCI(req, ex.target.identity.endpoint, path)
+
 	// Add OCI authentication headers
-	if err := p.authenticator.SignRequest(req); err != nil {
+	signSpan := ex.span.Child("sign", tracing.SpanKindInternal)
+	err := ex.target.identity.authenticator.SignRequest(req)
+	signSpan.SetError(err)
+	signSpan.End()
+	if err != nil {
 		return fmt.Errorf("failed to authenticate request: %w", err)
 	}
 
-	bodyBytes, _ := io.ReadAll(req.Body)
-	log.Printf("[%s] Outgoing OCI request: %s %s\nHeaders: %v\nBody: %s", p.name, req.Method, req.URL.String(), req.Header, string(bodyBytes))
-	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	if p.logger.Enabled(logging.LevelDebug) {
+		fields := []interface{}{"method", req.Method, "url", req.URL.String(), "headers", p.logger.Headers(req.Header)}
+		if p.logger.LogsBodies() {
+			fields = append(fields, "body", p.logger.Body(body))
+		}
+		p.logger.Debug("outgoing OCI request", fields...)
+	}
 
 	return nil
 }
 
+// OCI GenAI action paths.
+const (
+	chatActionPath            = "/20231130/actions/chat"
+	generateTextActionPath    = "/20231130/actions/generateText"
+	rerankTextActionPath      = "/20231130/actions/rerankText"
+	embedTextActionPath       = "/20231130/actions/embedText"
+	applyGuardrailsActionPath = "/20231130/actions/applyGuardrails"
+)
+
+// routeToOCI points the request at an action of an OCI GenAI inference endpoint. RequestURI is
+// rewritten as well, since Traefik builds the upstream path from it rather than from the URL.
+func routeToOCI(req *http.Request, endpoint *url.URL, path string) {
+	req.URL.Scheme = endpoint.Scheme
+	req.URL.Host = endpoint.Host
+	req.URL.Path = path
+	req.URL.RawPath = ""
+	req.URL.RawQuery = ""
+	req.Host = endpoint.Host
+	req.RequestURI = req.URL.RequestURI()
+}
+
+// writeError writes an error response in the OpenAI API format.
+func writeError(rw http.ResponseWriter, status int, errType, code, message string) {
+	writeJSON(rw, status, types.ErrorResponse{
+		Error: types.APIError{Message: message, Type: errType, Code: code},
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	rw.WriteHeader(status)
+	_, _ = rw.Write(body)
+}
+
 // CreateConfig creates the default plugin configuration.
 // This function is required by Traefik's plugin system.
 func CreateConfig() *config.Config {
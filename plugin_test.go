@@ -0,0 +1,564 @@
+package ocigenai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+const (
+	testRegion      = "us-chicago-1"
+	testTenancy     = "ocid1.tenancy.oc1..fake"
+	testCompartment = "ocid1.compartment.oc1..fake"
+	testRequest     = `{"model":"gpt-4","messages":[{"role":"user","content":"Hello, world!"}]}`
+)
+
+// testProxy is the plugin serving a client over HTTP, signing with a fake instance principal and
+// forwarding to a fake GenAI endpoint, like Traefik or the standalone binary would.
+type testProxy struct {
+	config *config.Config
+	genai  *ocitest.GenAI
+	server *httptest.Server
+	proxy  *Proxy
+
+	mu        sync.Mutex
+	forwarded []*http.Request
+}
+
+// newTestProxy starts the plugin in front of a fake GenAI endpoint. configure, in order, may
+// adjust the configuration before the plugin is created.
+func newTestProxy(t *testing.T, configure ...func(*config.Config)) *testProxy {
+	t.Helper()
+
+	env := ocitest.Start(t, testRegion, testTenancy)
+	tp := &testProxy{genai: ocitest.StartGenAI(t, env.Federation.VerifyRequest)}
+
+	tp.config = CreateConfig()
+	tp.config.CompartmentID = testCompartment
+	tp.config.Endpoint = tp.genai.URL()
+	for _, c := range configure {
+		if c != nil {
+			c(tp.config)
+		}
+	}
+
+	// Like Traefik, build the upstream path from RequestURI rather than from the request URL
+	upstream := &httputil.ReverseProxy{Director: func(req *http.Request) {
+		uri, err := url.ParseRequestURI(req.RequestURI)
+		if err != nil {
+			t.Errorf("invalid request URI %q: %v", req.RequestURI, err)
+			return
+		}
+		req.URL.Path, req.URL.RawPath, req.URL.RawQuery = uri.Path, uri.RawPath, uri.RawQuery
+	}, FlushInterval: -1}
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("failed to read forwarded body: %v", err)
+		}
+		forwarded := req.Clone(context.Background())
+		forwarded.Body = io.NopCloser(bytes.NewReader(body))
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		tp.mu.Lock()
+		tp.forwarded = append(tp.forwarded, forwarded)
+		tp.mu.Unlock()
+
+		if req.URL.Host == "" {
+			http.NotFound(rw, req)
+			return
+		}
+		upstream.ServeHTTP(rw, req)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler, err := New(ctx, next, tp.config, "test")
+	if err != nil {
+		t.Fatalf("failed to create plugin: %v", err)
+	}
+	tp.proxy = handler.(*Proxy)
+
+	tp.server = httptest.NewServer(handler)
+	t.Cleanup(tp.server.Close)
+	return tp
+}
+
+// post sends a request to the plugin with the key sk-test and returns the response and its body.
+// header holds name and value pairs set on the request; an empty value removes the header.
+func (tp *testProxy) post(t *testing.T, path, body string, header ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, tp.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-test")
+	for i := 0; i+1 < len(header); i += 2 {
+		if header[i+1] == "" {
+			req.Header.Del(header[i])
+		} else {
+			req.Header.Set(header[i], header[i+1])
+		}
+	}
+	return tp.do(t, req)
+}
+
+func (tp *testProxy) do(t *testing.T, req *http.Request) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+// lastForwarded returns the last request the plugin passed to the next handler.
+func (tp *testProxy) lastForwarded(t *testing.T) *http.Request {
+	t.Helper()
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if len(tp.forwarded) == 0 {
+		t.Fatal("expected a request to be forwarded")
+	}
+	return tp.forwarded[len(tp.forwarded)-1]
+}
+
+// lastOCIRequest returns the last chat request OCI received.
+func (tp *testProxy) lastOCIRequest(t *testing.T) types.OracleCloudRequest {
+	t.Helper()
+	requests := tp.genai.Requests(ocitest.ChatAction)
+	if len(requests) == 0 {
+		t.Fatal("expected a chat request to be sent to OCI")
+	}
+	var oracleReq types.OracleCloudRequest
+	if err := requests[len(requests)-1].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	return oracleReq
+}
+
+// readEvents decodes the data of the server-sent events of a streamed response.
+func readEvents(t *testing.T, body []byte) []string {
+	t.Helper()
+	var events []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "data: ") {
+			t.Fatalf("unexpected line in event stream: %q", line)
+		}
+		events = append(events, strings.TrimPrefix(line, "data: "))
+	}
+	return events
+}
+
+func TestProxy_ChatCompletion(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/chat/completions", testRequest)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	verifyTransformedRequest(t, forwarded, tp.config)
+	if forwarded.URL.Path != "/20231130/actions/chat" {
+		t.Errorf("expected request to be routed to the chat action, got %s", forwarded.URL.Path)
+	}
+	if forwarded.RequestURI != "/20231130/actions/chat" {
+		t.Errorf("expected the request URI to be rewritten to the chat action, got %s", forwarded.RequestURI)
+	}
+	if n := len(tp.genai.Requests(ocitest.ChatAction)); n != 1 {
+		t.Errorf("expected 1 verified chat request, got %d", n)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %s", ct)
+	}
+	if resp.Header.Get("opc-request-id") == "" {
+		t.Error("expected the opc-request-id header of OCI to be returned")
+	}
+
+	var completion types.ChatCompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if completion.Object != "chat.completion" || completion.Model != "gpt-4" || !strings.HasPrefix(completion.ID, "chatcmpl-") {
+		t.Errorf("unexpected completion: %s", body)
+	}
+	if len(completion.Choices) != 1 {
+		t.Fatalf("expected 1 choice, got %d", len(completion.Choices))
+	}
+	choice := completion.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "Echo: Hello, world!" {
+		t.Errorf("unexpected message: %+v", choice.Message)
+	}
+	if choice.FinishReason != "stop" {
+		t.Errorf("expected finish reason stop, got %s", choice.FinishReason)
+	}
+	expectedUsage := types.CompletionUsage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}
+	if completion.Usage == nil || *completion.Usage != expectedUsage {
+		t.Errorf("expected usage %+v, got %+v", expectedUsage, completion.Usage)
+	}
+}
+
+func TestProxy_ChatCompletionMaxTokens(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	_, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","max_tokens":1,"messages":[{"role":"user","content":"Hello"}]}`)
+
+	var completion types.ChatCompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if completion.Choices[0].Message.Content != "Echo: " || completion.Choices[0].FinishReason != "length" {
+		t.Errorf("expected a truncated completion, got %+v", completion.Choices[0])
+	}
+}
+
+func TestProxy_ChatCompletionStream(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"Hello, world!"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("expected an event stream, got %s", ct)
+	}
+
+	events := readEvents(t, body)
+	if len(events) == 0 || events[len(events)-1] != "[DONE]" {
+		t.Fatalf("expected the stream to end with [DONE], got %v", events)
+	}
+
+	var chunks []types.ChatCompletionChunk
+	for _, event := range events[:len(events)-1] {
+		var chunk types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(event), &chunk); err != nil {
+			t.Fatalf("failed to decode chunk %s: %v", event, err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	// Role, one content delta per word, finish reason and usage
+	if len(chunks) != 6 {
+		t.Fatalf("expected 6 chunks, got %d: %v", len(chunks), events)
+	}
+
+	var content strings.Builder
+	for i, chunk := range chunks {
+		if chunk.Object != "chat.completion.chunk" || chunk.ID != chunks[0].ID || chunk.Model != "gpt-4" {
+			t.Errorf("unexpected chunk %d: %s", i, events[i])
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+	if chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Errorf("expected the first chunk to carry the role, got %s", events[0])
+	}
+	if content.String() != "Echo: Hello, world!" {
+		t.Errorf("expected streamed content %q, got %q", "Echo: Hello, world!", content.String())
+	}
+	if reason := chunks[4].Choices[0].FinishReason; reason == nil || *reason != "stop" {
+		t.Errorf("expected finish reason stop, got %s", events[4])
+	}
+
+	usage := chunks[5]
+	expectedUsage := types.CompletionUsage{PromptTokens: 2, CompletionTokens: 3, TotalTokens: 5}
+	if len(usage.Choices) != 0 || usage.Usage == nil || *usage.Usage != expectedUsage {
+		t.Errorf("expected a usage chunk with %+v, got %s", expectedUsage, events[5])
+	}
+}
+
+func TestProxy_ChatCompletionStreamWithoutUsage(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	_, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"Hello"}]}`)
+
+	// OCI is always asked for usage, but it is only forwarded to clients that asked for it
+	for _, event := range readEvents(t, body) {
+		if strings.Contains(event, `"usage"`) {
+			t.Errorf("expected no usage chunk, got %s", event)
+		}
+	}
+
+	var oracleReq types.OracleCloudRequest
+	if err := tp.genai.Requests(ocitest.ChatAction)[0].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	if !oracleReq.ChatRequest.IsStream || !oracleReq.ChatRequest.StreamOptions.IsIncludeUsage {
+		t.Errorf("expected a streamed OCI request including usage, got %+v", oracleReq.ChatRequest)
+	}
+}
+
+func TestProxy_UpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  bool
+		reply   ocitest.Reply
+		status  int
+		errType string
+		code    string
+		message string
+	}{
+		{
+			name:    "invalid parameter",
+			reply:   ocitest.ErrorReply(http.StatusBadRequest, "InvalidParameter", "Invalid model."),
+			status:  http.StatusBadRequest,
+			errType: "invalid_request_error",
+			code:    "InvalidParameter",
+			message: "Invalid model.",
+		},
+		{
+			name:    "not found",
+			reply:   ocitest.ErrorReply(http.StatusNotFound, "NotAuthorizedOrNotFound", "Authorization failed or requested resource not found."),
+			status:  http.StatusNotFound,
+			errType: "not_found_error",
+			code:    "NotAuthorizedOrNotFound",
+			message: "Authorization failed or requested resource not found.",
+		},
+		{
+			name:    "server error while streaming",
+			stream:  true,
+			reply:   ocitest.ErrorReply(http.StatusInternalServerError, "InternalServerError", "Internal error."),
+			status:  http.StatusInternalServerError,
+			errType: "server_error",
+			code:    "InternalServerError",
+			message: "Internal error.",
+		},
+		{
+			name:    "error without a message",
+			reply:   ocitest.ErrorReply(http.StatusServiceUnavailable, "", ""),
+			status:  http.StatusServiceUnavailable,
+			errType: "server_error",
+			message: "Service Unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTestProxy(t, nil)
+			tp.genai.Enqueue(ocitest.ChatAction, tt.reply)
+
+			request := testRequest
+			if tt.stream {
+				request = `{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+			}
+			resp, body := tp.post(t, "/v1/chat/completions", request)
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %s", ct)
+			}
+			var errResp types.ErrorResponse
+			if err := json.Unmarshal(body, &errResp); err != nil {
+				t.Fatalf("failed to decode error: %v: %s", err, body)
+			}
+			expected := types.APIError{Message: tt.message, Type: tt.errType, Code: tt.code}
+			if errResp.Error != expected {
+				t.Errorf("expected error %+v, got %+v", expected, errResp.Error)
+			}
+		})
+	}
+}
+
+func TestProxy_Throttled(t *testing.T) {
+	tp := newTestProxy(t, nil)
+	tp.genai.Throttle(1)
+
+	resp, body := tp.post(t, "/v1/chat/completions", testRequest)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", resp.StatusCode)
+	}
+	var errResp types.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		t.Fatal(err)
+	}
+	if errResp.Error.Type != "rate_limit_error" || errResp.Error.Code != "TooManyRequests" {
+		t.Errorf("expected a rate limit error, got %+v", errResp.Error)
+	}
+
+	// The throttling has passed
+	resp, _ = tp.post(t, "/v1/chat/completions", testRequest)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 once throttling has passed, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxy_InvalidRequest(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), `"type":"invalid_request_error"`) {
+		t.Errorf("expected an invalid request error, got %s", body)
+	}
+	if len(tp.forwarded) != 0 || len(tp.genai.Requests(ocitest.ChatAction)) != 0 {
+		t.Error("expected the request not to be forwarded")
+	}
+}
+
+func TestProxy_PassesThroughOtherRequests(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	req, err := http.NewRequest(http.MethodGet, tp.server.URL+"/v1/models", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer sk-test")
+	resp, _ := tp.do(t, req)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the next handler's status 404, got %d", resp.StatusCode)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	if forwarded.URL.Path != "/v1/models" || forwarded.Header.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("expected the request to be passed through unchanged, got %s %v", forwarded.URL, forwarded.Header)
+	}
+	if n := len(tp.genai.Requests(ocitest.ChatAction)); n != 0 {
+		t.Errorf("expected no request to OCI, got %d", n)
+	}
+}
+
+func TestProxy_CachesDeterministicRequests(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Cache.Enabled = true
+	})
+	request := `{"model":"gpt-4","temperature":0,"messages":[{"role":"user","content":"Hello, world!"}]}`
+
+	first, firstBody := tp.post(t, "/v1/chat/completions", request)
+	second, secondBody := tp.post(t, "/v1/chat/completions", request)
+
+	if first.Header.Get("X-Cache") != "MISS" || second.Header.Get("X-Cache") != "HIT" {
+		t.Errorf("expected a miss then a hit, got %s then %s", first.Header.Get("X-Cache"), second.Header.Get("X-Cache"))
+	}
+	if n := len(tp.genai.Requests(ocitest.ChatAction)); n != 1 {
+		t.Errorf("expected 1 request to OCI, got %d", n)
+	}
+
+	var a, b types.ChatCompletionResponse
+	if err := json.Unmarshal(firstBody, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(secondBody, &b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Choices[0].Message.Content != b.Choices[0].Message.Content {
+		t.Errorf("expected the cached content %q, got %q", a.Choices[0].Message.Content, b.Choices[0].Message.Content)
+	}
+}
+
+func TestProxy_UsageTotals(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Usage.Enabled = true
+		cfg.Usage.AdminToken = "admin-secret"
+	})
+	if resp, body := tp.post(t, "/v1/chat/completions", testRequest); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	getTotals := func(token string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, tp.server.URL+"/ocigenai/usage", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return tp.do(t, req)
+	}
+
+	for _, token := range []string{"", "sk-client"} {
+		if resp, body := getTotals(token); resp.StatusCode != http.StatusUnauthorized || strings.Contains(string(body), `"requests"`) {
+			t.Errorf("expected the totals to require the admin token, got %d: %s", resp.StatusCode, body)
+		}
+	}
+
+	resp, body := getTotals("admin-secret")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"requests":1`) {
+		t.Errorf("expected the totals, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_MetricsLabels(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Metrics.Enabled = true
+	})
+	for _, model := range []string{"cohere.command-r-plus", "client-model-42"} {
+		resp, body := tp.post(t, "/proxy/v1/chat/completions", `{"model":"`+model+`","messages":[{"role":"user","content":"Hello"}]}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, tp.server.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, body := tp.do(t, req)
+	exposition := string(body)
+	for _, label := range []string{`model="cohere.command-r-plus"`, `model="other"`, `route="/chat/completions"`} {
+		if !strings.Contains(exposition, label) {
+			t.Errorf("expected the label %s, got:\n%s", label, exposition)
+		}
+	}
+	for _, value := range []string{"client-model-42", "/proxy/"} {
+		if strings.Contains(exposition, value) {
+			t.Errorf("expected %s not to be a label value, got:\n%s", value, exposition)
+		}
+	}
+}
+
+func TestProxy_MetricsRejections(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Metrics.Enabled = true
+		cfg.RateLimit.RequestsPerMinute = 1
+	})
+	rerank := `{"model":"cohere.rerank-v3.5","query":"penguins","documents":["emperor penguins"]}`
+	for _, body := range []string{`{"model":`, rerank, rerank} {
+		tp.post(t, "/v1/rerank", body)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, tp.server.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, body := tp.do(t, req)
+	exposition := string(body)
+	// Requests rejected by the plugin never reach OCI, and are counted with the status of their error
+	for _, status := range []string{"400", "429"} {
+		if !strings.Contains(exposition, `route="/rerank",status="`+status+`"`) {
+			t.Errorf("expected a rerank request counted with status %s, got:\n%s", status, exposition)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ocigenai
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+)
+
+// policyHeader lists the request parameters changed by the parameter policies, as name=value.
+const policyHeader = "X-Policy-Overrides"
+
+// keyedPolicy is a parameter policy with the hashed client keys it applies to.
+type keyedPolicy struct {
+	policy config.ParameterPolicy
+	keys   map[string]bool // Hashed client keys; nil applies to every key
+}
+
+// newKeyedPolicies returns the parameter policies of the configuration, in order.
+func newKeyedPolicies(policies []config.ParameterPolicy) []keyedPolicy {
+	keyed := make([]keyedPolicy, 0, len(policies))
+	for _, policy := range policies {
+		kp := keyedPolicy{policy: policy}
+		if len(policy.Keys) > 0 {
+			kp.keys = make(map[string]bool, len(policy.Keys))
+			for _, key := range policy.Keys {
+				kp.keys[hashKey(key)] = true
+			}
+		}
+		keyed = append(keyed, kp)
+	}
+	return keyed
+}
+
+// policyTransformer returns the transformer of the exchange's target, applying the parameter
+// policies of the exchange's client key.
+func (p *Proxy) policyTransformer(ex *exchange) *transform.Transformer {
+	var policies []config.ParameterPolicy
+	for _, kp := range ex.settings.policies {
+		if kp.keys == nil || kp.keys[ex.clientKey] {
+			policies = append(policies, kp.policy)
+		}
+	}
+	return ex.target.transformer.WithPolicies(policies)
+}
+
+// enforcePolicies reports the parameters changed by the policies of a transformer that converted
+// the exchange's request in the policy header. A request setting a forbidden parameter is
+// rejected and false is returned.
+func (p *Proxy) enforcePolicies(rw http.ResponseWriter, ex *exchange, transformer *transform.Transformer) bool {
+	overrides, err := transformer.PolicyOverrides()
+	if err != nil {
+		p.logger.Info("request rejected by parameter policy", "error", err, "key", ex.clientKey)
+		ex.span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "unsupported_parameter", err.Error())
+		return false
+	}
+	if len(overrides) > 0 {
+		applied := strings.Join(overrides, ", ")
+		p.logger.Debug("parameter policies applied", "overrides", applied, "key", ex.clientKey)
+		ex.span.SetAttribute("policy.overrides", applied)
+		rw.Header().Set(policyHeader, applied)
+	}
+	return true
+}
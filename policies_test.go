@@ -0,0 +1,106 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// testPolicies sets a token cap for interns, a fixed temperature for the default test key and
+// top_p forbidden for Llama models.
+func testPolicies(cfg *config.Config) {
+	cfg.Policies = []config.ParameterPolicy{
+		{Name: "interns", Keys: []string{"sk-intern"}, Max: map[string]float64{"max_tokens": 2048}},
+		{Name: "eval", Keys: []string{"sk-test"}, Min: map[string]float64{"temperature": 0}, Max: map[string]float64{"temperature": 0}},
+		{Name: "llama", Models: []string{"meta.*"}, Forbid: []string{"top_p"}},
+	}
+}
+
+func TestProxy_PoliciesClamp(t *testing.T) {
+	tp := newTestProxy(t, testPolicies)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","max_tokens":4096,"messages":[{"role":"user","content":"Hello"}]}`, "Authorization", "Bearer sk-intern")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if chat := tp.lastOCIRequest(t).ChatRequest; chat.MaxTokens != 2048 {
+		t.Errorf("expected max_tokens to be capped at 2048, got %d", chat.MaxTokens)
+	}
+	if header := resp.Header.Get(policyHeader); header != "max_tokens=2048" {
+		t.Errorf("expected the override to be reported, got %q", header)
+	}
+
+	// The temperature of the eval key is fixed
+	resp, body = tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","temperature":0.7,"messages":[{"role":"user","content":"Hello"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if chat := tp.lastOCIRequest(t).ChatRequest; chat.Temperature != 0 || chat.MaxTokens != 600 {
+		t.Errorf("expected temperature 0 and the default max_tokens, got %+v", chat)
+	}
+	if header := resp.Header.Get(policyHeader); header != "temperature=0" {
+		t.Errorf("expected the override to be reported, got %q", header)
+	}
+
+	// Explicit zeros within the bounds are kept and are not reported
+	resp, body = tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","temperature":0,"messages":[{"role":"user","content":"Hello"}]}`, "Authorization", "Bearer sk-intern")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if chat := tp.lastOCIRequest(t).ChatRequest; chat.Temperature != 0 {
+		t.Errorf("expected explicit temperature 0, got %f", chat.Temperature)
+	}
+	if header := resp.Header.Get(policyHeader); header != "" {
+		t.Errorf("expected no override, got %q", header)
+	}
+}
+
+func TestProxy_PoliciesForbid(t *testing.T) {
+	tp := newTestProxy(t, testPolicies)
+
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"meta.llama-3.1-70b-instruct","top_p":0.5,"messages":[{"role":"user","content":"Hello"}]}`, "Authorization", "Bearer sk-intern")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", resp.StatusCode, body)
+	}
+	var apiErr types.ErrorResponse
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if apiErr.Error.Code != "unsupported_parameter" || !strings.Contains(apiErr.Error.Message, "top_p") {
+		t.Errorf("expected an unsupported_parameter error naming top_p, got %s", body)
+	}
+	if requests := tp.genai.Requests(ocitest.ChatAction); len(requests) != 0 {
+		t.Errorf("expected no request to be sent to OCI, got %d", len(requests))
+	}
+
+	// Other dialects are held to the same policies
+	resp, body = tp.post(t, "/v1/messages", `{"model":"meta.llama-3.1-70b-instruct","max_tokens":64,"top_p":0.5,"messages":[{"role":"user","content":"Hello"}]}`, anthropicHeader...)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "top_p") {
+		t.Errorf("expected the Messages request to be rejected, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_PoliciesClampRateLimitEstimate(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.RateLimit.TokensPerMinute = 5000
+		cfg.Policies = []config.ParameterPolicy{
+			{Name: "interns", Keys: []string{"sk-intern"}, Max: map[string]float64{"max_tokens": 2048}},
+		}
+	})
+
+	// The reservation is estimated on the capped max_tokens, not on the one the client sent
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","max_tokens":100000,"messages":[{"role":"user","content":"Hello"}]}`, "Authorization", "Bearer sk-intern")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("x-ratelimit-remaining-tokens"))
+	if err != nil || remaining < 5000-2048-10 || remaining > 5000-2048 {
+		t.Errorf("expected about %d tokens to remain, got %q", 5000-2048, resp.Header.Get("x-ratelimit-remaining-tokens"))
+	}
+}
@@ -0,0 +1,295 @@
+package ocigenai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/guardrail"
+	"github.com/zalbiraw/ocigenai/internal/logging"
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk"
+	"github.com/zalbiraw/ocigenai/internal/ratelimit"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+)
+
+// settings are the parts of the plugin built from its reloadable configuration. They are
+// read-only once built; a reload replaces them as a whole, and each request keeps the settings
+// current when it arrived.
+type settings struct {
+	config      *config.Config         // Plugin configuration
+	transformer *transform.Transformer // Request transformer of the default compartment
+	targets     *targets               // Compartments and identities requests are sent to
+	limiter     *ratelimit.Limiter     // Token-aware rate limiter, nil when disabled
+	guardrails  *guardrail.Pipeline    // Content rules, nil when none are configured
+	policies    []keyedPolicy          // Parameter policies, in order
+	models      *models.Registry       // Model capabilities, nil when context window checks are disabled
+	known       *models.Registry       // Built-in and configured models, labelled by name in the metrics
+}
+
+// restartSections are the configuration sections of components created once, which a reload
+// does not change.
+var restartSections = []string{"Usage", "Metrics", "Tracing", "Logging", "Cache", "Responses", "Batches"}
+
+// current returns the current settings.
+func (p *Proxy) current() *settings {
+	return p.live.Load().(*settings)
+}
+
+// newSettings builds the settings of cfg. The rate limiter of previous, if any, is kept when the
+// rate limits are unchanged, so that reloads keep the budgets spent.
+func (p *Proxy) newSettings(cfg *config.Config, previous *settings) (*settings, error) {
+	transformer := transform.New(cfg)
+	targets, err := newTargets(cfg, transformer, p.authenticator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targets: %w", err)
+	}
+
+	s := &settings{
+		config:      cfg,
+		transformer: transformer,
+		targets:     targets,
+		policies:    newKeyedPolicies(cfg.Policies),
+	}
+
+	if cfg.RateLimit.Enabled() {
+		if previous != nil && previous.limiter != nil && reflect.DeepEqual(previous.config.RateLimit, cfg.RateLimit) {
+			s.limiter = previous.limiter
+		} else {
+			s.limiter = ratelimit.New(cfg.RateLimit)
+		}
+	}
+
+	s.known = models.NewRegistry(cfg.ContextWindow.Models)
+	if cfg.ContextWindow.Enabled {
+		s.models = s.known
+	}
+
+	if len(cfg.Guardrails.Rules) > 0 {
+		guardrails, err := guardrail.New(cfg.Guardrails)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create guardrails: %w", err)
+		}
+		s.guardrails = guardrails
+	}
+
+	return s, nil
+}
+
+// authenticatorKey identifies the credentials of an identity.
+type authenticatorKey struct {
+	auth, configFile, profile string
+	key                       [sha256.Size]byte // Hash of the private key and passphrase, which may be rotated
+}
+
+func newAuthenticatorKey(c config.Identity) authenticatorKey {
+	return authenticatorKey{
+		auth:       c.Auth,
+		configFile: c.ConfigFile,
+		profile:    c.Profile,
+		key:        sha256.Sum256([]byte(c.PrivateKey + "\x00" + c.Passphrase)),
+	}
+}
+
+// authenticator returns the authenticator of an identity; the zero identity is the instance
+// principal. Authenticators are created once per credentials and kept across reloads, with their
+// cached tokens, until their key material changes. The instance principal is only created once
+// an identity uses it, as it requires an OCI instance. Calls are serialized by reloadMu, or made
+// before the plugin serves requests.
+func (p *Proxy) authenticator(c config.Identity) (*ocisdk.Authenticator, error) {
+	if c.Auth == "" || c.Auth == ocisdk.AuthInstancePrincipal {
+		if p.instancePrincipal == nil {
+			instancePrincipal, err := ocisdk.New()
+			if err != nil {
+				return nil, fmt.Errorf("instance principal: %w", err)
+			}
+			p.instancePrincipal = instancePrincipal
+		}
+		return p.instancePrincipal, nil
+	}
+
+	key := newAuthenticatorKey(c)
+	if authenticator, ok := p.authenticators[key]; ok {
+		return authenticator, nil
+	}
+
+	provider, err := ocisdk.Provider(c.Auth, c.ConfigFile, c.Profile, ocisdk.Key{PrivateKey: c.PrivateKey, Passphrase: c.Passphrase})
+	if err != nil {
+		return nil, err
+	}
+	authenticator := ocisdk.NewWithProvider(provider)
+	p.authenticators[key] = authenticator
+	return authenticator, nil
+}
+
+// evictAuthenticators drops the authenticators, and their cached tokens, of the credentials cfg
+// no longer uses, such as rotated keys. Calls are serialized by reloadMu.
+func (p *Proxy) evictAuthenticators(cfg *config.Config) {
+	used := make(map[authenticatorKey]bool, len(cfg.Targets.Identities))
+	for _, identity := range cfg.Targets.Identities {
+		used[newAuthenticatorKey(identity)] = true
+	}
+	for key := range p.authenticators {
+		if !used[key] {
+			delete(p.authenticators, key)
+		}
+	}
+}
+
+// loadConfig returns the configuration of base, overridden by data, the content of its reload
+// file when it has one, with its secret references resolved, and the resolved secrets. The files
+// referenced by secrets are returned even when the configuration is rejected, once data could
+// be decoded. Errors do not contain the secrets.
+func loadConfig(base *config.Config, data []byte) (cfg *config.Config, secrets, files []string, err error) {
+	// A copy keeps the inline configuration and its references intact for the next reloads
+	encoded, err := json.Marshal(base)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cfg = &config.Config{}
+	if err := json.Unmarshal(encoded, cfg); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if base.Reload.File != "" {
+		set, err := config.Overlay(cfg, data, filepath.Ext(base.Reload.File) == ".json")
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to decode %s: %w", base.Reload.File, err)
+		}
+		for _, name := range set {
+			if name == "reload" {
+				return nil, nil, nil, fmt.Errorf("%s: reload cannot be set in the reload file", base.Reload.File)
+			}
+		}
+	}
+
+	files = config.SecretFiles(cfg)
+	secrets, err = config.ResolveSecrets(cfg, os.LookupEnv)
+	if err != nil {
+		return nil, nil, files, err
+	}
+	if err := cfg.Validate(); err != nil {
+		redactor := logging.NewRedactor(false)
+		redactor.AddSecrets(secrets...)
+		return nil, nil, files, errors.New(redactor.Redact(err.Error()))
+	}
+	return cfg, secrets, files, nil
+}
+
+// reloadHash hashes data, the content of the reload file, with the content of the secret files
+// the configuration references, so that rotated secret files are reloaded as well.
+func reloadHash(data []byte, files []string) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(data)
+	for _, path := range files {
+		h.Write([]byte("\x00" + path + "\x00"))
+		content, err := os.ReadFile(path)
+		if err != nil {
+			// A missing file hashes differently from an empty one
+			h.Write([]byte(err.Error()))
+			continue
+		}
+		h.Write(content)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// keepRestartSections copies the restart sections of running to cfg, and returns the names of
+// those cfg changed.
+func keepRestartSections(cfg, running *config.Config) []string {
+	var changed []string
+	next, current := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(running).Elem()
+	for _, name := range restartSections {
+		field := next.FieldByName(name)
+		if !reflect.DeepEqual(field.Interface(), current.FieldByName(name).Interface()) {
+			changed = append(changed, name)
+		}
+		field.Set(current.FieldByName(name))
+	}
+	return changed
+}
+
+// Reload reads the reload file and applies its configuration to the requests that follow. An
+// invalid configuration is rejected with an error and the current one keeps running. The file is
+// also reloaded when it, or a secret file it references, changes, at the configured interval.
+func (p *Proxy) Reload() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	data, err := os.ReadFile(p.base.Reload.File)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	return p.apply(data)
+}
+
+// apply applies data, the content of the reload file. Calls are serialized by reloadMu.
+func (p *Proxy) apply(data []byte) error {
+	cfg, secrets, files, err := loadConfig(p.base, data)
+	p.secretFiles = files
+	p.reloadHash = reloadHash(data, files)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	previous := p.current()
+	if changed := keepRestartSections(cfg, previous.config); len(changed) > 0 {
+		p.logger.Warn("configuration changes require a restart", "sections", changed)
+	}
+
+	s, err := p.newSettings(cfg, previous)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	p.live.Store(s)
+	p.evictAuthenticators(cfg)
+	p.logger.SetSecrets(secrets...)
+	p.logger.Info("configuration reloaded", "file", p.base.Reload.File)
+	return nil
+}
+
+// watch reloads the reload file whenever its content, or that of a secret file it references,
+// changes, until ctx is done. A file that fails to reload is reported once, and not retried until
+// it changes again.
+func (p *Proxy) watch(ctx context.Context, interval time.Duration) {
+	defer close(p.watchDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	unreadable := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(p.base.Reload.File)
+		if err != nil {
+			if !unreadable {
+				p.logger.Warn("failed to read config file", "file", p.base.Reload.File, "error", err)
+			}
+			unreadable = true
+			continue
+		}
+		unreadable = false
+
+		p.reloadMu.Lock()
+		if reloadHash(data, p.secretFiles) != p.reloadHash {
+			if err := p.apply(data); err != nil {
+				p.logger.Error("configuration reload rejected, keeping the running configuration", "file", p.base.Reload.File, "error", err)
+			}
+		}
+		p.reloadMu.Unlock()
+	}
+}
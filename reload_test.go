@@ -0,0 +1,256 @@
+package ocigenai
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk"
+)
+
+// reloadFrom reads the reload file at path, checked at interval.
+func reloadFrom(path, interval string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.Reload.File = path
+		cfg.Reload.Interval = interval
+	}
+}
+
+func writeReloadFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chatMaxTokens sends a chat completion request and returns the max_tokens received by OCI.
+func (tp *testProxy) chatMaxTokens(t *testing.T) int {
+	t.Helper()
+	resp, body := tp.post(t, "/v1/chat/completions", testRequest)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	return tp.lastOCIRequest(t).ChatRequest.MaxTokens
+}
+
+func TestProxy_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "maxTokens: 100\n")
+	tp := newTestProxy(t, reloadFrom(path, "1h"))
+
+	if maxTokens := tp.chatMaxTokens(t); maxTokens != 100 {
+		t.Fatalf("expected the reload file to apply at start, got max_tokens %d", maxTokens)
+	}
+
+	writeReloadFile(t, path, "maxTokens: 200\npolicies:\n  - name: cap\n    max:\n      max_tokens: 150\n")
+	if err := tp.proxy.Reload(); err != nil {
+		t.Fatalf("expected the reload to succeed, got %v", err)
+	}
+	if maxTokens := tp.chatMaxTokens(t); maxTokens != 150 {
+		t.Errorf("expected the reloaded policy to cap max_tokens at 150, got %d", maxTokens)
+	}
+
+	invalid := []struct {
+		name    string
+		content string
+	}{
+		{"out of range", "temperature: 5\n"},
+		{"unknown field", "maxToken: 300\n"},
+		{"malformed", "policies: [\n"},
+		{"reload section", "reload:\n  interval: 1s\n"},
+	}
+	for _, tt := range invalid {
+		writeReloadFile(t, path, tt.content)
+		if err := tp.proxy.Reload(); err == nil {
+			t.Errorf("%s: expected the reload to be rejected", tt.name)
+		}
+	}
+	if maxTokens := tp.chatMaxTokens(t); maxTokens != 150 {
+		t.Errorf("expected the last good configuration to keep running, got max_tokens %d", maxTokens)
+	}
+}
+
+func TestProxy_ReloadReplacesSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "policies:\n  - name: b\n")
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Reload.File = path
+		cfg.Reload.Interval = "1h"
+		cfg.Policies = []config.ParameterPolicy{
+			{Name: "a", Forbid: []string{"temperature"}, Max: map[string]float64{"max_tokens": 10}},
+		}
+	})
+
+	// The policy of the file leaves out forbid and max, so nothing of the inline policy remains
+	resp, body := tp.post(t, "/v1/chat/completions", `{"model":"gpt-4","temperature":0.5,"max_tokens":100,"messages":[{"role":"user","content":"Hello"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if maxTokens := tp.lastOCIRequest(t).ChatRequest.MaxTokens; maxTokens != 100 {
+		t.Errorf("expected max_tokens 100, got %d", maxTokens)
+	}
+	if policies := tp.proxy.current().config.Policies; !reflect.DeepEqual(policies, []config.ParameterPolicy{{Name: "b"}}) {
+		t.Errorf("expected only the policy of the file, got %+v", policies)
+	}
+}
+
+func TestProxy_ReloadRestartSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "")
+	tp := newTestProxy(t, reloadFrom(path, "1h"))
+
+	writeReloadFile(t, path, "maxTokens: 300\nlogging:\n  level: debug\n")
+	if err := tp.proxy.Reload(); err != nil {
+		t.Fatalf("expected the reload to succeed, got %v", err)
+	}
+	if maxTokens := tp.chatMaxTokens(t); maxTokens != 300 {
+		t.Errorf("expected max_tokens 300, got %d", maxTokens)
+	}
+	if level := tp.proxy.current().config.Logging.Level; level != "info" {
+		t.Errorf("expected logging to keep its level until restart, got %q", level)
+	}
+}
+
+func TestProxy_ReloadWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "maxTokens: 100\n")
+	tp := newTestProxy(t, reloadFrom(path, "10ms"))
+
+	writeReloadFile(t, path, "maxTokens: 250\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for tp.chatMaxTokens(t) != 250 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the changed file to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProxy_ReloadResolvesSecrets(t *testing.T) {
+	t.Setenv("EVAL_KEY", "sk-eval-1")
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "maxTokens: 100\npolicies:\n  - name: eval\n    keys: [\"env:EVAL_KEY\"]\n    max:\n      max_tokens: 50\n")
+	tp := newTestProxy(t, reloadFrom(path, "1h"))
+
+	chatMaxTokensAs := func(key string) int {
+		t.Helper()
+		resp, body := tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer "+key)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+		return tp.lastOCIRequest(t).ChatRequest.MaxTokens
+	}
+	if maxTokens := chatMaxTokensAs("sk-eval-1"); maxTokens != 50 {
+		t.Errorf("expected the policy of the referenced key, got max_tokens %d", maxTokens)
+	}
+
+	// The rotated key applies once reloaded
+	t.Setenv("EVAL_KEY", "sk-eval-2")
+	if err := tp.proxy.Reload(); err != nil {
+		t.Fatalf("expected the reload to succeed, got %v", err)
+	}
+	if maxTokens := chatMaxTokensAs("sk-eval-1"); maxTokens != 100 {
+		t.Errorf("expected the old key to lose the policy, got max_tokens %d", maxTokens)
+	}
+	if maxTokens := chatMaxTokensAs("sk-eval-2"); maxTokens != 50 {
+		t.Errorf("expected the rotated key to get the policy, got max_tokens %d", maxTokens)
+	}
+
+	if err := os.Unsetenv("EVAL_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.proxy.Reload(); err == nil || !strings.Contains(err.Error(), "EVAL_KEY") {
+		t.Errorf("expected an unset variable to be rejected, got %v", err)
+	}
+}
+
+func TestProxy_ReloadWatchesSecretFiles(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "eval-key")
+	writeReloadFile(t, keyPath, "sk-eval-1\n")
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "policies:\n  - name: eval\n    keys: [\"file://"+keyPath+"\"]\n    max:\n      max_tokens: 50\n")
+	tp := newTestProxy(t, reloadFrom(path, "10ms"))
+
+	chatMaxTokensAs := func(key string) int {
+		t.Helper()
+		resp, body := tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer "+key)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+		return tp.lastOCIRequest(t).ChatRequest.MaxTokens
+	}
+
+	// Rotating the referenced file reloads the configuration, although the reload file is unchanged
+	writeReloadFile(t, keyPath, "sk-eval-2\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for chatMaxTokensAs("sk-eval-2") != 50 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the rotated secret file to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if maxTokens := chatMaxTokensAs("sk-eval-1"); maxTokens == 50 {
+		t.Error("expected the old key to lose the policy")
+	}
+}
+
+func TestProxy_ReloadEvictsAuthenticators(t *testing.T) {
+	configPath := writeAPIKeyConfig(t)
+	keyPath := filepath.Join(filepath.Dir(configPath), "key.pem")
+	path := filepath.Join(t.TempDir(), "ocigenai.yaml")
+	writeReloadFile(t, path, "targets:\n  identities:\n    - name: team-b\n      auth: api_key\n      configFile: "+configPath+"\n      privateKey: file://"+keyPath+"\n")
+	tp := newTestProxy(t, reloadFrom(path, "1h"))
+
+	authenticators := func() []*ocisdk.Authenticator {
+		tp.proxy.reloadMu.Lock()
+		defer tp.proxy.reloadMu.Unlock()
+		var list []*ocisdk.Authenticator
+		for _, a := range tp.proxy.authenticators {
+			list = append(list, a)
+		}
+		return list
+	}
+	before := authenticators()
+	if len(before) != 1 {
+		t.Fatalf("expected an authenticator for team-b, got %d", len(before))
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeReloadFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+	if err := tp.proxy.Reload(); err != nil {
+		t.Fatalf("expected the reload to succeed, got %v", err)
+	}
+
+	after := authenticators()
+	if len(after) != 1 || after[0] == before[0] {
+		t.Errorf("expected the authenticator of the rotated key to replace the old one, got %d authenticators", len(after))
+	}
+}
+
+func TestNew_InvalidReloadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocigenai.json")
+	cfg := CreateConfig()
+	cfg.Reload.File = path
+
+	if _, err := New(context.Background(), http.NotFoundHandler(), cfg, "test"); err == nil {
+		t.Error("expected an error for a missing reload file")
+	}
+
+	writeReloadFile(t, path, `{"compartmentId": "ocid1.compartment.oc1..fake", "temperature": 5}`)
+	if _, err := New(context.Background(), http.NotFoundHandler(), cfg, "test"); err == nil {
+		t.Error("expected an error for an invalid reload file")
+	}
+}
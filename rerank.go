@@ -0,0 +1,128 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/models"
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// isRerankRequest reports whether a request is a rerank request, a POST to a path ending with "/rerank".
+func isRerankRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/rerank")
+}
+
+// serveRerank proxies a Cohere or Jina style rerank request to the OCI rerankText action and
+// translates the ranked documents back. Rerank requests are rate limited, traced, metered and
+// counted in the metrics under their model, but they are not cached. OCI reports no usage for
+// rerankText, so the tokens of the query and documents are metered as prompt tokens.
+func (p *Proxy) serveRerank(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "rerank", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "rerank")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	rerankReq, err := p.parseRerankRequest(req)
+	if err != nil {
+		p.logger.Warn("failed to parse rerank request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse rerank request")
+		return
+	}
+	p.logger.Debug("rerank request parsed", "model", rerankReq.Model, "documents", len(rerankReq.Documents))
+	ex.request.Model = rerankReq.Model
+	span.SetName("rerank " + rerankReq.Model)
+	span.SetAttribute("gen_ai.request.model", rerankReq.Model)
+
+	rerankTextReq, err := ex.target.transformer.ToRerankTextRequest(rerankReq)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	tokens := models.CountTokens(ex.request.Model, rerankTextReq.Input)
+	for _, document := range rerankTextReq.Documents {
+		tokens += models.CountTokens(ex.request.Model, document)
+	}
+	if !p.reserveTokens(rw, ex, tokens) {
+		return
+	}
+
+	body, err := json.Marshal(rerankTextReq)
+	if err == nil {
+		err = p.prepareOCIRequest(req, ex, body, rerankTextActionPath)
+	}
+	if err != nil {
+		ex.reservation.Release()
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return
+	}
+
+	// Rerank responses are never streamed, so the recorder buffers the whole response
+	recorder := newResponseRecorder(rw, nil)
+	p.forward(recorder, req, parent, span)
+
+	if p.respondRerank(rw, ex, recorder) {
+		ex.usage = &types.Usage{PromptTokens: tokens, TotalTokens: tokens}
+	}
+	p.complete(ex, recorder)
+}
+
+// parseRerankRequest reads the request body and decodes the rerank request.
+func (p *Proxy) parseRerankRequest(req *http.Request) (types.RerankRequest, error) {
+	var rerankReq types.RerankRequest
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return rerankReq, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return rerankReq, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming rerank request", "body", p.logger.Body(body))
+	}
+
+	err = json.Unmarshal(body, &rerankReq)
+	return rerankReq, err
+}
+
+// respondRerank sends the OCI rerankText response recorded from the next handler to the client,
+// and reports whether it succeeded.
+func (p *Proxy) respondRerank(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder) bool {
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+
+	var rerankTextResp types.RerankTextResponse
+	if !p.decodeResponse(rw, ex, recorder, translateSpan, &rerankTextResp) {
+		return false
+	}
+
+	resp := transform.ToRerankResponse(rerankTextResp, ex.request.Model)
+	ex.responseID = resp.ID
+	writeJSON(rw, http.StatusOK, resp)
+	return true
+}
@@ -0,0 +1,125 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/internal/usage"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+func TestProxy_Rerank(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/rerank", `{
+		"model": "cohere.rerank-v3.5",
+		"query": "penguins antarctica",
+		"documents": ["penguins live in antarctica", {"text": "polar bears live in the arctic"}, "emperor penguins"],
+		"top_n": 2,
+		"return_documents": true
+	}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	if forwarded.URL.Path != "/20231130/actions/rerankText" {
+		t.Errorf("expected request to be routed to the rerankText action, got %s", forwarded.URL.Path)
+	}
+
+	requests := tp.genai.Requests(ocitest.RerankTextAction)
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 verified rerankText request, got %d", len(requests))
+	}
+	var rerankTextReq types.RerankTextRequest
+	if err := requests[0].Decode(&rerankTextReq); err != nil {
+		t.Fatal(err)
+	}
+	if rerankTextReq.CompartmentID != testCompartment || rerankTextReq.Input != "penguins antarctica" || !rerankTextReq.IsEcho {
+		t.Errorf("unexpected rerankText request %+v", rerankTextReq)
+	}
+	if len(rerankTextReq.Documents) != 3 || rerankTextReq.Documents[1] != "polar bears live in the arctic" {
+		t.Errorf("expected the documents as strings, got %q", rerankTextReq.Documents)
+	}
+
+	var rerankResp types.RerankResponse
+	if err := json.Unmarshal(body, &rerankResp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rerankResp.Model != "cohere.rerank-v3.5" || rerankResp.ID == "" {
+		t.Errorf("unexpected response: %s", body)
+	}
+	expected := []types.RerankResult{
+		{Index: 0, RelevanceScore: 1, Document: &types.RerankDocument{Text: "penguins live in antarctica"}},
+		{Index: 2, RelevanceScore: 0.5, Document: &types.RerankDocument{Text: "emperor penguins"}},
+	}
+	if len(rerankResp.Results) != len(expected) {
+		t.Fatalf("expected %d results, got %s", len(expected), body)
+	}
+	for i, result := range rerankResp.Results {
+		if result.Index != expected[i].Index || result.RelevanceScore != expected[i].RelevanceScore ||
+			result.Document == nil || *result.Document != *expected[i].Document {
+			t.Errorf("expected result %d to be %+v, got %+v", i, expected[i], result)
+		}
+	}
+}
+
+func TestProxy_RerankInvalidRequest(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/rerank", `{"model":"cohere.rerank-v3.5","query":"penguins","documents":[42]}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "documents[0]") {
+		t.Errorf("expected the invalid document to be reported, got %s", body)
+	}
+	if len(tp.genai.Requests(ocitest.RerankTextAction)) != 0 {
+		t.Error("expected the request not to be forwarded")
+	}
+}
+
+func TestProxy_RerankUpstreamError(t *testing.T) {
+	tp := newTestProxy(t, nil)
+	tp.genai.Enqueue(ocitest.RerankTextAction, ocitest.ErrorReply(http.StatusNotFound, "NotAuthorizedOrNotFound", "Unknown model."))
+
+	resp, body := tp.post(t, "/v1/rerank", `{"model":"cohere.rerank-v3.5","query":"penguins","documents":["emperor penguins"]}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", resp.StatusCode)
+	}
+	var errResp types.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		t.Fatal(err)
+	}
+	if errResp.Error.Type != "not_found_error" || errResp.Error.Code != "NotAuthorizedOrNotFound" {
+		t.Errorf("expected a not found error, got %+v", errResp.Error)
+	}
+}
+
+func TestProxy_RerankRateLimit(t *testing.T) {
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.RateLimit.RequestsPerMinute = 1
+		cfg.Usage.Enabled = true
+	})
+
+	request := `{"model":"cohere.rerank-v3.5","query":"penguins","documents":["penguins live in antarctica"]}`
+	if resp, body := tp.post(t, "/v1/rerank", request); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := tp.post(t, "/v1/rerank", request); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the second request to be rate limited, got %d: %s", resp.StatusCode, body)
+	}
+	if requests := tp.genai.Requests(ocitest.RerankTextAction); len(requests) != 1 {
+		t.Errorf("expected only the first request to be sent to OCI, got %d", len(requests))
+	}
+
+	totals := tp.proxy.ledger.Totals(usage.Filter{Model: "cohere.rerank-v3.5"})
+	if len(totals) != 1 || totals[0].Requests != 1 || totals[0].PromptTokens == 0 {
+		t.Errorf("expected the rerank request to be metered, got %+v", totals)
+	}
+}
@@ -0,0 +1,282 @@
+package ocigenai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// responseRecorder captures the response of the next handler, which is in the OCI format,
+// and keeps a copy of the upstream body so the plugin can inspect it once the next handler
+// returns. Regular responses are buffered and translated by the plugin afterwards; successful
+// event streams are translated event by event and flushed to the client as they arrive.
+type responseRecorder struct {
+	client     http.ResponseWriter
+	header     http.Header
+	status     int
+	body       bytes.Buffer
+	newStream  func() streamTranslator
+	stream     streamTranslator
+	pending    []byte
+	firstWrite time.Time
+}
+
+// streamTranslator translates the events of an OCI stream to the events sent to the client.
+type streamTranslator interface {
+	// translate translates the data of a single OCI event to client events
+	translate(data []byte) ([]byte, error)
+
+	// finish returns the client events closing the stream
+	finish() ([]byte, error)
+
+	// summary returns the ID of the response and its finish reason, once reported
+	summary() (id, finishReason string)
+}
+
+// contentTyper is implemented by stream translators whose client stream is not a server-sent
+// event stream.
+type contentTyper interface {
+	contentType() string
+}
+
+// chatStream streams OpenAI chat completion chunks.
+type chatStream struct {
+	*transform.StreamTranslator
+}
+
+func (s chatStream) translate(data []byte) ([]byte, error) {
+	chunks, err := s.Event(data)
+	if err != nil {
+		return nil, err
+	}
+	var events []byte
+	for _, chunk := range chunks {
+		if events, err = appendDataEvent(events, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (s chatStream) finish() ([]byte, error) {
+	var events []byte
+	var err error
+	for _, chunk := range s.Finish() {
+		if events, err = appendDataEvent(events, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return append(events, "data: [DONE]\n\n"...), nil
+}
+
+func (s chatStream) summary() (string, string) {
+	return s.ID(), s.FinishReason()
+}
+
+// appendDataEvent appends v to events as a server-sent event holding its JSON encoding.
+func appendDataEvent(events []byte, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return events, err
+	}
+	events = append(events, "data: "...)
+	events = append(events, data...)
+	return append(events, "\n\n"...), nil
+}
+
+// newResponseRecorder creates a recorder writing to rw. newStream is called when the upstream
+// starts a successful event stream and returns the translator for it; when nil, event streams
+// are buffered like any other response.
+func newResponseRecorder(rw http.ResponseWriter, newStream func() streamTranslator) *responseRecorder {
+	return &responseRecorder{client: rw, header: make(http.Header), newStream: newStream}
+}
+
+// Header returns the upstream response headers.
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader records the status code. Event streams are started on the client right away.
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+	r.status = status
+
+	if status >= http.StatusBadRequest || r.newStream == nil || !isEventStream(r.header.Get("Content-Type")) {
+		return
+	}
+
+	r.stream = r.newStream()
+	copyHeaders(r.client.Header(), r.header)
+	if typed, ok := r.stream.(contentTyper); ok {
+		r.client.Header().Set("Content-Type", typed.contentType())
+	}
+	r.client.WriteHeader(status)
+}
+
+// Write records the body chunk and, for event streams, translates the complete events it holds.
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+
+	if r.stream == nil {
+		return len(b), nil
+	}
+
+	r.pending = append(r.pending, b...)
+	for {
+		i := bytes.IndexByte(r.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := r.pending[:i]
+		r.pending = r.pending[i+1:]
+		if err := r.translateLine(line); err != nil {
+			return len(b), err
+		}
+	}
+	r.Flush()
+	return len(b), nil
+}
+
+// translateLine translates a single line of an OCI event stream.
+func (r *responseRecorder) translateLine(line []byte) error {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return nil
+	}
+	data := bytes.TrimSpace(line[len("data:"):])
+	if len(data) == 0 || string(data) == "[DONE]" {
+		return nil
+	}
+
+	events, err := r.stream.translate(data)
+	if err != nil {
+		// Skip events that cannot be translated rather than breaking the stream
+		return nil
+	}
+	return r.writeEvents(events)
+}
+
+// writeEvents writes translated events to the client.
+func (r *responseRecorder) writeEvents(events []byte) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if r.firstWrite.IsZero() {
+		r.firstWrite = time.Now()
+	}
+	_, err := r.client.Write(events)
+	return err
+}
+
+// finishStream translates any trailing event and terminates the client stream.
+func (r *responseRecorder) finishStream() error {
+	if len(r.pending) > 0 {
+		line := r.pending
+		r.pending = nil
+		if err := r.translateLine(line); err != nil {
+			return err
+		}
+	}
+	events, err := r.stream.finish()
+	if err != nil {
+		return err
+	}
+	if err := r.writeEvents(events); err != nil {
+		return err
+	}
+	r.Flush()
+	return nil
+}
+
+// Flush forwards flushes so streamed responses reach the client immediately.
+func (r *responseRecorder) Flush() {
+	if r.stream == nil {
+		return
+	}
+	if flusher, ok := r.client.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Status returns the status code returned by the upstream.
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// copyHeaders copies upstream headers to the client, leaving out the headers describing
+// the upstream body, which is replaced by its translation.
+func copyHeaders(dst, src http.Header) {
+	for name, values := range src {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Content-Encoding":
+			continue
+		}
+		dst[name] = values
+	}
+}
+
+// isEventStream reports whether the content type is a server-sent event stream.
+func isEventStream(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/event-stream")
+}
+
+// usageEvent matches both a complete OCI chat response and a single OCI stream event.
+type usageEvent struct {
+	Usage        *types.Usage        `json:"usage"`
+	ChatResponse *types.ChatResponse `json:"chatResponse"`
+}
+
+func (e usageEvent) usage() *types.Usage {
+	if e.Usage != nil {
+		return e.Usage
+	}
+	if e.ChatResponse != nil {
+		return e.ChatResponse.Usage
+	}
+	return nil
+}
+
+// parseUsage extracts the token usage from an OCI chat response.
+// Both regular JSON responses and server-sent event streams are supported;
+// for streams the last event carrying usage wins.
+func parseUsage(contentType string, body []byte) *types.Usage {
+	if !isEventStream(contentType) {
+		var event usageEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil
+		}
+		return event.usage()
+	}
+
+	var usage *types.Usage
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event usageEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line[len("data:"):])), &event); err != nil {
+			continue
+		}
+		if u := event.usage(); u != nil {
+			usage = u
+		}
+	}
+	return usage
+}
@@ -0,0 +1,265 @@
+package ocigenai
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zalbiraw/ocigenai/internal/guardrail"
+	"github.com/zalbiraw/ocigenai/internal/store"
+	"github.com/zalbiraw/ocigenai/internal/tracing"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// isResponsesRequest reports whether a request is an OpenAI Responses request, a POST to a path
+// ending with "/responses".
+func isResponsesRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/responses")
+}
+
+// serveResponses proxies an OpenAI Responses request to the OCI chat action and translates the
+// response, or its events, back to the Responses format. The conversation of a previous response
+// is loaded from the store and prepended to the input; unless the client opts out, the new
+// response is stored in turn so that it can be continued. The request is rate limited, traced,
+// metered and counted in the metrics like a chat completion, but it is not cached.
+func (p *Proxy) serveResponses(rw http.ResponseWriter, req *http.Request) {
+	parent, _ := tracing.ParseTraceparent(req.Header.Get("traceparent"))
+	span := p.tracer.Start(parent, "chat", tracing.SpanKindServer)
+	defer span.End()
+	span.SetAttribute("gen_ai.operation.name", "chat")
+	span.SetAttribute("gen_ai.system", genAISystem)
+	span.SetAttribute("http.route", req.URL.Path)
+
+	ex := &exchange{
+		ctx:       req.Context(),
+		start:     time.Now(),
+		route:     endpointRoute(req.URL.Path),
+		clientKey: p.clientKey(req),
+		settings:  p.current(),
+		span:      span,
+	}
+
+	if !p.selectTarget(rw, req, ex) {
+		return
+	}
+
+	responsesReq, err := p.parseResponsesRequest(req)
+	if err != nil {
+		p.logger.Warn("failed to parse Responses request", "error", err)
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", "Failed to parse Responses request")
+		return
+	}
+
+	history, err := p.loadConversation(responsesReq.PreviousResponseID, ex.clientKey)
+	if err != nil {
+		span.SetError(err)
+		if errors.Is(err, store.ErrNotFound) {
+			p.reject(rw, ex, http.StatusNotFound, "invalid_request_error", "previous_response_not_found",
+				"Previous response with id '"+responsesReq.PreviousResponseID+"' not found.")
+			return
+		}
+		p.logger.Error("failed to load previous response", "error", err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", "Failed to load previous response")
+		return
+	}
+
+	transformer := p.policyTransformer(ex)
+	oracleReq, err := transformer.ResponsesToOracleCloudRequest(responsesReq, history)
+	if err != nil {
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	p.logger.Debug("Responses request parsed", "model", responsesReq.Model, "items", len(history)+len(responsesReq.Input), "stream", responsesReq.Stream)
+	ex.request = transform.ResponsesChatRequest(responsesReq, history)
+	ex.apiFormat = oracleReq.ChatRequest.APIFormat
+	span.SetName("chat " + responsesReq.Model)
+
+	if !p.enforcePolicies(rw, ex, transformer) {
+		return
+	}
+	texts, storeTexts := chatRequestTexts(&oracleReq)
+	if !p.guardInput(rw, ex, texts) || !p.moderateInput(rw, ex, texts) {
+		return
+	}
+	storeTexts()
+	if !p.fitContext(rw, ex, &oracleReq) || !p.reserve(rw, ex, oracleReq) {
+		return
+	}
+	setRequestAttributes(span, oracleReq)
+
+	body, err := json.Marshal(oracleReq)
+	if err == nil {
+		err = p.prepareOCIRequest(req, ex, body, chatActionPath)
+	}
+	if err != nil {
+		ex.reservation.Release()
+		span.SetError(err)
+		p.reject(rw, ex, http.StatusInternalServerError, "server_error", "", err.Error())
+		return
+	}
+
+	var stream *transform.ResponsesStream
+	recorder := newResponseRecorder(rw, p.guardStreams(ex, func() streamTranslator {
+		ex.translateSpan = ex.span.Child("response-translate", tracing.SpanKindInternal)
+		stream = ex.settings.transformer.NewResponsesStream(responsesReq)
+		return responsesStream{stream}
+	}))
+	p.forward(recorder, req, parent, span)
+
+	resp, ok := p.respondResponses(rw, ex, recorder, responsesReq, stream)
+	if ok && responsesReq.IsStored() {
+		p.saveConversation(resp.ID, ex.clientKey, history, p.maskItems(ex, responsesReq.Input), resp.Output)
+	}
+	p.complete(ex, recorder)
+}
+
+// parseResponsesRequest reads the request body and decodes the Responses request.
+func (p *Proxy) parseResponsesRequest(req *http.Request) (types.ResponsesRequest, error) {
+	var responsesReq types.ResponsesRequest
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return responsesReq, err
+	}
+	if closeErr := req.Body.Close(); closeErr != nil {
+		return responsesReq, closeErr
+	}
+
+	if p.logger.LogsBodies() {
+		p.logger.Debug("incoming Responses request", "body", p.logger.Body(body))
+	}
+
+	err = json.Unmarshal(body, &responsesReq)
+	return responsesReq, err
+}
+
+// storedConversation is the conversation of a response in the store, with the client key of the
+// request that created it.
+type storedConversation struct {
+	Key   string               `json:"key"`
+	Items []types.ResponseItem `json:"items"`
+}
+
+// loadConversation returns the items of the conversation stored for a previous response, none
+// when id is empty. Conversations stored for another client key are not found.
+func (p *Proxy) loadConversation(id, clientKey string) ([]types.ResponseItem, error) {
+	if id == "" {
+		return nil, nil
+	}
+	value, err := p.responses.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	var conversation storedConversation
+	if err := json.Unmarshal(value, &conversation); err != nil {
+		return nil, err
+	}
+	if conversation.Key != clientKey {
+		return nil, store.ErrNotFound
+	}
+	return conversation.Items, nil
+}
+
+// saveConversation stores the conversation of a response for a client key: the items it
+// continued, its input and its output. Failures are logged; the response has already been sent.
+func (p *Proxy) saveConversation(id, clientKey string, history, input, output []types.ResponseItem) {
+	items := make([]types.ResponseItem, 0, len(history)+len(input)+len(output))
+	items = append(append(append(items, history...), input...), output...)
+
+	value, err := json.Marshal(storedConversation{Key: clientKey, Items: items})
+	if err == nil {
+		err = p.responses.Put(id, value)
+	}
+	if err != nil {
+		p.logger.Error("failed to store response", "id", id, "error", err)
+	}
+}
+
+// maskItems returns a copy of Responses input items with the masks of the input guardrails
+// applied, as they were sent to OCI, so that unmasked text is not stored.
+func (p *Proxy) maskItems(ex *exchange, items []types.ResponseItem) []types.ResponseItem {
+	if ex.settings.guardrails == nil || !ex.settings.guardrails.Enabled(guardrail.StageInput) {
+		return items
+	}
+	mask := func(text string) string {
+		return ex.settings.guardrails.Apply(guardrail.StageInput, text).Text
+	}
+
+	masked := make([]types.ResponseItem, len(items))
+	for i, item := range items {
+		item.Content = append(types.ResponseContents(nil), item.Content...)
+		for k := range item.Content {
+			item.Content[k].Text = mask(item.Content[k].Text)
+		}
+		item.Arguments = mask(item.Arguments)
+		item.Output = mask(item.Output)
+		masked[i] = item
+	}
+	return masked
+}
+
+// respondResponses sends the OCI response recorded from the next handler to the client in the
+// Responses format, and returns it. Streams, translated by stream, only need to be terminated.
+// false is returned for error responses and for streams that ended without a finish reason.
+func (p *Proxy) respondResponses(rw http.ResponseWriter, ex *exchange, recorder *responseRecorder, responsesReq types.ResponsesRequest, stream *transform.ResponsesStream) (types.ResponsesResponse, bool) {
+	if recorder.stream != nil {
+		p.closeStream(ex, recorder)
+		return stream.Result(), stream.FinishReason() != ""
+	}
+
+	translateSpan := ex.span.Child("response-translate", tracing.SpanKindInternal)
+	defer translateSpan.End()
+
+	var oracleResp types.OracleCloudResponse
+	if !p.decodeResponse(rw, ex, recorder, translateSpan, &oracleResp) {
+		return types.ResponsesResponse{}, false
+	}
+
+	resp := ex.settings.transformer.ToResponsesResponse(oracleResp, responsesReq)
+	ex.responseID = resp.ID
+	ex.finishReason = transform.FinishReason(oracleResp.ChatResponse.FinishReason)
+	if len(oracleResp.ChatResponse.Choices) > 0 {
+		ex.finishReason = transform.FinishReason(oracleResp.ChatResponse.Choices[0].FinishReason)
+	}
+	writeJSON(rw, http.StatusOK, resp)
+	return resp, true
+}
+
+// responsesStream streams Responses API events.
+type responsesStream struct {
+	*transform.ResponsesStream
+}
+
+func (s responsesStream) translate(data []byte) ([]byte, error) {
+	events, err := s.Event(data)
+	if err != nil {
+		return nil, err
+	}
+	return appendResponsesEvents(nil, events)
+}
+
+func (s responsesStream) finish() ([]byte, error) {
+	return appendResponsesEvents(nil, s.Finish())
+}
+
+func (s responsesStream) summary() (string, string) {
+	return s.ID(), s.FinishReason()
+}
+
+// appendResponsesEvents appends Responses events to out as server-sent events named after their type.
+func appendResponsesEvents(out []byte, events []types.ResponsesStreamEvent) ([]byte, error) {
+	var err error
+	for _, event := range events {
+		out = append(out, "event: "+event.Type+"\n"...)
+		if out, err = appendDataEvent(out, event); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
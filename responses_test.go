@@ -0,0 +1,233 @@
+package ocigenai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+// readResponsesEvents decodes the events of a streamed Responses API response, checking that
+// each event is named after its type.
+func readResponsesEvents(t *testing.T, body []byte) []types.ResponsesStreamEvent {
+	t.Helper()
+	var events []types.ResponsesStreamEvent
+	var name string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var event types.ResponsesStreamEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("failed to decode event %s: %v", line, err)
+			}
+			if event.Type != name {
+				t.Errorf("expected event %s to carry its type, got %s", name, line)
+			}
+			events = append(events, event)
+		default:
+			t.Fatalf("unexpected line in event stream: %q", line)
+		}
+	}
+	return events
+}
+
+// decodeResponses decodes a Responses API response, failing the test on an error status.
+func decodeResponses(t *testing.T, resp *http.Response, body []byte) types.ResponsesResponse {
+	t.Helper()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	var response types.ResponsesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestProxy_Responses(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/responses", `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"instructions": "You are terse.",
+		"input": "Hello"
+	}`)
+	first := decodeResponses(t, resp, body)
+
+	forwarded := tp.lastForwarded(t)
+	verifyAuthHeaders(t, forwarded)
+	if forwarded.URL.Path != "/20231130/actions/chat" {
+		t.Errorf("expected request to be routed to the chat action, got %s", forwarded.URL.Path)
+	}
+
+	if first.Object != "response" || first.Status != "completed" || !first.Store || !strings.HasPrefix(first.ID, "resp_") {
+		t.Errorf("unexpected response: %s", body)
+	}
+	if len(first.Output) != 1 || first.Output[0].Type != "message" || first.Output[0].Content[0].Text != "Echo: Hello" {
+		t.Errorf("expected a single message item, got %s", body)
+	}
+	if first.Usage == nil || first.Usage.OutputTokens != 2 {
+		t.Errorf("expected usage, got %s", body)
+	}
+
+	// The second turn continues the stored conversation without resending it
+	resp, body = tp.post(t, "/v1/responses", `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"previous_response_id": "`+first.ID+`",
+		"input": [{"role": "user", "content": "Again"}]
+	}`)
+	second := decodeResponses(t, resp, body)
+	if second.PreviousResponseID == nil || *second.PreviousResponseID != first.ID {
+		t.Errorf("expected the previous response ID, got %s", body)
+	}
+
+	var oracleReq types.OracleCloudRequest
+	if err := tp.genai.Requests(ocitest.ChatAction)[1].Decode(&oracleReq); err != nil {
+		t.Fatal(err)
+	}
+	var conversation []string
+	for _, msg := range oracleReq.ChatRequest.Messages {
+		conversation = append(conversation, msg.Role+": "+msg.Content[0].Text)
+	}
+	expected := "USER: Hello|ASSISTANT: Echo: Hello|USER: Again"
+	if strings.Join(conversation, "|") != expected {
+		t.Errorf("expected conversation %q without the previous instructions, got %q", expected, strings.Join(conversation, "|"))
+	}
+}
+
+func TestProxy_ResponsesStream(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/responses", `{
+		"model": "meta.llama-3.3-70b-instruct",
+		"stream": true,
+		"input": "Hello there"
+	}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	// The response lifecycle, one message item with a delta per word, then its completion
+	events := readResponsesEvents(t, body)
+	expected := []string{"response.created", "response.in_progress", "response.output_item.added", "response.content_part.added",
+		"response.output_text.delta", "response.output_text.delta", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done", "response.completed"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %v, got %s", expected, body)
+	}
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], event.Type)
+		}
+	}
+	final := events[len(events)-1].Response
+	if final == nil || final.Status != "completed" || final.Usage == nil || final.Output[0].Content[0].Text != "Echo: Hello there" {
+		t.Fatalf("expected the completed response, got %+v", final)
+	}
+
+	// Streamed responses are stored too
+	resp, body = tp.post(t, "/v1/responses", `{"model":"meta.llama-3.3-70b-instruct","previous_response_id":"`+final.ID+`","input":"More"}`)
+	decodeResponses(t, resp, body)
+}
+
+func TestProxy_ResponsesErrors(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/responses", `{"model":"meta.llama-3.3-70b-instruct","store":false,"input":"Hi"}`)
+	unstored := decodeResponses(t, resp, body)
+	if unstored.Store {
+		t.Errorf("expected the response not to be stored, got %s", body)
+	}
+
+	tp.genai.Enqueue(ocitest.ChatAction, ocitest.ErrorReply(http.StatusNotFound, "NotAuthorizedOrNotFound", "Unknown model."))
+	tests := []struct {
+		name    string
+		request string
+		status  int
+		code    string
+	}{
+		{"invalid json", `{"model":`, http.StatusBadRequest, ""},
+		{"unknown role", `{"model":"gpt-4","input":[{"role":"tool","content":"Hi"}]}`, http.StatusBadRequest, ""},
+		{"unknown previous response", `{"model":"gpt-4","previous_response_id":"resp_unknown","input":"Hi"}`, http.StatusNotFound, "previous_response_not_found"},
+		{"unstored previous response", `{"model":"gpt-4","previous_response_id":"` + unstored.ID + `","input":"Hi"}`, http.StatusNotFound, "previous_response_not_found"},
+		{"upstream error", `{"model":"gpt-4","input":"Hi"}`, http.StatusNotFound, "NotAuthorizedOrNotFound"},
+	}
+
+	for _, tt := range tests {
+		resp, body := tp.post(t, "/v1/responses", tt.request)
+		var errResp types.ErrorResponse
+		if err := json.Unmarshal(body, &errResp); err != nil {
+			t.Fatalf("%s: failed to decode error: %v", tt.name, err)
+		}
+		if resp.StatusCode != tt.status || errResp.Error.Message == "" || errResp.Error.Code != tt.code {
+			t.Errorf("%s: expected a %d error with code %q, got %d: %s", tt.name, tt.status, tt.code, resp.StatusCode, body)
+		}
+	}
+	if len(tp.forwarded) != 2 {
+		t.Errorf("expected only the valid requests to be forwarded, got %d", len(tp.forwarded))
+	}
+}
+
+func TestProxy_ResponsesCohere(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/responses", `{"model":"cohere.command-r-plus","instructions":"You are terse.","input":"Hello"}`)
+	first := decodeResponses(t, resp, body)
+	chatReq := tp.lastOCIRequest(t).ChatRequest
+	if chatReq.APIFormat != "COHERE" || chatReq.PreambleOverride != "You are terse." || chatReq.Message != "Hello" {
+		t.Errorf("expected the instructions as the preamble, got %+v", chatReq)
+	}
+
+	resp, body = tp.post(t, "/v1/responses", `{"model":"cohere.command-r-plus","previous_response_id":"`+first.ID+`","input":"Again"}`)
+	decodeResponses(t, resp, body)
+	chatReq = tp.lastOCIRequest(t).ChatRequest
+	history, err := json.Marshal(chatReq.ChatHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"role":"USER","message":"Hello"},{"role":"CHATBOT","message":"Echo: Hello"}]`
+	if string(history) != expected || chatReq.Message != "Again" || chatReq.PreambleOverride != "" {
+		t.Errorf("expected the stored conversation as the chat history %s, got %s and %+v", expected, history, chatReq)
+	}
+}
+
+func TestProxy_ResponsesClientKey(t *testing.T) {
+	tp := newTestProxy(t, nil)
+
+	resp, body := tp.post(t, "/v1/responses", `{"model":"meta.llama-3.3-70b-instruct","input":"Hello"}`, "Authorization", "Bearer sk-alice")
+	first := decodeResponses(t, resp, body)
+
+	continued := `{"model":"meta.llama-3.3-70b-instruct","previous_response_id":"` + first.ID + `","input":"Again"}`
+	resp, body = tp.post(t, "/v1/responses", continued, "Authorization", "Bearer sk-bob")
+	if resp.StatusCode != http.StatusNotFound || !strings.Contains(string(body), "previous_response_not_found") {
+		t.Errorf("expected another key to be rejected with a 404, got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = tp.post(t, "/v1/responses", continued, "Authorization", "Bearer sk-alice")
+	decodeResponses(t, resp, body)
+}
+
+func TestProxy_ResponsesStoreMasked(t *testing.T) {
+	tp := newTestProxy(t, testGuardrails)
+
+	resp, body := tp.post(t, "/v1/responses", `{"model":"meta.llama-3.3-70b-instruct","input":"Email jane@example.com"}`)
+	response := decodeResponses(t, resp, body)
+
+	stored, err := tp.proxy.responses.Get(response.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stored), "jane@example.com") || !strings.Contains(string(stored), "Email [EMAIL]") {
+		t.Errorf("expected the masked input to be stored, got %s", stored)
+	}
+}
@@ -0,0 +1,167 @@
+package ocigenai
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk"
+	"github.com/zalbiraw/ocigenai/internal/transform"
+)
+
+// target is where a request is sent: an OCI compartment, and the identity signing requests to it.
+type target struct {
+	name          string                 // Name of the compartment; "" for the default compartment
+	compartmentID string                 // OCID of the compartment
+	transformer   *transform.Transformer // Builds OCI requests for the compartment
+	identity      *identity
+}
+
+// identity is an OCI identity signing requests, with its own credentials and cached tokens.
+type identity struct {
+	authenticator *ocisdk.Authenticator
+	endpoint      *url.URL // OCI GenAI inference endpoint
+}
+
+// newIdentity creates an identity signing with authenticator for endpoint, or for the inference
+// endpoint of the authenticator's region when endpoint is empty.
+func newIdentity(authenticator *ocisdk.Authenticator, endpoint string) (*identity, error) {
+	if endpoint == "" {
+		endpoint = ocisdk.InferenceEndpoint(authenticator.Region())
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	return &identity{authenticator: authenticator, endpoint: endpointURL}, nil
+}
+
+// targets selects the target of each request.
+type targets struct {
+	header   string               // Trusted header naming the compartment; "" when disabled
+	fallback *target              // Target of requests selecting no compartment
+	allowed  map[string]*target   // Allowed compartments, by name and ID
+	keys     map[string][]*target // Compartments of restricted client keys, by hashed key
+}
+
+// newTargets creates the targets of the configuration. transformer builds the requests of the
+// default compartment, signed by the identity named by the configuration or by the instance
+// principal; requests to the other compartments are signed by the same identity unless they name
+// one of their own. The authenticators of the identities are returned by authenticator, the zero
+// identity being the instance principal.
+func newTargets(cfg *config.Config, transformer *transform.Transformer, authenticator func(config.Identity) (*ocisdk.Authenticator, error)) (*targets, error) {
+	identities := make(map[string]*identity, len(cfg.Targets.Identities))
+	for _, c := range cfg.Targets.Identities {
+		a, err := authenticator(c)
+		if err != nil {
+			return nil, fmt.Errorf("identity %s: %w", c.Name, err)
+		}
+		endpoint := c.Endpoint
+		if endpoint == "" {
+			endpoint = cfg.Endpoint
+		}
+		id, err := newIdentity(a, endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("identity %s: %w", c.Name, err)
+		}
+		identities[c.Name] = id
+	}
+
+	fallback := &target{compartmentID: cfg.CompartmentID, transformer: transformer, identity: identities[cfg.Targets.Identity]}
+	if fallback.identity == nil {
+		a, err := authenticator(config.Identity{})
+		if err != nil {
+			return nil, err
+		}
+		if fallback.identity, err = newIdentity(a, cfg.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &targets{
+		header:   cfg.Targets.Header,
+		fallback: fallback,
+		allowed:  make(map[string]*target, 2*len(cfg.Targets.Compartments)),
+		keys:     make(map[string][]*target, len(cfg.Targets.Keys)),
+	}
+
+	for _, c := range cfg.Targets.Compartments {
+		compartmentCfg := *cfg
+		compartmentCfg.CompartmentID = c.ID
+		tg := &target{
+			name:          c.Name,
+			compartmentID: c.ID,
+			transformer:   transform.New(&compartmentCfg),
+			identity:      fallback.identity,
+		}
+		if c.Identity != "" {
+			tg.identity = identities[c.Identity]
+		}
+		t.allowed[c.Name] = tg
+		t.allowed[c.ID] = tg
+	}
+
+	for _, policy := range cfg.Targets.Keys {
+		key := hashKey(policy.Key)
+		for _, name := range policy.Compartments {
+			t.keys[key] = append(t.keys[key], t.allowed[name])
+		}
+	}
+
+	return t, nil
+}
+
+// selectFor returns the target of a request of clientKey. The compartment named by the header, if
+// any, must be allowed, and be one of the key's compartments when the key is restricted. Requests
+// naming no compartment go to the first compartment of their key, or to the default compartment.
+// The header is removed so that it does not reach OCI.
+func (t *targets) selectFor(req *http.Request, clientKey string) (*target, error) {
+	var name string
+	if t.header != "" {
+		name = strings.TrimSpace(req.Header.Get(t.header))
+		req.Header.Del(t.header)
+	}
+	restricted, isRestricted := t.keys[clientKey]
+
+	if name == "" {
+		if isRestricted {
+			return restricted[0], nil
+		}
+		return t.fallback, nil
+	}
+
+	selected, ok := t.allowed[name]
+	if !ok {
+		return nil, fmt.Errorf("compartment %q is not allowed", name)
+	}
+	if isRestricted {
+		for _, tg := range restricted {
+			if tg == selected {
+				return selected, nil
+			}
+		}
+		return nil, fmt.Errorf("compartment %q is not allowed for this key", name)
+	}
+	return selected, nil
+}
+
+// selectTarget selects the target of the exchange. Requests selecting a compartment they may not
+// use are answered with a permission error and false is returned.
+func (p *Proxy) selectTarget(rw http.ResponseWriter, req *http.Request, ex *exchange) bool {
+	selected, err := ex.settings.targets.selectFor(req, ex.clientKey)
+	if err != nil {
+		p.logger.Warn("compartment not allowed", "key", ex.clientKey, "error", err)
+		ex.span.SetError(err)
+		p.reject(rw, ex, http.StatusForbidden, "permission_error", "compartment_not_allowed", err.Error())
+		return false
+	}
+
+	ex.target = selected
+	if selected.name != "" {
+		p.logger.Debug("compartment selected", "compartment", selected.name)
+		ex.span.SetAttribute("oci.compartment.name", selected.name)
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package ocigenai
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalbiraw/ocigenai/internal/config"
+	"github.com/zalbiraw/ocigenai/internal/ocisdk/ocitest"
+	"github.com/zalbiraw/ocigenai/pkg/types"
+)
+
+const (
+	teamACompartment = "ocid1.compartment.oc1..team-a"
+	teamBCompartment = "ocid1.compartment.oc1..team-b"
+)
+
+// testTargets sets two selectable compartments and a key restricted to the first.
+func testTargets(cfg *config.Config) {
+	cfg.Targets = config.Targets{
+		Header: "X-OCI-Compartment",
+		Compartments: []config.Compartment{
+			{Name: "team-a", ID: teamACompartment},
+			{Name: "team-b", ID: teamBCompartment},
+		},
+		Keys: []config.KeyPolicy{{Key: "sk-team-a", Compartments: []string{"team-a"}}},
+	}
+}
+
+func TestProxy_TargetHeader(t *testing.T) {
+	tp := newTestProxy(t, testTargets)
+
+	if resp, body := tp.post(t, "/v1/chat/completions", testRequest); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if compartment := tp.lastOCIRequest(t).CompartmentID; compartment != testCompartment {
+		t.Errorf("expected the default compartment, got %s", compartment)
+	}
+
+	// Compartments are selected by name or ID
+	for _, selector := range []string{"team-b", teamBCompartment} {
+		if resp, body := tp.post(t, "/v1/chat/completions", testRequest, "X-OCI-Compartment", selector); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+		if compartment := tp.lastOCIRequest(t).CompartmentID; compartment != teamBCompartment {
+			t.Errorf("%s: expected the team-b compartment, got %s", selector, compartment)
+		}
+		if tp.lastForwarded(t).Header.Get("X-OCI-Compartment") != "" {
+			t.Error("expected the compartment header not to be forwarded")
+		}
+	}
+
+	resp, body := tp.post(t, "/v1/chat/completions", testRequest, "X-OCI-Compartment", "ocid1.compartment.oc1..other")
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "compartment_not_allowed") {
+		t.Errorf("expected status 403 for a compartment outside the allowlist, got %d: %s", resp.StatusCode, body)
+	}
+	if len(tp.genai.Requests(ocitest.ChatAction)) != 3 {
+		t.Error("expected the rejected request not to be forwarded")
+	}
+}
+
+func TestProxy_TargetKeyPolicy(t *testing.T) {
+	tp := newTestProxy(t, testTargets)
+
+	if resp, body := tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer sk-team-a"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if compartment := tp.lastOCIRequest(t).CompartmentID; compartment != teamACompartment {
+		t.Errorf("expected the compartment of the key, got %s", compartment)
+	}
+
+	if resp, body := tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer sk-team-a", "X-OCI-Compartment", "team-b"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403 for a compartment of another key, got %d: %s", resp.StatusCode, body)
+	}
+
+	// Errors are reported in the dialect of the endpoint
+	resp, body := tp.post(t, "/v1/messages", `{"model":"m","max_tokens":10,"messages":[{"role":"user","content":"Hi"}]}`, "Authorization", "Bearer sk-team-a", "X-OCI-Compartment", "team-b")
+	var anthropicErr types.AnthropicErrorResponse
+	if err := json.Unmarshal(body, &anthropicErr); err != nil || resp.StatusCode != http.StatusForbidden || anthropicErr.Error.Type != "permission_error" {
+		t.Errorf("expected an Anthropic permission error, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_TargetIdentity(t *testing.T) {
+	configPath := writeAPIKeyConfig(t)
+	tp := newTestProxy(t, testTargets, func(cfg *config.Config) {
+		cfg.Targets.Identities = []config.Identity{{Name: "team-b", Auth: "api_key", ConfigFile: configPath}}
+		cfg.Targets.Compartments[1].Identity = "team-b"
+	})
+
+	// The fake endpoint only accepts the instance principal, so only the signature is checked
+	tp.post(t, "/v1/chat/completions", testRequest, "X-OCI-Compartment", "team-b")
+	if keyID := tp.lastForwarded(t).Header.Get("Authorization"); !strings.Contains(keyID, `keyId="ocid1.tenancy.oc1..team-b/ocid1.user.oc1..team-b/`) {
+		t.Errorf("expected the request to be signed with the API key of team-b, got %s", keyID)
+	}
+
+	if resp, body := tp.post(t, "/v1/chat/completions", testRequest, "X-OCI-Compartment", "team-a"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the instance principal to sign for team-a, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestProxy_TargetSecretReferences(t *testing.T) {
+	configPath := writeAPIKeyConfig(t)
+	keyPath := filepath.Join(filepath.Dir(configPath), "key.pem")
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The key is only available from the environment
+	if err := os.Remove(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEAM_B_PRIVATE_KEY", string(keyPEM))
+	clientKeyPath := filepath.Join(t.TempDir(), "client-key")
+	if err := os.WriteFile(clientKeyPath, []byte("sk-team-b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tp := newTestProxy(t, testTargets, func(cfg *config.Config) {
+		cfg.Targets.Identities = []config.Identity{{Name: "team-b", Auth: "api_key", ConfigFile: configPath, PrivateKey: "env:TEAM_B_PRIVATE_KEY"}}
+		cfg.Targets.Compartments[1].Identity = "team-b"
+		cfg.Targets.Keys = append(cfg.Targets.Keys, config.KeyPolicy{Key: "file://" + clientKeyPath, Compartments: []string{"team-b"}})
+	})
+
+	// The key restricted to team-b by the referenced file goes there without selecting it
+	tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer sk-team-b")
+	if keyID := tp.lastForwarded(t).Header.Get("Authorization"); !strings.Contains(keyID, `keyId="ocid1.tenancy.oc1..team-b/ocid1.user.oc1..team-b/`) {
+		t.Errorf("expected the request to be signed with the API key of team-b, got %s", keyID)
+	}
+	if resp, body := tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer sk-team-b", "X-OCI-Compartment", "team-a"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the referenced key to be restricted, got %d: %s", resp.StatusCode, body)
+	}
+	if tp.config.Targets.Keys[1].Key != "file://"+clientKeyPath {
+		t.Errorf("expected the inline configuration to keep its reference, got %q", tp.config.Targets.Keys[1].Key)
+	}
+}
+
+// writeAPIKeyConfig writes an OCI CLI configuration file with an API key profile.
+func writeAPIKeyConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(dir, "config")
+	content := fmt.Sprintf("[DEFAULT]\nuser=ocid1.user.oc1..team-b\nfingerprint=aa:bb\ntenancy=ocid1.tenancy.oc1..team-b\nregion=us-chicago-1\nkey_file=%s\n", keyPath)
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestProxy_TargetsWithoutInstancePrincipal(t *testing.T) {
+	configPath := writeAPIKeyConfig(t)
+	tp := newTestProxy(t, func(cfg *config.Config) {
+		cfg.Targets.Identities = []config.Identity{{Name: "team-b", Auth: "api_key", ConfigFile: configPath}}
+		cfg.Targets.Identity = "team-b"
+	})
+
+	// Off OCI, the instance metadata service cannot be reached
+	imds := httptest.NewServer(http.NotFoundHandler())
+	imds.Close()
+	t.Setenv("OCI_METADATA_BASE_URL", imds.URL)
+
+	if _, err := New(context.Background(), http.NotFoundHandler(), tp.config, "test"); err != nil {
+		t.Fatalf("expected the plugin to start without the instance principal, got %v", err)
+	}
+
+	tp.post(t, "/v1/chat/completions", testRequest, "Authorization", "Bearer sk-any")
+	if keyID := tp.lastForwarded(t).Header.Get("Authorization"); !strings.Contains(keyID, `keyId="ocid1.tenancy.oc1..team-b/ocid1.user.oc1..team-b/`) {
+		t.Errorf("expected the default compartment to be signed with the API key of team-b, got %s", keyID)
+	}
+}